@@ -13,7 +13,26 @@ type BlockMetadata struct {
 	Status   *int
 	Checksum string
 
+	// PendingChecksum - checksum of the block re-encrypted by an interrupted
+	// key rotation, which may be stored instead (empty if none)
+	PendingChecksum string
+
+	// RemoteID - provider ID of the file storing the block, filled in
+	// on upload by disks which address files by ID (empty if unknown)
+	RemoteID string
+
 	Content *[]uint8
 
 	CompleteCallback func(uuid.UUID, *int)
 }
+
+// MatchesChecksum - check whether the stored contents match one of the recorded versions of the block
+//
+// params:
+//   - chksum string: checksum of the stored contents
+//
+// return type:
+//   - bool: true if the checksum matches the block or its pending version
+func (blockMetadata *BlockMetadata) MatchesChecksum(chksum string) bool {
+	return chksum == blockMetadata.Checksum || (blockMetadata.PendingChecksum != "" && chksum == blockMetadata.PendingChecksum)
+}
@@ -23,9 +23,15 @@ const (
 	DATABASE_DISK_NOT_FOUND   = "DB-003"
 	DATABASE_VOLUME_NOT_FOUND = "DB-004"
 	DATABASE_FILE_NOT_FOUND   = "DB-005"
+	DATABASE_SCRUB_NOT_FOUND  = "DB-006"
 
 	// Encryption errors
-	ENCRYPTION_JOB_FAILED = "ENC-001"
+	ENCRYPTION_JOB_FAILED         = "ENC-001"
+	CREDENTIALS_SEALING_FAILED    = "ENC-002"
+	ENCRYPTION_INVALID_PASSPHRASE = "ENC-003"
+
+	// Compression errors
+	COMPRESSION_JOB_FAILED = "CMP-001"
 
 	// Transport errors
 	TRANSPORT_VOLUME_NOT_FOUND     = "TRN-001"
@@ -33,18 +39,22 @@ const (
 	TRANSPORT_DISK_IS_BEING_USED   = "TRN-003"
 	TRANSPORT_VOLUME_IS_BEING_USED = "TRN-004"
 	TRANSPORT_VOLUME_NOT_READY     = "TRN-005"
+	TRANSPORT_VOLUME_LOCKED        = "TRN-006"
 	TRANSPORT_LOCK_FAILED          = "TRN-010"
 	TRANSPORT_FILE_TOO_BIG         = "TRN-011"
+	TRANSPORT_UPLOAD_NOT_FOUND     = "TRN-012"
 
 	// Remote filesystem errors
-	REMOTE_CANNOT_AUTHENTICATE = "RMT-000"
-	REMOTE_CLIENT_UNAVAILABLE  = "RMT-001"
-	REMOTE_BAD_REQUEST         = "RMT-002"
-	REMOTE_BAD_FILE            = "RMT-010"
-	REMOTE_CORRUPTED_FILES     = "RMT-011"
-	REMOTE_FAILED_JOB          = "RMT-020"
-	REMOTE_CORRUPTED_BLOCKS    = "RMT-021"
-	REMOTE_CANNOT_GET_STATS    = "RMT-030"
+	REMOTE_CANNOT_AUTHENTICATE  = "RMT-000"
+	REMOTE_CLIENT_UNAVAILABLE   = "RMT-001"
+	REMOTE_BAD_REQUEST          = "RMT-002"
+	REMOTE_TLS_HANDSHAKE_FAILED = "RMT-003"
+	REMOTE_HOST_KEY_MISMATCH    = "RMT-004"
+	REMOTE_BAD_FILE             = "RMT-010"
+	REMOTE_CORRUPTED_FILES      = "RMT-011"
+	REMOTE_FAILED_JOB           = "RMT-020"
+	REMOTE_CORRUPTED_BLOCKS     = "RMT-021"
+	REMOTE_CANNOT_GET_STATS     = "RMT-030"
 
 	// Authorization errors
 	AUTH_UNAUTHORIZED         = "AUTH-000"
@@ -72,6 +82,9 @@ const (
 	FS_BAD_FILE            = "FS-030"
 	FS_DIRECTORY_NOT_EMPTY = "FS-040"
 	FS_PATH_CYCLE          = "FS-050"
+	FS_RANGE_INVALID       = "FS-060"
+	FS_CHECKSUM_MISMATCH   = "FS-070"
+	FS_INTEGRITY_UNKNOWN   = "FS-071"
 
 	// Ownership errors
 	OWNER_MISMATCH = "OWN-001"
@@ -88,4 +101,5 @@ const (
 	// Operation errors
 	OPERATION_NOT_SUPPORTED = "OP-000"
 	OPERATION_FAILED        = "OP-001"
+	OPERATION_IN_PROGRESS   = "OP-002"
 )
@@ -6,7 +6,13 @@ const (
 	PROVIDER_TYPE_GDRIVE   int = 2
 	PROVIDER_TYPE_ONEDRIVE int = 3
 	PROVIDER_TYPE_FTP      int = 4
+	PROVIDER_TYPE_LOCAL    int = 5
+	PROVIDER_TYPE_S3       int = 6
+	PROVIDER_TYPE_WEBDAV   int = 7
+	PROVIDER_TYPE_DROPBOX  int = 8
+	PROVIDER_TYPE_AZURE    int = 9
 	PROVIDER_TYPE_RAID1    int = -1 // Virtual disk provider
+	PROVIDER_TYPE_ERASURE  int = -2 // Virtual disk provider
 )
 
 // File types
@@ -19,14 +25,37 @@ const (
 
 // Backup types
 const (
-	BACKUP_TYPE_RAID_1    int = 1
-	BACKUP_TYPE_NO_BACKUP int = 2
+	BACKUP_TYPE_RAID_1         int = 1
+	BACKUP_TYPE_NO_BACKUP      int = 2
+	BACKUP_TYPE_ERASURE_CODING int = 3
+)
+
+// Mirroring constants
+const (
+	RAID1_DEFAULT_REPLICAS int = 2
+)
+
+// Erasure coding constants
+const (
+	ERASURE_DEFAULT_DATA_SHARDS   int = 2
+	ERASURE_DEFAULT_PARITY_SHARDS int = 1
+	ERASURE_SHARD_HEADER_SIZE     int = 9 // Shard index (1 byte) and encoded block size (8 bytes)
 )
 
 // Encryption types
 const (
 	ENCRYPTION_TYPE_AES_256       int = 1
 	ENCRYPTION_TYPE_NO_ENCRYPTION int = 2
+	ENCRYPTION_TYPE_PASSPHRASE    int = 3 // data keys are wrapped with a key derived from the passphrase known only to the user
+)
+
+// Compression types
+//
+// Only gzip is supported, as it is available in the standard library. The algorithm
+// is recorded per block, so other algorithms can be added without migrating the blocks.
+const (
+	COMPRESSION_TYPE_GZIP           int = 1
+	COMPRESSION_TYPE_NO_COMPRESSION int = 2
 )
 
 // FilePartition types
@@ -44,6 +73,39 @@ const (
 	BLOCK_STATUS_FAILED      int = 3
 )
 
+// Event types
+const (
+	EVENT_TYPE_SPARE_PROMOTED         int = 1
+	EVENT_TYPE_SPARE_PROMOTION_FAILED int = 2
+	EVENT_TYPE_RESYNC_FAILED          int = 3
+)
+
+// Resync constants
+const (
+	RESYNC_MAX_ATTEMPTS int = 8
+)
+
+// Disk replacement constants
+const (
+	DISK_REPLACEMENT_WORKERS int = 8 // Blocks transferred to the new disk at once
+)
+
+// Scrub status
+const (
+	SCRUB_STATUS_RUNNING   int = 1
+	SCRUB_STATUS_COMPLETED int = 2
+)
+
+// Scrub constants
+const (
+	SCRUB_BATCH_SIZE int = 100
+)
+
+// Key rotation constants
+const (
+	KEY_ROTATION_BATCH_SIZE int = 100
+)
+
 // Pagination constants
 const (
 	PAGINATION_RECORDS_PER_PAGE int = 12
@@ -55,12 +117,67 @@ const (
 	ONEDRIVE_UPLOAD_LIMIT int = 192 * 320 * 1024 // 60 MiB
 )
 
+// Cloud disk constants
+const (
+	CLOUD_DISK_FOLDER_NAME string = "DCFS" // Root folder of the per-volume block folders
+)
+
+// Azure Blob constants
+const (
+	AZURE_SIZE_LIMIT  int = 4 * 1024 * 1024
+	AZURE_BLOCK_LIMIT int = 4 * 1024 * 1024
+)
+
+// Dropbox constants
+const (
+	DROPBOX_SIZE_LIMIT   int = 4 * 1024 * 1024
+	DROPBOX_UPLOAD_LIMIT int = 4 * 1024 * 1024 // Upload session chunks are multiples of 4 MiB
+)
+
+// FTP TLS modes
+const (
+	FTP_TLS_MODE_NONE     string = "none"
+	FTP_TLS_MODE_EXPLICIT string = "explicit" // AUTH TLS on the plain control connection
+	FTP_TLS_MODE_IMPLICIT string = "implicit" // TLS from the first byte, usually on port 990
+)
+
+// Connection pool constants
+const (
+	CONNECTION_POOL_MAX_CONNECTIONS int = 4 // Per disk, shared by block operations and readiness checks
+)
+
+// S3 constants
+const (
+	S3_MULTIPART_THRESHOLD int = 5 * 1024 * 1024
+	S3_MULTIPART_PART_SIZE int = 5 * 1024 * 1024 // Minimal part size accepted by S3
+)
+
 // Sizes constants
 const (
 	DEFAULT_VOLUME_BLOCK_SIZE int = 8 * 1024 * 1024
 	FRONT_RAM_CAPACITY        int = 8 * 1024 * 1024
 )
 
+// Download constants
+const (
+	DOWNLOAD_PREFETCH_BLOCKS       int    = 4 // Blocks of the streamed file downloaded ahead of the one being sent
+	DOWNLOAD_INTEGRITY_REPORT_NAME string = "DCFS-integrity-report.json"
+)
+
+// Integrity constants
+const (
+	MERKLE_LEAF_PREFIX   uint8  = 0x00 // Domain separation of the leaves and the inner nodes of the tree
+	MERKLE_NODE_PREFIX   uint8  = 0x01
+	MERKLE_SIBLING_LEFT  string = "left"
+	MERKLE_SIBLING_RIGHT string = "right"
+)
+
+// Sealing constants
+const (
+	MASTER_KEY_PATH string = "./master.key" // Key-encryption key sealing the disk credentials and the volume data keys saved in the db
+	SEALED_PREFIX   string = "sealed:v1:"
+)
+
 // Deletion constants
 const (
 	DELETION   bool = false
@@ -68,6 +185,25 @@ const (
 )
 
 const (
-	VOLUME_NONCE_SIZE      int = 12
-	VOLUME_CIPHER_TAG_SIZE int = 16
+	VOLUME_NONCE_SIZE        int = 12
+	VOLUME_CIPHER_TAG_SIZE   int = 16
+	VOLUME_DATA_KEY_SIZE     int = 32 // AES-256
+	VOLUME_BLOCK_HEADER_SIZE int = 4  // Magic (3 bytes) and format version (1 byte)
+
+	// Overhead of the encrypted block over its stored size
+	VOLUME_CIPHER_OVERHEAD int = VOLUME_BLOCK_HEADER_SIZE + VOLUME_NONCE_SIZE + VOLUME_CIPHER_TAG_SIZE
+
+	BLOCK_HEADER_MAGIC          string = "DCB"
+	BLOCK_FORMAT_VERSION_LEGACY uint8  = 0 // Ciphertext without the header, not bound to the identity of the block
+	BLOCK_FORMAT_VERSION_BOUND  uint8  = 1 // Ciphertext bound to the identity of the block
+
+	LEGACY_ENCRYPTION_KEY_PATH string = "./encryption.key" // Global key of the volumes created before the data keys were introduced
+)
+
+// Passphrase key derivation constants (Argon2id), changing them makes the existing passphrase volumes inaccessible
+const (
+	PASSPHRASE_SALT_SIZE   int    = 16
+	PASSPHRASE_KDF_TIME    uint32 = 3
+	PASSPHRASE_KDF_MEMORY  uint32 = 64 * 1024 // KiB
+	PASSPHRASE_KDF_THREADS uint8  = 4
 )
@@ -5,4 +5,12 @@ import "time"
 // Timeout constants
 const (
 	JWT_TOKEN_EXPIRATION_TIME = 24 * time.Hour
+	RESYNC_INTERVAL           = 1 * time.Minute
+	SCRUB_CHECK_INTERVAL      = 1 * time.Hour
+	SCRUB_INTERVAL            = 7 * 24 * time.Hour
+	SPARE_CHECK_INTERVAL      = 1 * time.Minute
+	SPARE_GRACE_PERIOD        = 15 * time.Minute
+	CONNECTION_IDLE_TIMEOUT   = 1 * time.Minute
+	UPLOAD_SESSION_INTERVAL   = 1 * time.Hour
+	UPLOAD_SESSION_EXPIRATION = 7 * 24 * time.Hour
 )
@@ -51,6 +51,13 @@ func ServeBackend() {
 		authorized.PUT("/volumes/manage/:VolumeUUID", UpdateVolume)
 		authorized.DELETE("/volumes/manage/:VolumeUUID", DeleteVolume)
 
+		authorized.POST("/volumes/manage/:VolumeUUID/scrub", StartVolumeScrub)
+		authorized.GET("/volumes/manage/:VolumeUUID/scrub", GetVolumeScrub)
+		authorized.POST("/volumes/manage/:VolumeUUID/keys/rotate", StartVolumeKeyRotation)
+		authorized.GET("/volumes/manage/:VolumeUUID/keys/rotate", GetVolumeKeyRotation)
+		authorized.POST("/volumes/manage/:VolumeUUID/unlock", UnlockVolume)
+		authorized.POST("/volumes/manage/:VolumeUUID/lock", LockVolume)
+
 		// Disk
 		authorized.POST("/disks/manage", CreateDisk)
 		authorized.GET("/disks/manage", GetDisks)
@@ -66,20 +73,29 @@ func ServeBackend() {
 		authorized.POST("/files/manage", CreateDirectory)
 
 		authorized.GET("/files/manage/:FileUUID", GetFile)
+		authorized.GET("/files/manage/:FileUUID/proof/:BlockUUID", GetBlockProof)
 		authorized.GET("/files/manage", GetFiles)
 
 		authorized.POST("/files/upload", InitFileUploadRequest)
+		authorized.GET("/files/upload/:FileUUID", GetFileUploadRequest)
 		authorized.POST("/files/download/:FileUUID", InitFileDownloadRequest)
 		authorized.POST("/files/upload/:FileUUID", CompleteFileUploadRequest)
+		authorized.POST("/files/stream", StreamFileUpload)
 
 		authorized.POST("/files/block/:BlockUUID", UploadBlock)
 		authorized.GET("/files/block/:BlockUUID", DownloadBlock)
 
+		authorized.GET("/files/content/:FileUUID", GetFileContent)
+		authorized.HEAD("/files/content/:FileUUID", GetFileContent)
+
 		authorized.PUT("/files/manage/:FileUUID", UpdateFile)
 		authorized.DELETE("/files/manage/:FileUUID", DeleteFile)
 
 		// Providers
 		authorized.GET("/providers", GetProviders)
+
+		// Events
+		authorized.GET("/events", GetEvents)
 	}
 
 	// Listen and serve on localhost:8080
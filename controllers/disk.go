@@ -11,6 +11,7 @@ import (
 	"dcfs/requests"
 	"dcfs/responses"
 	"dcfs/util/logger"
+	"dcfs/util/sealing"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
@@ -68,6 +69,13 @@ func CreateDisk(c *gin.Context) {
 		return
 	}
 
+	// Hot spares can only replace disks of volumes with backup
+	if requestBody.IsSpare && volume.VolumeSettings.Backup == constants.BACKUP_TYPE_NO_BACKUP {
+		logger.Logger.Error("api", "Received a request to create a hot spare disk in the volume without backup.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_VALIDATOR_ERROR, "IsSpare", "Hot spare disks are supported only in volumes with backup"))
+		return
+	}
+
 	// Create disk object
 	_disk := dbo.Disk{
 		AbstractDatabaseObject: dbo.AbstractDatabaseObject{
@@ -83,6 +91,7 @@ func CreateDisk(c *gin.Context) {
 		TotalSpace:      requestBody.TotalSpace,
 		IsVirtual:       false,
 		VirtualDiskUUID: uuid.Nil,
+		IsSpare:         requestBody.IsSpare,
 	}
 	disk := models.CreateDisk(models.CreateDiskMetadata{
 		Disk:   &_disk,
@@ -112,11 +121,21 @@ func CreateDisk(c *gin.Context) {
 
 		// check if the credentials are correct
 		if !disk.GetReadiness().IsReadyForce(c) {
-			logger.Logger.Error("api", "Credentials for a new disk: ", requestBody.Credentials.ToString(), " were incorrect.")
+			logger.Logger.Error("api", "Credentials for a new disk: ", _disk.UUID.String(), " were incorrect.")
 			volume.DeleteDisk(_disk.UUID)
-			c.JSON(500, responses.NewOperationFailureResponse(constants.VAL_CREDENTIALS_INVALID, "Provided credentials were incorrect"))
+			switch errCode := disk.GetReadiness().GetFailureCode(); errCode {
+			case constants.REMOTE_TLS_HANDSHAKE_FAILED:
+				c.JSON(500, responses.NewOperationFailureResponse(errCode, "TLS handshake with the remote server failed"))
+			case constants.REMOTE_HOST_KEY_MISMATCH:
+				c.JSON(500, responses.NewOperationFailureResponse(errCode, "Host key of the remote server does not match the trusted one"))
+			default:
+				c.JSON(500, responses.NewOperationFailureResponse(constants.VAL_CREDENTIALS_INVALID, "Provided credentials were incorrect"))
+			}
 			return
 		}
+
+		// Credentials may be completed while connecting, e.g. with the SFTP host key trusted on first use
+		_disk.Credentials = disk.GetCredentials().ToString()
 	}
 
 	// Find virtual disk uuid for new disk
@@ -130,6 +149,15 @@ func CreateDisk(c *gin.Context) {
 		_disk.VirtualDiskUUID = virtualDiskUUID
 	}
 
+	// Seal disk credentials before they are saved
+	_disk.Credentials, err = sealing.Seal(_disk.Credentials)
+	if err != nil {
+		logger.Logger.Error("api", "Could not seal the credentials of the disk: ", _disk.UUID.String(), ".")
+		volume.DeleteDisk(_disk.UUID)
+		c.JSON(500, responses.NewOperationFailureResponse(constants.CREDENTIALS_SEALING_FAILED, "Could not encrypt disk credentials"))
+		return
+	}
+
 	// Save disk to database
 	result := db.DB.DatabaseHandle.Create(&_disk)
 	if result.Error != nil {
@@ -238,6 +266,13 @@ func DiskOAuth(c *gin.Context) {
 
 	// Save disk credentials to database
 	_diskDBO := disk.GetDiskDBO(userUUID, _disk.ProviderUUID, _disk.VolumeUUID)
+	_diskDBO.Credentials, err = sealing.Seal(_diskDBO.Credentials)
+	if err != nil {
+		logger.Logger.Error("api", "Could not seal the credentials of the disk: ", _diskUUID, ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.CREDENTIALS_SEALING_FAILED, "Could not encrypt disk credentials"))
+		return
+	}
+
 	result := db.DB.DatabaseHandle.Save(&_diskDBO)
 	if result.Error != nil {
 		logger.Logger.Error("api", "Could not save the disk: ", _diskUUID, " in the db.")
@@ -405,13 +440,50 @@ func UpdateDisk(c *gin.Context) {
 
 		disk.CreateCredentials(cred)
 		if !disk.GetReadiness().IsReadyForce(c) {
-			logger.Logger.Error("api", "The provided credentials: ", body.Credentials.ToString(), " are invalid.")
-			c.JSON(405, responses.NewOperationFailureResponse(constants.VAL_CREDENTIALS_INVALID, "Provided credentials are invalid"))
+			logger.Logger.Error("api", "The provided credentials of the disk: ", _diskUUID, " are invalid.")
+			switch errCode := disk.GetReadiness().GetFailureCode(); errCode {
+			case constants.REMOTE_TLS_HANDSHAKE_FAILED:
+				c.JSON(405, responses.NewOperationFailureResponse(errCode, "TLS handshake with the remote server failed"))
+			case constants.REMOTE_HOST_KEY_MISMATCH:
+				c.JSON(405, responses.NewOperationFailureResponse(errCode, "Host key of the remote server does not match the trusted one"))
+			default:
+				c.JSON(405, responses.NewOperationFailureResponse(constants.VAL_CREDENTIALS_INVALID, "Provided credentials are invalid"))
+			}
 			return
 		}
 		logger.Logger.Debug("api", "Updated the credentials of the disk with the uuid: ", _diskUUID)
 	}
 
+	// Mark or unmark the disk as a hot spare
+	if body.IsSpare != nil && *body.IsSpare != disk.GetIsSpareFlag() {
+		if volume.VolumeSettings.Backup == constants.BACKUP_TYPE_NO_BACKUP {
+			logger.Logger.Error("api", "Attempted to mark the disk: ", _diskUUID, " of the volume without backup as a hot spare.")
+			c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_VALIDATOR_ERROR, "IsSpare", "Hot spare disks are supported only in volumes with backup"))
+			return
+		}
+
+		if disk.GetVirtualDiskUUID() != uuid.Nil {
+			logger.Logger.Error("api", "Attempted to mark the disk: ", _diskUUID, " assigned to a backup disk as a hot spare.")
+			c.JSON(405, responses.NewOperationFailureResponse(constants.OPERATION_NOT_SUPPORTED, "Disk which is part of backup disk cannot be a hot spare"))
+			return
+		}
+
+		disk.SetIsSpareFlag(*body.IsSpare)
+		logger.Logger.Debug("api", "Updated the hot spare flag of the disk with the uuid: ", _diskUUID, " to: ", strconv.FormatBool(*body.IsSpare), ".")
+
+		// Disk which is no longer a hot spare can be grouped with other unassigned disks
+		if !*body.IsSpare {
+			virtualDiskUUID, err := volume.GenerateVirtualDisk(disk)
+			if err != nil {
+				logger.Logger.Error("api", "Could not generate virtual disk UUID.")
+				c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Could not generate virtual disk UUID (for volumes with backup): "+err.Error()))
+				return
+			} else if virtualDiskUUID != uuid.Nil {
+				go volume.RefreshPartitioner()
+			}
+		}
+	}
+
 	// Change name of the disk
 	disk.SetName(body.Name)
 	logger.Logger.Debug("api", "Updated the name of the disk with the uuid: ", _diskUUID, " to: ", body.Name, ".")
@@ -438,6 +510,13 @@ func UpdateDisk(c *gin.Context) {
 
 	// Save disk details to database
 	diskDBO := disk.GetDiskDBO(userUUID, disk.GetProviderUUID(), volume.UUID)
+	diskDBO.Credentials, err = sealing.Seal(diskDBO.Credentials)
+	if err != nil {
+		logger.Logger.Error("api", "Could not seal the credentials of the disk: ", _diskUUID, ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.CREDENTIALS_SEALING_FAILED, "Could not encrypt disk credentials"))
+		return
+	}
+
 	result := db.DB.DatabaseHandle.Save(&diskDBO)
 	if result.Error != nil {
 		logger.Logger.Error("api", "Could not update the disk metadata in the db.")
@@ -568,7 +647,6 @@ func ReplaceBackupDisk(c *gin.Context) {
 	var _diskUUID string
 	var _disk dbo.Disk
 	var _newDisk *dbo.Disk
-	var _blocks []dbo.Block
 	var volume *models.Volume
 	var virtualDisk models.Disk
 	var disk models.Disk
@@ -643,41 +721,18 @@ func ReplaceBackupDisk(c *gin.Context) {
 		}
 	}
 	newDisk = volume.GetDisk(_newDisk.UUID)
-
-	// Retrieve blocks associated with the virtual disk
-	result := db.DB.DatabaseHandle.Where("disk_uuid = ?", virtualDisk.GetUUID()).Find(&_blocks)
-	if result.Error != nil {
-		logger.Logger.Error("api", "Could not find blocks associated with the disk with the uuid: ", virtualDisk.GetUUID().String(), " in the db.")
-		c.JSON(500, responses.NewNotFoundErrorResponse(constants.DATABASE_ERROR, "Could not find blocks associated with the virtual disk: "+result.Error.Error()))
+	if newDisk == nil {
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_DISK_NOT_FOUND, "New disk not found"))
 		return
 	}
 
 	// Trigger the replacement process
-	errCode = virtualDisk.(models.VirtualDisk).ReplaceDisk(disk, newDisk, _blocks)
+	errCode, err = volume.ReplaceVirtualDiskMember(disk, newDisk)
 	if errCode != constants.SUCCESS {
-		c.JSON(500, responses.NewOperationFailureResponse(errCode, "Replacement of the disk failed during the relocation process"))
+		c.JSON(500, responses.NewOperationFailureResponse(errCode, "Replacement of the disk failed: "+err.Error()))
 		return
 	}
 
-	// Remove virtual disk uuid from the current disk
-	result = db.DB.DatabaseHandle.Model(&_disk).Update("virtual_disk_uuid", uuid.Nil)
-	if result.Error != nil {
-		c.JSON(500, responses.NewOperationFailureResponse(errCode, "Replacement of the disk failed: "+result.Error.Error()))
-		return
-	}
-	disk.SetVirtualDiskUUID(uuid.Nil)
-
-	// Add virtual disk uuid to the new disk
-	result = db.DB.DatabaseHandle.Model(&_newDisk).Update("virtual_disk_uuid", virtualDisk.GetUUID())
-	if result.Error != nil {
-		c.JSON(500, responses.NewOperationFailureResponse(errCode, "Replacement of the disk failed: "+result.Error.Error()))
-		return
-	}
-	newDisk.SetVirtualDiskUUID(virtualDisk.GetUUID())
-
-	// Refresh volume partitioner after disk change
-	go volume.RefreshPartitioner()
-
 	c.JSON(200, responses.NewEmptySuccessResponse())
 }
 
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/middleware"
+	"dcfs/models"
+	"dcfs/requests"
+	"dcfs/responses"
+	"dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetEvents - handler for Get list of events request
+//
+// Get list of events (GET /events) - retrieving a paginated list of events
+// (such as automatic promotions of hot spare disks) concerning the user.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func GetEvents(c *gin.Context) {
+	var _events []dbo.Event
+	var eventsPagination []interface{}
+	var userUUID uuid.UUID
+	var page int
+	var err error
+
+	// Retrieve page from query
+	page = requests.GetPageFromQuery(c)
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve list of events of current user from the database
+	err = db.DB.DatabaseHandle.Where("user_uuid = ?", userUUID).Find(&_events).Error
+	if err != nil {
+		logger.Logger.Error("api", "Could not retrieve a list of events from the db.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
+
+	// Prepare pagination list
+	for _, _event := range _events {
+		eventsPagination = append(eventsPagination, _event)
+	}
+
+	pagination := models.Paginate(eventsPagination, page, constants.PAGINATION_RECORDS_PER_PAGE)
+	if pagination == nil {
+		logger.Logger.Error("api", "Could not paginate the provided list of events.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.INT_PAGINATION_ERROR, "Pagination process failed."))
+		return
+	}
+
+	// Return list of events
+	logger.Logger.Debug("api", "GetEvents endpoint successful exit.")
+	c.JSON(200, responses.NewPaginationResponse(responses.PaginationData{Pagination: pagination.Pagination, Data: pagination.Data}))
+}
@@ -10,12 +10,19 @@ import (
 	"dcfs/requests"
 	"dcfs/responses"
 	"dcfs/util/checksum"
+	"dcfs/util/httprange"
 	"dcfs/util/logger"
+	"dcfs/util/merkle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"mime"
+	"net/http"
+	"path"
 	"strconv"
-	"time"
+	"strings"
 )
 
 // CreateDirectory - handler for Create directory request
@@ -298,6 +305,13 @@ func InitFileUploadRequest(c *gin.Context) {
 		return
 	}
 
+	// Verify that the volume encrypted with a passphrase was unlocked
+	if volume.IsLocked() {
+		logger.Logger.Error("api", "Attempted to execute file operations on a locked volume: ", volumeUUID.String())
+		c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	}
+
 	// Verify that the rootUUID exists in the volume, and it's a directory
 	errCode := db.ValidateRootDirectory(rootUUID, volumeUUID)
 	if errCode != constants.SUCCESS {
@@ -316,59 +330,68 @@ func InitFileUploadRequest(c *gin.Context) {
 		return
 	}
 
+	// Save the checksum of the file to verify it on download
+	file.Checksum = strings.ToLower(requestBody.File.Checksum)
+
+	// Save the upload session, so the upload can be resumed later
+	err = models.SaveUploadSession(file, userUUID)
+	if err != nil {
+		logger.Logger.Error("api", "Could not save the upload session of the file: ", file.GetUUID().String(), " in the db.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
+
 	models.Transport.FileUploadQueue.EnqueueInstance(file.GetUUID(), file)
 	logger.Logger.Debug("api", "Prepared a request with ", strconv.FormatUint(uint64(len(file.Blocks)), 10), " blocks")
 
 	logger.Logger.Debug("api", "InitFileUploadRequest endpoint successful exit.")
 	c.JSON(200, responses.NewInitFileUploadRequestResponse(userUUID, file))
+}
 
-	// periodically check if the file was successfully transferred
-	go func(file models.File, ctx *gin.Context) {
-		// wait for the typical transport remove time
-		for models.Transport.FileUploadQueue.GetEnqueuedInstance(file.GetUUID()) != nil {
-			time.Sleep(models.Transport.WaitTime)
-		}
+// GetFileUploadRequest - handler for Get file upload request
+//
+// Get file upload request (GET /files/upload/{fileUUID}) - retrieving the list
+// of blocks which still have to be uploaded to resume the interrupted upload
+// of the file.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func GetFileUploadRequest(c *gin.Context) {
+	var fileUUID uuid.UUID
+	var userUUID uuid.UUID
+	var file *models.RegularFile
 
-		// if file was successfully transferred, terminate
-		if file.IsCompleted() {
-			return
-		}
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
 
-		logger.Logger.Error("api", "File: ", file.GetUUID().String(), " was not uploaded, will attempt to delete the parts that were transferred to remote.")
-
-		// delete outstanding file blocks from remote servers, if the file has not been transferred and is no longer in the queue
-		for _, block := range file.GetBlocks() {
-			if block.Status == constants.BLOCK_STATUS_TRANSFERRED {
-				logger.Logger.Error("api", "Block: ", block.UUID.String(), " of file: ", file.GetUUID().String(), " was transferred to remote (but file was not successfully uploaded), will be deleted.")
-				bm := apicalls.BlockMetadata{
-					Ctx:              c,
-					FileUUID:         file.GetUUID(),
-					UUID:             block.UUID,
-					Size:             0,
-					Status:           new(int),
-					Checksum:         "",
-					Content:          nil,
-					CompleteCallback: func(UUID uuid.UUID, status *int) {},
-				}
-
-				errWrapper := block.Disk.Remove(&bm)
-				if errWrapper != nil {
-					// repeat after 30 mins (onedrive case)
-					logger.Logger.Error("api", "Could not delete block: ", block.UUID.String(), " from remote. Will retry again in 30 minutes")
-
-					go func(bm apicalls.BlockMetadata) {
-						time.Sleep(30 * time.Minute)
-						err := block.Disk.Remove(&bm)
-						if err != nil {
-							logger.Logger.Error("api", "Could not delete block: ", block.UUID.String(), " from remote.")
-							// this error cannot be properly handled
-						}
-					}(bm)
-				}
-			}
-		}
+	// Retrieve and validate data from request
+	fileUUID, err := uuid.Parse(c.Param("FileUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong file uuid.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "FileUUID", "Provided fileUUID is not a valid UUID"))
+		return
+	}
+
+	// Retrieve file from transport (or resume its upload session)
+	file = models.Transport.GetUploadFile(fileUUID)
+	if file == nil {
+		logger.Logger.Error("api", "Could not find the upload of the file: ", fileUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_UPLOAD_NOT_FOUND, "Upload not found"))
+		return
+	}
 
-	}(file, c)
+	// Verify that the user is owner of the volume
+	if userUUID != file.GetVolume().UserUUID {
+		logger.Logger.Error("api", "The provided user: ", userUUID.String(), " is not the owner of the volume: ", file.GetVolume().UUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Upload not found"))
+		return
+	}
+
+	logger.Logger.Debug("api", "GetFileUploadRequest endpoint successful exit.")
+	c.JSON(200, responses.NewFileUploadStatusResponse(userUUID, file))
 }
 
 // UploadBlock - handler for Upload block details request
@@ -424,6 +447,14 @@ func UploadBlock(c *gin.Context) {
 		return
 	}
 
+	// Retrieve file from transport (or resume its upload session)
+	file = models.Transport.GetUploadFile(fileUUID)
+	if file == nil || file.Blocks[blockUUID] == nil {
+		logger.Logger.Error("api", "The block: ", blockUUID.String(), " belongs to an unknown file.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.FS_BLOCK_MISMATCH, "Block belongs to an unknown file"))
+		return
+	}
+
 	// Lock file for upload
 	err = models.Transport.FileUploadQueue.MarkAsUsed(fileUUID)
 	if err != nil {
@@ -432,13 +463,15 @@ func UploadBlock(c *gin.Context) {
 		return
 	}
 
-	// Retrieve file from transport and set block status to uploading
-	file = models.Transport.FileUploadQueue.GetEnqueuedInstance(fileUUID).(*models.RegularFile)
-	if file == nil {
-		logger.Logger.Error("api", "The block: ", blockUUID.String(), " belongs to an unknown file.")
-		c.JSON(500, responses.NewOperationFailureResponse(constants.FS_BLOCK_MISMATCH, "Block belongs to an unknown file"))
-		return
-	}
+	// Unlock the file if the block fails before its transfer is handed off to the disk
+	var handedOff bool = false
+	defer func() {
+		if !handedOff {
+			models.Transport.FileUploadQueue.MarkAsCompleted(fileUUID)
+		}
+	}()
+
+	// Set block status to uploading
 	file.Blocks[blockUUID].Status = constants.BLOCK_STATUS_IN_PROGRESS
 	logger.Logger.Debug("api", "Changed the status of the block: ", blockUUID.String(), " to BLOCK_STATUS_IN_PROGRESS.")
 
@@ -452,8 +485,19 @@ func UploadBlock(c *gin.Context) {
 		return
 	}
 
-	// Save real size of the block
+	// Save real size of the block and the hash of its plain contents
 	file.Blocks[blockUUID].Size = readSize
+	file.Blocks[blockUUID].ContentHash = merkle.HashContent(contents)
+
+	// Compress the block
+	compression, err := file.Volume.Compress(&contents)
+	if err != nil {
+		logger.Logger.Error("api", "Failed to compress file: ", file.UUID.String())
+		c.JSON(500, responses.NewOperationFailureResponse(constants.COMPRESSION_JOB_FAILED, "Failed to compress file: "+file.UUID.String()))
+		return
+	}
+	file.Blocks[blockUUID].Compression = compression
+	file.Blocks[blockUUID].CompressedSize = len(contents)
 
 	// Prepare internal block metadata
 	var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
@@ -461,7 +505,7 @@ func UploadBlock(c *gin.Context) {
 	blockMetadata.FileUUID = fileUUID
 	blockMetadata.Content = &contents
 	blockMetadata.UUID = blockUUID
-	blockMetadata.Size = blockHeader.Size
+	blockMetadata.Size = int64(len(contents))
 	blockMetadata.Status = &file.Blocks[blockUUID].Status
 	blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
 		*status = constants.BLOCK_STATUS_TRANSFERRED
@@ -471,17 +515,23 @@ func UploadBlock(c *gin.Context) {
 	}
 
 	// encrypt the file
-	err = file.Volume.Encrypt(blockMetadata.Content)
-	if err != nil {
+	file.Blocks[blockUUID].KeyUUID, err = file.Volume.Encrypt(blockMetadata.Content, file.Blocks[blockUUID].GetIdentity(fileUUID))
+	if errors.Is(err, models.ErrVolumeLocked) {
+		logger.Logger.Error("api", "Failed to encrypt file: ", file.UUID.String(), ", the volume is locked.")
+		c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	} else if err != nil {
 		logger.Logger.Error("api", "Failed to encrypt file: ", file.UUID.String())
 		c.JSON(500, responses.NewOperationFailureResponse(constants.ENCRYPTION_JOB_FAILED, "Failed to encrypt file: "+file.UUID.String()))
 		return
 	}
+	file.Blocks[blockUUID].FormatVersion = constants.BLOCK_FORMAT_VERSION_BOUND
 
 	// Calculate block checksum
 	file.Blocks[blockUUID].Checksum = checksum.CalculateChecksum(contents)
 
-	// Upload file to target disk
+	// Upload file to target disk, from now on the file is unlocked once the transfer completes or fails
+	handedOff = true
 	errorWrapper := file.Blocks[blockUUID].Disk.Upload(blockMetadata)
 	if errorWrapper != nil {
 		logger.Logger.Error("api", "Failed to upload the block: ", _blockUUID)
@@ -492,13 +542,171 @@ func UploadBlock(c *gin.Context) {
 		return
 	}
 
+	// Save the remote ID of the uploaded block
+	file.Blocks[blockUUID].RemoteID = blockMetadata.RemoteID
+
 	// Update target disk usage
-	file.Blocks[blockUUID].Disk.UpdateUsedSpace(int64(file.Blocks[blockUUID].Size))
+	file.Blocks[blockUUID].Disk.UpdateUsedSpace(int64(file.Blocks[blockUUID].GetStoredSize()))
+
+	// Save the block status in the upload session
+	err = models.UpdateUploadSessionBlock(fileUUID, file.Blocks[blockUUID])
+	if err != nil {
+		logger.Logger.Error("api", "Could not save the status of the block: ", _blockUUID, " in the db.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
 
 	logger.Logger.Debug("api", "UploadBlock endpoint successful exit.")
 	c.JSON(200, responses.NewEmptySuccessResponse())
 }
 
+// StreamFileUpload - handler for Stream file upload request
+//
+// Stream file upload (POST /files/stream) - uploading a whole file streamed
+// in the request body. The file is partitioned into blocks on the backend
+// side and saved once all of its blocks are transferred. The SHA-256 of the
+// file is calculated while streaming, the upload is rejected if it does not
+// match the optional checksum provided by the client.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func StreamFileUpload(c *gin.Context) {
+	var userUUID uuid.UUID
+	var volumeUUID uuid.UUID
+	var rootUUID uuid.UUID
+	var name string
+	var err error
+
+	var file *models.RegularFile
+	var volume *models.Volume
+
+	// Retrieve and validate data from query
+	name = c.Query("name")
+	if len(name) < 1 || len(name) > 64 {
+		logger.Logger.Error("api", "Wrong file name.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_VALIDATOR_ERROR, "name", "Field Name is required and must be at most 64 characters long."))
+		return
+	}
+
+	volumeUUID, err = uuid.Parse(c.Query("volumeUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong volume uuid.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "volumeUUID", "Provided VolumeUUID is not a valid UUID"))
+		return
+	}
+
+	rootUUIDString := c.Query("rootUUID")
+	if rootUUIDString != "" {
+		rootUUID, err = uuid.Parse(rootUUIDString)
+		if err != nil {
+			logger.Logger.Error("api", "Wrong root uuid.")
+			c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "rootUUID", "Provided RootUUID is not a valid UUID"))
+			return
+		}
+	} else {
+		rootUUID = uuid.Nil
+	}
+
+	expectedChecksum := strings.ToLower(c.Query("checksum"))
+	if expectedChecksum != "" {
+		if _, err = hex.DecodeString(expectedChecksum); err != nil || len(expectedChecksum) != 64 {
+			logger.Logger.Error("api", "Wrong file checksum.")
+			c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_VALIDATOR_ERROR, "checksum", "Field Checksum must be a hex encoded SHA-256 hash."))
+			return
+		}
+	}
+
+	if c.Request.ContentLength > int64(models.Transport.MaximumFileSize) {
+		logger.Logger.Error("api", "The size: ", strconv.FormatInt(c.Request.ContentLength, 10), " is to big. The maximum file size is: ", strconv.Itoa(models.Transport.MaximumFileSize), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.TRANSPORT_FILE_TOO_BIG, fmt.Sprintf("The uploaded file is too big. Please make sure that the files are no larger than: %dB.", models.Transport.MaximumFileSize)))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from transport
+	volume = models.Transport.GetVolume(volumeUUID)
+	if volume == nil {
+		logger.Logger.Error("api", "Could not find a volume with the provided uuid: ", volumeUUID.String())
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_VOLUME_NOT_FOUND, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The provided user: ", userUUID.String(), " is not the owner of the volume: ", volume.UUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Verify that the volume is ready to handle file operations
+	if !volume.IsReady(c, true) {
+		logger.Logger.Error("api", "Attempted to execute file operations on a not ready volume: ", volumeUUID.String())
+		c.JSON(500, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_NOT_READY, "Selected volume is not ready. Please make sure that its disks are configured properly."))
+		return
+	}
+
+	// Verify that the volume encrypted with a passphrase was unlocked
+	if volume.IsLocked() {
+		logger.Logger.Error("api", "Attempted to execute file operations on a locked volume: ", volumeUUID.String())
+		c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	}
+
+	// Verify that the rootUUID exists in the volume, and it's a directory
+	errCode := db.ValidateRootDirectory(rootUUID, volumeUUID)
+	if errCode != constants.SUCCESS {
+		logger.Logger.Error("api", "The provided root directory: ", rootUUID.String(), " does not exist on the provided volume: ", volumeUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(errCode, "Root directory not found"))
+		return
+	}
+
+	// Partition the streamed file into blocks and upload them
+	file, errorWrapper := volume.StreamFileUpload(c, c.Request.Body, name, userUUID, rootUUID)
+	if errorWrapper != nil && errorWrapper.Code == constants.TRANSPORT_VOLUME_LOCKED {
+		logger.Logger.Error("api", "Failed to upload the streamed file: ", name, ", the volume was locked.")
+		c.JSON(423, responses.NewOperationFailureResponse(errorWrapper.Code, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	} else if errorWrapper != nil && errorWrapper.Code == constants.VAL_SIZE_INVALID {
+		logger.Logger.Error("api", "The streamed file: ", name, " is empty.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(errorWrapper.Code, "body", "The uploaded file must not be empty."))
+		return
+	} else if errorWrapper != nil {
+		logger.Logger.Error("api", "Failed to upload the streamed file: ", name, ".")
+		c.JSON(500, responses.NewOperationFailureResponse(errorWrapper.Code, "File upload failed: "+errorWrapper.Error.Error()))
+		return
+	}
+
+	// Verify the checksum of the file if it was provided by the client
+	errorWrapper = models.VerifyStreamedFile(c, file, expectedChecksum)
+	if errorWrapper != nil {
+		logger.Logger.Error("api", "Checksum of the streamed file: ", name, " is invalid.")
+		c.JSON(422, responses.NewOperationFailureResponse(errorWrapper.Code, errorWrapper.Error.Error()))
+		return
+	}
+
+	// Save file and its blocks to database
+	fileDBO, err := models.SaveUploadedFile(file, userUUID)
+	if err != nil {
+		logger.Logger.Error("api", "Could not save the file: ", file.GetUUID().String(), " in the db.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
+
+	// Remove the upload session, the blocks of the file are no longer in flight
+	err = models.RemoveUploadSession(file.GetUUID())
+	if err != nil {
+		logger.Logger.Warning("api", "Could not remove the upload session of the file: ", file.GetUUID().String(), " from the db.")
+	}
+
+	logger.Logger.Debug("api", "StreamFileUpload endpoint successful exit.")
+	c.JSON(200, responses.NewFileDataSuccessResponse(fileDBO))
+}
+
 // UpdateFile - handler for Update file request
 //
 // Update file (PUT /files/manage/{fileUUID}) - updating the name or location
@@ -685,6 +893,8 @@ func DeleteFile(c *gin.Context) {
 //
 // Init file download request (POST /files/download/{fileUUID}) - initiating
 // the process of downloading a file and retrieving a list of blocks.
+// Additional files and directories listed in the request body are
+// downloaded along with the file as a single ZIP archive.
 //
 // params:
 //   - c *gin.Context: context of the request
@@ -692,12 +902,12 @@ func DeleteFile(c *gin.Context) {
 // return type:
 //   - API response with appropriate HTTP code
 func InitFileDownloadRequest(c *gin.Context) {
+	var requestBody requests.InitFileDownloadRequest
 	var fileUUID uuid.UUID
+	var userUUID uuid.UUID
 	var files []uuid.UUID = make([]uuid.UUID, 0)
-	var file *dbo.File
-	var blocks []*dbo.Block
+	var _files []*dbo.File
 	var err error
-	var code string
 	var response *responses.SuccessResponse = nil
 
 	// Retrieve and validate fileUUID from params
@@ -707,82 +917,333 @@ func InitFileDownloadRequest(c *gin.Context) {
 		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "FileUUID", "Provided FileUUID is not a valid UUID"))
 		return
 	}
-
-	// Potentially backend could receive an array of fileUUIDs to download
 	files = append(files, fileUUID)
 
-	if len(files) == 1 {
-		file, code = db.FileFromDatabase(fileUUID.String())
+	// Retrieve additional files to download from the request body if provided
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			logger.Logger.Error("api", "Wrong request body.")
+			c.JSON(422, responses.NewValidationErrorResponse(err))
+			return
+		}
+
+		for _, _fileUUID := range requestBody.Files {
+			UUID, err := uuid.Parse(_fileUUID)
+			if err != nil {
+				logger.Logger.Error("api", "Wrong file uuid.")
+				c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "Files", "Provided FileUUID is not a valid UUID"))
+				return
+			}
+
+			if UUID != fileUUID {
+				files = append(files, UUID)
+			}
+		}
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve files from database
+	for _, UUID := range files {
+		file, code := db.FileFromDatabase(UUID.String())
 		if file == nil {
-			logger.Logger.Error("api", "A file with the provided uuid: ", fileUUID.String(), " was not found in the db.")
+			logger.Logger.Error("api", "A file with the uuid: ", UUID.String(), " was not found in the db.")
 			c.JSON(404, responses.NewNotFoundErrorResponse(code, "File not found"))
 			return
 		}
 
-		if file.Type == constants.FILE_TYPE_DIRECTORY {
-			logger.Logger.Error("disk", "Directory download is not permitted.")
-			c.JSON(500, responses.NewOperationFailureResponse(constants.REMOTE_BAD_REQUEST, "Directory download is not permitted."))
+		// Verify that the user is owner of the file
+		if userUUID != file.UserUUID {
+			logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the file: ", file.UUID.String(), ".")
+			c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "File not found"))
 			return
 		}
 
-		if file.Size <= constants.FRONT_RAM_CAPACITY {
-			blocks, code = db.BlocksFromDatabase(fileUUID.String())
-			if blocks == nil {
-				logger.Logger.Warning("api", "Could not find file blocks in the db.")
-				c.JSON(405, responses.NewOperationFailureResponse(code, "File corrupted"))
-				return
-			}
+		// Verify that the volume encrypted with a passphrase was unlocked
+		volume := models.Transport.GetVolume(file.VolumeUUID)
+		if volume != nil && volume.IsLocked() {
+			logger.Logger.Error("api", "Attempted to download the file: ", file.UUID.String(), " from a locked volume: ", file.VolumeUUID.String())
+			c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+			return
+		}
 
-			f := models.NewFileFromDBO(file)
+		_files = append(_files, file)
+	}
 
-			for _, b := range f.GetBlocks() {
-				b.Status = constants.BLOCK_STATUS_QUEUED
-			}
+	// Small files are downloaded block by block by the client
+	if len(_files) == 1 && _files[0].Type == constants.FILE_TYPE_REGULAR && _files[0].Size <= constants.FRONT_RAM_CAPACITY {
+		blocks, code := db.BlocksFromDatabase(fileUUID.String())
+		if blocks == nil {
+			logger.Logger.Warning("api", "Could not find file blocks in the db.")
+			c.JSON(405, responses.NewOperationFailureResponse(code, "File corrupted"))
+			return
+		}
 
-			f = models.NewFileWrapper(constants.FILE_TYPE_SMALLER_WRAPPER, []models.File{f})
-			models.Transport.FileDownloadQueue.EnqueueInstance(f.GetUUID(), f)
-			logger.Logger.Debug("api", "Successfully enqueued the file: ", file.UUID.String(), " for download")
+		f := models.NewFileFromDBO(_files[0])
 
-			response = responses.NewInitFileUploadRequestResponse(file.UserUUID, f)
+		for _, b := range f.GetBlocks() {
+			b.Status = constants.BLOCK_STATUS_QUEUED
 		}
+
+		f = models.NewFileWrapper(constants.FILE_TYPE_SMALLER_WRAPPER, []models.File{f})
+		models.Transport.FileDownloadQueue.EnqueueInstance(f.GetUUID(), f)
+		logger.Logger.Debug("api", "Successfully enqueued the file: ", fileUUID.String(), " for download")
+
+		response = responses.NewInitFileUploadRequestResponse(userUUID, f)
 	}
 
+	// Larger files, multiple files and directories are streamed by the backend
 	if response == nil {
-		var _files []models.File
-		for _, UUID := range files {
-			_f, code := db.FileFromDatabase(UUID.String())
-			if _f == nil {
-				logger.Logger.Error("api", "A File with the uuid: ", UUID.String(), " was not found in the db.")
-				c.JSON(404, responses.NewNotFoundErrorResponse(code, "File not found"))
-				return
-			}
-
-			if _f.Type == constants.FILE_TYPE_DIRECTORY {
-				logger.Logger.Error("disk", "Directory download is not permitted.")
-				c.JSON(500, responses.NewOperationFailureResponse(constants.REMOTE_BAD_REQUEST, "Directory download is not permitted."))
+		var wrappedFiles []models.File
+		for _, _f := range _files {
+			f := models.NewFileFromDBO(_f)
+			if f == nil {
+				logger.Logger.Error("api", "Could not load the file: ", _f.UUID.String(), " from the db.")
+				c.JSON(405, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "File corrupted"))
 				return
 			}
 
-			f := models.NewFileFromDBO(_f)
-
 			for _, b := range f.GetBlocks() {
 				b.Status = constants.BLOCK_STATUS_QUEUED
 			}
 
-			_files = append(_files, f)
+			wrappedFiles = append(wrappedFiles, f)
 		}
 
-		wrapper := models.NewFileWrapper(constants.FILE_TYPE_WRAPPER, _files)
+		wrapper := models.NewFileWrapper(constants.FILE_TYPE_WRAPPER, wrappedFiles)
 		models.Transport.FileDownloadQueue.EnqueueInstance(wrapper.GetUUID(), wrapper)
 		logger.Logger.Debug("api", "Successfully enqueued the file: ", wrapper.GetUUID().String(), " for download")
 
-		response = responses.NewInitFileUploadRequestResponse(file.UserUUID, wrapper)
+		response = responses.NewInitFileUploadRequestResponse(userUUID, wrapper)
 	}
 
 	logger.Logger.Debug("api", "InitDownloadRequest endpoint successful exit.")
 	c.JSON(200, response)
 }
 
+// GetFileContent - handler for Get file content request
+//
+// Get file content (GET /files/content/{fileUUID}) - downloading the content
+// of the regular file directly. Single byte ranges requested with the Range
+// header are supported, only the blocks covering the requested range are
+// downloaded and decrypted.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func GetFileContent(c *gin.Context) {
+	var fileUUID uuid.UUID
+	var userUUID uuid.UUID
+	var _file *dbo.File
+	var file models.File
+	var errCode string
+
+	// Retrieve and validate fileUUID from param
+	fileUUID, err := uuid.Parse(c.Param("FileUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong file uuid.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "FileUUID", "Provided FileUUID is not a valid UUID"))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve file from database
+	_file, errCode = db.FileFromDatabase(fileUUID.String())
+	if _file == nil {
+		logger.Logger.Error("api", "A file with the uuid: ", fileUUID.String(), " was not found in the db.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(errCode, "File not found"))
+		return
+	}
+
+	// Verify that the user is owner of the file
+	if userUUID != _file.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the file: ", _file.UUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "File not found"))
+		return
+	}
+
+	if _file.Type != constants.FILE_TYPE_REGULAR {
+		logger.Logger.Error("api", "Attempted to download the content of the directory: ", _file.UUID.String(), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.FS_FILE_TYPE_MISMATCH, "Only the content of regular files can be downloaded."))
+		return
+	}
+
+	// Generate file model
+	file = models.NewFileFromDBO(_file)
+	if file == nil || file.GetVolume() == nil {
+		logger.Logger.Error("api", "Could not load the file: ", _file.UUID.String(), " from the db.")
+		c.JSON(405, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "File corrupted"))
+		return
+	}
+
+	// Verify that the volume encrypted with a passphrase was unlocked
+	if file.GetVolume().IsLocked() {
+		logger.Logger.Error("api", "Attempted to download the file: ", _file.UUID.String(), " from a locked volume: ", _file.VolumeUUID.String())
+		c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	}
+
+	// Contents of the file never change, so its UUID identifies them
+	size := int64(file.GetSize())
+	etag := "\"" + file.GetUUID().String() + "\""
+	contentType := mime.TypeByExtension(path.Ext(file.GetName()))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Access-Control-Expose-Headers", "Accept-Ranges, Content-Range, ETag")
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", etag)
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// Serve the requested range, the range is ignored if the file has changed since it was requested
+	byteRange := httprange.Range{Start: 0, End: size - 1}
+	status := http.StatusOK
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" && (c.GetHeader("If-Range") == "" || c.GetHeader("If-Range") == etag) {
+		requestedRange, err := httprange.Parse(rangeHeader, size)
+		if err == httprange.ErrUnsatisfiable {
+			logger.Logger.Error("api", "The range: ", rangeHeader, " is outside of the file: ", fileUUID.String(), ".")
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, responses.NewOperationFailureResponse(constants.FS_RANGE_INVALID, "Requested range not satisfiable"))
+			return
+		}
+
+		if err == nil {
+			byteRange = requestedRange
+			status = http.StatusPartialContent
+			c.Header("Content-Range", byteRange.ContentRange(size))
+		}
+	}
+
+	c.Header("Content-Length", strconv.FormatInt(byteRange.Length(), 10))
+	c.Header("Content-Type", contentType)
+	c.Status(status)
+
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+
+	// Stream the blocks covering the range
+	brokenBlocks, err := models.WriteFileRange(c.Writer, file, byteRange.Start, byteRange.End, &apicalls.BlockMetadata{Ctx: c, FileUUID: fileUUID})
+	if err != nil {
+		logger.Logger.Error("api", "Could not stream the content of the file: ", fileUUID.String(), " to the client: ", err.Error(), ".")
+		return
+	}
+
+	if len(brokenBlocks) > 0 {
+		logger.Logger.Warning("api", strconv.Itoa(len(brokenBlocks)), " blocks of the file: ", fileUUID.String(), " failed verification. Sent a corrupted file.")
+	}
+
+	logger.Logger.Debug("api", "GetFileContent endpoint successful exit.")
+}
+
+// GetBlockProof - handler for Get block proof request
+//
+// Get block proof (GET /files/manage/{fileUUID}/proof/{blockUUID}) - retrieving
+// the proof of inclusion of the block in the merkle tree of the file, so the
+// client can verify the downloaded block against the merkle root of the file.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func GetBlockProof(c *gin.Context) {
+	var fileUUID uuid.UUID
+	var blockUUID uuid.UUID
+	var userUUID uuid.UUID
+	var _file *dbo.File
+	var errCode string
+
+	// Retrieve and validate fileUUID and blockUUID from params
+	fileUUID, err := uuid.Parse(c.Param("FileUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong file uuid.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "FileUUID", "Provided FileUUID is not a valid UUID"))
+		return
+	}
+
+	blockUUID, err = uuid.Parse(c.Param("BlockUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong block uuid.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_UUID_INVALID, "BlockUUID", "Provided BlockUUID is not a valid UUID"))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve file from database
+	_file, errCode = db.FileFromDatabase(fileUUID.String())
+	if _file == nil {
+		logger.Logger.Error("api", "A file with the uuid: ", fileUUID.String(), " was not found in the db.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(errCode, "File not found"))
+		return
+	}
+
+	// Verify that the user is owner of the file
+	if userUUID != _file.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the file: ", _file.UUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "File not found"))
+		return
+	}
+
+	if _file.Type != constants.FILE_TYPE_REGULAR {
+		logger.Logger.Error("api", "Attempted to retrieve the block proof of the directory: ", _file.UUID.String(), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.FS_FILE_TYPE_MISMATCH, "Only the blocks of regular files can be verified."))
+		return
+	}
+
+	// Generate file model
+	file, ok := models.NewFileFromDBO(_file).(*models.RegularFile)
+	if !ok || file.GetVolume() == nil {
+		logger.Logger.Error("api", "Could not load the file: ", _file.UUID.String(), " from the db.")
+		c.JSON(405, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "File corrupted"))
+		return
+	}
+
+	// The block has to be decrypted to verify its contents
+	if file.GetVolume().IsLocked() {
+		logger.Logger.Error("api", "Attempted to verify the block of the file: ", _file.UUID.String(), " from a locked volume: ", _file.VolumeUUID.String())
+		c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	}
+
+	// Build the proof of the block
+	proof, err := file.GetBlockProof(blockUUID)
+	if errors.Is(err, models.ErrIntegrityUnknown) {
+		logger.Logger.Error("api", "The file: ", fileUUID.String(), " has no merkle root.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.FS_INTEGRITY_UNKNOWN, "The file was uploaded before its blocks were hashed"))
+		return
+	} else if err != nil {
+		logger.Logger.Error("api", "Could not build the proof of the block: ", blockUUID.String(), ", got an error: ", err.Error())
+		c.JSON(500, responses.NewOperationFailureResponse(constants.OPERATION_FAILED, "Could not build the proof of the block: "+err.Error()))
+		return
+	} else if proof == nil {
+		logger.Logger.Error("api", "The block: ", blockUUID.String(), " does not belong to the file: ", fileUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.FS_BLOCK_MISMATCH, "Block not found"))
+		return
+	}
+
+	// Verify the stored contents of the block against the merkle root saved on upload
+	verified := file.VerifyBlockProof(c, proof)
+	if !verified {
+		logger.Logger.Warning("api", "Contents of the block: ", blockUUID.String(), " do not match the merkle root of the file: ", fileUUID.String(), ".")
+	}
+
+	logger.Logger.Debug("api", "GetBlockProof endpoint successful exit.")
+	c.JSON(200, responses.NewBlockProofSuccessResponse(file, proof, verified))
+}
+
 // DownloadBlock - handler for Download block request
 //
 // Download block (POST /files/download/{fileUUID}) - downloading a single
@@ -831,6 +1292,13 @@ func DownloadBlock(c *gin.Context) {
 		Checksum:         file.GetBlocks()[blockUUID].Checksum,
 	}
 
+	// Verify that the volume encrypted with a passphrase was not locked in the meantime
+	if file.GetVolume() != nil && file.GetVolume().IsLocked() {
+		logger.Logger.Error("api", "Attempted to download the file: ", fileUUID.String(), " from a locked volume.")
+		c.JSON(423, responses.NewOperationFailureResponse(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	}
+
 	// Block the current file in the FileDownloadQueue
 	err = models.Transport.FileDownloadQueue.MarkAsUsed(fileUUID)
 	if err != nil {
@@ -888,8 +1356,14 @@ func CompleteFileUploadRequest(c *gin.Context) {
 		return
 	}
 
-	// Retrieve file from transport
-	file = *models.Transport.FileUploadQueue.GetEnqueuedInstance(fileUUID).(*models.RegularFile)
+	// Retrieve file from transport (or resume its upload session)
+	_file := models.Transport.GetUploadFile(fileUUID)
+	if _file == nil {
+		logger.Logger.Error("api", "Could not find the upload of the file: ", fileUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_UPLOAD_NOT_FOUND, "Upload not found"))
+		return
+	}
+	file = *_file
 
 	// Verify whether blocks were successfully uploaded
 	for _, _block := range file.Blocks {
@@ -914,46 +1388,18 @@ func CompleteFileUploadRequest(c *gin.Context) {
 		return
 	}
 
-	// Save file to database
-	result := db.DB.DatabaseHandle.Create(&dbo.File{
-		AbstractDatabaseObject: dbo.AbstractDatabaseObject{
-			UUID: file.GetUUID(),
-		},
-		VolumeUUID: file.GetVolume().UUID,
-		RootUUID:   file.GetRoot(),
-		UserUUID:   userUUID,
-		Type:       file.GetType(),
-		Name:       file.GetName(),
-		Size:       file.GetSize(),
-		Checksum:   "",
-	})
-
-	if result.Error != nil {
+	// Save file and its blocks to database
+	_, err = models.SaveUploadedFile(&file, userUUID)
+	if err != nil {
 		logger.Logger.Error("api", "Could not save the file: ", file.GetUUID().String(), " in the db.")
-		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+result.Error.Error()))
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
 		return
 	}
 
-	// Save blocks to database
-	for _, _block := range file.Blocks {
-		result := db.DB.DatabaseHandle.Create(&dbo.Block{
-			AbstractDatabaseObject: dbo.AbstractDatabaseObject{
-				UUID: _block.UUID,
-			},
-			FileUUID:   fileUUID,
-			UserUUID:   userUUID,
-			VolumeUUID: file.Volume.UUID,
-			DiskUUID:   _block.Disk.GetUUID(),
-			Size:       _block.Size,
-			Order:      _block.Order,
-			Checksum:   _block.Checksum,
-		})
-
-		if result.Error != nil {
-			logger.Logger.Error("api", "Could not save the block: ", _block.UUID.String(), " in the db.")
-			c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+result.Error.Error()))
-			return
-		}
+	// Remove the upload session
+	err = models.RemoveUploadSession(fileUUID)
+	if err != nil {
+		logger.Logger.Warning("api", "Could not remove the upload session of the file: ", fileUUID.String(), " from the db.")
 	}
 
 	// Remove file from transport
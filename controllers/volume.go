@@ -9,6 +9,7 @@ import (
 	"dcfs/requests"
 	"dcfs/responses"
 	"dcfs/util/logger"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"strconv"
@@ -43,6 +44,13 @@ func CreateVolume(c *gin.Context) {
 		return
 	}
 
+	// Verify that the passphrase of the volume was provided
+	if requestBody.Settings.Encryption == constants.ENCRYPTION_TYPE_PASSPHRASE && requestBody.Passphrase == "" {
+		logger.Logger.Error("api", "Passphrase of the volume was not provided.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_VALIDATOR_ERROR, "Passphrase", "Field Passphrase is required."))
+		return
+	}
+
 	// Create a new volume
 	volume = dbo.NewVolumeFromRequest(&requestBody, user.UUID)
 
@@ -55,7 +63,29 @@ func CreateVolume(c *gin.Context) {
 	}
 
 	// Initiate volume in transport
-	_ = models.Transport.GetVolume(volume.UUID)
+	v := models.Transport.GetVolume(volume.UUID)
+
+	// Derive the key wrapping the data keys from the passphrase, the passphrase itself is not stored
+	if v != nil && volume.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_PASSPHRASE {
+		err := v.SetPassphrase(requestBody.Passphrase)
+		if err != nil {
+			logger.Logger.Error("api", "Could not set the passphrase of the volume: ", volume.UUID.String(), ".")
+			c.JSON(500, responses.NewOperationFailureResponse(constants.ENCRYPTION_JOB_FAILED, "Could not set the passphrase of the volume"))
+			return
+		}
+	}
+
+	// Generate the data key of the encrypted volume
+	if v != nil && volume.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
+		keyUUID, err := v.GenerateDataKey()
+		if err != nil {
+			logger.Logger.Error("api", "Could not generate the data key of the volume: ", volume.UUID.String(), ".")
+			c.JSON(500, responses.NewOperationFailureResponse(constants.ENCRYPTION_JOB_FAILED, "Could not generate the data key of the volume"))
+			return
+		}
+
+		volume.KeyUUID = keyUUID
+	}
 
 	logger.Logger.Debug("api", "CreateVolume endpoint successful exit.")
 	c.JSON(200, responses.NewVolumeDataSuccessResponse(volume))
@@ -106,8 +136,9 @@ func GetVolume(c *gin.Context) {
 	// Return volume data
 	logger.Logger.Debug("api", "GetVolume endpoint successful exit.")
 	c.JSON(200, responses.NewVolumeListSuccessResponse(&responses.VolumeResponse{
-		Volume:  *volume,
-		IsReady: v.IsReady(c, false),
+		Volume:   *volume,
+		IsReady:  v.IsReady(c, false),
+		IsLocked: v.IsLocked(),
 	}))
 }
 
@@ -163,17 +194,51 @@ func UpdateVolume(c *gin.Context) {
 		return
 	}
 
+	// Verify that the passphrase was provided if the volume is to be encrypted with it
+	if requestBody.Settings.Encryption == constants.ENCRYPTION_TYPE_PASSPHRASE && volume.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_PASSPHRASE && requestBody.Passphrase == "" {
+		logger.Logger.Error("api", "Passphrase of the volume: ", volumeUUID.String(), " was not provided.")
+		c.JSON(422, responses.NewValidationErrorResponseSingle(constants.VAL_VALIDATOR_ERROR, "Passphrase", "Field Passphrase is required."))
+		return
+	}
+
 	// Update volume data
 	volume.Name = requestBody.Name
 	volume.VolumeSettings.FilePartition = requestBody.Settings.FilePartition
 	logger.Logger.Debug("api", "Updated name to: ", requestBody.Name, ",  partitioning settings to: ", strconv.Itoa(requestBody.Settings.FilePartition), " of the volume: ", volumeUUID.String(), ".")
 
+	// Compression algorithm is recorded per block, so it can be changed at any time
+	if requestBody.Settings.Compression != 0 {
+		volume.VolumeSettings.Compression = requestBody.Settings.Compression
+		logger.Logger.Debug("api", "Updated compression to: ", strconv.Itoa(requestBody.Settings.Compression), " of the volume: ", volumeUUID.String(), ".")
+	}
+
 	// Update options for empty volume
 	empty, err := db.IsVolumeEmpty(volume.UUID)
 	if empty && err == nil {
+		previousEncryption := volume.VolumeSettings.Encryption
 		volume.VolumeSettings.Encryption = requestBody.Settings.Encryption
 
 		logger.Logger.Debug("api", "Updated encryption to: ", strconv.Itoa(requestBody.Settings.Encryption), " of the volume: ", volumeUUID.String(), ".")
+
+		// The passphrase of the empty volume may be changed, as no block is encrypted with its data key yet
+		if volume.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_PASSPHRASE && requestBody.Passphrase != "" {
+			err = volume.SetPassphrase(requestBody.Passphrase)
+			if err != nil {
+				logger.Logger.Error("api", "Could not set the passphrase of the volume: ", volumeUUID.String(), ".")
+				c.JSON(500, responses.NewOperationFailureResponse(constants.ENCRYPTION_JOB_FAILED, "Could not set the passphrase of the volume"))
+				return
+			}
+		}
+
+		// Generate the data key if the volume was not encrypted before, or its data key was wrapped with another key
+		if volume.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_NO_ENCRYPTION && (volume.GetKeyUUID() == uuid.Nil || previousEncryption != volume.VolumeSettings.Encryption || requestBody.Passphrase != "") {
+			_, err = volume.GenerateDataKey()
+			if err != nil {
+				logger.Logger.Error("api", "Could not generate the data key of the volume: ", volumeUUID.String(), ".")
+				c.JSON(500, responses.NewOperationFailureResponse(constants.ENCRYPTION_JOB_FAILED, "Could not generate the data key of the volume"))
+				return
+			}
+		}
 	}
 
 	// Save volume to database
@@ -310,8 +375,9 @@ func GetVolumes(c *gin.Context) {
 		}
 
 		volumesPagination = append(volumesPagination, responses.VolumeResponse{
-			Volume:  _v,
-			IsReady: v.IsReady(c, false),
+			Volume:   _v,
+			IsReady:  v.IsReady(c, false),
+			IsLocked: v.IsLocked(),
 		})
 	}
 
@@ -326,3 +392,396 @@ func GetVolumes(c *gin.Context) {
 	logger.Logger.Debug("api", "GetVolumes endpoint successful exit.")
 	c.JSON(200, responses.NewPaginationResponse(responses.PaginationData{Pagination: pagination.Pagination, Data: pagination.Data}))
 }
+
+// StartVolumeScrub - handler for Start volume scrub request
+//
+// Start volume scrub (POST /volumes/manage/{volumeUUID}/scrub) - starting
+// verification of all blocks of the specified volume or resuming the interrupted one.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func StartVolumeScrub(c *gin.Context) {
+	var volume *models.Volume
+	var volumeUUID uuid.UUID
+	var userUUID uuid.UUID
+	var errCode string
+	var err error
+
+	// Retrieve volumeUUID from path parameters
+	volumeUUID, err = uuid.Parse(c.Param("VolumeUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong volume uuid.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.VAL_UUID_INVALID, "Volume not found (invalid UUID)"))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from transport
+	volume = models.Transport.GetVolume(volumeUUID)
+	if volume == nil {
+		logger.Logger.Error("api", "A volume with the provided uuid: ", volumeUUID.String(), " was not found.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_VOLUME_NOT_FOUND, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the volume: ", volumeUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Start the scrub
+	_, errCode = models.StartScrub(volume)
+	if errCode == constants.OPERATION_IN_PROGRESS {
+		logger.Logger.Error("api", "The volume: ", volumeUUID.String(), " is already being scrubbed.")
+		c.JSON(409, responses.NewOperationFailureResponse(errCode, "Scrub of the volume is already in progress"))
+		return
+	} else if errCode != constants.SUCCESS {
+		logger.Logger.Error("api", "Could not start the scrub of the volume: ", volumeUUID.String(), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(errCode, "Could not start the scrub of the volume"))
+		return
+	}
+
+	// Retrieve the scrub report
+	scrub, diskErrors, err := models.GetLatestScrub(volumeUUID)
+	if err != nil || scrub == nil {
+		logger.Logger.Error("api", "Could not retrieve the scrub of the volume: ", volumeUUID.String(), " from the db.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Could not retrieve the scrub of the volume"))
+		return
+	}
+
+	// Return scrub report
+	logger.Logger.Debug("api", "StartVolumeScrub endpoint successful exit.")
+	c.JSON(200, responses.NewScrubSuccessResponse(scrub, diskErrors, models.IsScrubInProgress(volumeUUID)))
+}
+
+// GetVolumeScrub - handler for Get volume scrub report request
+//
+// Get volume scrub report (GET /volumes/manage/{volumeUUID}/scrub) - retrieving
+// progress and report of the latest scrub of the specified volume.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func GetVolumeScrub(c *gin.Context) {
+	var volume *dbo.Volume
+	var volumeUUID string
+	var userUUID uuid.UUID
+
+	// Retrieve volumeUUID from path parameters
+	volumeUUID = c.Param("VolumeUUID")
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from database
+	volume, dbErr := db.VolumeFromDatabase(volumeUUID)
+	if dbErr != constants.SUCCESS {
+		logger.Logger.Error("api", "A volume with the provided uuid: ", volumeUUID, " was not found in the db.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(dbErr, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the volume: ", volumeUUID)
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Retrieve the scrub report
+	scrub, diskErrors, err := models.GetLatestScrub(volume.UUID)
+	if err != nil {
+		logger.Logger.Error("api", "Could not retrieve the scrub of the volume: ", volumeUUID, " from the db.")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
+
+	if scrub == nil {
+		logger.Logger.Error("api", "The volume: ", volumeUUID, " was never scrubbed.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.DATABASE_SCRUB_NOT_FOUND, "Scrub not found"))
+		return
+	}
+
+	// Return scrub report
+	logger.Logger.Debug("api", "GetVolumeScrub endpoint successful exit.")
+	c.JSON(200, responses.NewScrubSuccessResponse(scrub, diskErrors, models.IsScrubInProgress(volume.UUID)))
+}
+
+// StartVolumeKeyRotation - handler for Start volume key rotation request
+//
+// Start volume key rotation (POST /volumes/manage/{volumeUUID}/keys/rotate) - generating
+// a new data key of the specified volume and re-encrypting its blocks in the background.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func StartVolumeKeyRotation(c *gin.Context) {
+	var volume *models.Volume
+	var volumeUUID uuid.UUID
+	var userUUID uuid.UUID
+	var errCode string
+	var err error
+
+	// Retrieve volumeUUID from path parameters
+	volumeUUID, err = uuid.Parse(c.Param("VolumeUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong volume uuid.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.VAL_UUID_INVALID, "Volume not found (invalid UUID)"))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from transport
+	volume = models.Transport.GetVolume(volumeUUID)
+	if volume == nil {
+		logger.Logger.Error("api", "A volume with the provided uuid: ", volumeUUID.String(), " was not found.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_VOLUME_NOT_FOUND, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the volume: ", volumeUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Start the key rotation
+	keyUUID, errCode := models.StartKeyRotation(volume)
+	if errCode == constants.OPERATION_IN_PROGRESS {
+		logger.Logger.Error("api", "The data key of the volume: ", volumeUUID.String(), " is already being rotated.")
+		c.JSON(409, responses.NewOperationFailureResponse(errCode, "Key rotation of the volume is already in progress"))
+		return
+	} else if errCode == constants.TRANSPORT_VOLUME_LOCKED {
+		logger.Logger.Error("api", "The volume: ", volumeUUID.String(), " is locked.")
+		c.JSON(423, responses.NewOperationFailureResponse(errCode, "The volume is locked. Please unlock it with its passphrase."))
+		return
+	} else if errCode == constants.OPERATION_NOT_SUPPORTED {
+		logger.Logger.Error("api", "The volume: ", volumeUUID.String(), " is not encrypted.")
+		c.JSON(422, responses.NewOperationFailureResponse(errCode, "The volume is not encrypted"))
+		return
+	} else if errCode != constants.SUCCESS {
+		logger.Logger.Error("api", "Could not start the key rotation of the volume: ", volumeUUID.String(), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(errCode, "Could not start the key rotation of the volume"))
+		return
+	}
+
+	// Retrieve the key rotation progress
+	remainingBlocks, err := models.CountBlocksToRotate(volumeUUID, keyUUID)
+	if err != nil {
+		logger.Logger.Error("api", "Could not count blocks to be re-encrypted of the volume: ", volumeUUID.String(), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
+
+	// Return key rotation progress
+	logger.Logger.Debug("api", "StartVolumeKeyRotation endpoint successful exit.")
+	c.JSON(200, responses.NewKeyRotationSuccessResponse(keyUUID, remainingBlocks, models.IsKeyRotationInProgress(volumeUUID)))
+}
+
+// GetVolumeKeyRotation - handler for Get volume key rotation progress request
+//
+// Get volume key rotation progress (GET /volumes/manage/{volumeUUID}/keys/rotate) - retrieving
+// the current data key of the specified volume and the number of blocks still encrypted with
+// the previous keys.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func GetVolumeKeyRotation(c *gin.Context) {
+	var volume *dbo.Volume
+	var volumeUUID string
+	var userUUID uuid.UUID
+
+	// Retrieve volumeUUID from path parameters
+	volumeUUID = c.Param("VolumeUUID")
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from database
+	volume, dbErr := db.VolumeFromDatabase(volumeUUID)
+	if dbErr != constants.SUCCESS {
+		logger.Logger.Error("api", "A volume with the provided uuid: ", volumeUUID, " was not found in the db.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(dbErr, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the volume: ", volumeUUID)
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Retrieve the key rotation progress
+	remainingBlocks, err := models.CountBlocksToRotate(volume.UUID, volume.KeyUUID)
+	if err != nil {
+		logger.Logger.Error("api", "Could not count blocks to be re-encrypted of the volume: ", volumeUUID, ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.DATABASE_ERROR, "Database operation failed: "+err.Error()))
+		return
+	}
+
+	// Return key rotation progress
+	logger.Logger.Debug("api", "GetVolumeKeyRotation endpoint successful exit.")
+	c.JSON(200, responses.NewKeyRotationSuccessResponse(volume.KeyUUID, remainingBlocks, models.IsKeyRotationInProgress(volume.UUID)))
+}
+
+// UnlockVolume - handler for Unlock volume request
+//
+// Unlock volume (POST /volumes/manage/{volumeUUID}/unlock) - unlocking the volume
+// encrypted with a passphrase. The key derived from the passphrase is kept only in
+// memory, so the volume is locked again after it was not used for some time.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func UnlockVolume(c *gin.Context) {
+	var requestBody requests.VolumeUnlockRequest
+	var volume *models.Volume
+	var volumeUUID uuid.UUID
+	var userUUID uuid.UUID
+	var err error
+
+	// Retrieve and validate data from request
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		logger.Logger.Error("api", "Wrong request body.")
+		c.JSON(422, responses.NewValidationErrorResponse(err))
+		return
+	}
+
+	// Retrieve volumeUUID from path parameters
+	volumeUUID, err = uuid.Parse(c.Param("VolumeUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong volume uuid.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.VAL_UUID_INVALID, "Volume not found (invalid UUID)"))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from transport
+	volume = models.Transport.GetVolume(volumeUUID)
+	if volume == nil {
+		logger.Logger.Error("api", "A volume with the provided uuid: ", volumeUUID.String(), " was not found.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_VOLUME_NOT_FOUND, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the volume: ", volumeUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Only volumes encrypted with a passphrase can be unlocked
+	if volume.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_PASSPHRASE {
+		logger.Logger.Error("api", "The volume: ", volumeUUID.String(), " is not encrypted with a passphrase.")
+		c.JSON(422, responses.NewOperationFailureResponse(constants.OPERATION_NOT_SUPPORTED, "The volume is not encrypted with a passphrase"))
+		return
+	}
+
+	// Unlock the volume
+	err = volume.Unlock(requestBody.Passphrase)
+	if errors.Is(err, models.ErrInvalidPassphrase) {
+		logger.Logger.Error("api", "Invalid passphrase of the volume: ", volumeUUID.String(), " was provided.")
+		c.JSON(403, responses.NewOperationFailureResponse(constants.ENCRYPTION_INVALID_PASSPHRASE, "Invalid passphrase"))
+		return
+	} else if err != nil {
+		logger.Logger.Error("api", "Could not unlock the volume: ", volumeUUID.String(), ".")
+		c.JSON(500, responses.NewOperationFailureResponse(constants.ENCRYPTION_JOB_FAILED, "Could not unlock the volume"))
+		return
+	}
+
+	// Resume the key rotation interrupted while the volume was locked
+	models.ResumeKeyRotation(volume)
+
+	// Return volume data
+	volumeDBO := volume.GetVolumeDBO()
+	logger.Logger.Debug("api", "UnlockVolume endpoint successful exit.")
+	c.JSON(200, responses.NewVolumeListSuccessResponse(&responses.VolumeResponse{
+		Volume:   volumeDBO,
+		IsReady:  volume.IsReady(c, false),
+		IsLocked: volume.IsLocked(),
+	}))
+}
+
+// LockVolume - handler for Lock volume request
+//
+// Lock volume (POST /volumes/manage/{volumeUUID}/lock) - removing the key derived
+// from the passphrase of the volume from memory before the session expires.
+//
+// params:
+//   - c *gin.Context: context of the request
+//
+// return type:
+//   - API response with appropriate HTTP code
+func LockVolume(c *gin.Context) {
+	var volume *models.Volume
+	var volumeUUID uuid.UUID
+	var userUUID uuid.UUID
+	var err error
+
+	// Retrieve volumeUUID from path parameters
+	volumeUUID, err = uuid.Parse(c.Param("VolumeUUID"))
+	if err != nil {
+		logger.Logger.Error("api", "Wrong volume uuid.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.VAL_UUID_INVALID, "Volume not found (invalid UUID)"))
+		return
+	}
+
+	// Retrieve userUUID from context
+	userUUID = c.MustGet("UserData").(middleware.UserData).UserUUID
+
+	// Retrieve volume from transport
+	volume = models.Transport.GetVolume(volumeUUID)
+	if volume == nil {
+		logger.Logger.Error("api", "A volume with the provided uuid: ", volumeUUID.String(), " was not found.")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.TRANSPORT_VOLUME_NOT_FOUND, "Volume not found"))
+		return
+	}
+
+	// Verify that the user is owner of the volume
+	if userUUID != volume.UserUUID {
+		logger.Logger.Error("api", "The user: ", userUUID.String(), " is not the owner of the volume: ", volumeUUID.String(), ".")
+		c.JSON(404, responses.NewNotFoundErrorResponse(constants.OWNER_MISMATCH, "Volume not found"))
+		return
+	}
+
+	// Only volumes encrypted with a passphrase can be locked
+	if volume.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_PASSPHRASE {
+		logger.Logger.Error("api", "The volume: ", volumeUUID.String(), " is not encrypted with a passphrase.")
+		c.JSON(422, responses.NewOperationFailureResponse(constants.OPERATION_NOT_SUPPORTED, "The volume is not encrypted with a passphrase"))
+		return
+	}
+
+	volume.Lock()
+
+	// Return volume data
+	volumeDBO := volume.GetVolumeDBO()
+	logger.Logger.Debug("api", "LockVolume endpoint successful exit.")
+	c.JSON(200, responses.NewVolumeListSuccessResponse(&responses.VolumeResponse{
+		Volume:   volumeDBO,
+		IsReady:  volume.IsReady(c, false),
+		IsLocked: volume.IsLocked(),
+	}))
+}
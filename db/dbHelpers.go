@@ -130,6 +130,28 @@ func FindUnassignedDisk(volumeUUID uuid.UUID) (*dbo.Disk, error) {
 	return &disk, nil
 }
 
+// FindUnassignedDisks - find disks from provided volume that are not assigned to any virtual disk
+//
+// Hot spare disks are omitted, as they are reserved for replacing failed disks.
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume to search in
+//   - limit int: maximum number of disks to be found
+//
+// return type:
+//   - []dbo.Disk: unassigned disks, empty if none found
+//   - error: database operation error
+func FindUnassignedDisks(volumeUUID uuid.UUID, limit int) ([]dbo.Disk, error) {
+	var disks []dbo.Disk
+
+	result := DB.DatabaseHandle.Where("volume_uuid = ? AND is_virtual = ? AND virtual_disk_uuid = ? AND is_spare = ?", volumeUUID, false, uuid.Nil, false).Limit(limit).Find(&disks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return disks, nil
+}
+
 // IsDirectoryEmpty - verify whether directory is empty
 //
 // params:
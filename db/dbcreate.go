@@ -1,8 +1,11 @@
 package db
 
 import (
+	"dcfs/db/dbo"
 	"dcfs/util/logger"
+	"dcfs/util/sealing"
 	"reflect"
+	"strconv"
 )
 
 // MigrateAll - migrate all dbo models to database
@@ -25,6 +28,50 @@ func (db *DatabaseConnection) MigrateAll() error {
 	return nil
 }
 
+// SealDiskCredentials - encrypt disk credentials saved in the database as plain text
+//
+// This function migrates disks created before credentials were sealed with
+// the key-encryption key. Already sealed credentials are left intact, so
+// it is safe to run it on every start.
+//
+// return type:
+//   - error
+func (db *DatabaseConnection) SealDiskCredentials() error {
+	var disks []dbo.Disk
+
+	err := db.DatabaseHandle.Where("credentials <> ''").Find(&disks).Error
+	if err != nil {
+		logger.Logger.Error("db", "Failed to retrieve the disks to seal their credentials: ", err.Error())
+		return err
+	}
+
+	var sealed int = 0
+	for _, disk := range disks {
+		if sealing.IsSealed(disk.Credentials) {
+			continue
+		}
+
+		credentials, err := sealing.Seal(disk.Credentials)
+		if err != nil {
+			logger.Logger.Error("db", "Failed to seal the credentials of the disk: ", disk.UUID.String())
+			return err
+		}
+
+		err = db.DatabaseHandle.Model(&dbo.Disk{}).Where("uuid = ?", disk.UUID).Update("credentials", credentials).Error
+		if err != nil {
+			logger.Logger.Error("db", "Failed to save the sealed credentials of the disk: ", disk.UUID.String())
+			return err
+		}
+
+		sealed++
+	}
+
+	if sealed > 0 {
+		logger.Logger.Warning("db", "Sealed the credentials of ", strconv.Itoa(sealed), " disks saved as plain text.")
+	}
+	return nil
+}
+
 // Respawn - drop the entire database and create everything anew
 //
 // return type:
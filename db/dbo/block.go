@@ -14,6 +14,20 @@ type Block struct {
 	Size     int    `json:"size"`
 	Order    int    `json:"order"`
 	Checksum string `json:"-"`
+	RemoteID string `json:"-"`
+
+	Compression    int `json:"-"` // algorithm the block was compressed with, 0 for blocks stored raw before compression was introduced
+	CompressedSize int `json:"-"` // number of bytes passed to the disk (before encryption), 0 if unknown
+
+	KeyUUID       uuid.UUID `json:"-"` // data key of the volume the block was encrypted with, uuid.Nil for the legacy global key
+	FormatVersion uint8     `json:"-"` // format of the encrypted block, 0 for blocks encrypted before the block format header was introduced
+
+	ContentHash string `json:"-"` // SHA-256 of the plain contents of the block, leaf of the merkle tree of the file
+
+	// Version of the block re-encrypted by the key rotation, recorded before it is uploaded, so that
+	// the block remains readable if the rotation is interrupted before the block is switched to it
+	PendingKeyUUID  uuid.UUID `json:"-"`
+	PendingChecksum string    `json:"-"`
 
 	//User   User   `gorm:"foreignKey:UserUUID;references:UUID"`
 	Volume Volume `gorm:"foreignKey:VolumeUUID;references:UUID" json:"-"`
@@ -0,0 +1,32 @@
+package dbo
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// DegradedBlock - block written to a backup disk without one of its replicas
+type DegradedBlock struct {
+	AbstractDatabaseObject
+	BlockUUID       uuid.UUID `json:"-"`
+	FileUUID        uuid.UUID `json:"-"`
+	VolumeUUID      uuid.UUID `json:"-"`
+	VirtualDiskUUID uuid.UUID `json:"-"`
+	DiskUUID        uuid.UUID `json:"-"`
+
+	Attempts int       `json:"-"` // number of failed attempts to resync the block
+	RetryAt  time.Time `json:"-"` // resync is postponed until this time after a failed attempt
+
+	CreatedAt time.Time `gorm:"<-:create" json:"-"`
+}
+
+// NewDegradedBlock - create new degraded block object
+//
+// return type:
+//   - *dbo.DegradedBlock: created degraded block DBO
+func NewDegradedBlock() *DegradedBlock {
+	var b *DegradedBlock = new(DegradedBlock)
+	b.AbstractDatabaseObject.DatabaseObject = b
+	b.UUID = uuid.New()
+	return b
+}
@@ -10,7 +10,7 @@ type Disk struct {
 	UserUUID     uuid.UUID `json:"-"`
 	VolumeUUID   uuid.UUID `json:"-"`
 	ProviderUUID uuid.UUID `json:"-"`
-	Credentials  string    `json:"credentials"`
+	Credentials  string    `json:"-"` // sealed with the key-encryption key, never returned to the client
 	Name         string    `json:"name"`
 
 	UsedSpace  uint64 `json:"-"`
@@ -21,6 +21,7 @@ type Disk struct {
 
 	IsVirtual       bool      `json:"-"`
 	VirtualDiskUUID uuid.UUID `json:"-"`
+	IsSpare         bool      `json:"isSpare"`
 
 	User     User     `gorm:"foreignKey:UserUUID;references:UUID" json:"-"`
 	Volume   Volume   `gorm:"foreignKey:VolumeUUID;references:UUID" json:"volume"`
@@ -0,0 +1,39 @@
+package dbo
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Event - notable action performed by the server on behalf of the user
+type Event struct {
+	AbstractDatabaseObject
+	UserUUID   uuid.UUID `json:"-"`
+	VolumeUUID uuid.UUID `json:"volumeUUID"`
+	DiskUUID   uuid.UUID `json:"diskUUID"`
+
+	Type    int    `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"createdAt"`
+}
+
+// NewEvent - create new event object
+//
+// return type:
+//   - *dbo.Event: created event DBO
+func NewEvent() *Event {
+	var e *Event = new(Event)
+	e.AbstractDatabaseObject.DatabaseObject = e
+	e.UUID = uuid.New()
+	return e
+}
+
+// GetCreationTime - get creation time of the event
+//
+// return type:
+//   - time.Time: creation time of the event
+func (e Event) GetCreationTime() time.Time {
+	return e.CreatedAt
+}
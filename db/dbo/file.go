@@ -17,8 +17,9 @@ type File struct {
 	Type       int       `json:"type"`
 	Name       string    `json:"name"`
 
-	Size     int    `json:"size"`
-	Checksum string `json:"checksum"`
+	Size       int    `json:"size"`
+	Checksum   string `json:"checksum"`   // SHA-256 of the plain contents of the file, empty if unknown
+	MerkleRoot string `json:"merkleRoot"` // root of the merkle tree over the hashes of the blocks, empty for files uploaded before the blocks were hashed
 
 	CreatedAt time.Time      `gorm:"<-:create" json:"creationDate"`
 	UpdatedAt time.Time      `json:"modificationDate"`
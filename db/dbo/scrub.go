@@ -0,0 +1,74 @@
+package dbo
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Scrub - progress and report of the verification of all blocks of the volume
+type Scrub struct {
+	AbstractDatabaseObject
+	VolumeUUID uuid.UUID `json:"volumeUUID"`
+	Status     int       `json:"status"`
+
+	// UUID of the last verified block, blocks are verified in order of their UUIDs
+	LastBlockUUID uuid.UUID `json:"-"`
+
+	CheckedBlocks       int `json:"checkedBlocks"`
+	RepairedBlocks      int `json:"repairedBlocks"`
+	UnrecoverableBlocks int `json:"unrecoverableBlocks"`
+
+	// Blocks whose plain contents could not be compared with the hash saved on upload,
+	// e.g. because the volume is locked or the block was uploaded before it was hashed
+	UnverifiedBlocks int `json:"unverifiedBlocks"`
+
+	// Files whose blocks do not match the merkle root saved on upload
+	CorruptedFiles int `json:"corruptedFiles"`
+
+	// Files whose merkle root could not be rebuilt from the contents of their blocks
+	UnverifiedFiles int `json:"unverifiedFiles"`
+
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// ScrubDiskError - number of invalid or unreadable blocks found on the disk during the scrub
+type ScrubDiskError struct {
+	AbstractDatabaseObject
+	ScrubUUID uuid.UUID `json:"-"`
+	DiskUUID  uuid.UUID `json:"diskUUID"`
+	Errors    int       `json:"errors"`
+}
+
+// NewScrub - create new scrub object
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the scrubbed volume
+//
+// return type:
+//   - *dbo.Scrub: created scrub DBO
+func NewScrub(volumeUUID uuid.UUID) *Scrub {
+	var s *Scrub = new(Scrub)
+	s.AbstractDatabaseObject.DatabaseObject = s
+	s.UUID = uuid.New()
+	s.VolumeUUID = volumeUUID
+	s.StartedAt = time.Now()
+	return s
+}
+
+// NewScrubDiskError - create new scrub disk error object
+//
+// params:
+//   - scrubUUID uuid.UUID: UUID of the scrub
+//   - diskUUID uuid.UUID: UUID of the disk
+//
+// return type:
+//   - *dbo.ScrubDiskError: created scrub disk error DBO
+func NewScrubDiskError(scrubUUID uuid.UUID, diskUUID uuid.UUID) *ScrubDiskError {
+	var e *ScrubDiskError = new(ScrubDiskError)
+	e.AbstractDatabaseObject.DatabaseObject = e
+	e.UUID = uuid.New()
+	e.ScrubUUID = scrubUUID
+	e.DiskUUID = diskUUID
+	return e
+}
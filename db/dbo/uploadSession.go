@@ -0,0 +1,71 @@
+package dbo
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// UploadSession - file which is being uploaded, the UUID of the session is the UUID of the file
+type UploadSession struct {
+	AbstractDatabaseObject
+	UserUUID   uuid.UUID `json:"-"`
+	VolumeUUID uuid.UUID `json:"volumeUUID"`
+	RootUUID   uuid.UUID `json:"rootUUID"`
+
+	Name     string `json:"name"`
+	Size     int    `json:"size"`
+	Checksum string `json:"checksum"` // SHA-256 of the plain contents of the file provided by the client
+
+	CreatedAt time.Time `gorm:"<-:create" json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // time of the last uploaded block
+}
+
+// UploadSessionBlock - block of the file which is being uploaded
+type UploadSessionBlock struct {
+	AbstractDatabaseObject
+	SessionUUID uuid.UUID `json:"-"`
+	DiskUUID    uuid.UUID `json:"-"`
+
+	Size     int    `json:"size"`
+	Order    int    `json:"order"`
+	Status   int    `json:"status"`
+	Checksum string `json:"-"`
+	RemoteID string `json:"-"`
+
+	Compression    int `json:"-"`
+	CompressedSize int `json:"-"`
+
+	KeyUUID       uuid.UUID `json:"-"`
+	FormatVersion uint8     `json:"-"`
+	ContentHash   string    `json:"-"`
+}
+
+// NewUploadSession - create new upload session object
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the uploaded file
+//
+// return type:
+//   - *dbo.UploadSession: created upload session DBO
+func NewUploadSession(fileUUID uuid.UUID) *UploadSession {
+	var s *UploadSession = new(UploadSession)
+	s.AbstractDatabaseObject.DatabaseObject = s
+	s.UUID = fileUUID
+	return s
+}
+
+// NewUploadSessionBlock - create new upload session block object
+//
+// params:
+//   - sessionUUID uuid.UUID: UUID of the upload session
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - *dbo.UploadSessionBlock: created upload session block DBO
+func NewUploadSessionBlock(sessionUUID uuid.UUID, blockUUID uuid.UUID) *UploadSessionBlock {
+	var b *UploadSessionBlock = new(UploadSessionBlock)
+	b.AbstractDatabaseObject.DatabaseObject = b
+	b.UUID = blockUUID
+	b.SessionUUID = sessionUUID
+	return b
+}
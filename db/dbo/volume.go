@@ -1,6 +1,7 @@
 package dbo
 
 import (
+	"dcfs/constants"
 	"dcfs/requests"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -10,7 +11,11 @@ import (
 type VolumeSettings struct {
 	Backup        int `json:"backup"`
 	Encryption    int `json:"encryption"`
+	Compression   int `json:"compression"`
 	FilePartition int `json:"filePartition"`
+	Replicas      int `json:"replicas"`
+	DataShards    int `json:"dataShards"`
+	ParityShards  int `json:"parityShards"`
 }
 
 type Volume struct {
@@ -19,6 +24,12 @@ type Volume struct {
 	UserUUID       uuid.UUID      `json:"-"`
 	VolumeSettings VolumeSettings `gorm:"embedded" json:"settings"`
 
+	// Current data key of the volume, uuid.Nil if the blocks are encrypted with the legacy global key
+	KeyUUID uuid.UUID `json:"-"`
+
+	// Salt of the key derived from the passphrase, the passphrase itself is never stored
+	PassphraseSalt string `json:"-"`
+
 	CreatedAt time.Time      `gorm:"<-:create" json:"-"`
 	DeletedAt gorm.DeletedAt `json:"-"`
 
@@ -52,8 +63,36 @@ func NewVolumeFromRequest(request *requests.VolumeCreateRequest, userUUID uuid.U
 	v.Name = request.Name
 	v.VolumeSettings.Backup = request.Settings.Backup
 	v.VolumeSettings.Encryption = request.Settings.Encryption
+	v.VolumeSettings.Compression = request.Settings.Compression
 	v.VolumeSettings.FilePartition = request.Settings.FilePartition
 
+	// Do not compress blocks if compression settings were not provided
+	if v.VolumeSettings.Compression == 0 {
+		v.VolumeSettings.Compression = constants.COMPRESSION_TYPE_NO_COMPRESSION
+	}
+
+	// Use default replica count if mirroring settings were not provided
+	if v.VolumeSettings.Backup == constants.BACKUP_TYPE_RAID_1 {
+		v.VolumeSettings.Replicas = request.Settings.Replicas
+
+		if v.VolumeSettings.Replicas == 0 {
+			v.VolumeSettings.Replicas = constants.RAID1_DEFAULT_REPLICAS
+		}
+	}
+
+	// Use default shard counts if erasure coding settings were not provided
+	if v.VolumeSettings.Backup == constants.BACKUP_TYPE_ERASURE_CODING {
+		v.VolumeSettings.DataShards = request.Settings.DataShards
+		v.VolumeSettings.ParityShards = request.Settings.ParityShards
+
+		if v.VolumeSettings.DataShards == 0 {
+			v.VolumeSettings.DataShards = constants.ERASURE_DEFAULT_DATA_SHARDS
+		}
+		if v.VolumeSettings.ParityShards == 0 {
+			v.VolumeSettings.ParityShards = constants.ERASURE_DEFAULT_PARITY_SHARDS
+		}
+	}
+
 	return v
 }
 
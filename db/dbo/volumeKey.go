@@ -0,0 +1,32 @@
+package dbo
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// VolumeKey - data key encrypting the blocks of the volume, wrapped with the master key
+type VolumeKey struct {
+	AbstractDatabaseObject
+	VolumeUUID uuid.UUID `json:"-"`
+	WrappedKey string    `json:"-"`
+
+	CreatedAt time.Time `gorm:"<-:create" json:"createdAt"`
+}
+
+// NewVolumeKey - create new volume key object
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume
+//   - wrappedKey string: data key sealed with the master key
+//
+// return type:
+//   - *dbo.VolumeKey: created volume key DBO
+func NewVolumeKey(volumeUUID uuid.UUID, wrappedKey string) *VolumeKey {
+	var k *VolumeKey = new(VolumeKey)
+	k.AbstractDatabaseObject.DatabaseObject = k
+	k.UUID = uuid.New()
+	k.VolumeUUID = volumeUUID
+	k.WrappedKey = wrappedKey
+	return k
+}
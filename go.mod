@@ -15,6 +15,8 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/h2non/filetype v1.1.1
 	github.com/jlaffaye/ftp v0.1.0
+	github.com/klauspost/reedsolomon v1.11.8
+	github.com/klauspost/reedsolomon v1.11.8
 	github.com/pkg/sftp v1.13.5
 	github.com/smartystreets/goconvey v1.7.2
 	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90
@@ -42,6 +44,7 @@ require (
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/klauspost/cpuid/v2 v2.1.1 // indirect
 	github.com/kr/fs v0.1.0 // indirect
 	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/mattn/go-colorable v0.1.9 // indirect
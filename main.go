@@ -1,12 +1,16 @@
 package main
 
 import (
+	"dcfs/constants"
 	"dcfs/controllers"
 	"dcfs/db"
 	"dcfs/db/dbo"
 	"dcfs/db/seeder"
 	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/util/connpool"
 	"dcfs/util/logger"
+	"dcfs/util/sealing"
 	"flag"
 	"github.com/google/uuid"
 	"log"
@@ -14,11 +18,17 @@ import (
 	"path/filepath"
 	"strings"
 
+	_ "dcfs/models/disk/AzureBlobDisk"
 	_ "dcfs/models/disk/BackupDisk"
+	_ "dcfs/models/disk/DropboxDisk"
+	_ "dcfs/models/disk/ErasureDisk"
 	_ "dcfs/models/disk/FTPDisk"
 	_ "dcfs/models/disk/GDriveDisk"
+	_ "dcfs/models/disk/LocalDisk"
 	_ "dcfs/models/disk/OneDriveDisk"
+	_ "dcfs/models/disk/S3Disk"
 	_ "dcfs/models/disk/SFTPDisk"
+	_ "dcfs/models/disk/WebDAVDisk"
 )
 
 // main - entry point for the server application
@@ -36,14 +46,26 @@ func main() {
 	path := flag.String("db-connection", "./connection.json", "file containing db connection info")
 	rspw := flag.Bool("respawn", false, "set to true to drop and create the database anew")
 	debugLevel := flag.Int("debug", 1, "debug level: 2 - debug, warnings and errors, 1 - warnings and errors, 0 - errors, -1 - none, default: 1")
-	logScope := flag.String("log", "", "a comma separated list of modules to collect logs from, available are: middleware, api, db, disks, credentials, file, partitioner, transport, volume. The option: all enables logs from all modules")
+	logScope := flag.String("log", "", "a comma separated list of modules to collect logs from, available are: middleware, api, db, disks, credentials, file, partitioner, resync, scrub, spare, transport, volume. The option: all enables logs from all modules")
 	fileMaximumSize := flag.Int("max_file_size", 4*1024*1024*1024, "Maximum file size in bytes, the default one is 4294967296 (4GB)")
+	spareGracePeriod := flag.Duration("spare_grace_period", constants.SPARE_GRACE_PERIOD, "Time after which an unavailable disk of a volume with backup is replaced with a hot spare disk, the default one is 15m")
+	maxDiskConnections := flag.Int("max_disk_connections", constants.CONNECTION_POOL_MAX_CONNECTIONS, "Maximum number of connections opened to a single FTP or SFTP disk, the default one is 4")
+	connectionIdleTimeout := flag.Duration("connection_idle_timeout", constants.CONNECTION_IDLE_TIMEOUT, "Time after which an unused FTP or SFTP connection is closed, the default one is 1m")
+	masterKey := flag.String("master_key", constants.MASTER_KEY_PATH, "file containing the 256 bit master key used to encrypt disk credentials and volume data keys saved in the db, generated if it does not exist")
+	localDiskRoot := flag.String("local_disk_root", "", "directory containing the directories of the local disks, every user may only use the local disks within its own subdirectory, local disks are disabled if it is not set")
+	uploadSessionExpiration := flag.Duration("upload_session_expiration", constants.UPLOAD_SESSION_EXPIRATION, "Time after which an abandoned file upload is removed along with its uploaded blocks, the default one is 168h")
 	flag.Parse()
 
 	logger.Logger.SetLogLevel(*debugLevel)
 	logger.Logger.SetScopes(strings.Split(*logScope, ","))
 
 	models.Transport.MaximumFileSize = *fileMaximumSize
+	models.SpareGracePeriod = *spareGracePeriod
+	connpool.MaxConnections = *maxDiskConnections
+	connpool.IdleTimeout = *connectionIdleTimeout
+	models.UploadSessionExpiration = *uploadSessionExpiration
+	sealing.KeyPath = *masterKey
+	credentials.LocalDiskRoot = *localDiskRoot
 
 	absolutePath, err := filepath.Abs(*path)
 	if err != nil {
@@ -58,10 +80,17 @@ func main() {
 
 	// Register all needed tables
 	db.DB.RegisterTable(dbo.Volume{})
+	db.DB.RegisterTable(dbo.VolumeKey{})
 	db.DB.RegisterTable(dbo.Provider{})
 	db.DB.RegisterTable(dbo.File{})
 	db.DB.RegisterTable(dbo.Disk{})
 	db.DB.RegisterTable(dbo.Block{})
+	db.DB.RegisterTable(dbo.DegradedBlock{})
+	db.DB.RegisterTable(dbo.Scrub{})
+	db.DB.RegisterTable(dbo.ScrubDiskError{})
+	db.DB.RegisterTable(dbo.Event{})
+	db.DB.RegisterTable(dbo.UploadSession{})
+	db.DB.RegisterTable(dbo.UploadSessionBlock{})
 	db.DB.RegisterTable(dbo.User{})
 	db.DB.RegisterTable(dbo.Provider{})
 
@@ -77,9 +106,30 @@ func main() {
 		}
 	}
 
+	// Encrypt disk credentials saved before they were sealed
+	err = db.DB.SealDiskCredentials()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Seed required data
 	seeder.Seed()
 
+	// Start background resynchronization of degraded blocks
+	models.StartResyncWorker()
+
+	// Start scheduled scrubbing of the volumes
+	models.StartScrubWorker()
+
+	// Resume re-encrypting volumes whose data key rotation was interrupted
+	go models.ResumeKeyRotations()
+
+	// Start replacing unavailable disks with hot spares
+	models.StartSpareWorker()
+
+	// Start removing abandoned file uploads
+	models.StartUploadSessionWorker()
+
 	// Serve API backend using Gin framework
 	controllers.ServeBackend()
 }
@@ -1,8 +1,16 @@
 package models
 
 import (
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
 	"dcfs/db/dbo"
+	"dcfs/util/checksum"
+	"dcfs/util/logger"
+	"encoding/binary"
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"net/http/httptest"
 )
 
 type Block struct {
@@ -13,11 +21,150 @@ type Block struct {
 
 	Size     int
 	Checksum string
+	RemoteID string
+
+	Compression    int
+	CompressedSize int
+	KeyUUID        uuid.UUID
+	FormatVersion  uint8
+	ContentHash    string
+
+	PendingKeyUUID  uuid.UUID
+	PendingChecksum string
 
 	Status int
 	Order  int
 }
 
+// BlockIdentity - identity of the block bound to its ciphertext as the associated data,
+// so that encrypted blocks cannot be swapped between files or reordered on the disks
+type BlockIdentity struct {
+	UUID     uuid.UUID
+	FileUUID uuid.UUID
+	Order    int
+
+	FormatVersion uint8 // recorded format of the encrypted block, it is not a part of the associated data
+}
+
+// NewBlockIdentityFromDBO - create identity of the block based on block DBO
+//
+// params:
+//   - _block dbo.Block: block DBO data (from database)
+//
+// return type:
+//   - BlockIdentity: identity of the block
+func NewBlockIdentityFromDBO(_block dbo.Block) BlockIdentity {
+	return BlockIdentity{UUID: _block.UUID, FileUUID: _block.FileUUID, Order: _block.Order, FormatVersion: _block.FormatVersion}
+}
+
+// GetIdentity - get identity of the block bound to its ciphertext
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the file to which the block belongs
+//
+// return type:
+//   - BlockIdentity: identity of the block
+func (block *Block) GetIdentity(fileUUID uuid.UUID) BlockIdentity {
+	return BlockIdentity{UUID: block.UUID, FileUUID: fileUUID, Order: block.Order, FormatVersion: block.FormatVersion}
+}
+
+// associatedData - serialize the identity of the block stored on the volume
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume storing the block
+//   - header []byte: block format header
+//
+// return type:
+//   - []byte: associated data authenticated along with the ciphertext
+func (identity BlockIdentity) associatedData(volumeUUID uuid.UUID, header []byte) []byte {
+	data := make([]byte, 0, len(header)+3*len(uuid.UUID{})+8)
+	data = append(data, header...)
+	data = append(data, volumeUUID[:]...)
+	data = append(data, identity.FileUUID[:]...)
+	data = append(data, identity.UUID[:]...)
+	return binary.BigEndian.AppendUint64(data, uint64(identity.Order))
+}
+
+// newBlockHeader - create header of the encrypted block in the current format
+//
+// return type:
+//   - []byte: block format header
+func newBlockHeader() []byte {
+	return append([]byte(constants.BLOCK_HEADER_MAGIC), constants.BLOCK_FORMAT_VERSION_BOUND)
+}
+
+// GetStoredVersion - match the contents of the block downloaded from the disk with its recorded versions
+//
+// If the key rotation was interrupted after the re-encrypted block was uploaded, the disk
+// stores the pending version of the block, which has to be decrypted with the pending key.
+//
+// params:
+//   - contents []uint8: contents of the block downloaded from the disk
+//
+// return type:
+//   - *Block: block describing the stored version
+//   - bool: false if the contents match none of the versions of the block
+func (block *Block) GetStoredVersion(contents []uint8) (*Block, bool) {
+	chksum := checksum.CalculateChecksum(contents)
+	if chksum == block.Checksum {
+		return block, true
+	}
+
+	if block.PendingChecksum == "" || chksum != block.PendingChecksum {
+		return block, false
+	}
+
+	var rotated Block = *block
+	rotated.KeyUUID = block.PendingKeyUUID
+	rotated.FormatVersion = constants.BLOCK_FORMAT_VERSION_BOUND
+	rotated.Checksum = block.PendingChecksum
+	rotated.PendingKeyUUID = uuid.Nil
+	rotated.PendingChecksum = ""
+	return &rotated, true
+}
+
+// getStoredVersion - match the contents of the block downloaded from the disk with its recorded versions
+//
+// params:
+//   - _block dbo.Block: block DBO data (from database)
+//   - contents []uint8: contents of the block downloaded from the disk
+//
+// return type:
+//   - dbo.Block: block DBO describing the stored version
+//   - bool: false if the contents match none of the versions of the block
+func getStoredVersion(_block dbo.Block, contents []uint8) (dbo.Block, bool) {
+	chksum := checksum.CalculateChecksum(contents)
+	if chksum == _block.Checksum {
+		return _block, true
+	}
+
+	if _block.PendingChecksum == "" || chksum != _block.PendingChecksum {
+		return _block, false
+	}
+
+	_block.KeyUUID = _block.PendingKeyUUID
+	_block.FormatVersion = constants.BLOCK_FORMAT_VERSION_BOUND
+	_block.Checksum = _block.PendingChecksum
+	_block.PendingKeyUUID = uuid.Nil
+	_block.PendingChecksum = ""
+	return _block, true
+}
+
+// GetStoredSize - get the number of bytes of the block passed to the disk
+//
+// Blocks saved before compression was introduced do not record their
+// compressed size, they are stored raw.
+//
+// return type:
+//   - int: size of the block after compression (before encryption)
+func (block *Block) GetStoredSize() int {
+	if block.CompressedSize == 0 {
+		return block.Size
+	}
+
+	return block.CompressedSize
+}
+
 // NewBlock - create new block model based on provided data
 //
 // This function creates block model used internally by backend based on
@@ -68,7 +215,70 @@ func NewBlockFromDBO(_block *dbo.Block) *Block {
 		Disk:     nil,
 		Size:     _block.Size,
 		Checksum: _block.Checksum,
+		RemoteID: _block.RemoteID,
 		Status:   0,
 		Order:    _block.Order,
+
+		Compression:    _block.Compression,
+		CompressedSize: _block.CompressedSize,
+		KeyUUID:        _block.KeyUUID,
+		FormatVersion:  _block.FormatVersion,
+		ContentHash:    _block.ContentHash,
+
+		PendingKeyUUID:  _block.PendingKeyUUID,
+		PendingChecksum: _block.PendingChecksum,
+	}
+}
+
+// NewBlockMetadataFromDBO - create api call for background operation on the block
+//
+// This function prepares api call used by background jobs (e.g. disk replacement)
+// which transfer blocks outside of any user request.
+//
+// params:
+//   - _block dbo.Block: block DBO data (from database)
+//
+// return type:
+//   - *apicalls.BlockMetadata: api call of the block
+func NewBlockMetadataFromDBO(_block dbo.Block) *apicalls.BlockMetadata {
+	// Prepare test context
+	writer := httptest.NewRecorder()
+	_ctx, _ := gin.CreateTestContext(writer)
+
+	// Prepare apicall metadata
+	var status int
+	var contents []uint8 = make([]uint8, 0)
+	var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
+	blockMetadata.Ctx = _ctx
+	blockMetadata.FileUUID = _block.FileUUID
+	blockMetadata.Content = &contents
+	blockMetadata.UUID = _block.UUID
+	blockMetadata.Size = int64(NewBlockFromDBO(&_block).GetStoredSize())
+	blockMetadata.Checksum = _block.Checksum
+	blockMetadata.PendingChecksum = _block.PendingChecksum
+	blockMetadata.RemoteID = _block.RemoteID
+	blockMetadata.Status = &status
+	blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
+	}
+
+	return blockMetadata
+}
+
+// UpdateBlockRemoteID - save the remote ID of the block found on the disk
+//
+// The ID is saved in the database only if the block is stored directly
+// on the disk. Replicas and shards of blocks stored on virtual disks
+// are not cached.
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the block
+//   - diskUUID uuid.UUID: UUID of the disk on which the block was found
+//   - remoteID string: provider ID of the file storing the block
+func UpdateBlockRemoteID(blockMetadata *apicalls.BlockMetadata, diskUUID uuid.UUID, remoteID string) {
+	blockMetadata.RemoteID = remoteID
+
+	err := db.DB.DatabaseHandle.Model(&dbo.Block{}).Where("uuid = ? AND disk_uuid = ?", blockMetadata.UUID, diskUUID).Update("remote_id", remoteID).Error
+	if err != nil {
+		logger.Logger.Warning("block", "Could not save the remote ID of the block: ", blockMetadata.UUID.String(), ": ", err.Error(), ".")
 	}
 }
@@ -0,0 +1,86 @@
+package credentials
+
+import (
+	"dcfs/apicalls"
+	"dcfs/requests"
+	"dcfs/util/azblob"
+	"dcfs/util/logger"
+	"encoding/json"
+)
+
+type AzureCredentials struct {
+	ConnectionString string `json:"connectionString"`
+	ServiceURL       string `json:"serviceURL"`
+	SASToken         string `json:"sasToken"`
+	Container        string `json:"container"`
+	Prefix           string `json:"prefix"`
+}
+
+// Authenticate - create Azure Blob client using saved credentials
+//
+// The connection string takes precedence over the service URL
+// with the SAS token.
+//
+// params:
+//   - md *apicalls.CredentialsAuthenticateMetadata: not used
+//
+// return type:
+//   - *azblob.Client: Azure Blob client object, nil if the credentials are invalid
+func (credentials *AzureCredentials) Authenticate(md *apicalls.CredentialsAuthenticateMetadata) interface{} {
+	var cs *azblob.ConnectionString = &azblob.ConnectionString{ServiceURL: credentials.ServiceURL, SASToken: credentials.SASToken}
+	var err error
+
+	if credentials.ConnectionString != "" {
+		cs, err = azblob.ParseConnectionString(credentials.ConnectionString)
+		if err != nil {
+			logger.Logger.Error("credentials", "Cannot parse the Azure connection string: ", err.Error(), ".")
+			return nil
+		}
+	}
+
+	client, err := azblob.NewClient(cs, credentials.Container)
+	if err != nil {
+		logger.Logger.Error("credentials", "Cannot create the Azure Blob client: ", err.Error(), ".")
+		return nil
+	}
+
+	return client
+}
+
+// ToString - convert credentials to JSON string
+//
+// return type:
+//   - string: JSON credential string
+func (credentials *AzureCredentials) ToString() string {
+	ret, _ := json.Marshal(credentials)
+	return string(ret)
+}
+
+// GetPath - get blob name prefix from credentials
+//
+// return type:
+//   - string: blob name prefix
+func (credentials *AzureCredentials) GetPath() string {
+	return credentials.Prefix
+}
+
+// NewAzureCredentials - create new Azure Blob credentials object based on JSON credential string
+//
+// params:
+//   - cred string: JSON credential string
+//
+// return type:
+//   - *AzureCredentials: created credentials object
+func NewAzureCredentials(cred string) *AzureCredentials {
+	var _credentials *requests.AzureCredentials = requests.StringToAzureCredentials(cred)
+
+	credentials := AzureCredentials{
+		ConnectionString: _credentials.ConnectionString,
+		ServiceURL:       _credentials.ServiceURL,
+		SASToken:         _credentials.SASToken,
+		Container:        _credentials.Container,
+		Prefix:           _credentials.Prefix,
+	}
+
+	return &credentials
+}
@@ -1,12 +1,21 @@
 package credentials
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"dcfs/apicalls"
+	"dcfs/constants"
 	"dcfs/requests"
 	"dcfs/util/logger"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jlaffaye/ftp"
+	"net/textproto"
+	"strings"
 	"time"
 )
 
@@ -16,37 +25,104 @@ type FTPCredentials struct {
 	Host     string `json:"host"`
 	Port     string `json:"port"`
 	Path     string `json:"path"`
+
+	TLSMode        string `json:"tlsMode,omitempty"`
+	TLSCA          string `json:"tlsCA,omitempty"`
+	TLSFingerprint string `json:"tlsFingerprint,omitempty"`
+}
+
+// FTPConnection - authenticated FTP client connection
+type FTPConnection struct {
+	*ftp.ServerConn
+}
+
+// IsAlive - check if the server still responds on the connection
+func (c *FTPConnection) IsAlive() bool {
+	return c.NoOp() == nil
+}
+
+// Close - log out and close the connection
+func (c *FTPConnection) Close() error {
+	return c.Quit()
 }
 
+// errFingerprintMismatch - returned by the TLS handshake when the server certificate is not the pinned one
+var errFingerprintMismatch = errors.New("server certificate does not match the pinned fingerprint")
+
 // Authenticate - authenticate to remote server using saved credentials
 //
 // params:
-//   - md *apicalls.CredentialsAuthenticateMetadata: not used
+//   - md *apicalls.CredentialsAuthenticateMetadata: optional, used to pass the request context
 //
 // return type:
-//   - *FTPCredentials: FTP client object
+//   - *FTPConnection: FTP client object
 func (credentials *FTPCredentials) Authenticate(md *apicalls.CredentialsAuthenticateMetadata) interface{} {
+	var ctx context.Context = context.Background()
+	if md != nil && md.Ctx != nil {
+		ctx = md.Ctx
+	}
+
+	conn, _ := credentials.Connect(ctx)
+	if conn == nil {
+		return nil
+	}
+
+	return conn
+}
+
+// Connect - connect and login to the FTP server, negotiating TLS if it is enabled
+//
+// params:
+//   - ctx context.Context: context of the connection
+//
+// return type:
+//   - *FTPConnection: FTP client object, nil if the connection failed
+//   - string: constants.SUCCESS, constants.REMOTE_TLS_HANDSHAKE_FAILED or constants.REMOTE_CANNOT_AUTHENTICATE
+func (credentials *FTPCredentials) Connect(ctx context.Context) (*FTPConnection, string) {
 	logger.Logger.Debug("credentials", "Connecting to ", credentials.Host, "...")
 
 	// Prepare FTP server address
 	addr := fmt.Sprintf("%s:%s", credentials.Host, credentials.Port)
 
+	options := []ftp.DialOption{ftp.DialWithTimeout(5 * time.Second), ftp.DialWithContext(ctx)}
+	if credentials.isTLS() {
+		tlsConfig, err := credentials.getTLSConfig()
+		if err != nil {
+			logger.Logger.Error("credentials", "Invalid TLS configuration of the FTP disk: ", err.Error())
+			return nil, constants.REMOTE_TLS_HANDSHAKE_FAILED
+		}
+
+		if credentials.TLSMode == constants.FTP_TLS_MODE_IMPLICIT {
+			options = append(options, ftp.DialWithTLS(tlsConfig))
+		} else {
+			options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+		}
+	}
+
 	// Connect to FTP server
-	conn, err := ftp.Dial(addr, ftp.DialWithTimeout(5*time.Second))
+	conn, err := ftp.Dial(addr, options...)
 	if err != nil {
 		logger.Logger.Error("credentials", "Failed to connect to the FTP server: ", addr, ". Got an error: ", err.Error(), ". ")
-		return nil
+
+		// The server refused the AUTH TLS command
+		var protocolError *textproto.Error
+		if credentials.TLSMode == constants.FTP_TLS_MODE_EXPLICIT && errors.As(err, &protocolError) && protocolError.Code >= 500 {
+			return nil, constants.REMOTE_TLS_HANDSHAKE_FAILED
+		}
+
+		return nil, credentials.getErrorCode(err)
 	}
 
-	// Login to FTP server
+	// Login to FTP server; with explicit TLS the handshake is performed on the first command
 	err = conn.Login(credentials.Login, credentials.Password)
 	if err != nil {
 		logger.Logger.Error("credentials", "Unable to login into the FTP disk, got an error: ", err.Error())
-		return nil
+		_ = conn.Quit()
+		return nil, credentials.getErrorCode(err)
 	}
 
 	logger.Logger.Debug("credentials", "Connected to: ", credentials.Host)
-	return conn
+	return &FTPConnection{ServerConn: conn}, constants.SUCCESS
 }
 
 // ToString - convert credentials to JSON string
@@ -66,6 +142,92 @@ func (credentials *FTPCredentials) GetPath() string {
 	return credentials.Path
 }
 
+// isTLS - check if the connection should be encrypted
+func (credentials *FTPCredentials) isTLS() bool {
+	return credentials.TLSMode == constants.FTP_TLS_MODE_EXPLICIT || credentials.TLSMode == constants.FTP_TLS_MODE_IMPLICIT
+}
+
+// getTLSConfig - prepare TLS configuration used by the control and data connections
+//
+// return type:
+//   - *tls.Config: TLS configuration
+//   - error: error if the CA bundle or the fingerprint are invalid
+func (credentials *FTPCredentials) getTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: credentials.Host,
+		MinVersion: tls.VersionTLS12,
+
+		// Servers commonly require the data connections to resume the control connection session
+		ClientSessionCache: tls.NewLRUClientSessionCache(4),
+	}
+
+	if credentials.TLSCA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(credentials.TLSCA)) {
+			return nil, errors.New("CA bundle does not contain any PEM encoded certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if credentials.TLSFingerprint != "" {
+		fingerprint, err := hex.DecodeString(strings.ReplaceAll(credentials.TLSFingerprint, ":", ""))
+		if err != nil || len(fingerprint) != sha256.Size {
+			return nil, errors.New("fingerprint is not a hex encoded SHA-256 digest")
+		}
+
+		// The pinned certificate replaces the chain verification, so self-signed certificates can be used
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errFingerprintMismatch
+			}
+
+			digest := sha256.Sum256(state.PeerCertificates[0].Raw)
+			if string(digest[:]) != string(fingerprint) {
+				return errFingerprintMismatch
+			}
+
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// getErrorCode - map the connection error to the completion code
+//
+// params:
+//   - err error: connection error
+//
+// return type:
+//   - string: constants.REMOTE_TLS_HANDSHAKE_FAILED if TLS could not be negotiated,
+//     constants.REMOTE_CANNOT_AUTHENTICATE otherwise
+func (credentials *FTPCredentials) getErrorCode(err error) string {
+	if !credentials.isTLS() {
+		return constants.REMOTE_CANNOT_AUTHENTICATE
+	}
+
+	var recordHeaderError tls.RecordHeaderError
+	var alertError tls.AlertError
+	var verificationError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, errFingerprintMismatch),
+		errors.As(err, &recordHeaderError),
+		errors.As(err, &alertError),
+		errors.As(err, &verificationError),
+		errors.As(err, &unknownAuthorityError),
+		errors.As(err, &hostnameError),
+		errors.As(err, &certificateInvalidError):
+		return constants.REMOTE_TLS_HANDSHAKE_FAILED
+	}
+
+	return constants.REMOTE_CANNOT_AUTHENTICATE
+}
+
 // NewFTPCredentials - create new FTP credentials object based on JSON credential string
 //
 // params:
@@ -82,6 +244,10 @@ func NewFTPCredentials(cred string) *FTPCredentials {
 		Host:     _credentials.Host,
 		Port:     _credentials.Port,
 		Path:     _credentials.Path,
+
+		TLSMode:        _credentials.TLSMode,
+		TLSCA:          _credentials.TLSCA,
+		TLSFingerprint: _credentials.TLSFingerprint,
 	}
 
 	return &credentials
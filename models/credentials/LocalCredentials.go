@@ -0,0 +1,130 @@
+package credentials
+
+import (
+	"dcfs/apicalls"
+	"dcfs/requests"
+	"dcfs/util/logger"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalDiskRoot - directory of the server containing the directories of the local disks, local disks are disabled if it is empty
+var LocalDiskRoot string = ""
+
+type LocalCredentials struct {
+	Path string `json:"path"`
+
+	OwnerUUID uuid.UUID `json:"-"` // local disks of every user are confined to a separate subdirectory of LocalDiskRoot
+}
+
+// Authenticate - check whether the configured directory is accessible
+//
+// params:
+//   - md *apicalls.CredentialsAuthenticateMetadata: not used
+//
+// return type:
+//   - string: resolved path of the directory, nil if the directory is not accessible
+func (credentials *LocalCredentials) Authenticate(md *apicalls.CredentialsAuthenticateMetadata) interface{} {
+	directory, err := credentials.resolveDirectory()
+	if err != nil {
+		logger.Logger.Error("credentials", "Cannot access the local directory: ", credentials.Path, ". Got an error: ", err.Error(), ".")
+		return nil
+	}
+
+	info, err := os.Stat(directory)
+	if err != nil {
+		logger.Logger.Error("credentials", "Cannot access the local directory: ", credentials.Path, ". Got an error: ", err.Error(), ".")
+		return nil
+	}
+
+	if !info.IsDir() {
+		logger.Logger.Error("credentials", "The local path: ", credentials.Path, " is not a directory.")
+		return nil
+	}
+
+	return directory
+}
+
+// ToString - convert credentials to JSON string
+//
+// return type:
+//   - string: JSON credential string
+func (credentials *LocalCredentials) ToString() string {
+	ret, _ := json.Marshal(credentials)
+	return string(ret)
+}
+
+// GetPath - get local directory path from credentials
+//
+// return type:
+//   - string: local directory path
+func (credentials *LocalCredentials) GetPath() string {
+	return credentials.Path
+}
+
+// NewLocalCredentials - create new local credentials object based on JSON credential string
+//
+// params:
+//   - cred string: JSON credential string
+//
+// return type:
+//   - *LocalCredentials: created credentials object
+func NewLocalCredentials(cred string) *LocalCredentials {
+	var _credentials *requests.FTPCredentials = requests.StringToFTPCredentials(cred)
+
+	credentials := LocalCredentials{
+		Path: _credentials.Path,
+	}
+
+	return &credentials
+}
+
+// resolveDirectory - resolve the directory of the disk within the directory of its owner
+//
+// Relative paths are resolved against the directory of the owner, which is created if it
+// does not exist. Symbolic links are followed, so that they cannot point outside of it.
+//
+// return type:
+//   - string: absolute path of the directory
+//   - error: error if the local disks are disabled or the path is outside of the directory of the owner
+func (credentials *LocalCredentials) resolveDirectory() (string, error) {
+	if LocalDiskRoot == "" {
+		return "", errors.New("local disks are disabled, the local disk root is not configured")
+	}
+
+	root, err := filepath.Abs(filepath.Join(LocalDiskRoot, credentials.OwnerUUID.String()))
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(root, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	var directory string = filepath.Clean(credentials.Path)
+	if !filepath.IsAbs(directory) {
+		directory = filepath.Join(root, directory)
+	}
+
+	directory, err = filepath.EvalSymlinks(directory)
+	if err != nil {
+		return "", err
+	}
+
+	relative, err := filepath.Rel(root, directory)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", errors.New("the local path is outside of the directory of the owner")
+	}
+
+	return directory, nil
+}
@@ -6,6 +6,7 @@ import (
 	"dcfs/db/dbo"
 	"dcfs/requests"
 	"dcfs/util/logger"
+	"dcfs/util/sealing"
 	"encoding/json"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
@@ -14,7 +15,8 @@ import (
 )
 
 type OauthCredentials struct {
-	Token *oauth2.Token
+	Token    *oauth2.Token
+	FolderID string
 }
 
 // Authenticate - authenticate to remote server using saved credentials
@@ -43,20 +45,38 @@ func (credentials *OauthCredentials) performOperation(operator func(token *oauth
 	operator(credentials.Token)
 
 	db.DB.DatabaseHandle.Where("uuid = ?", diskUUID.String()).First(&_disk)
-	if _disk.Credentials != credentials.ToString() {
-		_disk.Credentials = credentials.ToString()
+	if saved, err := sealing.Unseal(_disk.Credentials); err != nil || saved != credentials.ToString() {
+		sealed, err := sealing.Seal(credentials.ToString())
+		if err != nil {
+			logger.Logger.Error("credentials", "Could not seal the updated OAuth tokens of the disk: ", diskUUID.String(), ".")
+			return
+		}
+
+		_disk.Credentials = sealed
 		db.DB.DatabaseHandle.Save(&_disk)
 	}
 
 	logger.Logger.Debug("credentials", "Successfully performed an operation on an OAuth credentials object and updated the tokens in the db.")
 }
 
+// SetFolderID - cache the ID of the remote folder storing the blocks and save it in the DB
+//
+// params:
+//   - folderID string: ID of the remote folder
+//   - diskUUID uuid.UUID: UUID of the disk owning the credentials
+func (credentials *OauthCredentials) SetFolderID(folderID string, diskUUID uuid.UUID) {
+	credentials.performOperation(
+		func(token *oauth2.Token) {
+			credentials.FolderID = folderID
+		}, diskUUID)
+}
+
 // ToString - convert credentials to JSON string
 //
 // return type:
 //   - string: JSON credential string
 func (credentials *OauthCredentials) ToString() string {
-	var _cred *requests.OAuthCredentials = &requests.OAuthCredentials{AccessToken: credentials.Token.AccessToken, RefreshToken: credentials.Token.RefreshToken}
+	var _cred *requests.OAuthCredentials = &requests.OAuthCredentials{AccessToken: credentials.Token.AccessToken, RefreshToken: credentials.Token.RefreshToken, FolderID: credentials.FolderID}
 	str, _ := json.Marshal(_cred)
 
 	return string(str)
@@ -79,6 +99,7 @@ func NewOauthCredentials(cred string) *OauthCredentials {
 	var credentials *OauthCredentials = &OauthCredentials{}
 
 	credentials.Token = &oauth2.Token{AccessToken: _credentials.AccessToken, RefreshToken: _credentials.RefreshToken}
+	credentials.FolderID = _credentials.FolderID
 
 	// Invalidate token
 	credentials.Token.Expiry = time.Now()
@@ -0,0 +1,79 @@
+package credentials
+
+import (
+	"dcfs/apicalls"
+	"dcfs/requests"
+	"dcfs/util/logger"
+	"dcfs/util/s3"
+	"encoding/json"
+)
+
+type S3Credentials struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// Authenticate - create S3 client using saved credentials
+//
+// params:
+//   - md *apicalls.CredentialsAuthenticateMetadata: not used
+//
+// return type:
+//   - *s3.Client: S3 client object, nil if the endpoint is invalid
+func (credentials *S3Credentials) Authenticate(md *apicalls.CredentialsAuthenticateMetadata) interface{} {
+	client, err := s3.NewClient(credentials.Endpoint, credentials.Region, credentials.Bucket, credentials.AccessKey, credentials.SecretKey)
+	if err != nil {
+		logger.Logger.Error("credentials", "Cannot create the S3 client for the endpoint: ", credentials.Endpoint, ". Got an error: ", err.Error(), ".")
+		return nil
+	}
+
+	return client
+}
+
+// ToString - convert credentials to JSON string
+//
+// return type:
+//   - string: JSON credential string
+func (credentials *S3Credentials) ToString() string {
+	ret, _ := json.Marshal(credentials)
+	return string(ret)
+}
+
+// GetPath - get object key prefix from credentials
+//
+// return type:
+//   - string: object key prefix
+func (credentials *S3Credentials) GetPath() string {
+	return credentials.Prefix
+}
+
+// NewS3Credentials - create new S3 credentials object based on JSON credential string
+//
+// params:
+//   - cred string: JSON credential string
+//
+// return type:
+//   - *S3Credentials: created credentials object
+func NewS3Credentials(cred string) *S3Credentials {
+	var _credentials *requests.S3Credentials = requests.StringToS3Credentials(cred)
+
+	credentials := S3Credentials{
+		Endpoint:  _credentials.Endpoint,
+		Region:    _credentials.Region,
+		Bucket:    _credentials.Bucket,
+		Prefix:    _credentials.Prefix,
+		AccessKey: _credentials.AccessKey,
+		SecretKey: _credentials.SecretKey,
+	}
+
+	// Most S3 compatible servers accept the default AWS region
+	if credentials.Region == "" {
+		credentials.Region = "us-east-1"
+	}
+
+	return &credentials
+}
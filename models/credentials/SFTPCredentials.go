@@ -2,8 +2,16 @@ package credentials
 
 import (
 	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/requests"
 	"dcfs/util/logger"
+	"dcfs/util/sealing"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"net"
 	"os"
 	"time"
@@ -16,20 +24,74 @@ import (
 type SFTPCredentials struct {
 	FTPCredentials
 
+	PrivateKey string `json:"privateKey,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	HostKey    string `json:"hostKey,omitempty"`
+}
+
+// SFTPConnection - SFTP client together with the SSH connection it runs on
+type SFTPConnection struct {
+	*sftp.Client
 	SSHConnection *ssh.Client
 }
 
+// IsAlive - check if the server still responds on the connection
+func (c *SFTPConnection) IsAlive() bool {
+	_, err := c.Getwd()
+	return err == nil
+}
+
+// Close - close the SFTP session and the SSH connection
+func (c *SFTPConnection) Close() error {
+	_ = c.Client.Close()
+	return c.SSHConnection.Close()
+}
+
+// errHostKeyMismatch - returned by the SSH handshake when the host key differs from the trusted one
+var errHostKeyMismatch = errors.New("host key does not match the trusted fingerprint")
+
 // Authenticate - authenticate to remote server using saved credentials
 //
 // params:
-//   - md *apicalls.CredentialsAuthenticateMetadata: not used
+//   - md *apicalls.CredentialsAuthenticateMetadata: optional, used to store the host key trusted on first use
 //
 // return type:
-//   - *SFTPCredentials: SFTP client object
+//   - *SFTPConnection: SFTP client object
 func (credentials *SFTPCredentials) Authenticate(md *apicalls.CredentialsAuthenticateMetadata) interface{} {
+	client, _ := credentials.Connect(md)
+	if client == nil {
+		return nil
+	}
+
+	return client
+}
+
+// Connect - connect to the SFTP server and verify its host key
+//
+// The fingerprint of the host key is trusted and saved on the first connection,
+// every next connection must present the same key.
+//
+// params:
+//   - md *apicalls.CredentialsAuthenticateMetadata: optional, used to store the host key trusted on first use
+//
+// return type:
+//   - *SFTPConnection: SFTP client object, nil if the connection failed
+//   - string: constants.SUCCESS, constants.REMOTE_HOST_KEY_MISMATCH or constants.REMOTE_CANNOT_AUTHENTICATE
+func (credentials *SFTPCredentials) Connect(md *apicalls.CredentialsAuthenticateMetadata) (*SFTPConnection, string) {
+	var auths []ssh.AuthMethod
+
+	// Use the private key of the disk if provided
+	if credentials.PrivateKey != "" {
+		signer, err := credentials.getSigner()
+		if err != nil {
+			logger.Logger.Error("credentials", "Cannot parse the private key of the SFTP disk: ", err.Error(), ".")
+			return nil, constants.REMOTE_CANNOT_AUTHENTICATE
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+
 	// Try to use $SSH_AUTH_SOCK which contains the path of the unix file socket that the sshd agent uses
 	// for communication with other processes.
-	var auths []ssh.AuthMethod
 	if aconn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
 		auths = append(auths, ssh.PublicKeysCallback(agent.NewClient(aconn).Signers))
 	}
@@ -39,12 +101,21 @@ func (credentials *SFTPCredentials) Authenticate(md *apicalls.CredentialsAuthent
 		auths = append(auths, ssh.Password(credentials.Password))
 	}
 
-	// Prepare client configuration
+	var diskUUID uuid.UUID = uuid.Nil
+	if md != nil {
+		diskUUID = md.DiskUUID
+	}
+
+	// Prepare client configuration; the handshake error does not wrap the callback error, so it is kept aside
+	var hostKeyErr error
 	config := ssh.ClientConfig{
-		User:            credentials.Login,
-		Auth:            auths,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         30 * time.Second,
+		User: credentials.Login,
+		Auth: auths,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyErr = credentials.verifyHostKey(key, diskUUID)
+			return hostKeyErr
+		},
+		Timeout: 30 * time.Second,
 	}
 
 	// Prepare SFTP server address
@@ -53,19 +124,22 @@ func (credentials *SFTPCredentials) Authenticate(md *apicalls.CredentialsAuthent
 	// Connect to SFTP server
 	conn, err := ssh.Dial("tcp", addr, &config)
 	if err != nil {
-		logger.Logger.Error("credentials", "Failed to connect to: ", credentials.Host, " to authenticate an SSH operation.")
-		return nil
+		logger.Logger.Error("credentials", "Failed to connect to: ", credentials.Host, " to authenticate an SSH operation: ", err.Error(), ".")
+		if errors.Is(hostKeyErr, errHostKeyMismatch) {
+			return nil, constants.REMOTE_HOST_KEY_MISMATCH
+		}
+		return nil, constants.REMOTE_CANNOT_AUTHENTICATE
 	}
 
 	// Create new SFTP client
 	sftpClient, err := sftp.NewClient(conn)
 	if err != nil {
 		logger.Logger.Error("credentials", "Could not create a new SFTP server instance.")
-		return nil
+		_ = conn.Close()
+		return nil, constants.REMOTE_CANNOT_AUTHENTICATE
 	}
 
-	credentials.SSHConnection = conn
-	return sftpClient
+	return &SFTPConnection{Client: sftpClient, SSHConnection: conn}, constants.SUCCESS
 }
 
 // ToString - convert credentials to JSON string
@@ -73,7 +147,8 @@ func (credentials *SFTPCredentials) Authenticate(md *apicalls.CredentialsAuthent
 // return type:
 //   - string: JSON credential string
 func (credentials *SFTPCredentials) ToString() string {
-	return credentials.FTPCredentials.ToString()
+	ret, _ := json.Marshal(credentials)
+	return string(ret)
 }
 
 // GetPath - get remote path from credentials
@@ -84,6 +159,51 @@ func (credentials *SFTPCredentials) GetPath() string {
 	return credentials.Path
 }
 
+// getSigner - parse the private key, decrypting it with the passphrase if provided
+func (credentials *SFTPCredentials) getSigner() (ssh.Signer, error) {
+	if credentials.Passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase([]byte(credentials.PrivateKey), []byte(credentials.Passphrase))
+	}
+
+	return ssh.ParsePrivateKey([]byte(credentials.PrivateKey))
+}
+
+// verifyHostKey - compare the host key with the trusted fingerprint, trust the key on the first connection
+//
+// params:
+//   - key ssh.PublicKey: host key presented by the server
+//   - diskUUID uuid.UUID: UUID of the disk whose credentials are updated with the trusted fingerprint
+//
+// return type:
+//   - error: errHostKeyMismatch if the key differs from the trusted one
+func (credentials *SFTPCredentials) verifyHostKey(key ssh.PublicKey, diskUUID uuid.UUID) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	if credentials.HostKey != "" {
+		if credentials.HostKey != fingerprint {
+			logger.Logger.Error("credentials", "Host key of: ", credentials.Host, " changed from: ", credentials.HostKey, " to: ", fingerprint, ".")
+			return errHostKeyMismatch
+		}
+
+		return nil
+	}
+
+	credentials.HostKey = fingerprint
+	logger.Logger.Warning("credentials", "Trusting the host key: ", fingerprint, " of: ", credentials.Host, " on first use.")
+
+	// Disks which are not saved yet store the fingerprint together with the rest of the credentials
+	if diskUUID != uuid.Nil {
+		sealed, err := sealing.Seal(credentials.ToString())
+		if err != nil {
+			logger.Logger.Error("credentials", "Could not seal the trusted host key of: ", credentials.Host, ".")
+			return nil
+		}
+
+		db.DB.DatabaseHandle.Model(&dbo.Disk{}).Where("uuid = ?", diskUUID.String()).Update("credentials", sealed)
+	}
+
+	return nil
+}
+
 // NewSFTPCredentials - create new SFTP credentials object based on JSON credential string
 //
 // params:
@@ -92,8 +212,13 @@ func (credentials *SFTPCredentials) GetPath() string {
 // return type:
 //   - *SFTPCredentials: created credentials object
 func NewSFTPCredentials(cred string) *SFTPCredentials {
+	var _credentials *requests.FTPCredentials = requests.StringToFTPCredentials(cred)
+
 	credentials := SFTPCredentials{
 		FTPCredentials: *NewFTPCredentials(cred),
+		PrivateKey:     _credentials.PrivateKey,
+		Passphrase:     _credentials.Passphrase,
+		HostKey:        _credentials.HostKey,
 	}
 
 	return &credentials
@@ -0,0 +1,79 @@
+package credentials
+
+import (
+	"dcfs/apicalls"
+	"dcfs/requests"
+	"dcfs/util/logger"
+	"dcfs/util/webdav"
+	"encoding/json"
+	"strings"
+)
+
+type WebDAVCredentials struct {
+	URL      string `json:"url"`
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Path     string `json:"path"`
+}
+
+// Authenticate - create WebDAV client for the base path using saved credentials
+//
+// The authentication scheme (basic or digest) is negotiated with
+// the server during the first request.
+//
+// params:
+//   - md *apicalls.CredentialsAuthenticateMetadata: not used
+//
+// return type:
+//   - *webdav.Client: WebDAV client object, nil if the URL is invalid
+func (credentials *WebDAVCredentials) Authenticate(md *apicalls.CredentialsAuthenticateMetadata) interface{} {
+	baseURL := strings.TrimSuffix(credentials.URL, "/")
+	if p := strings.Trim(credentials.Path, "/"); p != "" {
+		baseURL += "/" + p
+	}
+
+	client, err := webdav.NewClient(baseURL, credentials.Login, credentials.Password)
+	if err != nil {
+		logger.Logger.Error("credentials", "Cannot create the WebDAV client for the URL: ", credentials.URL, ". Got an error: ", err.Error(), ".")
+		return nil
+	}
+
+	return client
+}
+
+// ToString - convert credentials to JSON string
+//
+// return type:
+//   - string: JSON credential string
+func (credentials *WebDAVCredentials) ToString() string {
+	ret, _ := json.Marshal(credentials)
+	return string(ret)
+}
+
+// GetPath - get base path from credentials
+//
+// return type:
+//   - string: base path
+func (credentials *WebDAVCredentials) GetPath() string {
+	return credentials.Path
+}
+
+// NewWebDAVCredentials - create new WebDAV credentials object based on JSON credential string
+//
+// params:
+//   - cred string: JSON credential string
+//
+// return type:
+//   - *WebDAVCredentials: created credentials object
+func NewWebDAVCredentials(cred string) *WebDAVCredentials {
+	var _credentials *requests.WebDAVCredentials = requests.StringToWebDAVCredentials(cred)
+
+	credentials := WebDAVCredentials{
+		URL:      _credentials.URL,
+		Login:    _credentials.Login,
+		Password: _credentials.Password,
+		Path:     _credentials.Path,
+	}
+
+	return &credentials
+}
@@ -7,10 +7,13 @@ import (
 	"dcfs/db/dbo"
 	"dcfs/models/credentials"
 	"dcfs/util/logger"
+	"dcfs/util/sealing"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http/httptest"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -45,6 +48,8 @@ type Disk interface {
 	GetIsVirtualFlag() bool
 	SetVirtualDiskUUID(uuid uuid.UUID)
 	GetVirtualDiskUUID() uuid.UUID
+	SetIsSpareFlag(isSpare bool)
+	GetIsSpareFlag() bool
 
 	GetProviderSpace() (uint64, uint64, string)
 	SetTotalSpace(quota uint64)
@@ -70,6 +75,21 @@ type VirtualDisk interface {
 	ReplaceDisk(oldDisk Disk, newDisk Disk, blocks []dbo.Block) string
 }
 
+type ResyncableDisk interface {
+	ResyncBlock(block dbo.Block, disk Disk) string
+}
+
+type ScrubbableDisk interface {
+	ScrubBlock(block dbo.Block) ScrubResult
+}
+
+type ScrubResult struct {
+	Code        string      // constants.SUCCESS if a valid copy of the block is available
+	FaultyDisks []uuid.UUID // disks storing invalid or unreadable copy of the block
+	Repaired    bool        // all invalid copies of the block were replaced with the valid ones
+	ContentHash string      // hash of the plain contents of the block, empty if they were not verified
+}
+
 type CreateDiskMetadata struct {
 	Disk   *dbo.Disk
 	Volume *Volume
@@ -78,21 +98,28 @@ type CreateDiskMetadata struct {
 // CreateDisk - create new disk model based on provided metadata
 //
 // This function creates disk model used internally by backend based on
-// provided metadata.
+// provided metadata. Credentials sealed in the db are decrypted here.
 //
 // params:
 //   - cdm CreateDiskMetadata: disk data
 //
 // return type:
-//   - models.Disk: created disk model, nil if provider is invalid
+//   - models.Disk: created disk model, nil if provider is invalid or credentials cannot be unsealed
 func CreateDisk(cdm CreateDiskMetadata) Disk {
 	if DiskTypesRegistry[cdm.Disk.Provider.Type] == nil || cdm.Disk.Provider.Type < 0 {
 		return nil
 	}
+
+	diskCredentials, err := sealing.Unseal(cdm.Disk.Credentials)
+	if err != nil {
+		logger.Logger.Error("disk", "Could not unseal the credentials of the disk: ", cdm.Disk.UUID.String(), ".")
+		return nil
+	}
+
 	var disk Disk = DiskTypesRegistry[cdm.Disk.Provider.Type]()
 
 	disk.SetVolume(cdm.Volume)
-	disk.CreateCredentials(cdm.Disk.Credentials)
+	disk.CreateCredentials(diskCredentials)
 	disk.SetUUID(cdm.Disk.UUID)
 	disk.SetName(cdm.Disk.Name)
 	disk.SetUsedSpace(cdm.Disk.UsedSpace)
@@ -100,6 +127,7 @@ func CreateDisk(cdm CreateDiskMetadata) Disk {
 	disk.SetCreationTime(cdm.Disk.CreatedAt)
 	disk.SetIsVirtualFlag(cdm.Disk.IsVirtual)
 	disk.SetVirtualDiskUUID(cdm.Disk.VirtualDiskUUID)
+	disk.SetIsSpareFlag(cdm.Disk.IsSpare)
 	cdm.Volume.AddDisk(disk.GetUUID(), disk)
 
 	logger.Logger.Debug("disk", "Successfully created a new disk.")
@@ -255,3 +283,41 @@ func MeasureDiskThroughput(d Disk) int {
 	logger.Logger.Debug("disk", "Disk ", d.GetName(), " has throughput of ", strconv.Itoa(throughput), "(upload: ", strconv.FormatInt(uploadTime.Milliseconds(), 10), " ms, download: ", strconv.FormatInt(downloadTime.Milliseconds(), 10), " ms).")
 	return throughput
 }
+
+// TransferBlocks - run the transfer of every block with a fixed number of workers
+//
+// At most constants.DISK_REPLACEMENT_WORKERS blocks are transferred at once, so that
+// replacing a large disk does not open a connection for every block.
+//
+// params:
+//   - blocks []dbo.Block: blocks to be transferred
+//   - transfer func(block dbo.Block) bool: transfer of a single block, false if it failed
+//
+// return type:
+//   - int: number of blocks which failed to be transferred
+func TransferBlocks(blocks []dbo.Block, transfer func(block dbo.Block) bool) int {
+	var waitGroup sync.WaitGroup
+	var failedBlocks int32 = 0
+	var queue = make(chan dbo.Block)
+
+	waitGroup.Add(constants.DISK_REPLACEMENT_WORKERS)
+	for i := 0; i < constants.DISK_REPLACEMENT_WORKERS; i++ {
+		go func() {
+			defer waitGroup.Done()
+
+			for block := range queue {
+				if !transfer(block) {
+					atomic.AddInt32(&failedBlocks, 1)
+				}
+			}
+		}()
+	}
+
+	for _, block := range blocks {
+		queue <- block
+	}
+	close(queue)
+	waitGroup.Wait()
+
+	return int(failedBlocks)
+}
@@ -25,6 +25,7 @@ type AbstractDisk struct {
 
 	IsVirtual       bool
 	VirtualDiskUUID uuid.UUID
+	IsSpare         bool
 
 	Size      uint64
 	UsedSpace uint64
@@ -115,6 +116,14 @@ func (d *AbstractDisk) GetIsVirtualFlag() bool {
 	return d.IsVirtual
 }
 
+func (d *AbstractDisk) SetIsSpareFlag(isSpare bool) {
+	d.IsSpare = isSpare
+}
+
+func (d *AbstractDisk) GetIsSpareFlag() bool {
+	return d.IsSpare
+}
+
 func (d *AbstractDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
 	d.VirtualDiskUUID = uuid
 }
@@ -192,6 +201,7 @@ func (d *AbstractDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, vo
 		IsVirtual:              d.IsVirtual,
 		FreeSpace:              d.Size - d.UsedSpace,
 		VirtualDiskUUID:        d.VirtualDiskUUID,
+		IsSpare:                d.IsSpare,
 		User:                   user,
 		Volume:                 volume,
 		Provider:               provider,
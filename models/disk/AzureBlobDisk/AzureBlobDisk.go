@@ -0,0 +1,321 @@
+package AzureBlobDisk
+
+import (
+	"context"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/azblob"
+	"dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AzureBlobDisk struct {
+	abstractDisk AbstractDisk.AbstractDisk
+}
+
+/* Mandatory Disk interface implementations */
+
+func (d *AzureBlobDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the Azure Blob client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create Azure Blob client")
+	}
+
+	var client *azblob.Client = _client.(*azblob.Client)
+	var err error
+
+	// Large blocks are staged in blocks and committed with the block list
+	if len(*blockMetadata.Content) > constants.AZURE_SIZE_LIMIT {
+		err = d.uploadStaged(client, blockMetadata)
+	} else {
+		err = client.PutBlob(getContext(blockMetadata), d.getBlobName(blockMetadata.UUID), *blockMetadata.Content)
+	}
+	if err != nil {
+		logger.Logger.Error("disk", "Failed to upload block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to upload block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+	logger.Logger.Debug("disk", "Successfully uploaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *AzureBlobDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the Azure Blob client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create Azure Blob client")
+	}
+
+	var client *azblob.Client = _client.(*azblob.Client)
+
+	// Download the object
+	buff, err := client.GetBlob(getContext(blockMetadata), d.getBlobName(blockMetadata.UUID))
+	if azblob.IsNotFound(err) {
+		logger.Logger.Error("disk", "Cannot find the block: ", blockMetadata.UUID.String(), " in the container.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Cannot find the block in the container:", err.Error())
+	} else if err != nil {
+		logger.Logger.Error("disk", "Failed to download block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to download block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.Content = &buff
+	blockMetadata.Size = int64(len(buff))
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully downloaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *AzureBlobDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the Azure Blob client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create Azure Blob client")
+	}
+
+	var client *azblob.Client = _client.(*azblob.Client)
+
+	// Remove the object
+	err := client.DeleteBlob(getContext(blockMetadata), d.getBlobName(blockMetadata.UUID))
+	if err != nil {
+		logger.Logger.Error("disk", "Failed to remove block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to remove block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully removed the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *AzureBlobDisk) SetVolume(volume *models.Volume) {
+	d.abstractDisk.SetVolume(volume)
+}
+
+func (d *AzureBlobDisk) GetVolume() *models.Volume {
+	return d.abstractDisk.GetVolume()
+}
+
+func (d *AzureBlobDisk) SetName(name string) {
+	d.abstractDisk.SetName(name)
+}
+
+func (d *AzureBlobDisk) GetName() string {
+	return d.abstractDisk.GetName()
+}
+
+func (d *AzureBlobDisk) SetUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetUUID(uuid)
+}
+
+func (d *AzureBlobDisk) GetUUID() uuid.UUID {
+	return d.abstractDisk.GetUUID()
+}
+
+func (d *AzureBlobDisk) GetCredentials() credentials.Credentials {
+	return d.abstractDisk.GetCredentials()
+}
+
+func (d *AzureBlobDisk) SetCreationTime(creationTime time.Time) {
+	d.abstractDisk.SetCreationTime(creationTime)
+}
+
+func (d *AzureBlobDisk) GetCreationTime() time.Time {
+	return d.abstractDisk.GetCreationTime()
+}
+
+func (d *AzureBlobDisk) SetCredentials(credentials credentials.Credentials) {
+	d.abstractDisk.SetCredentials(credentials)
+}
+
+func (d *AzureBlobDisk) CreateCredentials(c string) {
+	d.abstractDisk.Credentials = credentials.NewAzureCredentials(c)
+}
+
+func (d *AzureBlobDisk) GetProviderUUID() uuid.UUID {
+	return d.abstractDisk.GetProvider(constants.PROVIDER_TYPE_AZURE)
+}
+
+func (d *AzureBlobDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
+	return d.abstractDisk.GetDiskDBO(userUUID, providerUUID, volumeUUID)
+}
+
+func (d *AzureBlobDisk) SetIsVirtualFlag(isVirtual bool) {
+	d.abstractDisk.SetIsVirtualFlag(isVirtual)
+}
+
+func (d *AzureBlobDisk) GetIsVirtualFlag() bool {
+	return d.abstractDisk.GetIsVirtualFlag()
+}
+
+func (d *AzureBlobDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *AzureBlobDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
+func (d *AzureBlobDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetVirtualDiskUUID(uuid)
+}
+
+func (d *AzureBlobDisk) GetVirtualDiskUUID() uuid.UUID {
+	return d.abstractDisk.GetVirtualDiskUUID()
+}
+
+func (d *AzureBlobDisk) GetProviderSpace() (uint64, uint64, string) {
+	// Containers do not have a quota which could be retrieved
+	return 0, 0, constants.OPERATION_NOT_SUPPORTED
+}
+
+func (d *AzureBlobDisk) SetTotalSpace(quota uint64) {
+	d.abstractDisk.SetTotalSpace(quota)
+}
+
+func (d *AzureBlobDisk) GetTotalSpace() uint64 {
+	return d.abstractDisk.GetTotalSpace()
+}
+
+func (d *AzureBlobDisk) SetUsedSpace(usage uint64) {
+	d.abstractDisk.SetUsedSpace(usage)
+}
+
+func (d *AzureBlobDisk) GetUsedSpace() uint64 {
+	return d.abstractDisk.GetUsedSpace()
+}
+
+func (d *AzureBlobDisk) UpdateUsedSpace(change int64) {
+	d.abstractDisk.UpdateUsedSpace(change)
+}
+
+func (d *AzureBlobDisk) AssignDisk(disk models.Disk) {
+	d.abstractDisk.AssignDisk(disk)
+}
+
+func (d *AzureBlobDisk) GetReadiness() models.DiskReadiness {
+	return d.abstractDisk.DiskReadiness
+}
+
+func (d *AzureBlobDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
+	return d.abstractDisk.GetResponse(_disk, ctx)
+}
+
+/* Azure Blob disk helper methods */
+
+// uploadStaged - upload the block as staged blocks of the block blob
+//
+// Staged blocks which are not committed are removed by the service,
+// so failed uploads do not need to be cleaned up.
+//
+// params:
+//   - client *azblob.Client: Azure Blob client
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block to upload
+//
+// return type:
+//   - error: nil if the block was uploaded
+func (d *AzureBlobDisk) uploadStaged(client *azblob.Client, blockMetadata *apicalls.BlockMetadata) error {
+	ctx := getContext(blockMetadata)
+	name := d.getBlobName(blockMetadata.UUID)
+	content := *blockMetadata.Content
+
+	var blockIDs []string
+	for offset := 0; offset < len(content); offset += constants.AZURE_BLOCK_LIMIT {
+		end := offset + constants.AZURE_BLOCK_LIMIT
+		if end > len(content) {
+			end = len(content)
+		}
+
+		blockID := azblob.BlockID(len(blockIDs))
+		err := client.PutBlock(ctx, name, blockID, content[offset:end])
+		if err != nil {
+			return err
+		}
+
+		logger.Logger.Debug("disk", "block upload: ", blockMetadata.UUID.String(), " progress: ", strconv.Itoa(end), "/", strconv.Itoa(len(content)))
+		blockIDs = append(blockIDs, blockID)
+	}
+
+	return client.PutBlockList(ctx, name, blockIDs)
+}
+
+// getBlobName - get name of the blob storing the block
+//
+// params:
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - string: blob name
+func (d *AzureBlobDisk) getBlobName(blockUUID uuid.UUID) string {
+	return strings.TrimPrefix(path.Join(d.GetCredentials().GetPath(), blockUUID.String()), "/")
+}
+
+// getContext - get context of the request performed for the block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block
+//
+// return type:
+//   - context.Context: request context
+func getContext(blockMetadata *apicalls.BlockMetadata) context.Context {
+	if blockMetadata.Ctx == nil {
+		return context.Background()
+	}
+
+	return blockMetadata.Ctx
+}
+
+/* Factory methods */
+func NewAzureBlobDisk() *AzureBlobDisk {
+	var d *AzureBlobDisk = new(AzureBlobDisk)
+	d.abstractDisk.Disk = d
+	d.abstractDisk.DiskReadiness = models.DiskReadinessRegistry[constants.PROVIDER_TYPE_AZURE](d)
+	return d
+}
+
+func init() {
+	models.DiskTypesRegistry[constants.PROVIDER_TYPE_AZURE] = func() models.Disk { return NewAzureBlobDisk() }
+	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_AZURE] = func(d models.Disk) models.DiskReadiness {
+		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+			logger.Logger.Debug("drive", "Checking readiness for Azure Blob drive: ", d.GetUUID().String(), ".")
+
+			_client := d.GetCredentials().Authenticate(nil)
+			if _client == nil {
+				return false
+			}
+
+			// Container properties call verifies both the credentials and the container
+			err := _client.(*azblob.Client).GetContainerProperties(ctx)
+			if err != nil {
+				logger.Logger.Warning("drive", "Azure Blob container of the drive: ", d.GetUUID().String(), " is not accessible: ", err.Error())
+				return false
+			}
+
+			return true
+		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
+	}
+	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_AZURE] = func() {
+		provider := dbo.Provider{}
+		db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_AZURE).First(&provider)
+		if provider.Type != constants.PROVIDER_TYPE_AZURE {
+			provider.UUID = uuid.New()
+			provider.Type = constants.PROVIDER_TYPE_AZURE
+			provider.Name = "Azure Blob Storage"
+			provider.Logo = "https://cdn.iconscout.com/icon/free/png-256/azure-storage-blob-3521428-2944764.png"
+
+			db.DB.DatabaseHandle.Create(&provider)
+		}
+	}
+}
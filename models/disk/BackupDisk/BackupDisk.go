@@ -12,7 +12,7 @@ import (
 	"dcfs/util/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"net/http/httptest"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -20,67 +20,66 @@ import (
 type BackupDisk struct {
 	abstractDisk AbstractDisk.AbstractDisk
 
-	firstDisk  models.Disk
-	secondDisk models.Disk
+	disks      []models.Disk
+	disksMutex sync.RWMutex // guards the replicas, which may be replaced while the blocks are transferred
 }
 
 /* Mandatory Disk interface methods */
 
 func (d *BackupDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	// TODO: Verify that onedrive doesn't modify the content
-
-	// Create a copy of the block contents
-	contents := &blockMetadata.Content
-
-	// Create a copy of the api call
-	blockMetadata1 := *blockMetadata
-	blockMetadata2 := *blockMetadata
-
-	blockMetadata2.Content = *contents
-
-	var emptyCallback = func(uuid.UUID, *int) {
-	}
-	blockMetadata1.CompleteCallback = emptyCallback
-	blockMetadata2.CompleteCallback = emptyCallback
-
-	var status1 int
-	var status2 int
-	blockMetadata1.Status = &status1
-	blockMetadata2.Status = &status2
+	disks := d.getDisks()
 
 	// Prepare for upload
 	var waitGroup sync.WaitGroup
-	var err1 *apicalls.ErrorWrapper
-	var err2 *apicalls.ErrorWrapper
+	var errs = make([]*apicalls.ErrorWrapper, len(disks))
 
-	waitGroup.Add(2)
+	waitGroup.Add(len(disks))
 
-	// Upload to the first disk
-	go func() {
-		defer waitGroup.Done()
-		err1 = d.firstDisk.Upload(&blockMetadata1)
-	}()
+	// Upload a copy of the block to every replica
+	for i, disk := range disks {
+		go func(i int, disk models.Disk) {
+			defer waitGroup.Done()
 
-	// Upload to the second disk
-	go func() {
-		defer waitGroup.Done()
-		err2 = d.secondDisk.Upload(&blockMetadata2)
-	}()
+			var contents []uint8 = make([]uint8, len(*blockMetadata.Content))
+			copy(contents, *blockMetadata.Content)
+
+			errs[i] = disk.Upload(prepareReplicaMetadata(blockMetadata, &contents))
+		}(i, disk)
+	}
 
 	// Wait for the upload to finish
 	waitGroup.Wait()
 
 	// Check for errors
-	if err1 != nil {
-		logger.Logger.Error("disk", "Cannot upload to the first disk, got an error: ", err1.Error.Error())
+	var failedDisks = make([]models.Disk, 0)
+	for i, err := range errs {
+		if err != nil {
+			logger.Logger.Error("disk", "Cannot upload to the replica ", disks[i].GetUUID().String(), ", got an error: ", err.Error.Error())
+			failedDisks = append(failedDisks, disks[i])
+		}
 	}
 
-	if err2 != nil {
-		logger.Logger.Error("disk", "Cannot upload to the second disk, got an error: ", err2.Error.Error())
+	if len(failedDisks) == len(disks) {
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot upload to any of the backup disks.")
 	}
 
-	if err1 != nil || err2 != nil {
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot upload to at least one of the backup disks.")
+	// Record missing replicas, so that the block can be resynchronized once the disks are ready again
+	if len(failedDisks) > 0 {
+		err := d.markBlockDegraded(blockMetadata, failedDisks)
+		if err != nil {
+			// Without the record the missing replicas would never be resynchronized, so the upload is rejected
+			logger.Logger.Error("disk", "Cannot record missing replicas of the block ", blockMetadata.UUID.String(), ", got an error: ", err.Error())
+
+			for i, disk := range disks {
+				if errs[i] == nil && disk.Remove(prepareReplicaMetadata(blockMetadata, nil)) != nil {
+					logger.Logger.Warning("disk", "Cannot remove the block ", blockMetadata.UUID.String(), " from the replica ", disk.GetUUID().String(), ".")
+				}
+			}
+
+			return apicalls.CreateErrorWrapper(constants.DATABASE_ERROR, "Cannot record missing replicas of the block:", err.Error())
+		}
+
+		logger.Logger.Warning("disk", "Uploaded the block ", blockMetadata.UUID.String(), " in degraded mode, ", strconv.Itoa(len(failedDisks)), " replicas are missing.")
 	}
 
 	// Call the original callback
@@ -91,160 +90,76 @@ func (d *BackupDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.Err
 }
 
 func (d *BackupDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	// Create a copy of the api call
-	blockMetadata1 := *blockMetadata
-	blockMetadata2 := *blockMetadata
-
-	var contents1 = make([]byte, len(*blockMetadata.Content))
-	var contents2 = make([]byte, len(*blockMetadata.Content))
-	blockMetadata2.Content = &contents1
-	blockMetadata2.Content = &contents2
-
-	var emptyCallback = func(uuid.UUID, *int) {
-	}
-	blockMetadata1.CompleteCallback = emptyCallback
-	blockMetadata2.CompleteCallback = emptyCallback
-
-	var status1 int
-	var status2 int
-	blockMetadata1.Status = &status1
-	blockMetadata2.Status = &status2
-
-	// Prepare for download
-	var waitGroup sync.WaitGroup
-	var err1 *apicalls.ErrorWrapper
-	var err2 *apicalls.ErrorWrapper
-	var checksum1 string
-	var checksum2 string
-
-	waitGroup.Add(2)
-
-	// Download from the first disk
-	go func() {
-		defer waitGroup.Done()
-		err1 = d.firstDisk.Download(&blockMetadata1)
-		if err1 == nil {
-			checksum1 = checksum.CalculateChecksum(*blockMetadata1.Content)
-		}
-	}()
-
-	// Download from the second disk
-	go func() {
-		defer waitGroup.Done()
-		err2 = d.secondDisk.Download(&blockMetadata2)
-		if err2 == nil {
-			checksum2 = checksum.CalculateChecksum(*blockMetadata2.Content)
-		}
-	}()
-
-	// Wait for the download to finish
-	waitGroup.Wait()
+	disks := d.getDisks()
 
-	// Check for errors
-	if err1 != nil {
-		logger.Logger.Error("disk", "Cannot download from the first disk, got an error: ", err1.Error.Error())
-	}
-
-	if err2 != nil {
-		logger.Logger.Error("disk", "Cannot download from the second disk, got an error: ", err2.Error.Error())
-	}
+	// Download the block from all replicas
+	contents, checksums, errs := downloadReplicas(blockMetadata, disks)
 
-	// If both disks worked and one of the blocks is corrupted, make attempt to recover
-	if err1 == nil && err2 == nil {
-		d.fixBlock(blockMetadata, *blockMetadata1.Content, *blockMetadata2.Content, checksum1, checksum2)
+	// Select the replica with the correct version of the block
+	index, trusted := voteReplica(blockMetadata, checksums, errs)
+	if index == -1 {
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot download from any of the backup disks.")
 	}
 
-	// Return block
-	if checksum1 == blockMetadata.Checksum || checksum2 == blockMetadata.Checksum {
-		// Return block with the correct checksum if possible
-		if err1 == nil && checksum1 == blockMetadata.Checksum {
-			logger.Logger.Debug("disk", "Successfully downloaded the block from the first disk: ", blockMetadata.UUID.String(), ".")
-			blockMetadata.Content = blockMetadata1.Content
-			blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
-			return nil
-		}
-
-		if err2 == nil && checksum2 == blockMetadata.Checksum {
-			logger.Logger.Debug("disk", "Successfully downloaded the block from the second disk: ", blockMetadata.UUID.String(), ".")
-			blockMetadata.Content = blockMetadata2.Content
-			blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
-			return nil
-		}
+	if trusted {
+		// Make attempt to recover replicas with the corrupted block
+		d.fixBlock(blockMetadata, contents[index], checksums, errs, disks)
+		logger.Logger.Debug("disk", "Successfully downloaded the block from the replica ", disks[index].GetUUID().String(), ": ", blockMetadata.UUID.String(), ".")
 	} else {
 		// Return block with the wrong checksum if one of the disks is available
-		if err1 == nil {
-			logger.Logger.Debug("disk", "Downloaded corrupted block from the first disk: ", blockMetadata.UUID.String(), ".")
-			blockMetadata.Content = blockMetadata1.Content
-			blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
-			return nil
-		}
-
-		if err2 == nil {
-			logger.Logger.Debug("disk", "Downloaded corrupted block from the second disk: ", blockMetadata.UUID.String(), ".")
-			blockMetadata.Content = blockMetadata2.Content
-			blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
-			return nil
-		}
-
-		// Return error if both disks failed
-		if err1 != nil && err2 != nil {
-			return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot download from both of the backup disks.")
-		}
+		logger.Logger.Debug("disk", "Downloaded corrupted block from the replica ", disks[index].GetUUID().String(), ": ", blockMetadata.UUID.String(), ".")
 	}
 
+	blockMetadata.Content = &contents[index]
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
 	return nil
 }
 
 func (d *BackupDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	// Create a copy of the api call
-	blockMetadata1 := *blockMetadata
-	blockMetadata2 := *blockMetadata
+	disks := d.getDisks()
 
-	var emptyCallback = func(uuid.UUID, *int) {
-	}
-	blockMetadata1.CompleteCallback = emptyCallback
-	blockMetadata2.CompleteCallback = emptyCallback
-
-	var status1 int
-	var status2 int
-	blockMetadata1.Status = &status1
-	blockMetadata2.Status = &status2
-
-	// Prepare for download
+	// Prepare for removal
 	var waitGroup sync.WaitGroup
-	var err1 *apicalls.ErrorWrapper
-	var err2 *apicalls.ErrorWrapper
+	var errs = make([]*apicalls.ErrorWrapper, len(disks))
+	var missingDisks = getMissingReplicas(blockMetadata.UUID)
 
-	waitGroup.Add(2)
-
-	// Remove from the first disk
-	go func() {
-		defer waitGroup.Done()
-		err1 = d.firstDisk.Remove(&blockMetadata1)
-	}()
+	// Remove the block from every replica storing it
+	for i, disk := range disks {
+		if missingDisks[disk.GetUUID()] {
+			continue
+		}
 
-	// Remove from the second disk
-	go func() {
-		defer waitGroup.Done()
-		err2 = d.secondDisk.Remove(&blockMetadata2)
-	}()
+		waitGroup.Add(1)
+		go func(i int, disk models.Disk) {
+			defer waitGroup.Done()
+			errs[i] = disk.Remove(prepareReplicaMetadata(blockMetadata, nil))
+		}(i, disk)
+	}
 
 	// Wait for the removal to finish
 	waitGroup.Wait()
 
 	// Check for errors
-	if err1 != nil || err2 != nil {
-		if err1 != nil {
-			logger.Logger.Error("disk", "Cannot remove from the first disk, got an error: ", err1.Error.Error())
-		}
-
-		if err2 != nil {
-			logger.Logger.Error("disk", "Cannot remove from the second disk, got an error: ", err2.Error.Error())
+	var failed bool = false
+	for i, err := range errs {
+		if err != nil {
+			logger.Logger.Error("disk", "Cannot remove from the replica ", disks[i].GetUUID().String(), ", got an error: ", err.Error.Error())
+			failed = true
 		}
+	}
 
+	if failed {
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot remove from at least one of the backup disks.")
 	}
 
+	// Remove records of missing replicas
+	if len(missingDisks) > 0 {
+		err := db.DB.DatabaseHandle.Where("block_uuid = ?", blockMetadata.UUID).Delete(&dbo.DegradedBlock{}).Error
+		if err != nil {
+			logger.Logger.Warning("disk", "Cannot remove records of missing replicas of the block ", blockMetadata.UUID.String(), ", got an error: ", err.Error())
+		}
+	}
+
 	// Call the original callback
 	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
 
@@ -307,6 +222,14 @@ func (d *BackupDisk) GetIsVirtualFlag() bool {
 	return d.abstractDisk.GetIsVirtualFlag()
 }
 
+func (d *BackupDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *BackupDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
 func (d *BackupDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
 	d.abstractDisk.SetVirtualDiskUUID(uuid)
 }
@@ -316,67 +239,93 @@ func (d *BackupDisk) GetVirtualDiskUUID() uuid.UUID {
 }
 
 func (d *BackupDisk) GetProviderSpace() (uint64, uint64, string) {
-	// Retrieve provider space from both disks
-	used1, total1, result1 := d.firstDisk.GetProviderSpace()
-	used2, total2, result2 := d.secondDisk.GetProviderSpace()
-
-	// Return not supported if one of the disks does not support it
-	if result1 == constants.OPERATION_NOT_SUPPORTED || result2 == constants.OPERATION_NOT_SUPPORTED {
-		return 0, 0, constants.OPERATION_NOT_SUPPORTED
-	}
+	disks := d.getDisks()
 
-	// Return error if one of the disks returned an error
-	if result1 != constants.SUCCESS || result2 != constants.SUCCESS {
-		return 0, 0, result1
-	}
-
-	// Return available space in both disks
 	var free uint64
 	var total uint64
+	var errCode string = constants.SUCCESS
 
-	if total1-used1 < total2-used2 {
-		free = total1 - used1
-	} else {
-		free = total2 - used2
+	// Retrieve provider space from all replicas
+	for i, disk := range disks {
+		_used, _total, result := disk.GetProviderSpace()
+
+		// Return not supported if one of the disks does not support it
+		if result == constants.OPERATION_NOT_SUPPORTED {
+			return 0, 0, constants.OPERATION_NOT_SUPPORTED
+		}
+
+		if result != constants.SUCCESS {
+			errCode = result
+			continue
+		}
+
+		if i == 0 || _total-_used < free {
+			free = _total - _used
+		}
+		if i == 0 || _total < total {
+			total = _total
+		}
 	}
 
-	if total1 < total2 {
-		total = total1
-	} else {
-		total = total2
+	// Return error if one of the disks returned an error
+	if errCode != constants.SUCCESS {
+		return 0, 0, errCode
 	}
 
+	// Return space available in all replicas
 	return total - free, total, constants.SUCCESS
 }
 
 func (d *BackupDisk) SetTotalSpace(quota uint64) {
-	d.firstDisk.SetTotalSpace(quota)
-	d.secondDisk.SetTotalSpace(quota)
+	disks := d.getDisks()
+
+	for _, disk := range disks {
+		disk.SetTotalSpace(quota)
+	}
 }
 
 func (d *BackupDisk) GetTotalSpace() uint64 {
-	var space1 = d.firstDisk.GetTotalSpace()
-	var space2 = d.secondDisk.GetTotalSpace()
+	disks := d.getDisks()
 
-	if space1 < space2 {
-		return space1
-	} else {
-		return space2
+	var space uint64
+
+	for i, disk := range disks {
+		if i == 0 || disk.GetTotalSpace() < space {
+			space = disk.GetTotalSpace()
+		}
 	}
+
+	return space
 }
 
 func (d *BackupDisk) SetUsedSpace(usage uint64) {
-	d.firstDisk.SetUsedSpace(usage)
-	d.secondDisk.SetUsedSpace(usage)
+	disks := d.getDisks()
+
+	for _, disk := range disks {
+		disk.SetUsedSpace(usage)
+	}
 }
 
 func (d *BackupDisk) GetUsedSpace() uint64 {
-	return d.firstDisk.GetUsedSpace()
+	disks := d.getDisks()
+
+	var usage uint64
+
+	for _, disk := range disks {
+		if disk.GetUsedSpace() > usage {
+			usage = disk.GetUsedSpace()
+		}
+	}
+
+	return usage
 }
 
 func (d *BackupDisk) UpdateUsedSpace(change int64) {
-	d.firstDisk.UpdateUsedSpace(change)
-	d.secondDisk.UpdateUsedSpace(change)
+	disks := d.getDisks()
+
+	for _, disk := range disks {
+		disk.UpdateUsedSpace(change)
+	}
 }
 
 func (d *BackupDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
@@ -384,38 +333,39 @@ func (d *BackupDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volu
 }
 
 func (d *BackupDisk) AssignDisk(disk models.Disk) {
-	if d.firstDisk == nil {
-		d.firstDisk = disk
-	} else if d.secondDisk == nil {
-		d.secondDisk = disk
-	} else {
-		// If both disks are already assigned, ignore the new disk
-		logger.Logger.Error("disk", "Cannot assign disk to backup disk, both disks are already assigned.")
+	d.disksMutex.Lock()
+	defer d.disksMutex.Unlock()
+
+	if d.GetVolume() != nil && len(d.disks) >= d.GetVolume().GetDisksPerVirtualDisk() {
+		// If all replicas are already assigned, ignore the new disk
+		logger.Logger.Error("disk", "Cannot assign disk to backup disk, all ", strconv.Itoa(len(d.disks)), " replicas are already assigned.")
 		return
 	}
+
+	d.disks = append(d.disks, disk)
 }
 
 func (d *BackupDisk) GetReadiness() models.DiskReadiness {
+	disks := d.getDisks()
+
 	arr := make([]models.DiskReadiness, 0)
 
-	if d.firstDisk != nil {
-		arr = append(arr, d.firstDisk.GetReadiness())
-	}
-	if d.secondDisk != nil {
-		arr = append(arr, d.secondDisk.GetReadiness())
+	for _, disk := range disks {
+		arr = append(arr, disk.GetReadiness())
 	}
 
 	return models.NewVirtualDiskReadiness(arr...)
 }
 
 func (d *BackupDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
-	arr := make([]models.DiskResponse, 0)
+	disks := d.getDisks()
 
-	firstDiskDBO := d.firstDisk.GetDiskDBO(_disk.UserUUID, d.firstDisk.GetProviderUUID(), _disk.VolumeUUID)
-	secondDiskDBO := d.secondDisk.GetDiskDBO(_disk.UserUUID, d.secondDisk.GetProviderUUID(), _disk.VolumeUUID)
+	arr := make([]models.DiskResponse, 0)
 
-	arr = append(arr, *d.firstDisk.GetResponse(&firstDiskDBO, ctx))
-	arr = append(arr, *d.secondDisk.GetResponse(&secondDiskDBO, ctx))
+	for _, disk := range disks {
+		diskDBO := disk.GetDiskDBO(_disk.UserUUID, disk.GetProviderUUID(), _disk.VolumeUUID)
+		arr = append(arr, *disk.GetResponse(&diskDBO, ctx))
+	}
 
 	return &models.DiskResponse{
 		Disk:    *_disk,
@@ -425,149 +375,431 @@ func (d *BackupDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.Disk
 }
 
 func (d *BackupDisk) ReplaceDisk(disk models.Disk, newDisk models.Disk, blocks []dbo.Block) string {
-	var sourceDisk *models.Disk
-	var targetDisk *models.Disk
-	var oldDisk *models.Disk
+	var position int = -1
+	var disks = d.getDisks()
+	var sourceDisks = make([]models.Disk, 0)
 
 	// Check if the old disk is assigned to the backup disk
-	if d.firstDisk == disk {
-		sourceDisk = &d.secondDisk
-		targetDisk = &newDisk
-		oldDisk = &d.firstDisk
-	} else if d.secondDisk == disk {
-		sourceDisk = &d.firstDisk
-		targetDisk = &newDisk
-		oldDisk = &d.secondDisk
-	} else {
-		// If both disks are already assigned, ignore the new disk
+	for i, _disk := range disks {
+		if _disk == disk {
+			position = i
+		} else {
+			sourceDisks = append(sourceDisks, _disk)
+		}
+	}
+
+	if position == -1 {
 		logger.Logger.Error("disk", "Cannot replace disk in backup disk, provided disk is not assigned.")
 		return constants.FS_DISK_MISMATCH
 	}
 
 	// Transfer all blocks to the new disk
-	var waitGroup sync.WaitGroup
-	var taskCompleted bool = true
+	var uploadedBlocks = make([]dbo.Block, 0, len(blocks))
+	var uploadedMutex sync.Mutex
+
+	failedBlocks := models.TransferBlocks(blocks, func(block dbo.Block) bool {
+		// Prepare apicall metadata
+		var blockMetadata *apicalls.BlockMetadata = models.NewBlockMetadataFromDBO(block)
+
+		// Download block from the remaining replicas
+		_contents, checksums, errs := downloadReplicas(blockMetadata, sourceDisks)
+		index, _ := voteReplica(blockMetadata, checksums, errs)
+		if index == -1 {
+			logger.Logger.Error("disk", "Replacement failed: cannot download block ", blockMetadata.UUID.String(), " from any of the remaining replicas.")
+			return false
+		}
 
-	waitGroup.Add(len(blocks))
+		// Upload block to target disk, a failed upload may leave a partial block behind
+		uploadedMutex.Lock()
+		uploadedBlocks = append(uploadedBlocks, block)
+		uploadedMutex.Unlock()
 
-	for _, block := range blocks {
-		go func(block dbo.Block) {
-			defer waitGroup.Done()
+		result := newDisk.Upload(prepareReplicaMetadata(blockMetadata, &_contents[index]))
+		if result != nil {
+			logger.Logger.Error("disk", "Replacement failed: cannot upload block ", blockMetadata.UUID.String(), " to new disk ", newDisk.GetUUID().String(), ".")
+			return false
+		}
 
-			// Prepare test context
-			writer := httptest.NewRecorder()
-			_ctx, _ := gin.CreateTestContext(writer)
-
-			// Prepare apicall metadata
-			var status int
-			var contents []uint8 = make([]uint8, block.Size)
-			var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
-			blockMetadata.Ctx = _ctx
-			blockMetadata.FileUUID = block.FileUUID
-			blockMetadata.Content = &contents
-			blockMetadata.UUID = block.UUID
-			blockMetadata.Size = int64(block.Size)
-			blockMetadata.Status = &status
-			blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
-			}
+		return true
+	})
 
-			// Download block from the source disk
-			result := (*sourceDisk).Download(blockMetadata)
-			if result != nil {
-				logger.Logger.Error("disk", "Replacement failed: cannot download block ", blockMetadata.UUID.String(), " from target disk ", disk.GetUUID().String(), ".")
-				taskCompleted = false
-				return
-			}
+	// Replace disk in backup disk, unless it was replaced in the meantime
+	d.disksMutex.Lock()
+	replaced := failedBlocks == 0 && d.disks[position] == disk
+	if replaced {
+		d.disks[position] = newDisk
+	}
+	d.disksMutex.Unlock()
 
-			// Upload block to target disk
-			result = (*targetDisk).Upload(blockMetadata)
+	if !replaced {
+		// Do not leave orphaned blocks on the new disk
+		models.TransferBlocks(uploadedBlocks, func(block dbo.Block) bool {
+			result := newDisk.Remove(prepareReplicaMetadata(models.NewBlockMetadataFromDBO(block), nil))
 			if result != nil {
-				logger.Logger.Error("disk", "Replacement failed: cannot download block ", blockMetadata.UUID.String(), " to new disk ", disk.GetUUID().String(), ".")
-				taskCompleted = false
-				return
+				logger.Logger.Warning("disk", "Cannot remove block ", block.UUID.String(), " from the new disk ", newDisk.GetUUID().String(), ".")
 			}
 
-			// Delete block from old disk
-			result = (*oldDisk).Remove(blockMetadata)
-			if result != nil {
-				taskCompleted = false
-				return
-			}
+			return result == nil
+		})
 
-			return
-		}(block)
+		return constants.OPERATION_FAILED
 	}
-	waitGroup.Wait()
 
-	if taskCompleted != true {
-		return constants.OPERATION_FAILED
+	// Delete blocks from old disk, which may be already unavailable
+	models.TransferBlocks(blocks, func(block dbo.Block) bool {
+		result := disk.Remove(prepareReplicaMetadata(models.NewBlockMetadataFromDBO(block), nil))
+		if result != nil {
+			logger.Logger.Warning("disk", "Cannot remove block ", block.UUID.String(), " from the replaced disk ", disk.GetUUID().String(), ".")
+		}
+
+		return result == nil
+	})
+
+	// All blocks were copied to the new disk, so the missing replicas of the old disk are no longer relevant
+	err := db.DB.DatabaseHandle.Where("disk_uuid = ?", disk.GetUUID()).Delete(&dbo.DegradedBlock{}).Error
+	if err != nil {
+		logger.Logger.Warning("disk", "Cannot remove records of missing replicas of the disk ", disk.GetUUID().String(), ", got an error: ", err.Error())
+	}
+
+	return constants.SUCCESS
+}
+
+// ResyncBlock - copy block written in degraded mode to the replica which is missing it
+//
+// params:
+//   - block dbo.Block: block to be copied
+//   - disk models.Disk: replica which is missing the block
+//
+// return type:
+//   - string: constants.SUCCESS if the block was copied, error code otherwise
+func (d *BackupDisk) ResyncBlock(block dbo.Block, disk models.Disk) string {
+	disks := d.getDisks()
+
+	var found bool = false
+	var sourceDisks = make([]models.Disk, 0)
+
+	// Check if the disk is assigned to the backup disk
+	for _, _disk := range disks {
+		if _disk.GetUUID() == disk.GetUUID() {
+			found = true
+		} else {
+			sourceDisks = append(sourceDisks, _disk)
+		}
+	}
+
+	if !found {
+		logger.Logger.Error("disk", "Cannot resync block in backup disk, provided disk is not assigned.")
+		return constants.FS_DISK_MISMATCH
+	}
+
+	// Download block from the remaining replicas
+	var blockMetadata *apicalls.BlockMetadata = models.NewBlockMetadataFromDBO(block)
+
+	contents, checksums, errs := downloadReplicas(blockMetadata, sourceDisks)
+	index, trusted := voteReplica(blockMetadata, checksums, errs)
+	if index == -1 {
+		logger.Logger.Error("disk", "Resync failed: cannot download block ", block.UUID.String(), " from any of the replicas.")
+		return constants.REMOTE_FAILED_JOB
 	}
 
-	// Replace disk in backup disk
-	if d.firstDisk == disk {
-		d.firstDisk = newDisk
-	} else if d.secondDisk == disk {
-		d.secondDisk = newDisk
+	// Do not propagate a corrupted block
+	if !trusted {
+		logger.Logger.Error("disk", "Resync failed: all replicas of the block ", block.UUID.String(), " are corrupted.")
+		return constants.REMOTE_CORRUPTED_BLOCKS
 	}
 
+	// Upload block to the lagging replica
+	result := disk.Upload(prepareReplicaMetadata(blockMetadata, &contents[index]))
+	if result != nil {
+		logger.Logger.Error("disk", "Resync failed: cannot upload block ", block.UUID.String(), " to disk ", disk.GetUUID().String(), ".")
+		return constants.REMOTE_FAILED_JOB
+	}
+
+	logger.Logger.Debug("disk", "Resynchronized the block ", block.UUID.String(), " to disk ", disk.GetUUID().String(), ".")
 	return constants.SUCCESS
 }
 
-func (d *BackupDisk) fixBlock(blockMetadata *apicalls.BlockMetadata, firstContents []uint8, secondContents []uint8, firstChecksum string, secondChecksum string) {
-	var err *apicalls.ErrorWrapper
-	var targetDisk models.Disk
+// ScrubBlock - verify all replicas of the block and repair the invalid ones
+//
+// Replicas missing the block because of the degraded upload are skipped, as they
+// are going to be resynchronized.
+//
+// params:
+//   - block dbo.Block: block to be verified
+//
+// return type:
+//   - models.ScrubResult: result of the verification
+func (d *BackupDisk) ScrubBlock(block dbo.Block) models.ScrubResult {
+	disks := d.getDisks()
+
+	var result models.ScrubResult
+	var blockMetadata *apicalls.BlockMetadata = models.NewBlockMetadataFromDBO(block)
+	var missingDisks = getMissingReplicas(block.UUID)
+
+	// Download the block from all replicas
+	contents, checksums, errs := downloadReplicas(blockMetadata, disks)
+	index, trusted := voteReplica(blockMetadata, checksums, errs)
+
+	// Find replicas storing unreadable or invalid copy of the block
+	var faultyReplicas = make([]int, 0)
+	for i, disk := range disks {
+		if missingDisks[disk.GetUUID()] {
+			continue
+		}
 
-	// Verify if the action should be performed
-	if firstChecksum == blockMetadata.Checksum && secondChecksum == blockMetadata.Checksum {
-		// Both disks have the correct block
-		return
+		if index == -1 || errs[i] != nil || !trusted || checksums[i] != checksums[index] {
+			faultyReplicas = append(faultyReplicas, i)
+			result.FaultyDisks = append(result.FaultyDisks, disk.GetUUID())
+		}
 	}
-	if firstChecksum != blockMetadata.Checksum && secondChecksum != blockMetadata.Checksum {
-		// Unrecoverable, both disks have the wrong block
-		logger.Logger.Error("disk", "RAID10 recovery failed: both disks have corrupted block ", blockMetadata.UUID.String(), ".")
-		return
+
+	if index == -1 {
+		logger.Logger.Error("disk", "Scrub failed: cannot download block ", block.UUID.String(), " from any of the replicas.")
+		result.Code = constants.REMOTE_FAILED_JOB
+		return result
 	}
 
-	// Create copy of the api call
-	_blockMetadata := *blockMetadata
-	_blockMetadata.Content = nil
+	if !trusted {
+		logger.Logger.Error("disk", "Scrub failed: all replicas of the block ", block.UUID.String(), " are corrupted.")
+		result.Code = constants.REMOTE_CORRUPTED_BLOCKS
+		return result
+	}
 
-	_blockMetadata.CompleteCallback = func(uuid.UUID, *int) {
+	// Repair faulty replicas
+	result.Code = constants.SUCCESS
+	result.Repaired = len(faultyReplicas) > 0
+	for _, i := range faultyReplicas {
+		if !repairReplica(blockMetadata, contents[index], disks[i], errs[i] == nil) {
+			result.Repaired = false
+		}
 	}
 
-	var status int
-	_blockMetadata.Status = &status
+	return result
+}
 
-	var contents []uint8
+// getDisks - retrieve the replicas assigned to the backup disk
+//
+// return type:
+//   - []models.Disk: copy of the replicas, safe to use while a replica is replaced
+func (d *BackupDisk) getDisks() []models.Disk {
+	d.disksMutex.RLock()
+	defer d.disksMutex.RUnlock()
 
-	// Set the target disk to disk with the wrong version of the block
-	if firstChecksum == blockMetadata.Checksum {
-		targetDisk = d.secondDisk
-		contents = make([]uint8, len(firstContents))
-		copy(contents, firstContents)
-	} else {
-		targetDisk = d.firstDisk
-		contents = make([]uint8, len(secondContents))
-		copy(contents, secondContents)
+	return append([]models.Disk(nil), d.disks...)
+}
+
+// markBlockDegraded - record replicas which are missing the uploaded block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the uploaded block
+//   - disks []models.Disk: replicas which failed to store the block
+//
+// return type:
+//   - error: database operation error
+func (d *BackupDisk) markBlockDegraded(blockMetadata *apicalls.BlockMetadata, disks []models.Disk) error {
+	var degradedBlocks = make([]dbo.DegradedBlock, 0)
+
+	for _, disk := range disks {
+		var degradedBlock *dbo.DegradedBlock = dbo.NewDegradedBlock()
+		degradedBlock.BlockUUID = blockMetadata.UUID
+		degradedBlock.FileUUID = blockMetadata.FileUUID
+		degradedBlock.VolumeUUID = d.GetVolume().UUID
+		degradedBlock.VirtualDiskUUID = d.GetUUID()
+		degradedBlock.DiskUUID = disk.GetUUID()
+
+		degradedBlocks = append(degradedBlocks, *degradedBlock)
+	}
+
+	return db.DB.DatabaseHandle.Create(&degradedBlocks).Error
+}
+
+// fixBlock - replace corrupted copies of the block with the correct one
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the block
+//   - validContents []uint8: correct contents of the block
+//   - checksums []string: checksums of the block downloaded from consecutive replicas
+//   - errs []*apicalls.ErrorWrapper: download errors of consecutive replicas
+//   - disks []models.Disk: replicas the block was downloaded from
+func (d *BackupDisk) fixBlock(blockMetadata *apicalls.BlockMetadata, validContents []uint8, checksums []string, errs []*apicalls.ErrorWrapper, disks []models.Disk) {
+	var validChecksum string = checksum.CalculateChecksum(validContents)
+
+	for i, targetDisk := range disks {
+		// Verify if the action should be performed
+		if errs[i] != nil || checksums[i] == validChecksum {
+			continue
+		}
+
+		repairReplica(blockMetadata, validContents, targetDisk, true)
 	}
+}
+
+/* Replica helper functions */
 
+// repairReplica - replace copy of the block stored on the replica with the correct one
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the block
+//   - validContents []uint8: correct contents of the block
+//   - targetDisk models.Disk: replica storing invalid copy of the block
+//   - isStored bool: true if the replica stores a copy of the block which must be removed first
+//
+// return type:
+//   - bool: true if the replica was repaired
+func repairReplica(blockMetadata *apicalls.BlockMetadata, validContents []uint8, targetDisk models.Disk, isStored bool) bool {
 	// Remove the invalid block from the target disk
-	err = targetDisk.Remove(&_blockMetadata)
-	if err != nil {
-		logger.Logger.Error("disk", "RAID10 recovery failed: cannot remove invalid block ", blockMetadata.UUID.String(), "from disk ", targetDisk.GetUUID().String(), ".")
-		return
+	err := targetDisk.Remove(prepareReplicaMetadata(blockMetadata, nil))
+	if err != nil && isStored {
+		logger.Logger.Error("disk", "RAID1 recovery failed: cannot remove invalid block ", blockMetadata.UUID.String(), " from disk ", targetDisk.GetUUID().String(), ".")
+		return false
 	}
 
 	// Upload the correct block to the target disk
-	_blockMetadata.Content = &contents
-	err = targetDisk.Upload(&_blockMetadata)
+	var contents []uint8 = make([]uint8, len(validContents))
+	copy(contents, validContents)
+
+	err = targetDisk.Upload(prepareReplicaMetadata(blockMetadata, &contents))
 	if err != nil {
-		logger.Logger.Error("disk", "RAID10 recovery failed: cannot upload valid block ", blockMetadata.UUID.String(), "to disk ", targetDisk.GetUUID().String(), ".")
-		return
+		logger.Logger.Error("disk", "RAID1 recovery failed: cannot upload valid block ", blockMetadata.UUID.String(), " to disk ", targetDisk.GetUUID().String(), ".")
+		return false
+	}
+
+	logger.Logger.Warning("disk", "RAID1 recovery completed: disk ", targetDisk.GetUUID().String(), " now has the correct block ", blockMetadata.UUID.String(), ".")
+	return true
+}
+
+// getMissingReplicas - retrieve replicas which are missing the block
+//
+// params:
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - map[uuid.UUID]bool: set of UUIDs of the disks missing the block
+func getMissingReplicas(blockUUID uuid.UUID) map[uuid.UUID]bool {
+	var degradedBlocks []dbo.DegradedBlock
+	var missingDisks = make(map[uuid.UUID]bool)
+
+	err := db.DB.DatabaseHandle.Where("block_uuid = ?", blockUUID).Find(&degradedBlocks).Error
+	if err != nil {
+		logger.Logger.Warning("disk", "Cannot retrieve missing replicas of the block ", blockUUID.String(), ", got an error: ", err.Error())
+		return missingDisks
+	}
+
+	for _, degradedBlock := range degradedBlocks {
+		missingDisks[degradedBlock.DiskUUID] = true
+	}
+
+	return missingDisks
+}
+
+// prepareReplicaMetadata - create copy of the api call for operation on a single replica
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the block
+//   - contents *[]uint8: contents of the block, nil for an empty buffer
+//
+// return type:
+//   - *apicalls.BlockMetadata: api call for the single replica
+func prepareReplicaMetadata(blockMetadata *apicalls.BlockMetadata, contents *[]uint8) *apicalls.BlockMetadata {
+	if contents == nil {
+		var _contents []uint8 = make([]uint8, 0)
+		contents = &_contents
+	}
+
+	var status int
+	var _blockMetadata apicalls.BlockMetadata = *blockMetadata
+	_blockMetadata.Content = contents
+	_blockMetadata.RemoteID = "" // remote IDs are cached only for blocks stored on a single disk
+	_blockMetadata.Status = &status
+	_blockMetadata.CompleteCallback = func(uuid.UUID, *int) {
 	}
 
-	logger.Logger.Warning("disk", "RAID10 recovery completed: disk ", targetDisk.GetUUID().String(), " now has the correct block ", blockMetadata.UUID.String(), ".")
-	return
+	return &_blockMetadata
+}
+
+// downloadReplicas - download the block from all provided replicas
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the block
+//   - replicas []models.Disk: disks to download the block from
+//
+// return type:
+//   - [][]uint8: contents of the block downloaded from consecutive replicas
+//   - []string: checksums of the downloaded contents
+//   - []*apicalls.ErrorWrapper: download errors of consecutive replicas
+func downloadReplicas(blockMetadata *apicalls.BlockMetadata, replicas []models.Disk) ([][]uint8, []string, []*apicalls.ErrorWrapper) {
+	var waitGroup sync.WaitGroup
+	var contents = make([][]uint8, len(replicas))
+	var checksums = make([]string, len(replicas))
+	var errs = make([]*apicalls.ErrorWrapper, len(replicas))
+
+	waitGroup.Add(len(replicas))
+
+	for i, disk := range replicas {
+		go func(i int, disk models.Disk) {
+			defer waitGroup.Done()
+
+			_blockMetadata := prepareReplicaMetadata(blockMetadata, nil)
+			errs[i] = disk.Download(_blockMetadata)
+			if errs[i] != nil {
+				logger.Logger.Error("disk", "Cannot download from the replica ", disk.GetUUID().String(), ", got an error: ", errs[i].Error.Error())
+				return
+			}
+
+			contents[i] = *_blockMetadata.Content
+			checksums[i] = checksum.CalculateChecksum(contents[i])
+		}(i, disk)
+	}
+
+	// Wait for the download to finish
+	waitGroup.Wait()
+
+	return contents, checksums, errs
+}
+
+// voteReplica - select replica with the correct version of the block
+//
+// A replica matching the checksum stored in the database, or the pending checksum
+// of the block re-encrypted by an interrupted key rotation, is always trusted.
+// Otherwise, the version shared by the majority of the downloaded replicas
+// is trusted. If there is no majority, the most common version is returned
+// without being trusted.
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the block with the checksums stored in the database
+//   - checksums []string: checksums of the block downloaded from consecutive replicas
+//   - errs []*apicalls.ErrorWrapper: download errors of consecutive replicas
+//
+// return type:
+//   - int: index of the selected replica, -1 if none of the replicas is available
+//   - bool: true if the selected version is trusted to be correct, false otherwise
+func voteReplica(blockMetadata *apicalls.BlockMetadata, checksums []string, errs []*apicalls.ErrorWrapper) (int, bool) {
+	var expectedChecksum string = blockMetadata.Checksum
+	var votes = make(map[string]int)
+	var downloaded int = 0
+	var index int = -1
+
+	for i := range checksums {
+		if errs[i] != nil {
+			continue
+		}
+
+		if expectedChecksum != "" && blockMetadata.MatchesChecksum(checksums[i]) {
+			return i, true
+		}
+
+		downloaded++
+		votes[checksums[i]]++
+		if index == -1 || votes[checksums[i]] > votes[checksums[index]] {
+			index = i
+		}
+	}
+
+	if index == -1 {
+		return -1, false
+	}
+
+	return index, expectedChecksum == "" && 2*votes[checksums[index]] > downloaded
 }
 
 /* Factory methods */
@@ -580,6 +812,8 @@ func NewBackupDisk() *BackupDisk {
 	d.abstractDisk.IsVirtual = true
 	d.abstractDisk.VirtualDiskUUID = uuid.Nil
 
+	d.disks = make([]models.Disk, 0)
+
 	return d
 }
 
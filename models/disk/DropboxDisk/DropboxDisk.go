@@ -0,0 +1,388 @@
+package DropboxDisk
+
+import (
+	"context"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CredentialsPath - path of the Dropbox app client secret file
+var CredentialsPath string = "./models/disk/DropboxDisk/credentials.json"
+
+type DropboxDisk struct {
+	abstractDisk AbstractDisk.AbstractDisk
+}
+
+/* Mandatory Disk interface implementations */
+
+func (d *DropboxDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	client := d.authenticate(getContext(blockMetadata))
+	if client == nil {
+		logger.Logger.Error("disk", "Could not connect to the Dropbox server.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "could not connect to the remote server")
+	}
+
+	var err error
+	var path string = d.getBlockPath(blockMetadata.UUID)
+	var content []uint8 = *blockMetadata.Content
+
+	if len(content) <= constants.DROPBOX_SIZE_LIMIT {
+		// fast upload
+		err = client.upload(path, content)
+	} else {
+		// upload session
+		var sessionID string
+		sessionID, err = client.startUploadSession(content[:constants.DROPBOX_UPLOAD_LIMIT])
+
+		offset := constants.DROPBOX_UPLOAD_LIMIT
+		for err == nil && len(content)-offset > constants.DROPBOX_UPLOAD_LIMIT {
+			err = client.appendUploadSession(dropboxCursor{SessionID: sessionID, Offset: offset}, content[offset:offset+constants.DROPBOX_UPLOAD_LIMIT])
+			offset += constants.DROPBOX_UPLOAD_LIMIT
+
+			logger.Logger.Debug("disk", "block upload: ", blockMetadata.UUID.String(), " progress: ", strconv.Itoa(offset), "/", strconv.Itoa(len(content)))
+		}
+
+		if err == nil {
+			err = client.finishUploadSession(dropboxCursor{SessionID: sessionID, Offset: offset}, path, content[offset:])
+		}
+	}
+	if err != nil {
+		logger.Logger.Error("disk", "Could not send file: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not send file:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+	logger.Logger.Debug("disk", "Successfully uploaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *DropboxDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	client := d.authenticate(getContext(blockMetadata))
+	if client == nil {
+		logger.Logger.Error("disk", "Could not connect to the Dropbox server.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "could not connect to the remote server")
+	}
+
+	buff, err := client.download(d.getBlockPath(blockMetadata.UUID))
+	if isNotFound(err) {
+		buff, err = client.download(getLegacyBlockPath(blockMetadata.UUID))
+	}
+	if isNotFound(err) {
+		logger.Logger.Error("disk", "Could not find the file: ", blockMetadata.UUID.String(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Could not find file:", err.Error())
+	} else if err != nil {
+		logger.Logger.Error("disk", "Could not download file: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not download file:", err.Error())
+	}
+
+	blockMetadata.Content = &buff
+	blockMetadata.Size = int64(len(buff))
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully downloaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *DropboxDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	client := d.authenticate(getContext(blockMetadata))
+	if client == nil {
+		logger.Logger.Error("disk", "Could not connect to the Dropbox server.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "could not connect to the remote server")
+	}
+
+	err := client.delete(d.getBlockPath(blockMetadata.UUID))
+	if isNotFound(err) {
+		err = client.delete(getLegacyBlockPath(blockMetadata.UUID))
+	}
+	if err != nil {
+		logger.Logger.Error("disk", "Could not remove file: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not remove file:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully removed the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *DropboxDisk) SetVolume(volume *models.Volume) {
+	d.abstractDisk.SetVolume(volume)
+}
+
+func (d *DropboxDisk) GetVolume() *models.Volume {
+	return d.abstractDisk.GetVolume()
+}
+
+func (d *DropboxDisk) SetName(name string) {
+	d.abstractDisk.SetName(name)
+}
+
+func (d *DropboxDisk) GetName() string {
+	return d.abstractDisk.GetName()
+}
+
+func (d *DropboxDisk) SetUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetUUID(uuid)
+}
+
+func (d *DropboxDisk) GetUUID() uuid.UUID {
+	return d.abstractDisk.GetUUID()
+}
+
+func (d *DropboxDisk) GetCredentials() credentials.Credentials {
+	return d.abstractDisk.GetCredentials()
+}
+
+func (d *DropboxDisk) SetCreationTime(creationTime time.Time) {
+	d.abstractDisk.SetCreationTime(creationTime)
+}
+
+func (d *DropboxDisk) GetCreationTime() time.Time {
+	return d.abstractDisk.GetCreationTime()
+}
+
+func (d *DropboxDisk) SetCredentials(credentials credentials.Credentials) {
+	d.abstractDisk.SetCredentials(credentials)
+}
+
+func (d *DropboxDisk) CreateCredentials(c string) {
+	d.abstractDisk.Credentials = credentials.NewOauthCredentials(c)
+}
+
+func (d *DropboxDisk) GetProviderUUID() uuid.UUID {
+	return d.abstractDisk.GetProvider(constants.PROVIDER_TYPE_DROPBOX)
+}
+
+func (d *DropboxDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
+	return d.abstractDisk.GetDiskDBO(userUUID, providerUUID, volumeUUID)
+}
+
+func (d *DropboxDisk) SetIsVirtualFlag(isVirtual bool) {
+	d.abstractDisk.SetIsVirtualFlag(isVirtual)
+}
+
+func (d *DropboxDisk) GetIsVirtualFlag() bool {
+	return d.abstractDisk.GetIsVirtualFlag()
+}
+
+func (d *DropboxDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *DropboxDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
+func (d *DropboxDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetVirtualDiskUUID(uuid)
+}
+
+func (d *DropboxDisk) GetVirtualDiskUUID() uuid.UUID {
+	return d.abstractDisk.GetVirtualDiskUUID()
+}
+
+func (d *DropboxDisk) GetProviderSpace() (uint64, uint64, string) {
+	client := d.authenticate(context.Background())
+	if client == nil {
+		logger.Logger.Error("disk", "Could not authenticate to get the remote provider free space.")
+		return 0, 0, constants.REMOTE_CANNOT_AUTHENTICATE
+	}
+
+	// Get the space usage of the account
+	usage, err := client.getSpaceUsage()
+	if err != nil {
+		logger.Logger.Error("disk", "Could not get the stats of a remote provider: ", err.Error())
+		return 0, 0, constants.REMOTE_CANNOT_GET_STATS
+	}
+
+	logger.Logger.Debug("disk", "Successfully obtained the remote provider stats.")
+	return usage.Used, usage.Allocation.Allocated, constants.SUCCESS
+}
+
+func (d *DropboxDisk) SetTotalSpace(quota uint64) {
+	d.abstractDisk.SetTotalSpace(quota)
+}
+
+func (d *DropboxDisk) GetTotalSpace() uint64 {
+	return d.abstractDisk.GetTotalSpace()
+}
+
+func (d *DropboxDisk) SetUsedSpace(usage uint64) {
+	d.abstractDisk.SetUsedSpace(usage)
+}
+
+func (d *DropboxDisk) GetUsedSpace() uint64 {
+	return d.abstractDisk.GetUsedSpace()
+}
+
+func (d *DropboxDisk) UpdateUsedSpace(change int64) {
+	d.abstractDisk.UpdateUsedSpace(change)
+}
+
+func (d *DropboxDisk) AssignDisk(disk models.Disk) {
+	d.abstractDisk.AssignDisk(disk)
+}
+
+func (d *DropboxDisk) GetReadiness() models.DiskReadiness {
+	return d.abstractDisk.DiskReadiness
+}
+
+func (d *DropboxDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
+	_disk.Credentials = ""
+
+	return &models.DiskResponse{
+		Disk:    *_disk,
+		Array:   nil,
+		IsReady: d.GetReadiness().IsReady(ctx),
+	}
+}
+
+/* Mandatory OAuthDisk interface implementations */
+func (d *DropboxDisk) GetConfig() *oauth2.Config {
+	b, err := os.ReadFile(CredentialsPath)
+	if err != nil {
+		logger.Logger.Error("disk", "Unable to read the client secret file: ", err.Error())
+		return nil
+	}
+
+	// Scopes are configured in the Dropbox App Console
+	config, err := google.ConfigFromJSON(b)
+	if err != nil {
+		logger.Logger.Error("disk", "Unable to parse client secret file to config: ", err.Error(), ".")
+		return nil
+	}
+
+	// Dropbox issues the refresh token only for the offline access
+	if !strings.Contains(config.Endpoint.AuthURL, "token_access_type") {
+		config.Endpoint.AuthURL += "?token_access_type=offline"
+	}
+
+	logger.Logger.Debug("disk", "Successfully got config of to the Dropbox provider.")
+	return config
+}
+
+/* Dropbox disk helper methods */
+
+// authenticate - prepare Dropbox client authorized with the disk OAuth token
+//
+// params:
+//   - ctx context.Context: request context
+//
+// return type:
+//   - *dropboxClient: Dropbox client, nil if the disk cannot be authenticated
+func (d *DropboxDisk) authenticate(ctx context.Context) *dropboxClient {
+	config := d.GetConfig()
+	if config == nil {
+		return nil
+	}
+
+	_client := d.GetCredentials().Authenticate(&apicalls.CredentialsAuthenticateMetadata{Ctx: ctx, Config: config, DiskUUID: d.GetUUID()})
+	if _client == nil {
+		return nil
+	}
+
+	return newDropboxClient(ctx, _client.(*http.Client))
+}
+
+// getBlockPath - get path of the file storing the block
+//
+// The Dropbox app is registered with the app folder access,
+// so the path is relative to the app folder. Blocks of every
+// volume are kept in a separate folder, which Dropbox creates
+// on the first upload.
+//
+// params:
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - string: path of the file
+func (d *DropboxDisk) getBlockPath(blockUUID uuid.UUID) string {
+	return "/" + d.GetVolume().UUID.String() + "/" + blockUUID.String()
+}
+
+// getLegacyBlockPath - get path of the file storing the block uploaded before the per-volume folders were introduced
+//
+// params:
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - string: path of the file
+func getLegacyBlockPath(blockUUID uuid.UUID) string {
+	return "/" + blockUUID.String()
+}
+
+// getContext - get context of the request performed for the block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block
+//
+// return type:
+//   - context.Context: request context
+func getContext(blockMetadata *apicalls.BlockMetadata) context.Context {
+	if blockMetadata.Ctx == nil {
+		return context.Background()
+	}
+
+	return blockMetadata.Ctx
+}
+
+/* Factory methods */
+
+func NewDropboxDisk() *DropboxDisk {
+	var d *DropboxDisk = new(DropboxDisk)
+	d.abstractDisk.Disk = d
+	d.abstractDisk.DiskReadiness = models.DiskReadinessRegistry[constants.PROVIDER_TYPE_DROPBOX](d)
+	return d
+}
+
+func init() {
+	models.DiskTypesRegistry[constants.PROVIDER_TYPE_DROPBOX] = func() models.Disk { return NewDropboxDisk() }
+	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_DROPBOX] = func(d models.Disk) models.DiskReadiness {
+		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+			logger.Logger.Debug("drive", "Checking readiness for Dropbox drive: ", d.GetUUID().String(), ".")
+
+			client := d.(*DropboxDisk).authenticate(ctx)
+			if client == nil {
+				return false
+			}
+
+			_, err := client.getSpaceUsage()
+			return err == nil
+		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
+	}
+	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_DROPBOX] = func() {
+		disk := DropboxDisk{}
+		config := disk.GetConfig()
+
+		if config == nil {
+			logger.Logger.Warning("disk", "Could not load configuration for Dropbox Drive")
+			return
+		}
+
+		provider := dbo.Provider{}
+		db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_DROPBOX).First(&provider)
+		if provider.Type != constants.PROVIDER_TYPE_DROPBOX {
+			provider.UUID = uuid.New()
+			provider.Type = constants.PROVIDER_TYPE_DROPBOX
+			provider.Name = "Dropbox"
+			provider.Logo = "https://upload.wikimedia.org/wikipedia/commons/7/78/Dropbox_Icon.svg"
+
+			db.DB.DatabaseHandle.Create(&provider)
+		}
+	}
+}
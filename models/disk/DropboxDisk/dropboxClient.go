@@ -0,0 +1,193 @@
+package DropboxDisk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DropboxAPIURL - base URL of the Dropbox RPC endpoints
+var DropboxAPIURL string = "https://api.dropboxapi.com/2"
+
+// DropboxContentURL - base URL of the Dropbox content endpoints
+var DropboxContentURL string = "https://content.dropboxapi.com/2"
+
+// dropboxClient - client of the Dropbox HTTP API v2 using an authorized HTTP client
+type dropboxClient struct {
+	client *http.Client
+	ctx    context.Context
+}
+
+// dropboxError - error returned by the Dropbox API
+type dropboxError struct {
+	StatusCode   int
+	ErrorSummary string `json:"error_summary"`
+}
+
+type dropboxCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    int    `json:"offset"`
+}
+
+type dropboxCommitInfo struct {
+	Path       string `json:"path"`
+	Mode       string `json:"mode"`
+	Autorename bool   `json:"autorename"`
+	Mute       bool   `json:"mute"`
+}
+
+type dropboxSpaceUsage struct {
+	Used       uint64 `json:"used"`
+	Allocation struct {
+		Tag       string `json:".tag"`
+		Allocated uint64 `json:"allocated"`
+	} `json:"allocation"`
+}
+
+func (e *dropboxError) Error() string {
+	return fmt.Sprintf("Dropbox request failed with status %d: %s", e.StatusCode, e.ErrorSummary)
+}
+
+// isNotFound - check whether the error indicates a missing file
+func isNotFound(err error) bool {
+	dErr, ok := err.(*dropboxError)
+	return ok && dErr.StatusCode == http.StatusConflict && strings.Contains(dErr.ErrorSummary, "not_found")
+}
+
+// upload - upload the file in a single request
+func (c *dropboxClient) upload(path string, content []byte) error {
+	_, err := c.contentRequest("/files/upload", dropboxCommitInfo{Path: path, Mode: "add", Mute: true}, content)
+	return err
+}
+
+// startUploadSession - start the upload session with the first chunk of the file
+func (c *dropboxClient) startUploadSession(content []byte) (string, error) {
+	body, err := c.contentRequest("/files/upload_session/start", map[string]bool{"close": false}, content)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		SessionID string `json:"session_id"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+
+	return result.SessionID, nil
+}
+
+// appendUploadSession - append the chunk of the file to the upload session
+func (c *dropboxClient) appendUploadSession(cursor dropboxCursor, content []byte) error {
+	_, err := c.contentRequest("/files/upload_session/append_v2", map[string]interface{}{"cursor": cursor, "close": false}, content)
+	return err
+}
+
+// finishUploadSession - upload the last chunk of the file and commit it under the path
+func (c *dropboxClient) finishUploadSession(cursor dropboxCursor, path string, content []byte) error {
+	_, err := c.contentRequest("/files/upload_session/finish", map[string]interface{}{
+		"cursor": cursor,
+		"commit": dropboxCommitInfo{Path: path, Mode: "add", Mute: true},
+	}, content)
+	return err
+}
+
+// download - download the file
+func (c *dropboxClient) download(path string) ([]byte, error) {
+	return c.contentRequest("/files/download", map[string]string{"path": path}, nil)
+}
+
+// delete - remove the file
+func (c *dropboxClient) delete(path string) error {
+	_, err := c.rpcRequest("/files/delete_v2", map[string]string{"path": path})
+	return err
+}
+
+// getSpaceUsage - retrieve the space usage of the account
+func (c *dropboxClient) getSpaceUsage() (*dropboxSpaceUsage, error) {
+	body, err := c.rpcRequest("/users/get_space_usage", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage dropboxSpaceUsage
+	if err = json.Unmarshal(body, &usage); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// rpcRequest - send the request to the RPC endpoint with JSON arguments in the body
+func (c *dropboxClient) rpcRequest(endpoint string, arg interface{}) ([]byte, error) {
+	var body io.Reader
+	if arg != nil {
+		b, err := json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, DropboxAPIURL+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if arg != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.send(req)
+}
+
+// contentRequest - send the request to the content endpoint with JSON arguments in the header
+func (c *dropboxClient) contentRequest(endpoint string, arg interface{}, content []byte) ([]byte, error) {
+	b, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, DropboxContentURL+endpoint, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Dropbox-API-Arg", string(b))
+	if content != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	return c.send(req)
+}
+
+// send - send the request and convert error responses
+func (c *dropboxClient) send(req *http.Request) ([]byte, error) {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		dErr := &dropboxError{StatusCode: res.StatusCode}
+		if json.Unmarshal(body, dErr) != nil {
+			dErr.ErrorSummary = strings.TrimSpace(string(body))
+		}
+		return nil, dErr
+	}
+
+	return body, nil
+}
+
+// newDropboxClient - create new Dropbox client
+func newDropboxClient(ctx context.Context, client *http.Client) *dropboxClient {
+	return &dropboxClient{client: client, ctx: ctx}
+}
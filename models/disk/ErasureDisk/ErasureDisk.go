@@ -0,0 +1,981 @@
+package ErasureDisk
+
+import (
+	"bytes"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/checksum"
+	"dcfs/util/logger"
+	"encoding/binary"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/klauspost/reedsolomon"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type ErasureDisk struct {
+	abstractDisk AbstractDisk.AbstractDisk
+
+	disks      []models.Disk
+	disksMutex sync.RWMutex // guards the disks, which may be replaced while the blocks are transferred
+}
+
+/* Mandatory Disk interface methods */
+
+func (d *ErasureDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	disks := d.getDisks()
+
+	encoder, errWrapper := d.getEncoder()
+	if errWrapper != nil {
+		return errWrapper
+	}
+
+	// Split the block into data and parity shards
+	shards, err := d.encodeBlock(encoder, *blockMetadata.Content)
+	if err != nil {
+		logger.Logger.Error("disk", "Cannot encode the block ", blockMetadata.UUID.String(), ", got an error: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot encode the block:", err.Error())
+	}
+
+	// Upload every shard to a separate disk
+	var waitGroup sync.WaitGroup
+	var errs = make([]*apicalls.ErrorWrapper, len(disks))
+
+	waitGroup.Add(len(disks))
+	for i, disk := range disks {
+		go func(i int, disk models.Disk) {
+			defer waitGroup.Done()
+			errs[i] = disk.Upload(d.prepareShardMetadata(blockMetadata, &shards[i]))
+		}(i, disk)
+	}
+
+	// Wait for the upload to finish
+	waitGroup.Wait()
+
+	// Check for errors
+	var failed bool = false
+	for i, err := range errs {
+		if err != nil {
+			logger.Logger.Error("disk", "Cannot upload shard to the disk ", disks[i].GetUUID().String(), ", got an error: ", err.Error.Error())
+			failed = true
+		}
+	}
+
+	if failed {
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot upload to at least one of the erasure coded disks.")
+	}
+
+	// Call the original callback
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully uploaded the block to erasure coded disk: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *ErasureDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	disks := d.getDisks()
+
+	encoder, errWrapper := d.getEncoder()
+	if errWrapper != nil {
+		return errWrapper
+	}
+
+	// Download all available shards
+	shards, holders, size := d.downloadShards(blockMetadata, disks)
+
+	dataShards, _ := d.getShardCount()
+	available := countShards(shards)
+	if available < dataShards {
+		logger.Logger.Error("disk", "Erasure coding recovery failed: not enough shards of the block ", blockMetadata.UUID.String(), " are available.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot download enough shards from the erasure coded disks.")
+	}
+
+	// Decode the block, reconstructing shards of the unavailable or corrupted disks if necessary
+	contents, corrupted, valid := d.recoverBlock(blockMetadata, encoder, shards, size)
+	if valid {
+		// Replace the corrupted shard on its disk
+		if corrupted != -1 {
+			for i, holder := range holders {
+				if holder == corrupted {
+					_shards := make([][]uint8, len(shards))
+					copy(_shards, shards)
+					_shards[corrupted] = nil
+
+					d.fixShard(blockMetadata, encoder, _shards, size, corrupted, disks[i])
+					break
+				}
+			}
+		}
+
+		logger.Logger.Debug("disk", "Successfully downloaded the block from erasure coded disk: ", blockMetadata.UUID.String(), ".")
+		blockMetadata.Content = &contents
+		blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+		return nil
+	}
+
+	// Block could be decoded, but its checksum is invalid
+	if contents != nil {
+		logger.Logger.Error("disk", "Erasure coding recovery failed: downloaded corrupted block ", blockMetadata.UUID.String(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CORRUPTED_BLOCKS, "The block decoded from the erasure coded disks is corrupted.")
+	}
+
+	return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot decode the block from the erasure coded disks.")
+}
+
+func (d *ErasureDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	disks := d.getDisks()
+
+	// Remove shards from all disks
+	var waitGroup sync.WaitGroup
+	var errs = make([]*apicalls.ErrorWrapper, len(disks))
+
+	waitGroup.Add(len(disks))
+	for i, disk := range disks {
+		go func(i int, disk models.Disk) {
+			defer waitGroup.Done()
+			errs[i] = disk.Remove(d.prepareShardMetadata(blockMetadata, nil))
+		}(i, disk)
+	}
+
+	// Wait for the removal to finish
+	waitGroup.Wait()
+
+	// Check for errors
+	var failed bool = false
+	for i, err := range errs {
+		if err != nil {
+			logger.Logger.Error("disk", "Cannot remove shard from the disk ", disks[i].GetUUID().String(), ", got an error: ", err.Error.Error())
+			failed = true
+		}
+	}
+
+	if failed {
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot remove from at least one of the erasure coded disks.")
+	}
+
+	// Call the original callback
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	return nil
+}
+
+func (d *ErasureDisk) SetUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetUUID(uuid)
+}
+
+func (d *ErasureDisk) GetUUID() uuid.UUID {
+	return d.abstractDisk.GetUUID()
+}
+
+func (d *ErasureDisk) SetVolume(volume *models.Volume) {
+	d.abstractDisk.SetVolume(volume)
+}
+
+func (d *ErasureDisk) GetVolume() *models.Volume {
+	return d.abstractDisk.GetVolume()
+}
+
+func (d *ErasureDisk) SetName(name string) {
+	panic("Not supported for erasure coded disk")
+}
+
+func (d *ErasureDisk) GetName() string {
+	return "Virtual erasure coded backup disk"
+}
+
+func (d *ErasureDisk) GetCredentials() credentials.Credentials {
+	panic("Not supported for erasure coded disk")
+}
+
+func (d *ErasureDisk) SetCredentials(credentials credentials.Credentials) {
+	panic("Not supported for erasure coded disk")
+}
+
+func (d *ErasureDisk) CreateCredentials(c string) {
+	panic("Not supported for erasure coded disk")
+}
+
+func (d *ErasureDisk) SetCreationTime(creationTime time.Time) {
+	d.abstractDisk.SetCreationTime(creationTime)
+}
+
+func (d *ErasureDisk) GetCreationTime() time.Time {
+	return d.abstractDisk.GetCreationTime()
+}
+
+func (d *ErasureDisk) GetProviderUUID() uuid.UUID {
+	panic("Not supported for erasure coded disk")
+}
+
+func (d *ErasureDisk) SetIsVirtualFlag(isVirtual bool) {
+	d.abstractDisk.SetIsVirtualFlag(isVirtual)
+}
+
+func (d *ErasureDisk) GetIsVirtualFlag() bool {
+	return d.abstractDisk.GetIsVirtualFlag()
+}
+
+func (d *ErasureDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *ErasureDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
+func (d *ErasureDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetVirtualDiskUUID(uuid)
+}
+
+func (d *ErasureDisk) GetVirtualDiskUUID() uuid.UUID {
+	return d.abstractDisk.GetVirtualDiskUUID()
+}
+
+func (d *ErasureDisk) GetProviderSpace() (uint64, uint64, string) {
+	disks := d.getDisks()
+
+	var free uint64
+	var total uint64
+	var errCode string = constants.SUCCESS
+
+	// Retrieve provider space from all disks
+	for i, disk := range disks {
+		_used, _total, result := disk.GetProviderSpace()
+
+		// Return not supported if one of the disks does not support it
+		if result == constants.OPERATION_NOT_SUPPORTED {
+			return 0, 0, constants.OPERATION_NOT_SUPPORTED
+		}
+
+		if result != constants.SUCCESS {
+			errCode = result
+			continue
+		}
+
+		if i == 0 || _total-_used < free {
+			free = _total - _used
+		}
+		if i == 0 || _total < total {
+			total = _total
+		}
+	}
+
+	// Return error if one of the disks returned an error
+	if errCode != constants.SUCCESS {
+		return 0, 0, errCode
+	}
+
+	// Every disk stores one shard of each block, so the usable space
+	// is limited by the smallest disk multiplied by the number of data shards
+	dataShards, _ := d.getShardCount()
+	free *= uint64(dataShards)
+	total *= uint64(dataShards)
+
+	return total - free, total, constants.SUCCESS
+}
+
+func (d *ErasureDisk) SetTotalSpace(quota uint64) {
+	disks := d.getDisks()
+
+	for _, disk := range disks {
+		disk.SetTotalSpace(d.getShardSpace(quota))
+	}
+}
+
+func (d *ErasureDisk) GetTotalSpace() uint64 {
+	disks := d.getDisks()
+
+	var space uint64
+
+	for i, disk := range disks {
+		if i == 0 || disk.GetTotalSpace() < space {
+			space = disk.GetTotalSpace()
+		}
+	}
+
+	dataShards, _ := d.getShardCount()
+	return space * uint64(dataShards)
+}
+
+func (d *ErasureDisk) SetUsedSpace(usage uint64) {
+	disks := d.getDisks()
+
+	for _, disk := range disks {
+		disk.SetUsedSpace(d.getShardSpace(usage))
+	}
+}
+
+func (d *ErasureDisk) GetUsedSpace() uint64 {
+	disks := d.getDisks()
+
+	var usage uint64
+
+	for _, disk := range disks {
+		if disk.GetUsedSpace() > usage {
+			usage = disk.GetUsedSpace()
+		}
+	}
+
+	dataShards, _ := d.getShardCount()
+	return usage * uint64(dataShards)
+}
+
+func (d *ErasureDisk) UpdateUsedSpace(change int64) {
+	disks := d.getDisks()
+
+	var shardChange int64
+
+	if change > 0 {
+		shardChange = int64(d.getShardSpace(uint64(change)))
+	} else {
+		shardChange = -int64(d.getShardSpace(uint64(-change)))
+	}
+
+	for _, disk := range disks {
+		disk.UpdateUsedSpace(shardChange)
+	}
+}
+
+func (d *ErasureDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
+	panic("Not supported for erasure coded disk")
+}
+
+func (d *ErasureDisk) AssignDisk(disk models.Disk) {
+	d.disksMutex.Lock()
+	defer d.disksMutex.Unlock()
+
+	if d.GetVolume() != nil {
+		dataShards, parityShards := d.getShardCount()
+		if len(d.disks) >= dataShards+parityShards {
+			// If all disks are already assigned, ignore the new disk
+			logger.Logger.Error("disk", "Cannot assign disk to erasure coded disk, all disks are already assigned.")
+			return
+		}
+	}
+
+	d.disks = append(d.disks, disk)
+}
+
+func (d *ErasureDisk) GetReadiness() models.DiskReadiness {
+	disks := d.getDisks()
+
+	arr := make([]models.DiskReadiness, 0)
+
+	for _, disk := range disks {
+		arr = append(arr, disk.GetReadiness())
+	}
+
+	return models.NewVirtualDiskReadiness(arr...)
+}
+
+func (d *ErasureDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
+	disks := d.getDisks()
+
+	arr := make([]models.DiskResponse, 0)
+
+	for _, disk := range disks {
+		diskDBO := disk.GetDiskDBO(_disk.UserUUID, disk.GetProviderUUID(), _disk.VolumeUUID)
+		arr = append(arr, *disk.GetResponse(&diskDBO, ctx))
+	}
+
+	return &models.DiskResponse{
+		Disk:    *_disk,
+		Array:   arr,
+		IsReady: d.GetReadiness().IsReady(ctx),
+	}
+}
+
+func (d *ErasureDisk) ReplaceDisk(disk models.Disk, newDisk models.Disk, blocks []dbo.Block) string {
+	var position int = -1
+	var disks = d.getDisks()
+
+	// Check if the old disk is assigned to the erasure coded disk
+	for i, _disk := range disks {
+		if _disk == disk {
+			position = i
+		}
+	}
+
+	if position == -1 {
+		logger.Logger.Error("disk", "Cannot replace disk in erasure coded disk, provided disk is not assigned.")
+		return constants.FS_DISK_MISMATCH
+	}
+
+	encoder, errWrapper := d.getEncoder()
+	if errWrapper != nil {
+		return constants.OPERATION_FAILED
+	}
+	dataShards, _ := d.getShardCount()
+
+	// Rebuild shards of all blocks on the new disk
+	var uploadedBlocks = make([]dbo.Block, 0, len(blocks))
+	var uploadedMutex sync.Mutex
+
+	failedBlocks := models.TransferBlocks(blocks, func(block dbo.Block) bool {
+		// Prepare apicall metadata
+		var blockMetadata *apicalls.BlockMetadata = models.NewBlockMetadataFromDBO(block)
+
+		// Download shards from all disks
+		shards, holders, size := d.downloadShards(blockMetadata, disks)
+
+		// Shard stored on the replaced disk has the index of the disk position
+		var index int = position
+		if holders[position] != -1 && holders[position] != index {
+			logger.Logger.Warning("disk", "The disk ", disk.GetUUID().String(), " stores shard ", strconv.Itoa(holders[position]), " of the block ", block.UUID.String(), " instead of shard ", strconv.Itoa(index), ".")
+		}
+		if shards[index] != nil && countShards(shards)-1 >= dataShards {
+			// Rebuild the shard from other disks, so that it is not copied from the failing disk
+			shards[index] = nil
+		}
+
+		// Reconstruct the missing shard
+		err := encoder.Reconstruct(shards)
+		if err != nil {
+			logger.Logger.Error("disk", "Replacement failed: cannot reconstruct the block ", block.UUID.String(), ", got an error: ", err.Error())
+			return false
+		}
+
+		// Verify the reconstructed block
+		_contents, err := d.decodeBlock(encoder, shards, size)
+		if err != nil || (block.Checksum != "" && !blockMetadata.MatchesChecksum(checksum.CalculateChecksum(_contents))) {
+			logger.Logger.Error("disk", "Replacement failed: reconstructed block ", block.UUID.String(), " is corrupted.")
+			return false
+		}
+
+		// Upload shard to the new disk, a failed upload may leave a partial shard behind
+		uploadedMutex.Lock()
+		uploadedBlocks = append(uploadedBlocks, block)
+		uploadedMutex.Unlock()
+
+		shard := createShard(index, size, shards[index])
+		result := newDisk.Upload(d.prepareShardMetadata(blockMetadata, &shard))
+		if result != nil {
+			logger.Logger.Error("disk", "Replacement failed: cannot upload shard of the block ", block.UUID.String(), " to new disk ", newDisk.GetUUID().String(), ".")
+			return false
+		}
+
+		return true
+	})
+
+	// Replace disk in erasure coded disk, unless it was replaced in the meantime
+	d.disksMutex.Lock()
+	replaced := failedBlocks == 0 && d.disks[position] == disk
+	if replaced {
+		d.disks[position] = newDisk
+	}
+	d.disksMutex.Unlock()
+
+	if !replaced {
+		// Do not leave orphaned shards on the new disk
+		models.TransferBlocks(uploadedBlocks, func(block dbo.Block) bool {
+			result := newDisk.Remove(d.prepareShardMetadata(models.NewBlockMetadataFromDBO(block), nil))
+			if result != nil {
+				logger.Logger.Warning("disk", "Cannot remove shard of the block ", block.UUID.String(), " from the new disk ", newDisk.GetUUID().String(), ".")
+			}
+
+			return result == nil
+		})
+
+		return constants.OPERATION_FAILED
+	}
+
+	// Delete shards from the old disk, which may be already unavailable
+	models.TransferBlocks(blocks, func(block dbo.Block) bool {
+		result := disk.Remove(d.prepareShardMetadata(models.NewBlockMetadataFromDBO(block), nil))
+		if result != nil {
+			logger.Logger.Warning("disk", "Cannot remove shard of the block ", block.UUID.String(), " from the replaced disk ", disk.GetUUID().String(), ".")
+		}
+
+		return result == nil
+	})
+
+	return constants.SUCCESS
+}
+
+// ScrubBlock - verify shards of the block stored on all disks and repair the invalid ones
+//
+// Shards are verified by comparing them with the shards encoded from the valid block.
+// If the block cannot be recovered, only disks with unreadable shards are reported.
+//
+// params:
+//   - block dbo.Block: block to be verified
+//
+// return type:
+//   - models.ScrubResult: result of the verification
+func (d *ErasureDisk) ScrubBlock(block dbo.Block) models.ScrubResult {
+	disks := d.getDisks()
+
+	var result models.ScrubResult
+	var blockMetadata *apicalls.BlockMetadata = models.NewBlockMetadataFromDBO(block)
+
+	encoder, errWrapper := d.getEncoder()
+	if errWrapper != nil {
+		result.Code = errWrapper.Code
+		return result
+	}
+
+	// Download shards from all disks
+	shards, holders, size := d.downloadShards(blockMetadata, disks)
+	for i, holder := range holders {
+		if holder == -1 {
+			result.FaultyDisks = append(result.FaultyDisks, disks[i].GetUUID())
+		}
+	}
+
+	dataShards, _ := d.getShardCount()
+	if countShards(shards) < dataShards {
+		logger.Logger.Error("disk", "Scrub failed: not enough shards of the block ", block.UUID.String(), " are available.")
+		result.Code = constants.REMOTE_FAILED_JOB
+		return result
+	}
+
+	// Decode the block and encode it again to obtain the valid shards
+	contents, _, valid := d.recoverBlock(blockMetadata, encoder, shards, size)
+	if !valid {
+		logger.Logger.Error("disk", "Scrub failed: cannot recover the block ", block.UUID.String(), ".")
+		result.Code = constants.REMOTE_CORRUPTED_BLOCKS
+		return result
+	}
+
+	validShards, err := d.encodeBlock(encoder, contents)
+	if err != nil {
+		logger.Logger.Error("disk", "Scrub failed: cannot encode the block ", block.UUID.String(), ", got an error: ", err.Error())
+		result.Code = constants.OPERATION_FAILED
+		return result
+	}
+
+	// Repair unreadable, misplaced and corrupted shards, every disk stores the shard with the index of its position
+	result.Code = constants.SUCCESS
+	result.Repaired = true
+	for i, holder := range holders {
+		if holder == -1 {
+			result.Repaired = d.repairShard(blockMetadata, validShards[i], disks[i], false) && result.Repaired
+		} else if holder != i || !bytes.Equal(shards[holder], validShards[i][constants.ERASURE_SHARD_HEADER_SIZE:]) {
+			result.FaultyDisks = append(result.FaultyDisks, disks[i].GetUUID())
+			result.Repaired = d.repairShard(blockMetadata, validShards[i], disks[i], true) && result.Repaired
+		}
+	}
+
+	result.Repaired = result.Repaired && len(result.FaultyDisks) > 0
+	return result
+}
+
+/* Erasure coding helper methods */
+
+// getDisks - retrieve the disks assigned to the erasure coded disk
+//
+// return type:
+//   - []models.Disk: copy of the disks ordered by their position, safe to use while a disk is replaced
+func (d *ErasureDisk) getDisks() []models.Disk {
+	d.disksMutex.RLock()
+	defer d.disksMutex.RUnlock()
+
+	return append([]models.Disk(nil), d.disks...)
+}
+
+// getShardCount - retrieve number of data and parity shards configured for the volume
+//
+// return type:
+//   - int: number of data shards
+//   - int: number of parity shards
+func (d *ErasureDisk) getShardCount() (int, int) {
+	return d.GetVolume().VolumeSettings.DataShards, d.GetVolume().VolumeSettings.ParityShards
+}
+
+// getShardSpace - compute space used on every disk to store data of provided size
+//
+// params:
+//   - space uint64: size of the data stored on the erasure coded disk
+//
+// return type:
+//   - uint64: size of the data stored on every assigned disk
+func (d *ErasureDisk) getShardSpace(space uint64) uint64 {
+	dataShards, _ := d.getShardCount()
+	return (space + uint64(dataShards) - 1) / uint64(dataShards)
+}
+
+// getEncoder - create Reed-Solomon encoder for the disks assigned to the erasure coded disk
+//
+// return type:
+//   - reedsolomon.Encoder: encoder for the configured number of data and parity shards
+//   - *apicalls.ErrorWrapper: error if the encoder cannot be created
+func (d *ErasureDisk) getEncoder() (reedsolomon.Encoder, *apicalls.ErrorWrapper) {
+	dataShards, parityShards := d.getShardCount()
+	disks := d.getDisks()
+
+	if dataShards+parityShards != len(disks) {
+		logger.Logger.Error("disk", "Erasure coding error. Expected ", strconv.Itoa(dataShards+parityShards), " disks, but ", strconv.Itoa(len(disks)), " disks are assigned.")
+		return nil, apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Invalid number of disks assigned to the erasure coded disk.")
+	}
+
+	encoder, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		logger.Logger.Error("disk", "Cannot create erasure encoder, got an error: ", err.Error())
+		return nil, apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot create erasure encoder:", err.Error())
+	}
+
+	return encoder, nil
+}
+
+// prepareShardMetadata - create copy of the api call for operation on a single shard
+//
+// Providers such as GDrive and OneDrive trim downloaded content to the declared
+// block size (extended by the cipher overhead if encryption is enabled), so the
+// size of a single shard has to be declared in the same manner.
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the whole block
+//   - contents *[]uint8: contents of the shard
+//
+// return type:
+//   - *apicalls.BlockMetadata: api call of the single shard
+func (d *ErasureDisk) prepareShardMetadata(blockMetadata *apicalls.BlockMetadata, contents *[]uint8) *apicalls.BlockMetadata {
+	var overhead int64 = 0
+	if d.GetVolume().VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
+		overhead = int64(constants.VOLUME_CIPHER_OVERHEAD)
+	}
+
+	dataShards, _ := d.getShardCount()
+	shardSize := (blockMetadata.Size + overhead + int64(dataShards) - 1) / int64(dataShards)
+	if shardSize == 0 {
+		shardSize = 1
+	}
+
+	if contents == nil {
+		var _contents []uint8 = make([]uint8, 0)
+		contents = &_contents
+	}
+
+	var status int
+	var _blockMetadata apicalls.BlockMetadata = *blockMetadata
+	_blockMetadata.Content = contents
+	_blockMetadata.Size = shardSize + int64(constants.ERASURE_SHARD_HEADER_SIZE) - overhead
+	_blockMetadata.RemoteID = "" // remote IDs are cached only for blocks stored on a single disk
+	_blockMetadata.Status = &status
+	_blockMetadata.CompleteCallback = func(uuid.UUID, *int) {
+	}
+
+	return &_blockMetadata
+}
+
+// encodeBlock - split block into data and parity shards
+//
+// params:
+//   - encoder reedsolomon.Encoder: encoder of the erasure coded disk
+//   - contents []uint8: contents of the block
+//
+// return type:
+//   - [][]uint8: shards (with headers) to be stored on consecutive disks
+//   - error: encoding error
+func (d *ErasureDisk) encodeBlock(encoder reedsolomon.Encoder, contents []uint8) ([][]uint8, error) {
+	// Copy the contents, so that the padding does not modify the original block
+	var data []uint8 = make([]uint8, len(contents))
+	copy(data, contents)
+
+	if len(data) == 0 {
+		data = make([]uint8, 1)
+	}
+
+	shards, err := encoder.Split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Encode(shards)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range shards {
+		shards[i] = createShard(i, uint64(len(contents)), shards[i])
+	}
+
+	return shards, nil
+}
+
+// decodeBlock - join data shards into the block
+//
+// params:
+//   - encoder reedsolomon.Encoder: encoder of the erasure coded disk
+//   - shards [][]uint8: shards (without headers) ordered by index, nil for missing shards
+//   - size uint64: size of the encoded block
+//
+// return type:
+//   - []uint8: contents of the block
+//   - error: decoding error
+func (d *ErasureDisk) decodeBlock(encoder reedsolomon.Encoder, shards [][]uint8, size uint64) ([]uint8, error) {
+	// Reconstruct missing data shards without modifying the provided shards
+	_shards := make([][]uint8, len(shards))
+	copy(_shards, shards)
+
+	err := encoder.ReconstructData(_shards)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	err = encoder.Join(&buffer, _shards, int(size))
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// recoverBlock - decode the block and verify its checksum
+//
+// If the checksum of the decoded block is invalid, the block is decoded again
+// without each of the shards to locate the corrupted one.
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the whole block
+//   - encoder reedsolomon.Encoder: encoder of the erasure coded disk
+//   - shards [][]uint8: shards (without headers) ordered by index, nil for missing shards
+//   - size uint64: size of the encoded block
+//
+// return type:
+//   - []uint8: contents of the block, nil if the block cannot be decoded
+//   - int: index of the corrupted shard, -1 if no shard was found corrupted
+//   - bool: true if the checksum of the decoded block is valid
+func (d *ErasureDisk) recoverBlock(blockMetadata *apicalls.BlockMetadata, encoder reedsolomon.Encoder, shards [][]uint8, size uint64) ([]uint8, int, bool) {
+	dataShards, _ := d.getShardCount()
+	available := countShards(shards)
+
+	contents, err := d.decodeBlock(encoder, shards, size)
+	if err == nil && (blockMetadata.Checksum == "" || blockMetadata.MatchesChecksum(checksum.CalculateChecksum(contents))) {
+		return contents, -1, true
+	}
+
+	// One of the shards is corrupted, try to locate it by decoding the block without each of the shards
+	for index := range shards {
+		if shards[index] == nil || available-1 < dataShards {
+			continue
+		}
+
+		_shards := make([][]uint8, len(shards))
+		copy(_shards, shards)
+		_shards[index] = nil
+
+		_contents, err := d.decodeBlock(encoder, _shards, size)
+		if err != nil || !blockMetadata.MatchesChecksum(checksum.CalculateChecksum(_contents)) {
+			continue
+		}
+
+		return _contents, index, true
+	}
+
+	if err != nil {
+		return nil, -1, false
+	}
+
+	return contents, -1, false
+}
+
+// downloadShards - download shards of the block from all disks
+//
+// Shards with invalid headers are treated as missing. If disks disagree about
+// the size of the block, the size reported by the majority of the disks is used.
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the whole block
+//   - disks []models.Disk: disks assigned to the erasure coded disk
+//
+// return type:
+//   - [][]uint8: shards (without headers) ordered by index, nil for missing shards
+//   - []int: index of the shard downloaded from each disk, -1 if no valid shard was downloaded
+//   - uint64: size of the encoded block
+func (d *ErasureDisk) downloadShards(blockMetadata *apicalls.BlockMetadata, disks []models.Disk) ([][]uint8, []int, uint64) {
+	var waitGroup sync.WaitGroup
+	var contents = make([][]uint8, len(disks))
+
+	waitGroup.Add(len(disks))
+	for i, disk := range disks {
+		go func(i int, disk models.Disk) {
+			defer waitGroup.Done()
+
+			_blockMetadata := d.prepareShardMetadata(blockMetadata, nil)
+			err := disk.Download(_blockMetadata)
+			if err != nil {
+				logger.Logger.Error("disk", "Cannot download shard from the disk ", disk.GetUUID().String(), ", got an error: ", err.Error.Error())
+				return
+			}
+
+			contents[i] = *_blockMetadata.Content
+		}(i, disk)
+	}
+
+	// Wait for the download to finish
+	waitGroup.Wait()
+
+	// Determine the size of the block
+	var votes = make(map[uint64]int)
+	var size uint64
+	for _, _contents := range contents {
+		if len(_contents) >= constants.ERASURE_SHARD_HEADER_SIZE {
+			_size := binary.BigEndian.Uint64(_contents[1:constants.ERASURE_SHARD_HEADER_SIZE])
+			votes[_size]++
+			if votes[_size] > votes[size] {
+				size = _size
+			}
+		}
+	}
+
+	// Order shards by their index
+	var shards = make([][]uint8, len(disks))
+	var holders = make([]int, len(disks))
+	var shardSize int = -1
+
+	for i, _contents := range contents {
+		holders[i] = -1
+
+		if len(_contents) < constants.ERASURE_SHARD_HEADER_SIZE {
+			continue
+		}
+
+		index := int(_contents[0])
+		if index >= len(shards) || shards[index] != nil || binary.BigEndian.Uint64(_contents[1:constants.ERASURE_SHARD_HEADER_SIZE]) != size {
+			logger.Logger.Warning("disk", "Invalid shard header of the block ", blockMetadata.UUID.String(), " on the disk ", disks[i].GetUUID().String(), ".")
+			continue
+		}
+
+		shard := _contents[constants.ERASURE_SHARD_HEADER_SIZE:]
+		if shardSize != -1 && len(shard) != shardSize {
+			logger.Logger.Warning("disk", "Invalid shard size of the block ", blockMetadata.UUID.String(), " on the disk ", disks[i].GetUUID().String(), ".")
+			continue
+		}
+
+		shardSize = len(shard)
+		shards[index] = shard
+		holders[i] = index
+	}
+
+	return shards, holders, size
+}
+
+// fixShard - replace corrupted shard on the disk with the reconstructed one
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the whole block
+//   - encoder reedsolomon.Encoder: encoder of the erasure coded disk
+//   - shards [][]uint8: valid shards (without headers) ordered by index, nil for missing shards
+//   - size uint64: size of the encoded block
+//   - index int: index of the corrupted shard
+//   - targetDisk models.Disk: disk storing the corrupted shard
+func (d *ErasureDisk) fixShard(blockMetadata *apicalls.BlockMetadata, encoder reedsolomon.Encoder, shards [][]uint8, size uint64, index int, targetDisk models.Disk) {
+	// Reconstruct the corrupted shard
+	_shards := make([][]uint8, len(shards))
+	copy(_shards, shards)
+
+	if encoder.Reconstruct(_shards) != nil {
+		logger.Logger.Error("disk", "Erasure coding recovery failed: cannot reconstruct shard of the block ", blockMetadata.UUID.String(), ".")
+		return
+	}
+
+	// Replace the invalid shard on the target disk
+	d.repairShard(blockMetadata, createShard(index, size, _shards[index]), targetDisk, true)
+}
+
+// repairShard - replace shard stored on the disk with the correct one
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: api call of the whole block
+//   - shard []uint8: correct shard (with header)
+//   - targetDisk models.Disk: disk storing invalid shard
+//   - isStored bool: true if the disk stores a shard which must be removed first
+//
+// return type:
+//   - bool: true if the shard was repaired
+func (d *ErasureDisk) repairShard(blockMetadata *apicalls.BlockMetadata, shard []uint8, targetDisk models.Disk, isStored bool) bool {
+	// Remove the invalid shard from the target disk
+	err := targetDisk.Remove(d.prepareShardMetadata(blockMetadata, nil))
+	if err != nil && isStored {
+		logger.Logger.Error("disk", "Erasure coding recovery failed: cannot remove invalid shard of the block ", blockMetadata.UUID.String(), " from disk ", targetDisk.GetUUID().String(), ".")
+		return false
+	}
+
+	// Upload the correct shard to the target disk
+	err = targetDisk.Upload(d.prepareShardMetadata(blockMetadata, &shard))
+	if err != nil {
+		logger.Logger.Error("disk", "Erasure coding recovery failed: cannot upload valid shard of the block ", blockMetadata.UUID.String(), " to disk ", targetDisk.GetUUID().String(), ".")
+		return false
+	}
+
+	logger.Logger.Warning("disk", "Erasure coding recovery completed: disk ", targetDisk.GetUUID().String(), " now has the correct shard of the block ", blockMetadata.UUID.String(), ".")
+	return true
+}
+
+// createShard - prepend shard header to the shard contents
+//
+// params:
+//   - index int: index of the shard
+//   - size uint64: size of the encoded block
+//   - contents []uint8: contents of the shard
+//
+// return type:
+//   - []uint8: shard with the header
+func createShard(index int, size uint64, contents []uint8) []uint8 {
+	var shard []uint8 = make([]uint8, constants.ERASURE_SHARD_HEADER_SIZE+len(contents))
+
+	shard[0] = uint8(index)
+	binary.BigEndian.PutUint64(shard[1:constants.ERASURE_SHARD_HEADER_SIZE], size)
+	copy(shard[constants.ERASURE_SHARD_HEADER_SIZE:], contents)
+
+	return shard
+}
+
+// countShards - count available shards
+//
+// params:
+//   - shards [][]uint8: shards ordered by index, nil for missing shards
+//
+// return type:
+//   - int: number of available shards
+func countShards(shards [][]uint8) int {
+	var count int = 0
+
+	for _, shard := range shards {
+		if shard != nil {
+			count++
+		}
+	}
+
+	return count
+}
+
+/* Factory methods */
+
+func NewErasureDisk() *ErasureDisk {
+	var d *ErasureDisk = new(ErasureDisk)
+	d.abstractDisk.Disk = d
+	d.abstractDisk.UUID = uuid.New()
+
+	d.abstractDisk.IsVirtual = true
+	d.abstractDisk.VirtualDiskUUID = uuid.Nil
+
+	d.disks = make([]models.Disk, 0)
+
+	return d
+}
+
+func init() {
+	models.DiskTypesRegistry[constants.PROVIDER_TYPE_ERASURE] = func() models.Disk { return NewErasureDisk() }
+	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_ERASURE] = func() {
+		providerErasure := dbo.Provider{}
+		db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_ERASURE).First(&providerErasure)
+		if providerErasure.Type != constants.PROVIDER_TYPE_ERASURE {
+			providerErasure.UUID = uuid.New()
+			providerErasure.Type = constants.PROVIDER_TYPE_ERASURE
+			providerErasure.Name = "Erasure coded virtual drive"
+			providerErasure.Logo = ""
+
+			db.DB.DatabaseHandle.Create(&providerErasure)
+		}
+	}
+}
@@ -10,30 +10,35 @@ import (
 	"dcfs/models"
 	"dcfs/models/credentials"
 	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/connpool"
 	"dcfs/util/logger"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/jlaffaye/ftp"
 	"io"
+	"sync"
 	"time"
 )
 
 type FTPDisk struct {
 	abstractDisk AbstractDisk.AbstractDisk
+
+	pool    *connpool.Pool
+	poolMtx sync.Mutex
 }
 
 /* Mandatory Disk interface methods */
 
 func (d *FTPDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	// Authenticate
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool
+	pool := d.getPool()
+	_client, _ := pool.Get(getContext(blockMetadata))
 	if _client == nil {
 		logger.Logger.Error("disk", "Could not connect to the remote server.")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "could not connect to the remote server")
 	}
 
-	var client *ftp.ServerConn = _client.(*ftp.ServerConn)
+	var client *credentials.FTPConnection = _client.(*credentials.FTPConnection)
 
 	// Generate remote path
 	_p := d.abstractDisk.Credentials.GetPath()
@@ -49,9 +54,11 @@ func (d *FTPDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorW
 	// Upload file to server
 	err := client.Stor(downloadPath, bytes.NewReader(*blockMetadata.Content))
 	if err != nil {
+		pool.Discard(client)
 		logger.Logger.Error("disk", "Cannot open the remote file with error: ", err.Error())
 		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "cannot open remote file:", err.Error())
 	}
+	pool.Put(client)
 
 	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
 
@@ -60,14 +67,15 @@ func (d *FTPDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorW
 }
 
 func (d *FTPDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	// Authenticate
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool
+	pool := d.getPool()
+	_client, _ := pool.Get(getContext(blockMetadata))
 	if _client == nil {
 		logger.Logger.Error("disk", "Could not connect to the remote server.")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "could not connect to the remote server")
 	}
 
-	var client *ftp.ServerConn = _client.(*ftp.ServerConn)
+	var client *credentials.FTPConnection = _client.(*credentials.FTPConnection)
 
 	// Generate remote path
 	_p := d.abstractDisk.Credentials.GetPath()
@@ -83,16 +91,22 @@ func (d *FTPDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.Erro
 	// Download file from server
 	reader, err := client.Retr(downloadPath)
 	if err != nil {
+		pool.Discard(client)
 		logger.Logger.Error("disk", "Cannot open the remote file, got an error: ", err.Error())
 		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "cannot open remote file:", err.Error())
 	}
 
-	// Load file content
+	// Load file content; the transfer has to be finished before the connection is reused
 	buff, err := io.ReadAll(reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		pool.Discard(client)
 		logger.Logger.Error("disk", "Cannot open the remote file, got an error: ", err.Error())
 		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "cannot open remote file:", err.Error())
 	}
+	pool.Put(client)
 	blockMetadata.Content = &buff
 	blockMetadata.Size = int64(len(buff))
 	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
@@ -102,14 +116,15 @@ func (d *FTPDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.Erro
 }
 
 func (d *FTPDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	// Authenticate
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool
+	pool := d.getPool()
+	_client, _ := pool.Get(getContext(blockMetadata))
 	if _client == nil {
 		logger.Logger.Error("disk", "Could not connect to the remote server.")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "could not connect to the remote server")
 	}
 
-	var client *ftp.ServerConn = _client.(*ftp.ServerConn)
+	var client *credentials.FTPConnection = _client.(*credentials.FTPConnection)
 
 	// Generate remote path
 	_p := d.abstractDisk.Credentials.GetPath()
@@ -123,9 +138,11 @@ func (d *FTPDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorW
 	// Delete file from server
 	err := client.Delete(downloadPath)
 	if err != nil {
+		pool.Discard(client)
 		logger.Logger.Error("disk", "Cannot remove the remote file: ", err.Error())
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot remove remote file:", err.Error())
 	}
+	pool.Put(client)
 
 	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
 
@@ -163,10 +180,12 @@ func (d *FTPDisk) GetCredentials() credentials.Credentials {
 
 func (d *FTPDisk) SetCredentials(credentials credentials.Credentials) {
 	d.abstractDisk.SetCredentials(credentials)
+	d.resetPool()
 }
 
 func (d *FTPDisk) CreateCredentials(c string) {
 	d.abstractDisk.Credentials = credentials.NewFTPCredentials(c)
+	d.resetPool()
 }
 
 func (d *FTPDisk) SetCreationTime(creationTime time.Time) {
@@ -189,6 +208,14 @@ func (d *FTPDisk) GetIsVirtualFlag() bool {
 	return d.abstractDisk.GetIsVirtualFlag()
 }
 
+func (d *FTPDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *FTPDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
 func (d *FTPDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
 	d.abstractDisk.SetVirtualDiskUUID(uuid)
 }
@@ -237,6 +264,56 @@ func (d *FTPDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskRes
 	return d.abstractDisk.GetResponse(_disk, ctx)
 }
 
+/* FTP disk helper methods */
+
+// getPool - get the pool of connections authenticated with the current credentials
+//
+// return type:
+//   - *connpool.Pool: connection pool of the disk
+func (d *FTPDisk) getPool() *connpool.Pool {
+	d.poolMtx.Lock()
+	defer d.poolMtx.Unlock()
+
+	if d.pool == nil {
+		d.pool = connpool.NewPool(func(ctx context.Context) (connpool.Connection, string) {
+			conn, errCode := d.GetCredentials().(*credentials.FTPCredentials).Connect(ctx)
+			if conn == nil {
+				return nil, errCode
+			}
+
+			return conn, errCode
+		})
+	}
+
+	return d.pool
+}
+
+// resetPool - close the connections authenticated with the previous credentials
+func (d *FTPDisk) resetPool() {
+	d.poolMtx.Lock()
+	defer d.poolMtx.Unlock()
+
+	if d.pool != nil {
+		d.pool.Close()
+		d.pool = nil
+	}
+}
+
+// getContext - get context of the request performed for the block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block
+//
+// return type:
+//   - context.Context: request context
+func getContext(blockMetadata *apicalls.BlockMetadata) context.Context {
+	if blockMetadata.Ctx == nil {
+		return context.Background()
+	}
+
+	return blockMetadata.Ctx
+}
+
 /* Factory methods */
 
 func NewFTPDisk() *FTPDisk {
@@ -249,17 +326,16 @@ func NewFTPDisk() *FTPDisk {
 func init() {
 	models.DiskTypesRegistry[constants.PROVIDER_TYPE_FTP] = func() models.Disk { return NewFTPDisk() }
 	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_FTP] = func(d models.Disk) models.DiskReadiness {
-		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+		return models.NewRealDiskReadinessWithCode(func(ctx context.Context) string {
 			logger.Logger.Debug("drive", "Checking readiness for FTP drive: ", d.GetUUID().String(), ".")
-			if d.GetCredentials().Authenticate(&apicalls.CredentialsAuthenticateMetadata{
-				Ctx:      ctx,
-				Config:   nil,
-				DiskUUID: d.GetUUID(),
-			}) == nil {
-				return false
+			pool := d.(*FTPDisk).getPool()
+			conn, errCode := pool.Get(ctx)
+			if conn == nil {
+				return errCode
 			}
 
-			return true
+			pool.Put(conn)
+			return constants.SUCCESS
 		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
 	}
 	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_FTP] = func() {
@@ -11,23 +11,29 @@ import (
 	"dcfs/models/credentials"
 	"dcfs/models/disk/AbstractDisk"
 	"dcfs/util/logger"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 )
 
+const folderMimeType string = "application/vnd.google-apps.folder"
+
 type GDriveDisk struct {
 	abstractDisk AbstractDisk.AbstractDisk
+	folderMtx    sync.Mutex
 }
 
 func (d *GDriveDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
@@ -42,17 +48,24 @@ func (d *GDriveDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.Err
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CLIENT_UNAVAILABLE, "Unable to retrieve Drive client:", err.Error())
 	}
 
+	folderID, err := d.getFolderID(srv, cred)
+	if err != nil {
+		logger.Logger.Error("disk", "Unable to prepare the volume folder on Google Drive, got an error: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Unable to prepare the volume folder on Google Drive:", err.Error())
+	}
+
 	fileCreate = srv.Files.
-		Create(&(drive.File{Name: blockMetadata.UUID.String()})).
+		Create(&(drive.File{Name: blockMetadata.UUID.String(), Parents: []string{folderID}})).
 		Media(bytes.NewReader(*blockMetadata.Content)).
 		ProgressUpdater(func(now, size int64) {
 			logger.Logger.Debug("disk", "block upload: ", blockMetadata.UUID.String(), " progress: ", strconv.FormatInt(now, 10), "/", strconv.FormatInt(size, 10))
 		})
-	_, err = fileCreate.Do()
+	file, err := fileCreate.Do()
 	if err != nil {
 		logger.Logger.Error("disk", "Failed to upload block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to upload block:", blockMetadata.UUID.String(), "with err:", err.Error())
 	}
+	blockMetadata.RemoteID = file.Id
 
 	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
 	logger.Logger.Debug("disk", "Successfully uploaded the block: ", blockMetadata.UUID.String())
@@ -70,22 +83,21 @@ func (d *GDriveDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CLIENT_UNAVAILABLE, "Unable to retrieve Drive client:", err.Error())
 	}
 
-	// we assume there is only one file named as the block UUID
-	files, err := srv.Files.
-		List().
-		Q(fmt.Sprintf("name = '%s'", blockMetadata.UUID.String())).
-		Do()
-	if err != nil {
-		logger.Logger.Error("disk", "Unable to retrieve files from Google Drive, got an error: ", err.Error())
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Unable to retrieve files from Google Drive:", err.Error())
+	var rsp *http.Response
+	if blockMetadata.RemoteID != "" {
+		rsp, err = srv.Files.Get(blockMetadata.RemoteID).Download()
 	}
 
-	if len(files.Files) == 0 {
-		logger.Logger.Debug("disk", "file with the given block uuid", blockMetadata.UUID.String(), " not found on the Google Drive.")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "can't find the file with the given blockUUID: %s", blockMetadata.UUID.String())
-	}
+	// search for the block only if the remote ID is unknown or stale
+	if blockMetadata.RemoteID == "" || isNotFound(err) {
+		fileID, errWrapper := d.findBlock(srv, cred, blockMetadata.UUID)
+		if errWrapper != nil {
+			return errWrapper
+		}
+		models.UpdateBlockRemoteID(blockMetadata, d.GetUUID(), fileID)
 
-	rsp, err := srv.Files.Get(files.Files[0].Id).Download()
+		rsp, err = srv.Files.Get(fileID).Download()
+	}
 	if err != nil {
 		logger.Logger.Error("disk", "Download failed: ", err.Error())
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "download failed:", err.Error())
@@ -106,7 +118,12 @@ func (d *GDriveDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 
 	blockSize := blockMetadata.Size
 	if d.GetVolume().VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
-		blockSize += int64(constants.VOLUME_NONCE_SIZE + constants.VOLUME_CIPHER_TAG_SIZE)
+		blockSize += int64(constants.VOLUME_CIPHER_OVERHEAD)
+	}
+
+	// Blocks encrypted in the legacy format do not have the block header
+	if blockSize > n {
+		blockSize = n
 	}
 
 	block := buf.Bytes()[0:blockSize]
@@ -129,22 +146,21 @@ func (d *GDriveDisk) Remove(bm *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CLIENT_UNAVAILABLE, "Unable to retrieve Drive client:", err.Error())
 	}
 
-	files, err := srv.Files.
-		List().
-		Q(fmt.Sprintf("name = '%s'", bm.UUID.String())).
-		Do()
-	if err != nil {
-		logger.Logger.Error("disk", "Unable to retrieve files from Google Drive, got an error: ", err.Error())
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Unable to retrieve files from Google Drive:", err.Error())
+	if bm.RemoteID != "" {
+		fileDelete = srv.Files.Delete(bm.RemoteID)
+		err = fileDelete.Do()
 	}
 
-	if len(files.Files) == 0 {
-		logger.Logger.Debug("disk", "file with the given block uuid", bm.UUID.String(), " not found on the Google Drive.")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "can't find the file with the given blockUUID: %s", bm.UUID.String())
-	}
+	// search for the block only if the remote ID is unknown or stale
+	if bm.RemoteID == "" || isNotFound(err) {
+		fileID, errWrapper := d.findBlock(srv, cred, bm.UUID)
+		if errWrapper != nil {
+			return errWrapper
+		}
 
-	fileDelete = srv.Files.Delete(files.Files[0].Id)
-	err = fileDelete.Do()
+		fileDelete = srv.Files.Delete(fileID)
+		err = fileDelete.Do()
+	}
 	if err != nil {
 		logger.Logger.Error("disk", "Failed to remove block: ", bm.UUID.String(), " with err: ", err.Error(), ".")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to remove block:", bm.UUID.String(), "with err:", err.Error())
@@ -212,6 +228,14 @@ func (d *GDriveDisk) GetIsVirtualFlag() bool {
 	return d.abstractDisk.GetIsVirtualFlag()
 }
 
+func (d *GDriveDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *GDriveDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
 func (d *GDriveDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
 	d.abstractDisk.SetVirtualDiskUUID(uuid)
 }
@@ -313,6 +337,130 @@ func (d *GDriveDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.Disk
 	}
 }
 
+/* Folder hierarchy */
+
+// getFolderID - get ID of the folder storing the blocks of the volume
+//
+// The folder is created under the DCFS root folder on the first use
+// and its ID is cached in the disk credentials.
+//
+// params:
+//   - srv *drive.Service: Google Drive client
+//   - cred *credentials.OauthCredentials: credentials of the disk
+//
+// return type:
+//   - string: ID of the folder
+//   - error: error if the folder could not be found or created
+func (d *GDriveDisk) getFolderID(srv *drive.Service, cred *credentials.OauthCredentials) (string, error) {
+	d.folderMtx.Lock()
+	defer d.folderMtx.Unlock()
+
+	if cred.FolderID != "" {
+		return cred.FolderID, nil
+	}
+
+	rootID, err := findOrCreateFolder(srv, constants.CLOUD_DISK_FOLDER_NAME, "root")
+	if err != nil {
+		return "", err
+	}
+
+	folderID, err := findOrCreateFolder(srv, d.GetVolume().UUID.String(), rootID)
+	if err != nil {
+		return "", err
+	}
+
+	cred.SetFolderID(folderID, d.GetUUID())
+	logger.Logger.Debug("disk", "Using the Google Drive folder: ", folderID, " for the disk: ", d.GetUUID().String(), ".")
+	return folderID, nil
+}
+
+// findBlock - get ID of the file storing the block
+//
+// Blocks uploaded before the per-volume folders were introduced
+// are looked up in the root folder of the drive.
+//
+// params:
+//   - srv *drive.Service: Google Drive client
+//   - cred *credentials.OauthCredentials: credentials of the disk
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - string: ID of the file
+//   - *apicalls.ErrorWrapper: error if the file could not be found
+func (d *GDriveDisk) findBlock(srv *drive.Service, cred *credentials.OauthCredentials, blockUUID uuid.UUID) (string, *apicalls.ErrorWrapper) {
+	folderID, err := d.getFolderID(srv, cred)
+	if err != nil {
+		logger.Logger.Error("disk", "Unable to prepare the volume folder on Google Drive, got an error: ", err.Error())
+		return "", apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Unable to prepare the volume folder on Google Drive:", err.Error())
+	}
+
+	for _, parentID := range []string{folderID, "root"} {
+		files, err := srv.Files.
+			List().
+			Q(fmt.Sprintf("name = '%s' and '%s' in parents and trashed = false", blockUUID.String(), parentID)).
+			Fields("files(id)").
+			Do()
+		if err != nil {
+			logger.Logger.Error("disk", "Unable to retrieve files from Google Drive, got an error: ", err.Error())
+			return "", apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Unable to retrieve files from Google Drive:", err.Error())
+		}
+
+		if len(files.Files) > 0 {
+			return files.Files[0].Id, nil
+		}
+	}
+
+	logger.Logger.Debug("disk", "file with the given block uuid", blockUUID.String(), " not found on the Google Drive.")
+	return "", apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "can't find the file with the given blockUUID:", blockUUID.String())
+}
+
+// isNotFound - check whether the request failed because the file does not exist
+//
+// params:
+//   - err error: error returned by the Google Drive client
+//
+// return type:
+//   - bool: true if the file was not found, false otherwise
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// findOrCreateFolder - get ID of the folder with the given name, creating it if it does not exist
+//
+// params:
+//   - srv *drive.Service: Google Drive client
+//   - name string: name of the folder
+//   - parentID string: ID of the parent folder
+//
+// return type:
+//   - string: ID of the folder
+//   - error: error if the folder could not be found or created
+func findOrCreateFolder(srv *drive.Service, name string, parentID string) (string, error) {
+	files, err := srv.Files.
+		List().
+		Q(fmt.Sprintf("name = '%s' and '%s' in parents and mimeType = '%s' and trashed = false", name, parentID, folderMimeType)).
+		Fields("files(id)").
+		Do()
+	if err != nil {
+		return "", err
+	}
+
+	if len(files.Files) > 0 {
+		return files.Files[0].Id, nil
+	}
+
+	folder, err := srv.Files.
+		Create(&drive.File{Name: name, MimeType: folderMimeType, Parents: []string{parentID}}).
+		Fields("id").
+		Do()
+	if err != nil {
+		return "", err
+	}
+
+	return folder.Id, nil
+}
+
 /* Factory methods */
 
 func NewGDriveDisk() *GDriveDisk {
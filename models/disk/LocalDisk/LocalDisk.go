@@ -0,0 +1,282 @@
+package LocalDisk
+
+import (
+	"context"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/logger"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type LocalDisk struct {
+	abstractDisk AbstractDisk.AbstractDisk
+}
+
+/* Mandatory Disk interface implementations */
+
+func (d *LocalDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	directory, ok := d.GetCredentials().Authenticate(nil).(string)
+	if !ok {
+		logger.Logger.Error("disk", "Cannot access the local directory.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot access the local directory")
+	}
+
+	// Write the block to a temporary file, so that the existing copy of the block is replaced only once it is complete
+	dstFile, err := os.CreateTemp(directory, "."+blockMetadata.UUID.String()+".*.tmp")
+	if err != nil {
+		logger.Logger.Error("disk", "Cannot open the local file: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Cannot open local file:", err.Error())
+	}
+
+	// Write file content and flush it to the storage
+	_, err = dstFile.Write(*blockMetadata.Content)
+	if err == nil {
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(dstFile.Name(), getBlockPath(directory, blockMetadata.UUID))
+	}
+	if err != nil {
+		_ = os.Remove(dstFile.Name())
+		logger.Logger.Error("disk", "Cannot write the local file: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot write local file:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+	logger.Logger.Debug("disk", "Successfully uploaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *LocalDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	directory, ok := d.GetCredentials().Authenticate(nil).(string)
+	if !ok {
+		logger.Logger.Error("disk", "Cannot access the local directory.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot access the local directory")
+	}
+
+	// Read local file
+	buff, err := os.ReadFile(getBlockPath(directory, blockMetadata.UUID))
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Logger.Error("disk", "Cannot open the local file: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Cannot open local file:", err.Error())
+	} else if err != nil {
+		logger.Logger.Error("disk", "Cannot read the local file: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot read local file:", err.Error())
+	}
+
+	blockMetadata.Content = &buff
+	blockMetadata.Size = int64(len(buff))
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully downloaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *LocalDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	directory, ok := d.GetCredentials().Authenticate(nil).(string)
+	if !ok {
+		logger.Logger.Error("disk", "Cannot access the local directory.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot access the local directory")
+	}
+
+	// Remove local file
+	err := os.Remove(getBlockPath(directory, blockMetadata.UUID))
+	if err != nil {
+		logger.Logger.Error("disk", "Cannot remove the local file: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Cannot remove local file:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully removed the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *LocalDisk) SetVolume(volume *models.Volume) {
+	d.abstractDisk.SetVolume(volume)
+}
+
+func (d *LocalDisk) GetVolume() *models.Volume {
+	return d.abstractDisk.GetVolume()
+}
+
+func (d *LocalDisk) SetName(name string) {
+	d.abstractDisk.SetName(name)
+}
+
+func (d *LocalDisk) GetName() string {
+	return d.abstractDisk.GetName()
+}
+
+func (d *LocalDisk) SetUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetUUID(uuid)
+}
+
+func (d *LocalDisk) GetUUID() uuid.UUID {
+	return d.abstractDisk.GetUUID()
+}
+
+func (d *LocalDisk) GetCredentials() credentials.Credentials {
+	return d.abstractDisk.GetCredentials()
+}
+
+func (d *LocalDisk) SetCreationTime(creationTime time.Time) {
+	d.abstractDisk.SetCreationTime(creationTime)
+}
+
+func (d *LocalDisk) GetCreationTime() time.Time {
+	return d.abstractDisk.GetCreationTime()
+}
+
+func (d *LocalDisk) SetCredentials(credentials credentials.Credentials) {
+	d.abstractDisk.SetCredentials(credentials)
+}
+
+func (d *LocalDisk) CreateCredentials(c string) {
+	var _credentials *credentials.LocalCredentials = credentials.NewLocalCredentials(c)
+	if d.GetVolume() != nil {
+		_credentials.OwnerUUID = d.GetVolume().UserUUID
+	}
+
+	d.abstractDisk.Credentials = _credentials
+}
+
+func (d *LocalDisk) GetProviderUUID() uuid.UUID {
+	return d.abstractDisk.GetProvider(constants.PROVIDER_TYPE_LOCAL)
+}
+
+func (d *LocalDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
+	return d.abstractDisk.GetDiskDBO(userUUID, providerUUID, volumeUUID)
+}
+
+func (d *LocalDisk) SetIsVirtualFlag(isVirtual bool) {
+	d.abstractDisk.SetIsVirtualFlag(isVirtual)
+}
+
+func (d *LocalDisk) GetIsVirtualFlag() bool {
+	return d.abstractDisk.GetIsVirtualFlag()
+}
+
+func (d *LocalDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *LocalDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
+func (d *LocalDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetVirtualDiskUUID(uuid)
+}
+
+func (d *LocalDisk) GetVirtualDiskUUID() uuid.UUID {
+	return d.abstractDisk.GetVirtualDiskUUID()
+}
+
+func (d *LocalDisk) GetProviderSpace() (uint64, uint64, string) {
+	directory, ok := d.GetCredentials().Authenticate(nil).(string)
+	if !ok {
+		logger.Logger.Error("disk", "Cannot access the local directory to get the provider space.")
+		return 0, 0, constants.REMOTE_CANNOT_AUTHENTICATE
+	}
+
+	// Get the filesystem stats of the local directory
+	usedSpace, totalSpace, err := getFilesystemSpace(directory)
+	if err != nil {
+		logger.Logger.Error("disk", "Could not get the local filesystem stats: ", err.Error())
+		return 0, 0, constants.OPERATION_NOT_SUPPORTED
+	}
+
+	return usedSpace, totalSpace, constants.SUCCESS
+}
+
+func (d *LocalDisk) SetTotalSpace(quota uint64) {
+	d.abstractDisk.SetTotalSpace(quota)
+}
+
+func (d *LocalDisk) GetTotalSpace() uint64 {
+	return d.abstractDisk.GetTotalSpace()
+}
+
+func (d *LocalDisk) SetUsedSpace(usage uint64) {
+	d.abstractDisk.SetUsedSpace(usage)
+}
+
+func (d *LocalDisk) GetUsedSpace() uint64 {
+	return d.abstractDisk.GetUsedSpace()
+}
+
+func (d *LocalDisk) UpdateUsedSpace(change int64) {
+	d.abstractDisk.UpdateUsedSpace(change)
+}
+
+func (d *LocalDisk) AssignDisk(disk models.Disk) {
+	d.abstractDisk.AssignDisk(disk)
+}
+
+func (d *LocalDisk) GetReadiness() models.DiskReadiness {
+	return d.abstractDisk.DiskReadiness
+}
+
+func (d *LocalDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
+	return d.abstractDisk.GetResponse(_disk, ctx)
+}
+
+/* Local disk helper methods */
+
+// getBlockPath - get path of the local file storing the block
+//
+// params:
+//   - directory string: resolved directory of the disk
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - string: path of the local file
+func getBlockPath(directory string, blockUUID uuid.UUID) string {
+	return filepath.Join(directory, blockUUID.String())
+}
+
+/* Factory methods */
+func NewLocalDisk() *LocalDisk {
+	var d *LocalDisk = new(LocalDisk)
+	d.abstractDisk.Disk = d
+	d.abstractDisk.DiskReadiness = models.DiskReadinessRegistry[constants.PROVIDER_TYPE_LOCAL](d)
+	return d
+}
+
+func init() {
+	models.DiskTypesRegistry[constants.PROVIDER_TYPE_LOCAL] = func() models.Disk { return NewLocalDisk() }
+	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_LOCAL] = func(d models.Disk) models.DiskReadiness {
+		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+			logger.Logger.Debug("drive", "Checking readiness for local drive: ", d.GetUUID().String(), ".")
+
+			return d.GetCredentials().Authenticate(nil) != nil
+		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
+	}
+	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_LOCAL] = func() {
+		provider := dbo.Provider{}
+		db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_LOCAL).First(&provider)
+		if provider.Type != constants.PROVIDER_TYPE_LOCAL {
+			provider.UUID = uuid.New()
+			provider.Type = constants.PROVIDER_TYPE_LOCAL
+			provider.Name = "Local drive"
+			provider.Logo = "https://cdn-icons-png.flaticon.com/512/2906/2906274.png"
+
+			db.DB.DatabaseHandle.Create(&provider)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package LocalDisk
+
+import "syscall"
+
+// getFilesystemSpace - retrieve space of the filesystem containing the directory
+//
+// Space reserved for the privileged users is reported as used, since
+// it cannot be used to store blocks.
+//
+// params:
+//   - path string: path of the directory
+//
+// return type:
+//   - uint64: used space in bytes
+//   - uint64: total space in bytes
+//   - error: error returned by statfs
+func getFilesystemSpace(path string) (uint64, uint64, error) {
+	var stats syscall.Statfs_t
+
+	err := syscall.Statfs(path, &stats)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	totalSpace := stats.Blocks * uint64(stats.Bsize)
+	availableSpace := stats.Bavail * uint64(stats.Bsize)
+	return totalSpace - availableSpace, totalSpace, nil
+}
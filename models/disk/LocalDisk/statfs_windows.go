@@ -0,0 +1,18 @@
+package LocalDisk
+
+import "errors"
+
+// getFilesystemSpace - retrieve space of the filesystem containing the directory
+//
+// Filesystem stats are not supported on Windows.
+//
+// params:
+//   - path string: path of the directory
+//
+// return type:
+//   - uint64: used space in bytes
+//   - uint64: total space in bytes
+//   - error: always returned
+func getFilesystemSpace(path string) (uint64, uint64, error) {
+	return 0, 0, errors.New("filesystem stats are not supported on this platform")
+}
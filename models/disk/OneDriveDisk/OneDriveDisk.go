@@ -26,11 +26,14 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type OneDriveDisk struct {
 	abstractDisk AbstractDisk.AbstractDisk
+	folderMtx    sync.Mutex
 }
 
 /* Mandatory Disk interface implementations */
@@ -45,9 +48,15 @@ func (d *OneDriveDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 	var client *http.Client = _client.(*http.Client)
 	oneDriveClient := onedrive.NewClient(client)
 
+	folderID, err := d.getFolderID(blockMetadata.Ctx, oneDriveClient)
+	if err != nil {
+		logger.Logger.Error("disk", "Could not prepare the volume folder: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not prepare the volume folder:", err.Error())
+	}
+
 	// size in bytes
 	var size int = len(*blockMetadata.Content)
-	var apiURL string = "me/drive/root:/" + url.PathEscape(blockMetadata.UUID.String())
+	var apiURL string = "me/drive/items/" + url.PathEscape(folderID) + ":/" + url.PathEscape(blockMetadata.UUID.String())
 
 	ft, err := filetype.Match(*blockMetadata.Content)
 	if err != nil {
@@ -69,6 +78,7 @@ func (d *OneDriveDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 			logger.Logger.Error("disk", "Could not sent the file: ", err.Error(), ".")
 			return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not send file:", err.Error())
 		}
+		blockMetadata.RemoteID = response.Id
 	} else {
 		// upload session
 		url, err := oneDriveClient.BaseURL.Parse(apiURL + ":/createUploadSession")
@@ -121,6 +131,7 @@ func (d *OneDriveDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 					logger.Logger.Error("disk", "Could not send file: ", err.Error())
 					return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not send file:", err.Error())
 				}
+				blockMetadata.RemoteID = rsp.ID
 			}
 		}
 	}
@@ -130,16 +141,6 @@ func (d *OneDriveDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 	return nil
 }
 
-type oneDriveSearchDriveItem struct {
-	DataType string `json:"@odata.type"`
-	Id       string `json:"id"`
-}
-
-type oneDriveSearchResponse struct {
-	Context string                    `json:"@odata.context"`
-	Value   []oneDriveSearchDriveItem `json:"value"`
-}
-
 func (d *OneDriveDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
 	var _client interface{} = d.GetCredentials().Authenticate(&apicalls.CredentialsAuthenticateMetadata{Ctx: blockMetadata.Ctx, Config: d.GetConfig(), DiskUUID: d.GetUUID()})
 	if _client == nil {
@@ -149,33 +150,22 @@ func (d *OneDriveDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls
 
 	var client *http.Client = _client.(*http.Client)
 	oneDriveClient := onedrive.NewClient(client)
-	var searchReqUrl string = "me/drive/root/search(q='" + url.PathEscape(blockMetadata.UUID.String()) + "')?select=id"
-
-	req, err := oneDriveClient.NewRequest("GET", searchReqUrl, nil)
-	if err != nil {
-		logger.Logger.Error("disk", "Could not create a file search request: ", err.Error(), ".")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_REQUEST, "Could not create a file search request:", err.Error())
-	}
 
-	var response oneDriveSearchResponse = oneDriveSearchResponse{}
-	err = oneDriveClient.Do(blockMetadata.Ctx, req, false, &response)
-	if err != nil {
-		logger.Logger.Error("disk", "Search request failed: ", err.Error(), ".")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Search request failed:", err.Error())
-	}
-
-	if len(response.Value) > 1 {
-		logger.Logger.Error("disk", "Block hierarchy corrupted.")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Block hierarchy corrupted")
-	}
-
-	if len(response.Value) == 0 {
-		logger.Logger.Error("disk", "Could not find the file.")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not find file")
+	var item *onedrive.DriveItem
+	var err error
+	if blockMetadata.RemoteID != "" {
+		item, err = oneDriveClient.DriveItems.Get(blockMetadata.Ctx, blockMetadata.RemoteID)
 	}
 
-	item, err := oneDriveClient.DriveItems.Get(blockMetadata.Ctx, response.Value[0].Id)
-	if err != nil {
+	// search for the block only if the remote ID is unknown or stale
+	if blockMetadata.RemoteID == "" || isNotFound(err) {
+		var errWrapper *apicalls.ErrorWrapper
+		item, errWrapper = d.findBlock(blockMetadata.Ctx, oneDriveClient, blockMetadata.UUID)
+		if errWrapper != nil {
+			return errWrapper
+		}
+		models.UpdateBlockRemoteID(blockMetadata, d.GetUUID(), item.Id)
+	} else if err != nil {
 		logger.Logger.Error("disk", "Could not download file: ", err.Error(), ".")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not download file:", err.Error())
 	}
@@ -208,7 +198,12 @@ func (d *OneDriveDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls
 
 	blockSize := blockMetadata.Size
 	if d.GetVolume().VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
-		blockSize += int64(constants.VOLUME_NONCE_SIZE + constants.VOLUME_CIPHER_TAG_SIZE)
+		blockSize += int64(constants.VOLUME_CIPHER_OVERHEAD)
+	}
+
+	// Blocks encrypted in the legacy format do not have the block header
+	if blockSize > n {
+		blockSize = n
 	}
 
 	block := buf.Bytes()[0:blockSize]
@@ -230,42 +225,21 @@ func (d *OneDriveDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.E
 	var client *http.Client = _client.(*http.Client)
 	oneDriveClient := onedrive.NewClient(client)
 
-	// Prepare the search request
-	var searchReqUrl string = "me/drive/root/search(q='" + url.PathEscape(blockMetadata.UUID.String()) + "')?select=id"
-
-	req, err := oneDriveClient.NewRequest("GET", searchReqUrl, nil)
-	if err != nil {
-		logger.Logger.Error("disk", "Could not create a file search request: ", err.Error(), ".")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_REQUEST, "Could not create a file search request:", err.Error())
-	}
-
-	// Locate the file on OneDrive
-	var response oneDriveSearchResponse = oneDriveSearchResponse{}
-	err = oneDriveClient.Do(blockMetadata.Ctx, req, false, &response)
-	if err != nil {
-		logger.Logger.Error("disk", "Search request failed: ", err.Error(), ".")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Search request failed:", err.Error())
-	}
-
-	if len(response.Value) > 1 {
-		logger.Logger.Error("disk", "Block hierarchy corrupted.")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Block hierarchy corrupted")
+	// Delete the file using the cached remote ID
+	var err error
+	if blockMetadata.RemoteID != "" {
+		err = deleteItem(blockMetadata.Ctx, oneDriveClient, blockMetadata.RemoteID)
 	}
 
-	if len(response.Value) == 0 {
-		logger.Logger.Error("disk", "Could not find the file.")
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not find file")
-	}
+	// Locate the file on OneDrive only if the remote ID is unknown or stale
+	if blockMetadata.RemoteID == "" || isNotFound(err) {
+		item, errWrapper := d.findBlock(blockMetadata.Ctx, oneDriveClient, blockMetadata.UUID)
+		if errWrapper != nil {
+			return errWrapper
+		}
 
-	// Prepare the delete request
-	apiURL := "me/drive/items/" + url.PathEscape(response.Value[0].Id)
-	deleteReq, err := oneDriveClient.NewRequest("DELETE", apiURL, nil)
-	if err != nil {
-		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not create delete request:", err.Error())
+		err = deleteItem(blockMetadata.Ctx, oneDriveClient, item.Id)
 	}
-
-	// Execute the delete request
-	err = oneDriveClient.Do(blockMetadata.Ctx, deleteReq, false, &response)
 	if err != nil {
 		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not remove file:", err.Error())
 	}
@@ -332,6 +306,14 @@ func (d *OneDriveDisk) GetIsVirtualFlag() bool {
 	return d.abstractDisk.GetIsVirtualFlag()
 }
 
+func (d *OneDriveDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *OneDriveDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
 func (d *OneDriveDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
 	d.abstractDisk.SetVirtualDiskUUID(uuid)
 }
@@ -431,6 +413,169 @@ func (d *OneDriveDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.Di
 	}
 }
 
+/* Folder hierarchy */
+
+// getFolderID - get ID of the folder storing the blocks of the volume
+//
+// The folder is created under the DCFS root folder on the first use
+// and its ID is cached in the disk credentials.
+//
+// params:
+//   - ctx context.Context: request context
+//   - oneDriveClient *onedrive.Client: OneDrive client
+//
+// return type:
+//   - string: ID of the folder
+//   - error: error if the folder could not be found or created
+func (d *OneDriveDisk) getFolderID(ctx context.Context, oneDriveClient *onedrive.Client) (string, error) {
+	d.folderMtx.Lock()
+	defer d.folderMtx.Unlock()
+
+	var cred *credentials.OauthCredentials = d.GetCredentials().(*credentials.OauthCredentials)
+	if cred.FolderID != "" {
+		return cred.FolderID, nil
+	}
+
+	rootID, err := findOrCreateFolder(ctx, oneDriveClient, constants.CLOUD_DISK_FOLDER_NAME, "root")
+	if err != nil {
+		return "", err
+	}
+
+	folderID, err := findOrCreateFolder(ctx, oneDriveClient, d.GetVolume().UUID.String(), rootID)
+	if err != nil {
+		return "", err
+	}
+
+	cred.SetFolderID(folderID, d.GetUUID())
+	logger.Logger.Debug("disk", "Using the OneDrive folder: ", folderID, " for the disk: ", d.GetUUID().String(), ".")
+	return folderID, nil
+}
+
+// findBlock - get the drive item storing the block
+//
+// Blocks uploaded before the per-volume folders were introduced
+// are looked up in the root folder of the drive.
+//
+// params:
+//   - ctx context.Context: request context
+//   - oneDriveClient *onedrive.Client: OneDrive client
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - *onedrive.DriveItem: drive item of the block
+//   - *apicalls.ErrorWrapper: error if the item could not be found
+func (d *OneDriveDisk) findBlock(ctx context.Context, oneDriveClient *onedrive.Client, blockUUID uuid.UUID) (*onedrive.DriveItem, *apicalls.ErrorWrapper) {
+	folderID, err := d.getFolderID(ctx, oneDriveClient)
+	if err != nil {
+		logger.Logger.Error("disk", "Could not prepare the volume folder: ", err.Error(), ".")
+		return nil, apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Could not prepare the volume folder:", err.Error())
+	}
+
+	for _, parentID := range []string{folderID, "root"} {
+		item, err := getChild(ctx, oneDriveClient, parentID, blockUUID.String())
+		if err == nil {
+			return item, nil
+		}
+	}
+
+	logger.Logger.Error("disk", "Could not find the file: ", blockUUID.String(), ".")
+	return nil, apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Could not find file:", blockUUID.String())
+}
+
+// getChild - get the drive item with the given name from the folder
+//
+// params:
+//   - ctx context.Context: request context
+//   - oneDriveClient *onedrive.Client: OneDrive client
+//   - parentID string: ID of the folder
+//   - name string: name of the item
+//
+// return type:
+//   - *onedrive.DriveItem: found drive item
+//   - error: error if the item could not be retrieved
+func getChild(ctx context.Context, oneDriveClient *onedrive.Client, parentID string, name string) (*onedrive.DriveItem, error) {
+	req, err := oneDriveClient.NewRequest("GET", "me/drive/items/"+url.PathEscape(parentID)+":/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *onedrive.DriveItem
+	err = oneDriveClient.Do(ctx, req, false, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// deleteItem - delete the drive item with the given ID
+//
+// params:
+//   - ctx context.Context: request context
+//   - oneDriveClient *onedrive.Client: OneDrive client
+//   - itemID string: ID of the item
+//
+// return type:
+//   - error: error if the item could not be deleted
+func deleteItem(ctx context.Context, oneDriveClient *onedrive.Client, itemID string) error {
+	req, err := oneDriveClient.NewRequest("DELETE", "me/drive/items/"+url.PathEscape(itemID), nil)
+	if err != nil {
+		return err
+	}
+
+	return oneDriveClient.Do(ctx, req, false, nil)
+}
+
+// isNotFound - check whether the request failed because the item does not exist
+//
+// params:
+//   - err error: error returned by the OneDrive client
+//
+// return type:
+//   - bool: true if the item was not found, false otherwise
+func isNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "itemNotFound")
+}
+
+// findOrCreateFolder - get ID of the folder with the given name, creating it if it does not exist
+//
+// params:
+//   - ctx context.Context: request context
+//   - oneDriveClient *onedrive.Client: OneDrive client
+//   - name string: name of the folder
+//   - parentID string: ID of the parent folder
+//
+// return type:
+//   - string: ID of the folder
+//   - error: error if the folder could not be found or created
+func findOrCreateFolder(ctx context.Context, oneDriveClient *onedrive.Client, name string, parentID string) (string, error) {
+	item, err := getChild(ctx, oneDriveClient, parentID, name)
+	if err == nil {
+		return item.Id, nil
+	}
+
+	body := map[string]interface{}{
+		"name":                              name,
+		"folder":                            map[string]interface{}{},
+		"@microsoft.graph.conflictBehavior": "fail",
+	}
+	req, err := oneDriveClient.NewRequest("POST", "me/drive/items/"+url.PathEscape(parentID)+"/children", body)
+	if err != nil {
+		return "", err
+	}
+
+	err = oneDriveClient.Do(ctx, req, false, &item)
+	if err != nil {
+		// the folder could have been created concurrently
+		item, err = getChild(ctx, oneDriveClient, parentID, name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return item.Id, nil
+}
+
 /* Factory methods */
 
 func NewOneDriveDisk() *OneDriveDisk {
@@ -0,0 +1,332 @@
+package S3Disk
+
+import (
+	"context"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/logger"
+	"dcfs/util/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Disk struct {
+	abstractDisk AbstractDisk.AbstractDisk
+}
+
+/* Mandatory Disk interface implementations */
+
+func (d *S3Disk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the S3 client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create S3 client")
+	}
+
+	var client *s3.Client = _client.(*s3.Client)
+	var err error
+
+	// Large blocks are uploaded in parts
+	if len(*blockMetadata.Content) > constants.S3_MULTIPART_THRESHOLD {
+		err = d.uploadMultipart(client, blockMetadata)
+	} else {
+		err = client.PutObject(getContext(blockMetadata), d.getObjectKey(blockMetadata.UUID), *blockMetadata.Content)
+	}
+	if err != nil {
+		logger.Logger.Error("disk", "Failed to upload block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to upload block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+	logger.Logger.Debug("disk", "Successfully uploaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *S3Disk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the S3 client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create S3 client")
+	}
+
+	var client *s3.Client = _client.(*s3.Client)
+
+	// Download the object
+	buff, err := client.GetObject(getContext(blockMetadata), d.getObjectKey(blockMetadata.UUID))
+	if s3.IsNotFound(err) {
+		logger.Logger.Error("disk", "Cannot find the block: ", blockMetadata.UUID.String(), " in the bucket.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Cannot find the block in the bucket:", err.Error())
+	} else if err != nil {
+		logger.Logger.Error("disk", "Failed to download block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to download block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.Content = &buff
+	blockMetadata.Size = int64(len(buff))
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully downloaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *S3Disk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the S3 client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create S3 client")
+	}
+
+	var client *s3.Client = _client.(*s3.Client)
+
+	// Remove the object
+	err := client.DeleteObject(getContext(blockMetadata), d.getObjectKey(blockMetadata.UUID))
+	if err != nil {
+		logger.Logger.Error("disk", "Failed to remove block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to remove block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully removed the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *S3Disk) SetVolume(volume *models.Volume) {
+	d.abstractDisk.SetVolume(volume)
+}
+
+func (d *S3Disk) GetVolume() *models.Volume {
+	return d.abstractDisk.GetVolume()
+}
+
+func (d *S3Disk) SetName(name string) {
+	d.abstractDisk.SetName(name)
+}
+
+func (d *S3Disk) GetName() string {
+	return d.abstractDisk.GetName()
+}
+
+func (d *S3Disk) SetUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetUUID(uuid)
+}
+
+func (d *S3Disk) GetUUID() uuid.UUID {
+	return d.abstractDisk.GetUUID()
+}
+
+func (d *S3Disk) GetCredentials() credentials.Credentials {
+	return d.abstractDisk.GetCredentials()
+}
+
+func (d *S3Disk) SetCreationTime(creationTime time.Time) {
+	d.abstractDisk.SetCreationTime(creationTime)
+}
+
+func (d *S3Disk) GetCreationTime() time.Time {
+	return d.abstractDisk.GetCreationTime()
+}
+
+func (d *S3Disk) SetCredentials(credentials credentials.Credentials) {
+	d.abstractDisk.SetCredentials(credentials)
+}
+
+func (d *S3Disk) CreateCredentials(c string) {
+	d.abstractDisk.Credentials = credentials.NewS3Credentials(c)
+}
+
+func (d *S3Disk) GetProviderUUID() uuid.UUID {
+	return d.abstractDisk.GetProvider(constants.PROVIDER_TYPE_S3)
+}
+
+func (d *S3Disk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
+	return d.abstractDisk.GetDiskDBO(userUUID, providerUUID, volumeUUID)
+}
+
+func (d *S3Disk) SetIsVirtualFlag(isVirtual bool) {
+	d.abstractDisk.SetIsVirtualFlag(isVirtual)
+}
+
+func (d *S3Disk) GetIsVirtualFlag() bool {
+	return d.abstractDisk.GetIsVirtualFlag()
+}
+
+func (d *S3Disk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *S3Disk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
+func (d *S3Disk) SetVirtualDiskUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetVirtualDiskUUID(uuid)
+}
+
+func (d *S3Disk) GetVirtualDiskUUID() uuid.UUID {
+	return d.abstractDisk.GetVirtualDiskUUID()
+}
+
+func (d *S3Disk) GetProviderSpace() (uint64, uint64, string) {
+	// Buckets do not have a quota which could be retrieved
+	return 0, 0, constants.OPERATION_NOT_SUPPORTED
+}
+
+func (d *S3Disk) SetTotalSpace(quota uint64) {
+	d.abstractDisk.SetTotalSpace(quota)
+}
+
+func (d *S3Disk) GetTotalSpace() uint64 {
+	return d.abstractDisk.GetTotalSpace()
+}
+
+func (d *S3Disk) SetUsedSpace(usage uint64) {
+	d.abstractDisk.SetUsedSpace(usage)
+}
+
+func (d *S3Disk) GetUsedSpace() uint64 {
+	return d.abstractDisk.GetUsedSpace()
+}
+
+func (d *S3Disk) UpdateUsedSpace(change int64) {
+	d.abstractDisk.UpdateUsedSpace(change)
+}
+
+func (d *S3Disk) AssignDisk(disk models.Disk) {
+	d.abstractDisk.AssignDisk(disk)
+}
+
+func (d *S3Disk) GetReadiness() models.DiskReadiness {
+	return d.abstractDisk.DiskReadiness
+}
+
+func (d *S3Disk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
+	return d.abstractDisk.GetResponse(_disk, ctx)
+}
+
+/* S3 disk helper methods */
+
+// uploadMultipart - upload the block in parts using the multipart upload
+//
+// The upload is aborted if any of the parts fails, so that the parts
+// already stored do not occupy the bucket.
+//
+// params:
+//   - client *s3.Client: S3 client
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block to upload
+//
+// return type:
+//   - error: nil if the block was uploaded
+func (d *S3Disk) uploadMultipart(client *s3.Client, blockMetadata *apicalls.BlockMetadata) error {
+	ctx := getContext(blockMetadata)
+	key := d.getObjectKey(blockMetadata.UUID)
+	content := *blockMetadata.Content
+
+	uploadID, err := client.CreateMultipartUpload(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var parts []s3.CompletedPart
+	for offset := 0; offset < len(content); offset += constants.S3_MULTIPART_PART_SIZE {
+		end := offset + constants.S3_MULTIPART_PART_SIZE
+		if end > len(content) {
+			end = len(content)
+		}
+
+		part, err := client.UploadPart(ctx, key, uploadID, len(parts)+1, content[offset:end])
+		if err != nil {
+			_ = client.AbortMultipartUpload(ctx, key, uploadID)
+			return err
+		}
+
+		logger.Logger.Debug("disk", "block upload: ", blockMetadata.UUID.String(), " progress: ", strconv.Itoa(end), "/", strconv.Itoa(len(content)))
+		parts = append(parts, part)
+	}
+
+	err = client.CompleteMultipartUpload(ctx, key, uploadID, parts)
+	if err != nil {
+		_ = client.AbortMultipartUpload(ctx, key, uploadID)
+		return err
+	}
+
+	return nil
+}
+
+// getObjectKey - get key of the object storing the block
+//
+// params:
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - string: object key
+func (d *S3Disk) getObjectKey(blockUUID uuid.UUID) string {
+	return strings.TrimPrefix(path.Join(d.GetCredentials().GetPath(), blockUUID.String()), "/")
+}
+
+// getContext - get context of the request performed for the block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block
+//
+// return type:
+//   - context.Context: request context
+func getContext(blockMetadata *apicalls.BlockMetadata) context.Context {
+	if blockMetadata.Ctx == nil {
+		return context.Background()
+	}
+
+	return blockMetadata.Ctx
+}
+
+/* Factory methods */
+func NewS3Disk() *S3Disk {
+	var d *S3Disk = new(S3Disk)
+	d.abstractDisk.Disk = d
+	d.abstractDisk.DiskReadiness = models.DiskReadinessRegistry[constants.PROVIDER_TYPE_S3](d)
+	return d
+}
+
+func init() {
+	models.DiskTypesRegistry[constants.PROVIDER_TYPE_S3] = func() models.Disk { return NewS3Disk() }
+	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_S3] = func(d models.Disk) models.DiskReadiness {
+		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+			logger.Logger.Debug("drive", "Checking readiness for S3 drive: ", d.GetUUID().String(), ".")
+
+			_client := d.GetCredentials().Authenticate(nil)
+			if _client == nil {
+				return false
+			}
+
+			// HEAD request verifies both the credentials and the bucket
+			err := _client.(*s3.Client).HeadBucket(ctx)
+			if err != nil {
+				logger.Logger.Warning("drive", "S3 bucket of the drive: ", d.GetUUID().String(), " is not accessible: ", err.Error())
+				return false
+			}
+
+			return true
+		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
+	}
+	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_S3] = func() {
+		provider := dbo.Provider{}
+		db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_S3).First(&provider)
+		if provider.Type != constants.PROVIDER_TYPE_S3 {
+			provider.UUID = uuid.New()
+			provider.Type = constants.PROVIDER_TYPE_S3
+			provider.Name = "S3 compatible storage"
+			provider.Logo = "https://cdn.iconscout.com/icon/free/png-256/aws-s3-3521436-2944772.png"
+
+			db.DB.DatabaseHandle.Create(&provider)
+		}
+	}
+}
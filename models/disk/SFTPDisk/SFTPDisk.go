@@ -10,6 +10,7 @@ import (
 	"dcfs/models"
 	"dcfs/models/credentials"
 	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/connpool"
 	"dcfs/util/logger"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -17,25 +18,30 @@ import (
 	"github.com/pkg/sftp"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
 type SFTPDisk struct {
 	abstractDisk AbstractDisk.AbstractDisk
+
+	pool    *connpool.Pool
+	poolMtx sync.Mutex
 }
 
 /* Mandatory Disk interface implementations */
 
 func (d *SFTPDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool, file errors do not break the connection
+	pool := d.getPool()
+	_client, _ := pool.Get(getContext(blockMetadata))
 	if _client == nil {
 		logger.Logger.Error("disk", "Cannot connect to the remote server.")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot connect to the remote server")
 	}
 
-	var client *sftp.Client = _client.(*sftp.Client)
-	defer client.Close()
-	defer d.GetCredentials().(*credentials.SFTPCredentials).SSHConnection.Close()
+	var client *credentials.SFTPConnection = _client.(*credentials.SFTPConnection)
+	defer pool.Put(client)
 
 	_p := d.abstractDisk.Credentials.GetPath()
 	downloadPath := fmt.Sprintf("%s/%s", _p, blockMetadata.UUID.String())
@@ -75,15 +81,16 @@ func (d *SFTPDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.Error
 }
 
 func (d *SFTPDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool, file errors do not break the connection
+	pool := d.getPool()
+	_client, _ := pool.Get(getContext(blockMetadata))
 	if _client == nil {
 		logger.Logger.Error("disk", "Cannot connect to the remote server.")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot connect to the remote server")
 	}
 
-	var client *sftp.Client = _client.(*sftp.Client)
-	defer client.Close()
-	defer d.GetCredentials().(*credentials.SFTPCredentials).SSHConnection.Close()
+	var client *credentials.SFTPConnection = _client.(*credentials.SFTPConnection)
+	defer pool.Put(client)
 
 	_p := d.abstractDisk.Credentials.GetPath()
 	downloadPath := fmt.Sprintf("%s/%s", _p, blockMetadata.UUID.String())
@@ -100,7 +107,6 @@ func (d *SFTPDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.Err
 		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Cannot open remote file:", err.Error())
 	}
 	defer remoteFile.Close()
-	defer d.GetCredentials().(*credentials.SFTPCredentials).SSHConnection.Close()
 
 	// Download remote file
 	buff, err := io.ReadAll(remoteFile)
@@ -117,15 +123,16 @@ func (d *SFTPDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.Err
 }
 
 func (d *SFTPDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool, file errors do not break the connection
+	pool := d.getPool()
+	_client, _ := pool.Get(getContext(blockMetadata))
 	if _client == nil {
 		logger.Logger.Error("disk", "Cannot connect to the remote server.")
 		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot connect to the remote server")
 	}
 
-	var client *sftp.Client = _client.(*sftp.Client)
-	defer client.Close()
-	defer d.GetCredentials().(*credentials.SFTPCredentials).SSHConnection.Close()
+	var client *credentials.SFTPConnection = _client.(*credentials.SFTPConnection)
+	defer pool.Put(client)
 
 	_p := d.abstractDisk.Credentials.GetPath()
 	downloadPath := fmt.Sprintf("%s/%s", _p, blockMetadata.UUID.String())
@@ -186,10 +193,12 @@ func (d *SFTPDisk) GetCreationTime() time.Time {
 
 func (d *SFTPDisk) SetCredentials(credentials credentials.Credentials) {
 	d.abstractDisk.SetCredentials(credentials)
+	d.resetPool()
 }
 
 func (d *SFTPDisk) CreateCredentials(c string) {
 	d.abstractDisk.Credentials = credentials.NewSFTPCredentials(c)
+	d.resetPool()
 }
 
 func (d *SFTPDisk) GetProviderUUID() uuid.UUID {
@@ -208,6 +217,14 @@ func (d *SFTPDisk) GetIsVirtualFlag() bool {
 	return d.abstractDisk.GetIsVirtualFlag()
 }
 
+func (d *SFTPDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *SFTPDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
 func (d *SFTPDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
 	d.abstractDisk.SetVirtualDiskUUID(uuid)
 }
@@ -220,17 +237,16 @@ func (d *SFTPDisk) GetProviderSpace() (uint64, uint64, string) {
 	var stats *sftp.StatVFS
 	var err error
 
-	// Authenticate to the remote server
-	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	// Get an authenticated connection from the pool
+	pool := d.getPool()
+	_client, _ := pool.Get(context.Background())
 	if _client == nil {
 		logger.Logger.Error("disk", "Could not authenticate to get the remote provider space.")
 		return 0, 0, constants.REMOTE_CANNOT_AUTHENTICATE
 	}
 
-	// Connect to the remote server
-	var client *sftp.Client = _client.(*sftp.Client)
-	defer client.Close()
-	defer d.GetCredentials().(*credentials.SFTPCredentials).SSHConnection.Close()
+	var client *credentials.SFTPConnection = _client.(*credentials.SFTPConnection)
+	defer pool.Put(client)
 
 	path := d.abstractDisk.Credentials.GetPath()
 	if path == "" {
@@ -280,6 +296,60 @@ func (d *SFTPDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskRe
 	return d.abstractDisk.GetResponse(_disk, ctx)
 }
 
+/* SFTP disk helper methods */
+
+// getPool - get the pool of connections authenticated with the current credentials
+//
+// return type:
+//   - *connpool.Pool: connection pool of the disk
+func (d *SFTPDisk) getPool() *connpool.Pool {
+	d.poolMtx.Lock()
+	defer d.poolMtx.Unlock()
+
+	if d.pool == nil {
+		d.pool = connpool.NewPool(func(ctx context.Context) (connpool.Connection, string) {
+			conn, errCode := d.GetCredentials().(*credentials.SFTPCredentials).Connect(&apicalls.CredentialsAuthenticateMetadata{
+				Ctx:      ctx,
+				Config:   nil,
+				DiskUUID: d.GetUUID(),
+			})
+			if conn == nil {
+				return nil, errCode
+			}
+
+			return conn, errCode
+		})
+	}
+
+	return d.pool
+}
+
+// resetPool - close the connections authenticated with the previous credentials
+func (d *SFTPDisk) resetPool() {
+	d.poolMtx.Lock()
+	defer d.poolMtx.Unlock()
+
+	if d.pool != nil {
+		d.pool.Close()
+		d.pool = nil
+	}
+}
+
+// getContext - get context of the request performed for the block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block
+//
+// return type:
+//   - context.Context: request context
+func getContext(blockMetadata *apicalls.BlockMetadata) context.Context {
+	if blockMetadata.Ctx == nil {
+		return context.Background()
+	}
+
+	return blockMetadata.Ctx
+}
+
 /* Factory methods */
 func NewSFTPDisk() *SFTPDisk {
 	var d *SFTPDisk = new(SFTPDisk)
@@ -291,23 +361,17 @@ func NewSFTPDisk() *SFTPDisk {
 func init() {
 	models.DiskTypesRegistry[constants.PROVIDER_TYPE_SFTP] = func() models.Disk { return NewSFTPDisk() }
 	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_SFTP] = func(d models.Disk) models.DiskReadiness {
-		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+		return models.NewRealDiskReadinessWithCode(func(ctx context.Context) string {
 			logger.Logger.Debug("drive", "Checking readiness for SFTP drive: ", d.GetUUID().String(), ".")
 
-			_client := d.GetCredentials().Authenticate(&apicalls.CredentialsAuthenticateMetadata{
-				Ctx:      ctx,
-				Config:   nil,
-				DiskUUID: d.GetUUID(),
-			})
-			if _client == nil {
-				return false
+			pool := d.(*SFTPDisk).getPool()
+			client, errCode := pool.Get(ctx)
+			if client == nil {
+				return errCode
 			}
 
-			var client *sftp.Client = _client.(*sftp.Client)
-			defer client.Close()
-			defer d.GetCredentials().(*credentials.SFTPCredentials).SSHConnection.Close()
-
-			return true
+			pool.Put(client)
+			return constants.SUCCESS
 		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
 	}
 	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_SFTP] = func() {
@@ -0,0 +1,283 @@
+package WebDAVDisk
+
+import (
+	"context"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/AbstractDisk"
+	"dcfs/util/logger"
+	"dcfs/util/webdav"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"time"
+)
+
+type WebDAVDisk struct {
+	abstractDisk AbstractDisk.AbstractDisk
+}
+
+/* Mandatory Disk interface implementations */
+
+func (d *WebDAVDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the WebDAV client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create WebDAV client")
+	}
+
+	var client *webdav.Client = _client.(*webdav.Client)
+
+	// Upload the file
+	err := client.Put(getContext(blockMetadata), blockMetadata.UUID.String(), *blockMetadata.Content)
+	if err != nil {
+		logger.Logger.Error("disk", "Failed to upload block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to upload block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+	logger.Logger.Debug("disk", "Successfully uploaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *WebDAVDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the WebDAV client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create WebDAV client")
+	}
+
+	var client *webdav.Client = _client.(*webdav.Client)
+
+	// Download the file
+	buff, err := client.Get(getContext(blockMetadata), blockMetadata.UUID.String())
+	if webdav.IsNotFound(err) {
+		logger.Logger.Error("disk", "Cannot find the block: ", blockMetadata.UUID.String(), " on the WebDAV server.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_BAD_FILE, "Cannot find the block on the WebDAV server:", err.Error())
+	} else if err != nil {
+		logger.Logger.Error("disk", "Failed to download block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to download block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.Content = &buff
+	blockMetadata.Size = int64(len(buff))
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully downloaded the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *WebDAVDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Cannot create the WebDAV client.")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_CANNOT_AUTHENTICATE, "Cannot create WebDAV client")
+	}
+
+	var client *webdav.Client = _client.(*webdav.Client)
+
+	// Remove the file
+	err := client.Delete(getContext(blockMetadata), blockMetadata.UUID.String())
+	if err != nil {
+		logger.Logger.Error("disk", "Failed to remove block: ", blockMetadata.UUID.String(), " with err: ", err.Error(), ".")
+		return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Failed to remove block:", blockMetadata.UUID.String(), "with err:", err.Error())
+	}
+
+	blockMetadata.CompleteCallback(blockMetadata.FileUUID, blockMetadata.Status)
+
+	logger.Logger.Debug("disk", "Successfully removed the block: ", blockMetadata.UUID.String(), ".")
+	return nil
+}
+
+func (d *WebDAVDisk) SetVolume(volume *models.Volume) {
+	d.abstractDisk.SetVolume(volume)
+}
+
+func (d *WebDAVDisk) GetVolume() *models.Volume {
+	return d.abstractDisk.GetVolume()
+}
+
+func (d *WebDAVDisk) SetName(name string) {
+	d.abstractDisk.SetName(name)
+}
+
+func (d *WebDAVDisk) GetName() string {
+	return d.abstractDisk.GetName()
+}
+
+func (d *WebDAVDisk) SetUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetUUID(uuid)
+}
+
+func (d *WebDAVDisk) GetUUID() uuid.UUID {
+	return d.abstractDisk.GetUUID()
+}
+
+func (d *WebDAVDisk) GetCredentials() credentials.Credentials {
+	return d.abstractDisk.GetCredentials()
+}
+
+func (d *WebDAVDisk) SetCreationTime(creationTime time.Time) {
+	d.abstractDisk.SetCreationTime(creationTime)
+}
+
+func (d *WebDAVDisk) GetCreationTime() time.Time {
+	return d.abstractDisk.GetCreationTime()
+}
+
+func (d *WebDAVDisk) SetCredentials(credentials credentials.Credentials) {
+	d.abstractDisk.SetCredentials(credentials)
+}
+
+func (d *WebDAVDisk) CreateCredentials(c string) {
+	d.abstractDisk.Credentials = credentials.NewWebDAVCredentials(c)
+}
+
+func (d *WebDAVDisk) GetProviderUUID() uuid.UUID {
+	return d.abstractDisk.GetProvider(constants.PROVIDER_TYPE_WEBDAV)
+}
+
+func (d *WebDAVDisk) GetDiskDBO(userUUID uuid.UUID, providerUUID uuid.UUID, volumeUUID uuid.UUID) dbo.Disk {
+	return d.abstractDisk.GetDiskDBO(userUUID, providerUUID, volumeUUID)
+}
+
+func (d *WebDAVDisk) SetIsVirtualFlag(isVirtual bool) {
+	d.abstractDisk.SetIsVirtualFlag(isVirtual)
+}
+
+func (d *WebDAVDisk) GetIsVirtualFlag() bool {
+	return d.abstractDisk.GetIsVirtualFlag()
+}
+
+func (d *WebDAVDisk) SetIsSpareFlag(isSpare bool) {
+	d.abstractDisk.SetIsSpareFlag(isSpare)
+}
+
+func (d *WebDAVDisk) GetIsSpareFlag() bool {
+	return d.abstractDisk.GetIsSpareFlag()
+}
+
+func (d *WebDAVDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
+	d.abstractDisk.SetVirtualDiskUUID(uuid)
+}
+
+func (d *WebDAVDisk) GetVirtualDiskUUID() uuid.UUID {
+	return d.abstractDisk.GetVirtualDiskUUID()
+}
+
+func (d *WebDAVDisk) GetProviderSpace() (uint64, uint64, string) {
+	var _client interface{} = d.GetCredentials().Authenticate(nil)
+	if _client == nil {
+		logger.Logger.Error("disk", "Could not create the WebDAV client to get the remote provider space.")
+		return 0, 0, constants.REMOTE_CANNOT_AUTHENTICATE
+	}
+
+	// Read the RFC 4331 quota properties of the base path
+	used, available, err := _client.(*webdav.Client).Quota(context.Background())
+	if err != nil {
+		logger.Logger.Error("disk", "Could not get the remote provider quota: ", err.Error())
+		return 0, 0, constants.REMOTE_CANNOT_GET_STATS
+	}
+
+	// Servers without quota support or with unlimited quota do not report available bytes
+	if used < 0 || available < 0 {
+		return 0, 0, constants.OPERATION_NOT_SUPPORTED
+	}
+
+	return uint64(used), uint64(used + available), constants.SUCCESS
+}
+
+func (d *WebDAVDisk) SetTotalSpace(quota uint64) {
+	d.abstractDisk.SetTotalSpace(quota)
+}
+
+func (d *WebDAVDisk) GetTotalSpace() uint64 {
+	return d.abstractDisk.GetTotalSpace()
+}
+
+func (d *WebDAVDisk) SetUsedSpace(usage uint64) {
+	d.abstractDisk.SetUsedSpace(usage)
+}
+
+func (d *WebDAVDisk) GetUsedSpace() uint64 {
+	return d.abstractDisk.GetUsedSpace()
+}
+
+func (d *WebDAVDisk) UpdateUsedSpace(change int64) {
+	d.abstractDisk.UpdateUsedSpace(change)
+}
+
+func (d *WebDAVDisk) AssignDisk(disk models.Disk) {
+	d.abstractDisk.AssignDisk(disk)
+}
+
+func (d *WebDAVDisk) GetReadiness() models.DiskReadiness {
+	return d.abstractDisk.DiskReadiness
+}
+
+func (d *WebDAVDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskResponse {
+	return d.abstractDisk.GetResponse(_disk, ctx)
+}
+
+/* WebDAV disk helper methods */
+
+// getContext - get context of the request performed for the block
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the block
+//
+// return type:
+//   - context.Context: request context
+func getContext(blockMetadata *apicalls.BlockMetadata) context.Context {
+	if blockMetadata.Ctx == nil {
+		return context.Background()
+	}
+
+	return blockMetadata.Ctx
+}
+
+/* Factory methods */
+func NewWebDAVDisk() *WebDAVDisk {
+	var d *WebDAVDisk = new(WebDAVDisk)
+	d.abstractDisk.Disk = d
+	d.abstractDisk.DiskReadiness = models.DiskReadinessRegistry[constants.PROVIDER_TYPE_WEBDAV](d)
+	return d
+}
+
+func init() {
+	models.DiskTypesRegistry[constants.PROVIDER_TYPE_WEBDAV] = func() models.Disk { return NewWebDAVDisk() }
+	models.DiskReadinessRegistry[constants.PROVIDER_TYPE_WEBDAV] = func(d models.Disk) models.DiskReadiness {
+		return models.NewRealDiskReadiness(func(ctx context.Context) bool {
+			logger.Logger.Debug("drive", "Checking readiness for WebDAV drive: ", d.GetUUID().String(), ".")
+
+			_client := d.GetCredentials().Authenticate(nil)
+			if _client == nil {
+				return false
+			}
+
+			// PROPFIND verifies both the credentials and the base path
+			_, _, err := _client.(*webdav.Client).Quota(ctx)
+			if err != nil {
+				logger.Logger.Warning("drive", "WebDAV base path of the drive: ", d.GetUUID().String(), " is not accessible: ", err.Error())
+				return false
+			}
+
+			return true
+		}, func() bool { return models.Transport.ActiveVolumes.GetEnqueuedInstance(d.GetVolume().UUID) != nil })
+	}
+	models.ProviderTypesRegistry[constants.PROVIDER_TYPE_WEBDAV] = func() {
+		provider := dbo.Provider{}
+		db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_WEBDAV).First(&provider)
+		if provider.Type != constants.PROVIDER_TYPE_WEBDAV {
+			provider.UUID = uuid.New()
+			provider.Type = constants.PROVIDER_TYPE_WEBDAV
+			provider.Name = "WebDAV drive"
+			provider.Logo = "https://cdn-icons-png.flaticon.com/512/2165/2165004.png"
+
+			db.DB.DatabaseHandle.Create(&provider)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"dcfs/constants"
 	"sync"
 	"time"
 )
@@ -10,16 +11,18 @@ type DiskReadiness interface {
 	IsReady(ctx context.Context) bool
 	IsReadyForce(ctx context.Context) bool
 	IsReadyForceNonBlocking(ctx context.Context) bool
+	GetFailureCode() string
 }
 
 type RealDiskReadiness struct {
-	isReady    bool
-	isReadyMtx sync.Mutex
+	isReady     bool
+	failureCode string
+	isReadyMtx  sync.Mutex
 
 	isReadyCheckQueued    bool
 	isReadyCheckQueuedMtx sync.Mutex
 
-	readinessChecker func(ctx context.Context) bool
+	readinessChecker func(ctx context.Context) string
 	alivenessChecker func() bool
 }
 
@@ -54,7 +57,7 @@ func (dr *RealDiskReadiness) isReadyPeriodicCheck(timeout time.Duration, ctx con
 		dr.isReadyMtx.Lock()
 		defer dr.isReadyMtx.Unlock()
 
-		dr.isReady = dr.readinessChecker(ctx)
+		dr.check(ctx)
 
 		dr.isReadyCheckQueuedMtx.Lock()
 		defer dr.isReadyCheckQueuedMtx.Unlock()
@@ -74,7 +77,7 @@ func (dr *RealDiskReadiness) IsReadyForce(ctx context.Context) bool {
 	dr.isReadyMtx.Lock()
 	defer dr.isReadyMtx.Unlock()
 
-	dr.isReady = dr.readinessChecker(ctx)
+	dr.check(ctx)
 	return dr.isReady
 }
 
@@ -88,15 +91,41 @@ func (dr *RealDiskReadiness) IsReadyForceNonBlocking(ctx context.Context) bool {
 		dr.isReadyMtx.Lock()
 		defer dr.isReadyMtx.Unlock()
 
-		dr.isReady = dr.readinessChecker(ctx)
+		dr.check(ctx)
 	}(ctx)
 
 	return dr.isReady
 }
 
+// GetFailureCode - completion code of the last readiness check, constants.SUCCESS if the disk is ready
+func (dr *RealDiskReadiness) GetFailureCode() string {
+	dr.isReadyMtx.Lock()
+	defer dr.isReadyMtx.Unlock()
+
+	return dr.failureCode
+}
+
+// check - run the readiness checker; the caller must hold isReadyMtx
+func (dr *RealDiskReadiness) check(ctx context.Context) {
+	dr.failureCode = dr.readinessChecker(ctx)
+	dr.isReady = dr.failureCode == constants.SUCCESS
+}
+
 func NewRealDiskReadiness(readinessChecker func(ctx context.Context) bool, alivenessChecker func() bool) DiskReadiness {
+	return NewRealDiskReadinessWithCode(func(ctx context.Context) string {
+		if !readinessChecker(ctx) {
+			return constants.REMOTE_CANNOT_AUTHENTICATE
+		}
+
+		return constants.SUCCESS
+	}, alivenessChecker)
+}
+
+// NewRealDiskReadinessWithCode - readiness checker returns constants.SUCCESS or the code describing the failure
+func NewRealDiskReadinessWithCode(readinessChecker func(ctx context.Context) string, alivenessChecker func() bool) DiskReadiness {
 	return &RealDiskReadiness{
 		isReady:               true,
+		failureCode:           constants.SUCCESS,
 		isReadyMtx:            sync.Mutex{},
 		isReadyCheckQueued:    false,
 		isReadyCheckQueuedMtx: sync.Mutex{},
@@ -149,3 +178,13 @@ func (vdr *VirtualDiskReadiness) IsReadyForce(ctx context.Context) bool {
 func (vdr *VirtualDiskReadiness) IsReadyForceNonBlocking(ctx context.Context) bool {
 	return vdr.forAll(func(dr DiskReadiness) bool { return dr.IsReadyForceNonBlocking(ctx) })
 }
+
+func (vdr *VirtualDiskReadiness) GetFailureCode() string {
+	for _, obj := range vdr.RealDiskReadinessObjects {
+		if code := obj.GetFailureCode(); code != constants.SUCCESS {
+			return code
+		}
+	}
+
+	return constants.SUCCESS
+}
@@ -1,24 +1,27 @@
 package models
 
 import (
+	"archive/zip"
+	"crypto/sha256"
 	"dcfs/apicalls"
 	"dcfs/constants"
 	"dcfs/db"
 	"dcfs/db/dbo"
 	"dcfs/requests"
-	"dcfs/util"
-	"dcfs/util/checksum"
 	"dcfs/util/logger"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"log"
-	"math"
+	"hash"
+	"io"
+	"mime"
 	"net/http"
-	"os"
 	"path"
+	"sort"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 )
 
@@ -151,6 +154,9 @@ func (file *AbstractFile) Download(blockMetadata *apicalls.BlockMetadata) *apica
 type RegularFile struct {
 	AbstractFile
 	Blocks map[uuid.UUID]*Block
+
+	Checksum   string // SHA-256 of the plain contents of the file
+	MerkleRoot string // root of the merkle tree over the hashes of the blocks
 }
 
 func (file *RegularFile) Remove() {
@@ -198,7 +204,11 @@ func (file *RegularFile) SetRoot(rootUUID uuid.UUID) {
 }
 
 func (file *RegularFile) GetFileDBO(userUUID uuid.UUID) dbo.File {
-	return file.AbstractFile.GetFileDBO(userUUID)
+	f := file.AbstractFile.GetFileDBO(userUUID)
+	f.Checksum = file.Checksum
+	f.MerkleRoot = file.MerkleRoot
+
+	return f
 }
 
 func (file *RegularFile) IsCompleted() bool {
@@ -377,7 +387,8 @@ func (f *SmallerFileWrapper) Download(blockMetadata *apicalls.BlockMetadata) *ap
 	block := f.GetBlocks()[blockMetadata.UUID]
 	blockCompleteness := "complete"
 
-	blockMetadata.Size = int64(block.Size)
+	blockMetadata.Size = int64(block.GetStoredSize())
+	blockMetadata.RemoteID = block.RemoteID
 	blockMetadata.Status = &block.Status
 	blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
 		*status = constants.BLOCK_STATUS_TRANSFERRED
@@ -395,16 +406,22 @@ func (f *SmallerFileWrapper) Download(blockMetadata *apicalls.BlockMetadata) *ap
 	}
 
 	// verify integrity of the downloaded block
-	chksum := checksum.CalculateChecksum(*blockMetadata.Content)
-	if chksum != block.Checksum {
+	stored, valid := block.GetStoredVersion(*blockMetadata.Content)
+	if !valid {
 		logger.Logger.Error("file", "Checksum of the block: ", block.UUID.String(), " is invalid. Block integrity is compromised.")
 		blockCompleteness = "not complete"
 	}
 
-	err := f.GetVolume().Decrypt(blockMetadata.Content)
+	err := f.GetVolume().Decrypt(blockMetadata.Content, stored.KeyUUID, stored.GetIdentity(f.GetUUID()))
 	if err != nil {
 		logger.Logger.Error("file", "Could not decrypt the block: ", block.UUID.String(), " is invalid. Block integrity is compromised.")
 		blockCompleteness = "not complete"
+	} else if err = f.GetVolume().Decompress(blockMetadata.Content, block.Compression); err != nil {
+		logger.Logger.Error("file", "Could not decompress the block: ", block.UUID.String(), ". Block integrity is compromised.")
+		blockCompleteness = "not complete"
+	} else if !block.VerifyBlockContent(*blockMetadata.Content) {
+		logger.Logger.Error("file", "Hash of the block: ", block.UUID.String(), " is invalid. Block integrity is compromised.")
+		blockCompleteness = "not complete"
 	}
 
 	// the file should be deleted from the download queue after 6 minutes, or after the last block gets transferred
@@ -451,8 +468,10 @@ func (f *FileWrapper) SetSize(newSize int) {
 }
 
 func (f *FileWrapper) GetName() string {
-	if len(f.Files) == 1 {
+	if len(f.Files) == 1 && f.Files[0].GetType() == constants.FILE_TYPE_REGULAR {
 		return f.Files[0].GetName()
+	} else if len(f.Files) == 1 {
+		return f.Files[0].GetName() + ".zip"
 	}
 
 	return "files.zip"
@@ -534,276 +553,407 @@ func (f *FileWrapper) GetBlocks() map[uuid.UUID]*Block {
 	return ret
 }
 
-func (f *FileWrapper) downloadFile(_path string, file File, blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	downloadpath := path.Join(_path, file.GetName())
-	brokenBlocks := make([]uuid.UUID, 0)
-	brokenBlocksMtx := sync.Mutex{}
-	blockSize := file.GetVolume().BlockSize
+// Download - stream the files of the wrapper to the client
+//
+// A single regular file is streamed as is, multiple files and directories
+// are streamed as a ZIP archive. Blocks are downloaded a few at a time and
+// written to the response in order, so no file is staged on the server's
+// disk. As the response is already sent when the blocks are verified,
+// the integrity of the download is reported in the File-Completeness
+// trailer, and entries whose blocks failed verification are listed in
+// the integrity report appended to the archive.
+//
+// params:
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the download request
+//
+// return type:
+//   - *apicalls.ErrorWrapper: error wrapper if the download could not be started, nil otherwise
+func (f *FileWrapper) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	var report []DownloadIntegrityEntry = make([]DownloadIntegrityEntry, 0)
+	var err error
+	ctx := blockMetadata.Ctx
 
-	_file, err := os.Create(downloadpath)
-	if err != nil {
-		return &apicalls.ErrorWrapper{
-			Error: err,
-			Code:  constants.REAL_FS_CREATE_FILE_ERROR,
+	for _, file := range f.Files {
+		if file == nil || (file.GetType() != constants.FILE_TYPE_REGULAR && file.GetType() != constants.FILE_TYPE_DIRECTORY) {
+			return apicalls.CreateErrorWrapper(constants.FS_BAD_FILE, "Cannot download a file of unknown type")
 		}
 	}
 
-	err = _file.Close()
-	if err != nil {
-		return &apicalls.ErrorWrapper{
-			Error: err,
-			Code:  constants.REAL_FS_CLOSE_FILE_ERROR,
-		}
+	contentType := "application/zip"
+	if len(f.Files) == 1 && f.Files[0].GetType() == constants.FILE_TYPE_REGULAR {
+		contentType = "application/octet-stream"
 	}
 
-	var wg sync.WaitGroup
-	for _, block := range file.GetBlocks() {
-		wg.Add(1)
-
-		go func(_f *os.File, _b *Block) {
-			defer wg.Done()
-
-			bm := &apicalls.BlockMetadata{
-				Ctx:      blockMetadata.Ctx,
-				FileUUID: blockMetadata.FileUUID,
-				UUID:     _b.UUID,
-				Size:     int64(_b.Size),
-				Status:   &_b.Status,
-				Content:  new([]uint8),
-				Checksum: _b.Checksum,
-				CompleteCallback: func(UUID uuid.UUID, status *int) {
-					*status = constants.BLOCK_STATUS_TRANSFERRED
-				},
-			}
+	ctx.Header("Access-Control-Expose-Headers", "File-Completeness")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.GetName()}))
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Trailer", "File-Completeness")
+	ctx.Status(http.StatusOK)
+
+	if contentType == "application/octet-stream" {
+		var brokenBlocks []uuid.UUID
+		brokenBlocks, err = f.writeFile(ctx.Writer, f.Files[0], blockMetadata)
+		if len(brokenBlocks) > 0 {
+			report = append(report, newDownloadIntegrityEntry(f.Files[0].GetName(), f.Files[0], brokenBlocks))
+		}
+	} else {
+		archive := zip.NewWriter(ctx.Writer)
+		names := make(map[string]bool)
 
-			errWrapper := _b.Disk.Download(bm)
-			if errWrapper != nil {
-				// one retry
-				errWrapper = _b.Disk.Download(bm)
-				if errWrapper != nil {
-					logger.Logger.Error("file", "Failed to download the block: ", bm.UUID.String(), " which is the ", strconv.Itoa(_b.Order), " block of the file: ", bm.FileUUID.String(), ".")
-
-					brokenBlocksMtx.Lock()
-					if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-						brokenBlocks = append(brokenBlocks, bm.UUID)
-					}
-					brokenBlocksMtx.Unlock()
-
-					// at this point, the writing procedure can be skipped
-					return
-				}
+		for _, file := range f.Files {
+			err = f.writeArchiveEntry(archive, uniqueEntryName("", file.GetName(), names), file, blockMetadata, &report)
+			if err != nil {
+				break
 			}
+		}
 
-			_checksum := checksum.CalculateChecksum(*bm.Content)
-			if _checksum != _b.Checksum {
-				logger.Logger.Debug("file", "Checksum of downloaded block: ", _b.UUID.String(), " is invalid. Block integrity is compromised.")
+		if err == nil && len(report) > 0 {
+			err = writeIntegrityReport(archive, report)
+		}
 
-				brokenBlocksMtx.Lock()
-				if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-					brokenBlocks = append(brokenBlocks, bm.UUID)
-				}
-				brokenBlocksMtx.Unlock()
-			}
+		if err == nil {
+			err = archive.Close()
+		}
+	}
 
-			// decrypt the file if needed
-			err = file.GetVolume().Decrypt(bm.Content)
-			if err != nil {
-				logger.Logger.Error("file", "Could not decrypt the block: ", _b.UUID.String(), " is invalid. Block integrity is compromised.")
+	if err != nil {
+		// The response has already been started, so the client can only notice the truncated stream
+		logger.Logger.Error("file", "Could not stream the download: ", f.UUID.String(), " to the client: ", err.Error(), ".")
+		return nil
+	}
 
-				brokenBlocksMtx.Lock()
-				if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-					brokenBlocks = append(brokenBlocks, bm.UUID)
-				}
-				brokenBlocksMtx.Unlock()
-			}
+	fileCompleteness := "complete"
+	if len(report) > 0 {
+		logger.Logger.Warning("file", strconv.Itoa(len(report)), " files of the download: ", f.UUID.String(), " are corrupted but downloaded.")
+		fileCompleteness = "not complete"
+	}
 
-			dest, err := os.OpenFile(downloadpath, os.O_RDWR, 777)
-			if err != nil {
-				brokenBlocksMtx.Lock()
-				if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-					brokenBlocks = append(brokenBlocks, bm.UUID)
-				}
-				brokenBlocksMtx.Unlock()
-
-				// at this point, the writing procedure can be skipped
-				return
-			}
+	ctx.Writer.Header().Set("File-Completeness", fileCompleteness)
+	return nil
+}
 
-			defer func() {
-				err := dest.Close()
-				if err != nil {
-					brokenBlocksMtx.Lock()
-					if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-						brokenBlocks = append(brokenBlocks, bm.UUID)
-					}
-					brokenBlocksMtx.Unlock()
-
-					// at this point, the writing procedure can be skipped
-					return
-				}
-			}()
-
-			// we may assume, every block is equal size, only the last one may be bigger / smaller
-			_, err = dest.Seek(int64(_b.Order*blockSize), 0)
-			if err != nil {
-				brokenBlocksMtx.Lock()
-				if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-					brokenBlocks = append(brokenBlocks, bm.UUID)
-				}
-				brokenBlocksMtx.Unlock()
-
-				// at this point, the writing procedure can be skipped
-				return
-			}
+// DownloadIntegrityEntry - integrity status of the downloaded file whose blocks failed verification
+type DownloadIntegrityEntry struct {
+	Path         string      `json:"path"`
+	FileUUID     uuid.UUID   `json:"fileUUID"`
+	Status       string      `json:"status"`
+	BrokenBlocks []uuid.UUID `json:"brokenBlocks"`
+}
+
+// newDownloadIntegrityEntry - create integrity status of the downloaded file
+//
+// params:
+//   - _path string: path of the file in the archive
+//   - file File: downloaded file
+//   - brokenBlocks []uuid.UUID: UUIDs of the blocks which failed verification
+//
+// return type:
+//   - DownloadIntegrityEntry: created integrity status
+func newDownloadIntegrityEntry(_path string, file File, brokenBlocks []uuid.UUID) DownloadIntegrityEntry {
+	return DownloadIntegrityEntry{
+		Path:         _path,
+		FileUUID:     file.GetUUID(),
+		Status:       "not complete",
+		BrokenBlocks: brokenBlocks,
+	}
+}
 
-			var n int
-			n, err = dest.Write(*bm.Content)
+// writeArchiveEntry - write the file or the directory tree to the archive
+//
+// params:
+//   - archive *zip.Writer: archive streamed to the client
+//   - _path string: path of the entry in the archive
+//   - file File: file or directory to write
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the download request
+//   - report *[]DownloadIntegrityEntry: integrity report of the download
+//
+// return type:
+//   - error: error of writing to the client, nil otherwise
+func (f *FileWrapper) writeArchiveEntry(archive *zip.Writer, _path string, file File, blockMetadata *apicalls.BlockMetadata, report *[]DownloadIntegrityEntry) error {
+	if file.GetType() == constants.FILE_TYPE_DIRECTORY {
+		_, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     _path + "/",
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
 
+		names := make(map[string]bool)
+		for _, child := range file.(*Directory).Files {
+			err = f.writeArchiveEntry(archive, uniqueEntryName(_path, child.GetName(), names), child, blockMetadata, report)
 			if err != nil {
-				brokenBlocksMtx.Lock()
-				if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-					brokenBlocks = append(brokenBlocks, bm.UUID)
-				}
-				brokenBlocksMtx.Unlock()
-
-				// at this point, the writing procedure can be skipped
-				return
+				return err
 			}
+		}
+
+		return nil
+	}
 
-			if n != _b.Size {
-				brokenBlocksMtx.Lock()
-				if !util.SliceContains[uuid.UUID](brokenBlocks, bm.UUID) {
-					brokenBlocks = append(brokenBlocks, bm.UUID)
-				}
-				brokenBlocksMtx.Unlock()
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     _path,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
 
-				// at this point, the writing procedure can be skipped
-				return
-			}
-		}(_file, block)
+	brokenBlocks, err := f.writeFile(writer, file, blockMetadata)
+	if len(brokenBlocks) > 0 {
+		*report = append(*report, newDownloadIntegrityEntry(_path, file, brokenBlocks))
 	}
 
-	wg.Wait()
+	return err
+}
 
-	if len(brokenBlocks) >= len(file.GetBlocks()) {
-		logger.Logger.Error("file", "All blocks of the file: ", blockMetadata.FileUUID.String(), " failed to download.")
+// downloadedBlock - contents of the block downloaded for the wrapper
+type downloadedBlock struct {
+	content []uint8
+	broken  bool
+}
 
-		return &apicalls.ErrorWrapper{
-			Error: fmt.Errorf("Block downloading failed"),
-			Code:  constants.REMOTE_FAILED_JOB,
-		}
-	} else if len(brokenBlocks) > 0 {
-		logger.Logger.Warning("blocks", strconv.Itoa(len(brokenBlocks)), " out of ", strconv.Itoa(len(file.GetBlocks())), " failed to download. Sending a corrupted file.")
-		return &apicalls.ErrorWrapper{
-			Error: fmt.Errorf("blocks %s out of %s failed to download. Sending a corrupted file", strconv.Itoa(len(brokenBlocks)), strconv.Itoa(len(file.GetBlocks()))),
-			Code:  constants.REMOTE_CORRUPTED_BLOCKS,
-		}
+// writeFile - download the blocks of the file and write them in order
+//
+// params:
+//   - writer io.Writer: destination of the file contents
+//   - file File: downloaded file
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the download request
+//
+// return type:
+//   - []uuid.UUID: UUIDs of the blocks which failed verification
+//   - error: error of writing to the destination, nil otherwise
+func (f *FileWrapper) writeFile(writer io.Writer, file File, blockMetadata *apicalls.BlockMetadata) ([]uuid.UUID, error) {
+	if file.GetSize() == 0 {
+		return make([]uuid.UUID, 0), nil
 	}
 
-	return nil
+	return WriteFileRange(writer, file, 0, int64(file.GetSize())-1, blockMetadata)
 }
 
-func (f *FileWrapper) downloadDirectory(_path string, dir *Directory, blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	downloadPath := path.Join(_path, dir.GetName())
-	err := os.MkdirAll(downloadPath, 0777)
-	corruptedFiles := 0
+// WriteFileRange - download the blocks covering the byte range of the file and write the range
+//
+// Only the blocks overlapping the range are downloaded, their offsets in the file
+// are determined by their order and the block size of the volume. Blocks which
+// cannot be downloaded or decrypted are replaced with zeros, so the offsets of
+// the remaining blocks are preserved. If the hashes of the blocks do not match
+// the merkle root of the file, or the whole file does not match its checksum,
+// all written blocks are reported as failed, as the corrupted one is unknown.
+//
+// params:
+//   - writer io.Writer: destination of the file contents
+//   - file File: downloaded file
+//   - start int64: offset of the first byte of the range
+//   - end int64: offset of the last byte of the range (inclusive)
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the download request
+//
+// return type:
+//   - []uuid.UUID: UUIDs of the blocks which failed verification
+//   - error: error of writing to the destination, nil otherwise
+func WriteFileRange(writer io.Writer, file File, start int64, end int64, blockMetadata *apicalls.BlockMetadata) ([]uuid.UUID, error) {
+	var brokenBlocks []uuid.UUID = make([]uuid.UUID, 0)
+	var blockSize int64 = int64(file.GetVolume().BlockSize)
+	var blocks []*Block
+	var trusted bool = true
+
+	// Verify that the blocks of the file were not tampered with
+	regularFile, isRegular := file.(*RegularFile)
+	if isRegular && !regularFile.VerifyMerkleRoot() {
+		logger.Logger.Error("file", "Blocks of the file: ", file.GetUUID().String(), " do not match its merkle root. File integrity is compromised.")
+		trusted = false
+	}
 
-	if err != nil {
-		return &apicalls.ErrorWrapper{
-			Error: err,
-			Code:  constants.REAL_FS_CREATE_DIR_ERROR,
+	// Calculate the checksum of the whole file when it is downloaded entirely
+	var fileHash hash.Hash
+	if isRegular && regularFile.Checksum != "" && start == 0 && end == int64(file.GetSize())-1 {
+		fileHash = sha256.New()
+		writer = io.MultiWriter(writer, fileHash)
+	}
+
+	for _, block := range file.GetBlocks() {
+		if int64(block.Order) >= start/blockSize && int64(block.Order) <= end/blockSize {
+			blocks = append(blocks, block)
 		}
 	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Order < blocks[j].Order })
+
+	// Prefetch a few blocks ahead of the one being written
+	results := make([]chan downloadedBlock, len(blocks))
+	prefetch := func(i int) {
+		results[i] = make(chan downloadedBlock, 1)
+		go func(_b *Block, result chan downloadedBlock) {
+			result <- downloadWrappedBlock(file, _b, blockMetadata)
+		}(blocks[i], results[i])
+	}
 
-	for _, file := range dir.Files {
-		errWrapper := f.downloadFile(downloadPath, file, blockMetadata)
-		if errWrapper != nil {
-			if errWrapper.Code == constants.REMOTE_CORRUPTED_BLOCKS {
-				logger.Logger.Warning("file", "File: ", file.GetUUID().String(), " is corrupted but downloaded.")
-			} else {
-				logger.Logger.Warning("file", "File: ", file.GetUUID().String(), " is corrupted and not downloaded.")
-			}
+	for i := 0; i < len(blocks) && i < constants.DOWNLOAD_PREFETCH_BLOCKS; i++ {
+		prefetch(i)
+	}
 
-			corruptedFiles++
+	for i, block := range blocks {
+		result := <-results[i]
+		if i+constants.DOWNLOAD_PREFETCH_BLOCKS < len(blocks) {
+			prefetch(i + constants.DOWNLOAD_PREFETCH_BLOCKS)
+		}
+
+		if result.broken {
+			brokenBlocks = append(brokenBlocks, block.UUID)
+		}
+
+		// Trim the blocks at the edges of the range
+		blockStart := int64(block.Order) * blockSize
+		from, to := start-blockStart, end-blockStart+1
+		if from < 0 {
+			from = 0
+		}
+		if to > int64(len(result.content)) {
+			to = int64(len(result.content))
+		}
+		if from >= to {
+			continue
 		}
-	}
 
-	if corruptedFiles > 0 || corruptedFiles < len(dir.Files) {
-		return &apicalls.ErrorWrapper{
-			Error: fmt.Errorf("some files were corrupted and may not have been downloaded"),
-			Code:  constants.REMOTE_CORRUPTED_FILES,
+		_, err := writer.Write(result.content[from:to])
+		if err != nil {
+			return brokenBlocks, err
 		}
 	}
 
-	if corruptedFiles >= len(dir.Files) {
-		return &apicalls.ErrorWrapper{
-			Error: fmt.Errorf("all selected files failed to download"),
-			Code:  constants.REMOTE_BAD_FILE,
+	if fileHash != nil && len(brokenBlocks) == 0 && hex.EncodeToString(fileHash.Sum(nil)) != regularFile.Checksum {
+		logger.Logger.Error("file", "Checksum of the file: ", file.GetUUID().String(), " is invalid. File integrity is compromised.")
+		trusted = false
+	}
+
+	if !trusted {
+		brokenBlocks = make([]uuid.UUID, 0, len(blocks))
+		for _, block := range blocks {
+			brokenBlocks = append(brokenBlocks, block.UUID)
 		}
 	}
 
-	return nil
+	return brokenBlocks, nil
 }
 
-func (f *FileWrapper) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
-	downloadPath := path.Join("./Download", f.GetName())
-	err := os.MkdirAll(downloadPath, 0777)
-	fileCompleteness := "complete"
+// downloadWrappedBlock - download, verify and decrypt the block of the file
+//
+// params:
+//   - file File: file of the block
+//   - block *Block: block to download
+//   - blockMetadata *apicalls.BlockMetadata: metadata of the download request
+//
+// return type:
+//   - downloadedBlock: decrypted contents of the block and its integrity status
+func downloadWrappedBlock(file File, block *Block, blockMetadata *apicalls.BlockMetadata) downloadedBlock {
+	bm := &apicalls.BlockMetadata{
+		Ctx:             blockMetadata.Ctx,
+		FileUUID:        file.GetUUID(),
+		UUID:            block.UUID,
+		Size:            int64(block.GetStoredSize()),
+		Status:          &block.Status,
+		Content:         new([]uint8),
+		Checksum:        block.Checksum,
+		RemoteID:        block.RemoteID,
+		PendingChecksum: block.PendingChecksum,
+		CompleteCallback: func(UUID uuid.UUID, status *int) {
+			*status = constants.BLOCK_STATUS_TRANSFERRED
+		},
+	}
 
-	if err != nil {
-		return &apicalls.ErrorWrapper{
-			Error: err,
-			Code:  constants.REAL_FS_CREATE_DIR_ERROR,
-		}
+	if block.Disk == nil {
+		logger.Logger.Error("file", "The disk of the block: ", block.UUID.String(), " of the file: ", file.GetUUID().String(), " is unavailable.")
+		return downloadedBlock{content: make([]uint8, block.Size), broken: true}
 	}
 
-	for _, file := range f.Files {
-		if file.GetType() == constants.FILE_TYPE_DIRECTORY {
-			errWrapper := f.downloadDirectory(downloadPath, file.(*Directory), blockMetadata)
-			if errWrapper != nil {
-				if errWrapper.Code == constants.REMOTE_CORRUPTED_FILES {
-					fileCompleteness = "not complete"
-				} else {
-					return errWrapper
-				}
-			}
-		} else if file.GetType() == constants.FILE_TYPE_REGULAR {
-			errWrapper := f.downloadFile(downloadPath, file, blockMetadata)
-			if errWrapper != nil {
-				if errWrapper.Code == constants.REMOTE_CORRUPTED_BLOCKS {
-					fileCompleteness = "not complete"
-				} else {
-					return errWrapper
-				}
-			}
-		} else {
-			return &apicalls.ErrorWrapper{
-				Error: nil,
-				Code:  constants.FS_BAD_FILE,
-			}
+	errWrapper := block.Disk.Download(bm)
+	if errWrapper != nil {
+		// one retry
+		errWrapper = block.Disk.Download(bm)
+		if errWrapper != nil {
+			logger.Logger.Error("file", "Failed to download the block: ", bm.UUID.String(), " which is the ", strconv.Itoa(block.Order), " block of the file: ", bm.FileUUID.String(), ".")
+			return downloadedBlock{content: make([]uint8, block.Size), broken: true}
 		}
 	}
 
-	filename := f.Files[0].GetName()
-	if len(f.Files) > 1 || len(f.Files) == 1 && f.Files[0].GetType() == constants.FILE_TYPE_DIRECTORY {
-		// zip files and send the zip
-		filename = "files.zip"
+	var broken bool = false
+	stored, valid := block.GetStoredVersion(*bm.Content)
+	if !valid {
+		logger.Logger.Warning("file", "Checksum of downloaded block: ", block.UUID.String(), " is invalid. Block integrity is compromised.")
+		broken = true
 	}
 
-	blockMetadata.Ctx.Header("Access-Control-Expose-Headers", "File-Completeness")
-	blockMetadata.Ctx.Header("File-Completeness", fileCompleteness)
-	blockMetadata.Ctx.File(path.Join(downloadPath, filename))
+	// decrypt the block if needed
+	err := file.GetVolume().Decrypt(bm.Content, stored.KeyUUID, stored.GetIdentity(file.GetUUID()))
+	if err != nil {
+		logger.Logger.Error("file", "Could not decrypt the block: ", block.UUID.String(), ". Block integrity is compromised.")
+		return downloadedBlock{content: make([]uint8, block.Size), broken: true}
+	}
 
-	// the files should reside on the server for 1hr x 1GiB
-	t := int(math.Max(1, math.Ceil(float64(f.GetSize())/float64(1024*1024*1024))))
-	time.AfterFunc(time.Duration(t)*60*time.Minute, func() {
-		err := os.RemoveAll(downloadPath)
-		if err != nil {
-			log.Printf("Could not remove file: %s", filename)
-		}
+	// decompress the block if needed
+	err = file.GetVolume().Decompress(bm.Content, block.Compression)
+	if err != nil {
+		logger.Logger.Error("file", "Could not decompress the block: ", block.UUID.String(), ". Block integrity is compromised.")
+		return downloadedBlock{content: make([]uint8, block.Size), broken: true}
+	}
+
+	// verify the plain contents of the block
+	if !block.VerifyBlockContent(*bm.Content) {
+		logger.Logger.Warning("file", "Hash of downloaded block: ", block.UUID.String(), " is invalid. Block integrity is compromised.")
+		broken = true
+	}
+
+	return downloadedBlock{content: *bm.Content, broken: broken}
+}
+
+// writeIntegrityReport - append the integrity report of the download to the archive
+//
+// params:
+//   - archive *zip.Writer: archive streamed to the client
+//   - report []DownloadIntegrityEntry: integrity report of the download
+//
+// return type:
+//   - error: error of writing to the client, nil otherwise
+func writeIntegrityReport(archive *zip.Writer, report []DownloadIntegrityEntry) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     constants.DOWNLOAD_INTEGRITY_REPORT_NAME,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
 	})
+	if err != nil {
+		return err
+	}
 
-	return nil
+	_, err = writer.Write(content)
+	return err
+}
+
+// uniqueEntryName - generate path of the archive entry which does not collide with its siblings
+//
+// params:
+//   - parent string: path of the parent directory in the archive
+//   - name string: name of the file
+//   - names map[string]bool: names already used in the parent directory
+//
+// return type:
+//   - string: path of the entry in the archive
+func uniqueEntryName(parent string, name string, names map[string]bool) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." || name == constants.DOWNLOAD_INTEGRITY_REPORT_NAME {
+		name = "_" + name
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; names[name]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	names[name] = true
+
+	return path.Join(parent, name)
 }
 
 func NewFileWrapper(filetype int, actualFiles []File) File {
@@ -887,7 +1037,9 @@ func NewFileFromDBO(fileDBO *dbo.File) File {
 				Parent:   nil, // don't want to walk all the way up to '/'
 				Volume:   Transport.GetVolume(fileDBO.VolumeUUID),
 			},
-			Blocks: blocks,
+			Blocks:     blocks,
+			Checksum:   fileDBO.Checksum,
+			MerkleRoot: fileDBO.MerkleRoot,
 		}
 	} else {
 		return nil
@@ -914,13 +1066,18 @@ func NewDirectoryFromDBO(directoryDBO *dbo.File) File {
 	var _files []dbo.File
 	var files []File
 
-	err := db.DB.DatabaseHandle.Where("parent_uuid = ?", directoryDBO.UUID.String()).Find(&_files).Error
+	err := db.DB.DatabaseHandle.Where("root_uuid = ?", directoryDBO.UUID.String()).Find(&_files).Error
 	if err != nil {
 		return nil
 	}
 
 	for _, _f := range _files {
 		f := NewFileFromDBO(&_f)
+		if f == nil {
+			logger.Logger.Warning("file", "Could not load the file: ", _f.UUID.String(), " of the directory: ", directoryDBO.UUID.String(), ".")
+			continue
+		}
+
 		files = append(files, f)
 	}
 
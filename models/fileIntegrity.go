@@ -0,0 +1,289 @@
+package models
+
+import (
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"dcfs/util/merkle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"sort"
+)
+
+// ErrIntegrityUnknown - the file was uploaded before its blocks were hashed, so there is no merkle tree to verify
+var ErrIntegrityUnknown = errors.New("the file has no merkle root")
+
+// BlockProof - proof of inclusion of the block in the merkle tree of the file
+type BlockProof struct {
+	BlockUUID uuid.UUID          `json:"blockUUID"`
+	Order     int                `json:"order"`
+	Hash      string             `json:"hash"`
+	Proof     []merkle.ProofNode `json:"proof"`
+}
+
+// ComputeMerkleRoot - calculate the root of the merkle tree over the hashes of the blocks of the file
+//
+// return type:
+//   - string: root of the tree, empty if any block of the file was not hashed
+func (file *RegularFile) ComputeMerkleRoot() string {
+	leaves := getBlockHashes(getOrderedBlocks(file.Blocks))
+	if leaves == nil {
+		return ""
+	}
+
+	root, err := merkle.Root(leaves)
+	if err != nil {
+		logger.Logger.Error("file", "Could not calculate the merkle root of the file: ", file.UUID.String(), ", got an error: ", err.Error())
+		return ""
+	}
+
+	return root
+}
+
+// VerifyMerkleRoot - check that the hashes of the blocks match the merkle root saved on upload
+//
+// Files uploaded before the blocks were hashed have no merkle root and are always trusted.
+//
+// return type:
+//   - bool: false if the blocks of the file were tampered with, true otherwise
+func (file *RegularFile) VerifyMerkleRoot() bool {
+	if file.MerkleRoot == "" {
+		return true
+	}
+
+	return file.ComputeMerkleRoot() == file.MerkleRoot
+}
+
+// GetBlockProof - build the proof of inclusion of the block in the merkle tree of the file
+//
+// params:
+//   - blockUUID uuid.UUID: UUID of the block
+//
+// return type:
+//   - *BlockProof: proof of the block, nil if the block does not belong to the file
+//   - error: ErrIntegrityUnknown if the file has no merkle root, other error if the proof cannot be built
+func (file *RegularFile) GetBlockProof(blockUUID uuid.UUID) (*BlockProof, error) {
+	if file.MerkleRoot == "" {
+		return nil, ErrIntegrityUnknown
+	}
+
+	blocks := getOrderedBlocks(file.Blocks)
+	leaves := getBlockHashes(blocks)
+	if leaves == nil {
+		return nil, ErrIntegrityUnknown
+	}
+
+	for index, block := range blocks {
+		if block.UUID != blockUUID {
+			continue
+		}
+
+		proof, err := merkle.Proof(leaves, index)
+		if err != nil {
+			return nil, err
+		}
+
+		return &BlockProof{BlockUUID: block.UUID, Order: block.Order, Hash: block.ContentHash, Proof: proof}, nil
+	}
+
+	return nil, nil
+}
+
+// VerifyBlockProof - check that the stored contents of the block lead to the merkle root of the file
+//
+// The block is downloaded and decrypted, so the proof is verified against its actual
+// contents rather than the hash saved in the database.
+//
+// params:
+//   - ctx *gin.Context: context of the request
+//   - proof *BlockProof: proof of inclusion of the block
+//
+// return type:
+//   - bool: true if the contents of the block are intact and belong to the file, false otherwise
+func (file *RegularFile) VerifyBlockProof(ctx *gin.Context, proof *BlockProof) bool {
+	block := file.Blocks[proof.BlockUUID]
+	if block == nil {
+		return false
+	}
+
+	downloaded := downloadWrappedBlock(file, block, &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.UUID})
+	if downloaded.broken {
+		return false
+	}
+
+	return merkle.Verify(merkle.HashContent(downloaded.content), proof.Proof, file.MerkleRoot)
+}
+
+// VerifyBlockContent - check that the plain contents of the block match the hash saved on upload
+//
+// Blocks uploaded before they were hashed cannot be verified and are always trusted.
+//
+// params:
+//   - contents []uint8: decrypted and decompressed contents of the block
+//
+// return type:
+//   - bool: false if the contents of the block are corrupted, true otherwise
+func (block *Block) VerifyBlockContent(contents []uint8) bool {
+	return block.ContentHash == "" || merkle.HashContent(contents) == block.ContentHash
+}
+
+// VerifyStreamedFile - compare the checksum of the streamed file with the one provided by the client
+//
+// Blocks of the file are removed from the disks if the checksums do not match.
+//
+// params:
+//   - ctx *gin.Context: context of the request
+//   - file *RegularFile: streamed file
+//   - expectedChecksum string: SHA-256 of the file provided by the client, empty to skip the verification
+//
+// return type:
+//   - *apicalls.ErrorWrapper: error wrapper if the checksums do not match, nil otherwise
+func VerifyStreamedFile(ctx *gin.Context, file *RegularFile, expectedChecksum string) *apicalls.ErrorWrapper {
+	if expectedChecksum == "" || expectedChecksum == file.Checksum {
+		return nil
+	}
+
+	logger.Logger.Error("transport", "Checksum of the streamed file: ", file.UUID.String(), " does not match the one provided by the client.")
+	abortStreamedUpload(ctx, file)
+	return apicalls.CreateErrorWrapper(constants.FS_CHECKSUM_MISMATCH, "The checksum of the uploaded file does not match the provided one.")
+}
+
+// verifyMerkleRoots - rebuild the merkle roots of the files stored on the volume from the contents of their blocks
+//
+// Hashes of the plain contents of the blocks verified by the scrub are reused, the other
+// blocks (e.g. verified before the scrub was interrupted) are downloaded and decrypted again.
+//
+// params:
+//   - volume *Volume: scrubbed volume
+//   - contentHashes map[uuid.UUID]string: hashes of the plain contents of the verified blocks
+//
+// return type:
+//   - int: number of files whose blocks do not match their merkle root
+//   - int: number of files whose blocks could not be verified
+//   - error: database operation error
+func verifyMerkleRoots(volume *Volume, contentHashes map[uuid.UUID]string) (int, int, error) {
+	var lastFileUUID uuid.UUID = uuid.Nil
+	var corrupted int = 0
+	var unverified int = 0
+
+	for {
+		var files []dbo.File
+
+		err := db.DB.DatabaseHandle.Where("volume_uuid = ? AND merkle_root <> ? AND uuid > ?", volume.UUID, "", lastFileUUID).Order("uuid").Limit(constants.SCRUB_BATCH_SIZE).Find(&files).Error
+		if err != nil {
+			return corrupted, unverified, err
+		}
+
+		if len(files) == 0 {
+			return corrupted, unverified, nil
+		}
+
+		for _, _file := range files {
+			_blocks, errCode := db.BlocksFromDatabase(_file.UUID.String())
+			if errCode != constants.SUCCESS {
+				return corrupted, unverified, errors.New("cannot retrieve blocks of the file: " + _file.UUID.String())
+			}
+
+			switch verifyFileContents(volume, _blocks, _file.MerkleRoot, contentHashes) {
+			case constants.FS_CHECKSUM_MISMATCH:
+				logger.Logger.Error("scrub", "Blocks of the file: ", _file.UUID.String(), " do not match its merkle root.")
+				corrupted++
+			case constants.FS_INTEGRITY_UNKNOWN:
+				logger.Logger.Warning("scrub", "Blocks of the file: ", _file.UUID.String(), " could not be verified against its merkle root.")
+				unverified++
+			}
+		}
+
+		lastFileUUID = files[len(files)-1].UUID
+	}
+}
+
+// verifyFileContents - compare the merkle root built from the plain contents of the blocks with the saved one
+//
+// params:
+//   - volume *Volume: volume storing the file
+//   - blocks []*dbo.Block: blocks of the file
+//   - merkleRoot string: merkle root saved on upload
+//   - contentHashes map[uuid.UUID]string: hashes of the plain contents of the verified blocks
+//
+// return type:
+//   - string: constants.SUCCESS if the contents match the merkle root, constants.FS_CHECKSUM_MISMATCH
+//     if they do not, constants.FS_INTEGRITY_UNKNOWN if any block could not be verified
+func verifyFileContents(volume *Volume, blocks []*dbo.Block, merkleRoot string, contentHashes map[uuid.UUID]string) string {
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Order < blocks[j].Order })
+
+	var leaves []string = make([]string, 0, len(blocks))
+	var code string = constants.SUCCESS
+	for _, block := range blocks {
+		contentHash, ok := contentHashes[block.UUID]
+		if !ok {
+			disk := volume.GetDisk(block.DiskUUID)
+			if disk == nil {
+				code = constants.FS_INTEGRITY_UNKNOWN
+				continue
+			}
+
+			var blockCode string
+			contentHash, blockCode = verifyScrubbedBlockContent(volume, disk, *block, nil)
+			if blockCode == constants.FS_CHECKSUM_MISMATCH {
+				return blockCode
+			} else if blockCode != constants.SUCCESS {
+				code = blockCode
+				continue
+			}
+		}
+
+		leaves = append(leaves, contentHash)
+	}
+
+	if code != constants.SUCCESS {
+		return code
+	}
+
+	root, err := merkle.Root(leaves)
+	if err != nil || root != merkleRoot {
+		return constants.FS_CHECKSUM_MISMATCH
+	}
+
+	return constants.SUCCESS
+}
+
+// getOrderedBlocks - sort the blocks of the file by their order
+//
+// params:
+//   - blocks map[uuid.UUID]*Block: blocks of the file
+//
+// return type:
+//   - []*Block: blocks in order of their contents in the file
+func getOrderedBlocks(blocks map[uuid.UUID]*Block) []*Block {
+	var ordered []*Block = make([]*Block, 0, len(blocks))
+	for _, block := range blocks {
+		ordered = append(ordered, block)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Order < ordered[j].Order })
+	return ordered
+}
+
+// getBlockHashes - retrieve the hashes of the blocks, the leaves of the merkle tree
+//
+// params:
+//   - blocks []*Block: ordered blocks of the file
+//
+// return type:
+//   - []string: hashes of the blocks, nil if any block was not hashed
+func getBlockHashes(blocks []*Block) []string {
+	var leaves []string = make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.ContentHash == "" {
+			return nil
+		}
+
+		leaves = append(leaves, block.ContentHash)
+	}
+
+	return leaves
+}
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/checksum"
+	"dcfs/util/logger"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+var keyRotationsInProgress map[uuid.UUID]bool = make(map[uuid.UUID]bool)
+var keyRotationsMutex sync.Mutex
+
+// IsKeyRotationInProgress - check whether the blocks of the volume are currently being re-encrypted
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume
+//
+// return type:
+//   - bool: true if the key rotation of the volume is in progress
+func IsKeyRotationInProgress(volumeUUID uuid.UUID) bool {
+	keyRotationsMutex.Lock()
+	defer keyRotationsMutex.Unlock()
+
+	return keyRotationsInProgress[volumeUUID]
+}
+
+// CountBlocksToRotate - count blocks of the volume which are not encrypted with its current data key
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume
+//   - keyUUID uuid.UUID: UUID of the current data key of the volume
+//
+// return type:
+//   - int64: number of blocks to be re-encrypted
+//   - error: database operation error
+func CountBlocksToRotate(volumeUUID uuid.UUID, keyUUID uuid.UUID) (int64, error) {
+	var count int64
+
+	err := db.DB.DatabaseHandle.Model(&dbo.Block{}).Where("volume_uuid = ? AND key_uuid <> ?", volumeUUID, keyUUID).Count(&count).Error
+	return count, err
+}
+
+// StartKeyRotation - generate a new data key of the volume and start re-encrypting its blocks
+//
+// Blocks uploaded after the key is generated are encrypted with the new key, the remaining
+// blocks are re-encrypted in the background while the volume stays available.
+//
+// params:
+//   - volume *Volume: volume whose data key should be rotated
+//
+// return type:
+//   - uuid.UUID: UUID of the new data key
+//   - string: constants.SUCCESS if the rotation was started, error code otherwise
+func StartKeyRotation(volume *Volume) (uuid.UUID, string) {
+	keyRotationsMutex.Lock()
+	defer keyRotationsMutex.Unlock()
+
+	if volume.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
+		return uuid.Nil, constants.OPERATION_NOT_SUPPORTED
+	}
+
+	if keyRotationsInProgress[volume.UUID] {
+		return uuid.Nil, constants.OPERATION_IN_PROGRESS
+	}
+
+	if volume.IsLocked() {
+		return uuid.Nil, constants.TRANSPORT_VOLUME_LOCKED
+	}
+
+	keyUUID, err := volume.GenerateDataKey()
+	if err != nil {
+		return uuid.Nil, constants.ENCRYPTION_JOB_FAILED
+	}
+
+	startKeyRotation(volume, keyUUID)
+	return keyUUID, constants.SUCCESS
+}
+
+// ResumeKeyRotations - resume re-encrypting blocks of the volumes whose key rotation was interrupted
+func ResumeKeyRotations() {
+	var volumes []dbo.Volume
+
+	err := db.DB.DatabaseHandle.Where("key_uuid <> ?", uuid.Nil).Find(&volumes).Error
+	if err != nil {
+		logger.Logger.Error("rotation", "Cannot retrieve volumes to be re-encrypted, got an error: ", err.Error())
+		return
+	}
+
+	for _, _volume := range volumes {
+		// Volumes encrypted with a passphrase are locked after the restart
+		if _volume.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_AES_256 {
+			continue
+		}
+
+		count, err := CountBlocksToRotate(_volume.UUID, _volume.KeyUUID)
+		if err != nil {
+			logger.Logger.Error("rotation", "Cannot count blocks to be re-encrypted of the volume: ", _volume.UUID.String(), ", got an error: ", err.Error())
+			continue
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		volume := Transport.GetVolume(_volume.UUID)
+		if volume == nil {
+			continue
+		}
+
+		ResumeKeyRotation(volume)
+	}
+}
+
+// ResumeKeyRotation - resume re-encrypting blocks of the volume with its current data key
+//
+// Key rotation of the volume encrypted with a passphrase is resumed once the volume is unlocked.
+//
+// params:
+//   - volume *Volume: volume whose blocks should be re-encrypted
+func ResumeKeyRotation(volume *Volume) {
+	keyRotationsMutex.Lock()
+	defer keyRotationsMutex.Unlock()
+
+	if keyRotationsInProgress[volume.UUID] || volume.IsLocked() || volume.GetKeyUUID() == uuid.Nil {
+		return
+	}
+
+	count, err := CountBlocksToRotate(volume.UUID, volume.GetKeyUUID())
+	if err != nil {
+		logger.Logger.Error("rotation", "Cannot count blocks to be re-encrypted of the volume: ", volume.UUID.String(), ", got an error: ", err.Error())
+		return
+	}
+
+	if count == 0 {
+		return
+	}
+
+	startKeyRotation(volume, volume.GetKeyUUID())
+}
+
+// startKeyRotation - run the key rotation of the volume in the background
+//
+// The caller must hold keyRotationsMutex.
+//
+// params:
+//   - volume *Volume: volume whose blocks should be re-encrypted
+//   - keyUUID uuid.UUID: UUID of the data key the blocks should be encrypted with
+func startKeyRotation(volume *Volume, keyUUID uuid.UUID) {
+	keyRotationsInProgress[volume.UUID] = true
+	go func() {
+		runKeyRotation(volume, keyUUID)
+
+		keyRotationsMutex.Lock()
+		delete(keyRotationsInProgress, volume.UUID)
+		keyRotationsMutex.Unlock()
+	}()
+
+	logger.Logger.Debug("rotation", "Started re-encrypting the volume: ", volume.UUID.String(), " with the data key: ", keyUUID.String(), ".")
+}
+
+// runKeyRotation - re-encrypt all blocks of the volume which are not encrypted with the given key
+//
+// Blocks which cannot be re-encrypted are skipped and picked up by the next rotation.
+// If disks of the volume are not available, the rotation is interrupted. The previous
+// data keys are removed once no block refers to them.
+//
+// params:
+//   - volume *Volume: volume whose blocks should be re-encrypted
+//   - keyUUID uuid.UUID: UUID of the data key the blocks should be encrypted with
+func runKeyRotation(volume *Volume, keyUUID uuid.UUID) {
+	var lastBlockUUID uuid.UUID = uuid.Nil
+	var rotated int = 0
+	var failed int = 0
+
+	// Prepare test context
+	writer := httptest.NewRecorder()
+	_ctx, _ := gin.CreateTestContext(writer)
+
+	for {
+		var blocks []dbo.Block
+
+		err := db.DB.DatabaseHandle.Where("volume_uuid = ? AND key_uuid <> ? AND uuid > ?", volume.UUID, keyUUID, lastBlockUUID).Order("uuid").Limit(constants.KEY_ROTATION_BATCH_SIZE).Find(&blocks).Error
+		if err != nil {
+			logger.Logger.Error("rotation", "Cannot retrieve blocks of the volume: ", volume.UUID.String(), ", got an error: ", err.Error())
+			return
+		}
+
+		// All blocks were re-encrypted
+		if len(blocks) == 0 {
+			break
+		}
+
+		if !volume.IsReady(_ctx, true) {
+			logger.Logger.Warning("rotation", "Volume: ", volume.UUID.String(), " is not ready, the key rotation is interrupted.")
+			return
+		}
+
+		if volume.IsLocked() {
+			logger.Logger.Warning("rotation", "Volume: ", volume.UUID.String(), " was locked, the key rotation is interrupted.")
+			return
+		}
+
+		for _, block := range blocks {
+			// Skip blocks of files which are not yet completely uploaded
+			if IsUploadInProgress(block.FileUUID) {
+				failed++
+				continue
+			}
+
+			err = ReencryptBlock(volume, block, keyUUID)
+			if err != nil {
+				logger.Logger.Warning("rotation", "Cannot re-encrypt the block: ", block.UUID.String(), ", got an error: ", err.Error())
+				failed++
+				continue
+			}
+
+			rotated++
+		}
+
+		lastBlockUUID = blocks[len(blocks)-1].UUID
+	}
+
+	if failed == 0 {
+		removeUnusedDataKeys(volume, keyUUID)
+	}
+
+	logger.Logger.Debug("rotation", "Finished re-encrypting the volume: ", volume.UUID.String(), ", re-encrypted ", strconv.Itoa(rotated), " blocks, ", strconv.Itoa(failed), " blocks were skipped.")
+}
+
+// ReencryptBlock - re-encrypt the block stored on the volume with the given data key
+//
+// The re-encrypted block is uploaded under the same name as the previous one, so its key
+// and checksum are recorded as pending before the upload. Until the block is switched
+// to the new key, it is read with whichever version is stored on the disk, and the
+// interrupted re-encryption is completed without uploading the block again.
+//
+// params:
+//   - volume *Volume: volume storing the block
+//   - block dbo.Block: block to be re-encrypted
+//   - keyUUID uuid.UUID: UUID of the data key the block should be encrypted with
+//
+// return type:
+//   - error: error if the block cannot be re-encrypted
+func ReencryptBlock(volume *Volume, block dbo.Block, keyUUID uuid.UUID) error {
+	disk := volume.GetDisk(block.DiskUUID)
+	if disk == nil {
+		return errors.New("the disk storing the block was not found")
+	}
+
+	// Download the block encrypted with the previous key
+	blockMetadata := NewBlockMetadataFromDBO(block)
+	errWrapper := disk.Download(blockMetadata)
+	if errWrapper != nil {
+		return errWrapper.Error
+	}
+
+	stored, valid := getStoredVersion(block, *blockMetadata.Content)
+	if !valid {
+		return errors.New("the block is corrupted")
+	}
+
+	// The block was already re-encrypted with the key before the rotation was interrupted
+	var uploadMetadata apicalls.BlockMetadata = *blockMetadata
+	uploadMetadata.Checksum = stored.Checksum
+	if stored.KeyUUID != keyUUID {
+		// Blocks encrypted in the legacy format are bound to their identity once re-encrypted
+		identity := NewBlockIdentityFromDBO(stored)
+		contents := *blockMetadata.Content
+		err := volume.decryptWithKey(&contents, stored.KeyUUID, identity)
+		if err != nil {
+			return err
+		}
+
+		err = volume.encryptWithKey(&contents, keyUUID, identity)
+		if err != nil {
+			return err
+		}
+
+		uploadMetadata.Content = &contents
+		uploadMetadata.Checksum = checksum.CalculateChecksum(contents)
+
+		// Record the pending version before it replaces the stored one, unless the block was removed in the meantime
+		result := db.DB.DatabaseHandle.Model(&dbo.Block{}).Where("uuid = ? AND key_uuid = ?", block.UUID, block.KeyUUID).Updates(map[string]interface{}{"pending_key_uuid": keyUUID, "pending_checksum": uploadMetadata.Checksum})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("the block was modified during the key rotation")
+		}
+
+		// Upload the re-encrypted block, the remote ID of the previous copy is still needed for removal
+		uploadMetadata.RemoteID = ""
+		errWrapper = disk.Upload(&uploadMetadata)
+		if errWrapper != nil {
+			return errWrapper.Error
+		}
+	}
+
+	// Switch the block to the new key only if it was not removed in the meantime
+	result := db.DB.DatabaseHandle.Model(&dbo.Block{}).Where("uuid = ? AND key_uuid = ?", block.UUID, block.KeyUUID).Updates(map[string]interface{}{"key_uuid": keyUUID, "format_version": constants.BLOCK_FORMAT_VERSION_BOUND, "checksum": uploadMetadata.Checksum, "remote_id": uploadMetadata.RemoteID, "pending_key_uuid": uuid.Nil, "pending_checksum": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		_ = disk.Remove(&uploadMetadata)
+		return errors.New("the block was modified during the key rotation")
+	}
+
+	// Remove the previous copy unless it was overwritten by the upload
+	if blockMetadata.RemoteID != "" && blockMetadata.RemoteID != uploadMetadata.RemoteID {
+		_ = disk.Remove(blockMetadata)
+	}
+
+	return nil
+}
+
+// removeUnusedDataKeys - remove previous data keys of the volume which no block refers to
+//
+// Only the keys removed from the db are evicted from the cache, which is shared by the volumes.
+//
+// params:
+//   - volume *Volume: volume whose data key was rotated
+//   - keyUUID uuid.UUID: UUID of the current data key of the volume
+func removeUnusedDataKeys(volume *Volume, keyUUID uuid.UUID) {
+	volumeUUID := volume.UUID
+	unusedKeys := func() *gorm.DB {
+		return db.DB.DatabaseHandle.
+			Where("volume_uuid = ? AND uuid <> ?", volumeUUID, keyUUID).
+			Where("uuid NOT IN (?)", db.DB.DatabaseHandle.Model(&dbo.Block{}).Select("key_uuid").Where("volume_uuid = ?", volumeUUID)).
+			Where("uuid NOT IN (?)", db.DB.DatabaseHandle.Model(&dbo.UploadSessionBlock{}).Select("key_uuid"))
+	}
+
+	var keyUUIDs []uuid.UUID
+	err := unusedKeys().Model(&dbo.VolumeKey{}).Pluck("uuid", &keyUUIDs).Error
+	if err != nil {
+		logger.Logger.Warning("rotation", "Cannot retrieve previous data keys of the volume: ", volumeUUID.String(), ", got an error: ", err.Error())
+		return
+	}
+
+	// Keys are removed one by one, as a block may refer to the key again in the meantime
+	var removedKeys []uuid.UUID
+	for _, _keyUUID := range keyUUIDs {
+		result := unusedKeys().Where("uuid = ?", _keyUUID).Delete(&dbo.VolumeKey{})
+		if result.Error != nil {
+			logger.Logger.Warning("rotation", "Cannot remove the data key: ", _keyUUID.String(), " of the volume: ", volumeUUID.String(), ", got an error: ", result.Error.Error())
+			continue
+		}
+
+		if result.RowsAffected > 0 {
+			removedKeys = append(removedKeys, _keyUUID)
+		}
+	}
+
+	dataKeysMutex.Lock()
+	if cache := volume.getKeyCache(); cache != nil {
+		for _, _keyUUID := range removedKeys {
+			delete(cache, _keyUUID)
+		}
+	}
+	dataKeysMutex.Unlock()
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+// ResyncDegradedBlocks - copy blocks written in degraded mode to the replicas which are missing them
+//
+// Records of blocks which cannot be resynchronized yet (disk is not ready or file upload
+// is still in progress) are kept for the next pass. Blocks which failed to be copied are
+// retried with an exponential backoff, up to constants.RESYNC_MAX_ATTEMPTS times.
+func ResyncDegradedBlocks() {
+	var degradedBlocks []dbo.DegradedBlock
+	var readiness = make(map[uuid.UUID]bool)
+	var resynced int = 0
+
+	err := db.DB.DatabaseHandle.Find(&degradedBlocks).Error
+	if err != nil {
+		logger.Logger.Error("resync", "Cannot retrieve degraded blocks, got an error: ", err.Error())
+		return
+	}
+
+	for _, degradedBlock := range degradedBlocks {
+		// Skip blocks of files which are not yet completely uploaded
+		if IsUploadInProgress(degradedBlock.FileUUID) {
+			continue
+		}
+
+		// Skip blocks which failed to be copied recently
+		if time.Now().Before(degradedBlock.RetryAt) {
+			continue
+		}
+
+		result, done := resyncDegradedBlock(degradedBlock, readiness)
+		if !done {
+			continue
+		}
+
+		if result == constants.SUCCESS {
+			resynced++
+		}
+
+		err = db.DB.DatabaseHandle.Delete(&degradedBlock).Error
+		if err != nil {
+			logger.Logger.Warning("resync", "Cannot remove the degraded block record: ", degradedBlock.UUID.String(), ", got an error: ", err.Error())
+		}
+	}
+
+	if resynced > 0 {
+		logger.Logger.Debug("resync", "Resynchronized ", strconv.Itoa(resynced), " degraded blocks.")
+	}
+}
+
+// StartResyncWorker - start background worker periodically resynchronizing degraded blocks
+func StartResyncWorker() {
+	go func() {
+		for {
+			time.Sleep(constants.RESYNC_INTERVAL)
+			ResyncDegradedBlocks()
+		}
+	}()
+}
+
+// resyncDegradedBlock - copy single degraded block to the replica which is missing it
+//
+// params:
+//   - degradedBlock dbo.DegradedBlock: record of the degraded block
+//   - readiness map[uuid.UUID]bool: readiness of the disks checked during current pass
+//
+// return type:
+//   - string: result of the resync
+//   - bool: true if the record is no longer needed, false if it should be retried later
+func resyncDegradedBlock(degradedBlock dbo.DegradedBlock, readiness map[uuid.UUID]bool) (string, bool) {
+	// Retrieve the block, it may have been removed in the meantime
+	var block dbo.Block
+	err := db.DB.DatabaseHandle.Where("uuid = ?", degradedBlock.BlockUUID).First(&block).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return constants.OPERATION_FAILED, true
+	} else if err != nil {
+		logger.Logger.Error("resync", "Cannot retrieve the block: ", degradedBlock.BlockUUID.String(), ", got an error: ", err.Error())
+		return constants.DATABASE_ERROR, false
+	}
+
+	volume := Transport.GetVolume(degradedBlock.VolumeUUID)
+	if volume == nil {
+		return constants.OPERATION_FAILED, true
+	}
+
+	// Check whether the lagging disk is still a part of the virtual disk
+	virtualDisk := volume.GetDisk(degradedBlock.VirtualDiskUUID)
+	disk := volume.GetDisk(degradedBlock.DiskUUID)
+	if virtualDisk == nil || disk == nil || disk.GetVirtualDiskUUID() != degradedBlock.VirtualDiskUUID {
+		logger.Logger.Warning("resync", "Disk: ", degradedBlock.DiskUUID.String(), " is no longer assigned to the virtual disk, skipping the block: ", block.UUID.String(), ".")
+		return constants.FS_DISK_MISMATCH, true
+	}
+
+	resyncableDisk, ok := virtualDisk.(ResyncableDisk)
+	if !ok {
+		logger.Logger.Warning("resync", "Virtual disk: ", virtualDisk.GetUUID().String(), " does not support resynchronization.")
+		return constants.OPERATION_FAILED, true
+	}
+
+	// Wait until the lagging disk is available again
+	ready, checked := readiness[disk.GetUUID()]
+	if !checked {
+		ready = disk.GetReadiness().IsReadyForce(context.Background())
+		readiness[disk.GetUUID()] = ready
+	}
+
+	if !ready {
+		return constants.REMOTE_FAILED_JOB, false
+	}
+
+	result := resyncableDisk.ResyncBlock(block, disk)
+	if result != constants.SUCCESS {
+		logger.Logger.Warning("resync", "Cannot resync the block: ", block.UUID.String(), " to the disk: ", disk.GetUUID().String(), ", got: ", result, ".")
+		return result, postponeResync(volume, disk, degradedBlock, result)
+	}
+
+	return result, true
+}
+
+// postponeResync - postpone the next attempt to resync the block which could not be copied
+//
+// The delay doubles after every failed attempt. Once the retry limit is reached, the record
+// is dropped and the user is notified, so that the replica is repaired by the scrub instead.
+//
+// params:
+//   - volume *Volume: volume of the block
+//   - disk Disk: replica which is missing the block
+//   - degradedBlock dbo.DegradedBlock: record of the degraded block
+//   - result string: result of the failed attempt
+//
+// return type:
+//   - bool: true if the record is no longer needed, false if it should be retried later
+func postponeResync(volume *Volume, disk Disk, degradedBlock dbo.DegradedBlock, result string) bool {
+	var attempts int = degradedBlock.Attempts + 1
+
+	if attempts >= constants.RESYNC_MAX_ATTEMPTS {
+		logger.Logger.Error("resync", "Giving up the resync of the block: ", degradedBlock.BlockUUID.String(), " to the disk: ", disk.GetUUID().String(), " after ", strconv.Itoa(attempts), " attempts.")
+		recordEvent(volume, disk, constants.EVENT_TYPE_RESYNC_FAILED, result,
+			"Block "+degradedBlock.BlockUUID.String()+" could not be copied to disk "+disk.GetName()+" after "+strconv.Itoa(attempts)+" attempts.")
+		return true
+	}
+
+	retryAt := time.Now().Add(constants.RESYNC_INTERVAL * time.Duration(1<<attempts))
+	err := db.DB.DatabaseHandle.Model(&dbo.DegradedBlock{}).Where("uuid = ?", degradedBlock.UUID).Updates(map[string]interface{}{"attempts": attempts, "retry_at": retryAt}).Error
+	if err != nil {
+		logger.Logger.Warning("resync", "Cannot postpone the resync of the block: ", degradedBlock.BlockUUID.String(), ", got an error: ", err.Error())
+	}
+
+	return false
+}
@@ -0,0 +1,403 @@
+package models
+
+import (
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"dcfs/util/merkle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var scrubsInProgress map[uuid.UUID]bool = make(map[uuid.UUID]bool)
+var scrubsMutex sync.Mutex
+
+// GetLatestScrub - retrieve the most recent scrub of the volume
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume
+//
+// return type:
+//   - *dbo.Scrub: latest scrub, nil if the volume was never scrubbed
+//   - []dbo.ScrubDiskError: number of errors found on each disk during the scrub
+//   - error: database operation error
+func GetLatestScrub(volumeUUID uuid.UUID) (*dbo.Scrub, []dbo.ScrubDiskError, error) {
+	var scrub dbo.Scrub
+	var diskErrors []dbo.ScrubDiskError
+
+	err := db.DB.DatabaseHandle.Where("volume_uuid = ?", volumeUUID).Order("started_at desc").First(&scrub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	err = db.DB.DatabaseHandle.Where("scrub_uuid = ?", scrub.UUID).Find(&diskErrors).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &scrub, diskErrors, nil
+}
+
+// IsScrubInProgress - check whether the volume is currently being scrubbed
+//
+// params:
+//   - volumeUUID uuid.UUID: UUID of the volume
+//
+// return type:
+//   - bool: true if the scrub of the volume is in progress
+func IsScrubInProgress(volumeUUID uuid.UUID) bool {
+	scrubsMutex.Lock()
+	defer scrubsMutex.Unlock()
+
+	return scrubsInProgress[volumeUUID]
+}
+
+// StartScrub - start a new scrub of the volume or resume the interrupted one
+//
+// params:
+//   - volume *Volume: volume to be scrubbed
+//
+// return type:
+//   - *dbo.Scrub: started scrub
+//   - string: constants.SUCCESS if the scrub was started, error code otherwise
+func StartScrub(volume *Volume) (*dbo.Scrub, string) {
+	scrubsMutex.Lock()
+	defer scrubsMutex.Unlock()
+
+	if scrubsInProgress[volume.UUID] {
+		return nil, constants.OPERATION_IN_PROGRESS
+	}
+
+	scrub, _, err := GetLatestScrub(volume.UUID)
+	if err != nil {
+		logger.Logger.Error("scrub", "Cannot retrieve the latest scrub of the volume: ", volume.UUID.String(), ", got an error: ", err.Error())
+		return nil, constants.DATABASE_ERROR
+	}
+
+	// Resume the scrub interrupted by the server restart or unavailable disks
+	if scrub == nil || scrub.Status != constants.SCRUB_STATUS_RUNNING {
+		scrub = dbo.NewScrub(volume.UUID)
+		scrub.Status = constants.SCRUB_STATUS_RUNNING
+
+		err = db.DB.DatabaseHandle.Create(scrub).Error
+		if err != nil {
+			logger.Logger.Error("scrub", "Cannot save the scrub of the volume: ", volume.UUID.String(), ", got an error: ", err.Error())
+			return nil, constants.DATABASE_ERROR
+		}
+	}
+
+	scrubsInProgress[volume.UUID] = true
+	go func() {
+		runScrub(volume, scrub)
+
+		scrubsMutex.Lock()
+		delete(scrubsInProgress, volume.UUID)
+		scrubsMutex.Unlock()
+	}()
+
+	logger.Logger.Debug("scrub", "Started the scrub: ", scrub.UUID.String(), " of the volume: ", volume.UUID.String(), ".")
+	return scrub, constants.SUCCESS
+}
+
+// ScheduleScrubs - start scrubs of the volumes which were not scrubbed recently
+// and resume the interrupted ones
+func ScheduleScrubs() {
+	var volumes []dbo.Volume
+
+	err := db.DB.DatabaseHandle.Find(&volumes).Error
+	if err != nil {
+		logger.Logger.Error("scrub", "Cannot retrieve volumes to be scrubbed, got an error: ", err.Error())
+		return
+	}
+
+	for _, _volume := range volumes {
+		if IsScrubInProgress(_volume.UUID) {
+			continue
+		}
+
+		scrub, _, err := GetLatestScrub(_volume.UUID)
+		if err != nil {
+			logger.Logger.Error("scrub", "Cannot retrieve the latest scrub of the volume: ", _volume.UUID.String(), ", got an error: ", err.Error())
+			continue
+		}
+
+		if scrub != nil && scrub.Status == constants.SCRUB_STATUS_COMPLETED && time.Since(*scrub.FinishedAt) < constants.SCRUB_INTERVAL {
+			continue
+		}
+
+		volume := Transport.GetVolume(_volume.UUID)
+		if volume == nil {
+			continue
+		}
+
+		_, _ = StartScrub(volume)
+	}
+}
+
+// StartScrubWorker - start background worker periodically scheduling scrubs of the volumes
+func StartScrubWorker() {
+	go func() {
+		for {
+			ScheduleScrubs()
+			time.Sleep(constants.SCRUB_CHECK_INTERVAL)
+		}
+	}()
+}
+
+// ScrubBlock - verify the block stored on the volume and repair it if possible
+//
+// Once the stored copies are verified, the block is decrypted to compare its plain
+// contents with the hash saved on upload. Blocks which cannot be decrypted, e.g.
+// because the volume is locked, are left unverified.
+//
+// params:
+//   - volume *Volume: volume storing the block
+//   - block dbo.Block: block to be verified
+//
+// return type:
+//   - ScrubResult: result of the verification
+func ScrubBlock(volume *Volume, block dbo.Block) ScrubResult {
+	var result ScrubResult
+
+	disk := volume.GetDisk(block.DiskUUID)
+	if disk == nil {
+		result.Code = constants.TRANSPORT_DISK_NOT_FOUND
+		return result
+	}
+
+	// Disks with redundancy verify and repair all copies of the block
+	scrubbableDisk, ok := disk.(ScrubbableDisk)
+	if ok {
+		result = scrubbableDisk.ScrubBlock(block)
+		if result.Code == constants.SUCCESS {
+			var code string
+			result.ContentHash, code = verifyScrubbedBlockContent(volume, disk, block, nil)
+			if code == constants.FS_CHECKSUM_MISMATCH {
+				result.Code = code
+			}
+		}
+
+		return result
+	}
+
+	// Block stored without redundancy can only be verified
+	blockMetadata := NewBlockMetadataFromDBO(block)
+	errWrapper := disk.Download(blockMetadata)
+	if errWrapper != nil {
+		result.Code = constants.REMOTE_FAILED_JOB
+		result.FaultyDisks = []uuid.UUID{disk.GetUUID()}
+		return result
+	}
+
+	if _, valid := getStoredVersion(block, *blockMetadata.Content); !valid {
+		result.Code = constants.REMOTE_CORRUPTED_BLOCKS
+		result.FaultyDisks = []uuid.UUID{disk.GetUUID()}
+		return result
+	}
+
+	var code string
+	result.ContentHash, code = verifyScrubbedBlockContent(volume, disk, block, *blockMetadata.Content)
+	if code == constants.FS_CHECKSUM_MISMATCH {
+		result.Code = code
+		return result
+	}
+
+	result.Code = constants.SUCCESS
+	return result
+}
+
+// verifyScrubbedBlockContent - compare the plain contents of the verified block with the hash saved on upload
+//
+// The stored copies of the block match its checksum at this point, so a mismatch means
+// that the block was corrupted before it was encrypted or its metadata was tampered with.
+//
+// params:
+//   - volume *Volume: volume storing the block
+//   - disk Disk: disk storing the block
+//   - block dbo.Block: verified block
+//   - contents []uint8: stored contents of the block, nil to download them from the disk
+//
+// return type:
+//   - string: hash of the plain contents of the block, empty if they could not be verified
+//   - string: constants.SUCCESS if the plain contents match the hash, constants.FS_CHECKSUM_MISMATCH
+//     if they do not, constants.FS_INTEGRITY_UNKNOWN if they could not be verified
+func verifyScrubbedBlockContent(volume *Volume, disk Disk, block dbo.Block, contents []uint8) (string, string) {
+	if block.ContentHash == "" || volume.IsLocked() {
+		return "", constants.FS_INTEGRITY_UNKNOWN
+	}
+
+	if contents == nil {
+		blockMetadata := NewBlockMetadataFromDBO(block)
+		errWrapper := disk.Download(blockMetadata)
+		if errWrapper != nil {
+			logger.Logger.Warning("scrub", "Cannot download the block: ", block.UUID.String(), " to verify its contents, got an error: ", errWrapper.Error.Error())
+			return "", constants.FS_INTEGRITY_UNKNOWN
+		}
+
+		contents = *blockMetadata.Content
+	}
+
+	// Block re-encrypted by an interrupted key rotation is decrypted with the pending key
+	block, _ = getStoredVersion(block, contents)
+
+	contents = append([]uint8(nil), contents...)
+	err := volume.Decrypt(&contents, block.KeyUUID, NewBlockIdentityFromDBO(block))
+	if err == nil {
+		err = volume.Decompress(&contents, block.Compression)
+	}
+	if err != nil {
+		logger.Logger.Error("scrub", "Cannot decode the block: ", block.UUID.String(), ", got an error: ", err.Error())
+		return "", constants.FS_CHECKSUM_MISMATCH
+	}
+
+	contentHash := merkle.HashContent(contents)
+	if contentHash != block.ContentHash {
+		logger.Logger.Error("scrub", "Plain contents of the block: ", block.UUID.String(), " do not match its hash.")
+		return "", constants.FS_CHECKSUM_MISMATCH
+	}
+
+	return contentHash, constants.SUCCESS
+}
+
+// runScrub - verify all blocks of the volume, starting after the last verified block
+//
+// Progress of the scrub is saved after every batch of blocks. If disks of the volume
+// are not available, the scrub is interrupted and may be resumed later. Once all blocks
+// are verified, the merkle root of each file is rebuilt from the plain contents of its blocks.
+//
+// params:
+//   - volume *Volume: volume to be scrubbed
+//   - scrub *dbo.Scrub: progress of the scrub
+func runScrub(volume *Volume, scrub *dbo.Scrub) {
+	var diskErrors = make(map[uuid.UUID]*dbo.ScrubDiskError)
+	var contentHashes = make(map[uuid.UUID]string)
+
+	// Retrieve errors found before the scrub was interrupted
+	var _diskErrors []dbo.ScrubDiskError
+	err := db.DB.DatabaseHandle.Where("scrub_uuid = ?", scrub.UUID).Find(&_diskErrors).Error
+	if err != nil {
+		logger.Logger.Error("scrub", "Cannot retrieve errors of the scrub: ", scrub.UUID.String(), ", got an error: ", err.Error())
+		return
+	}
+
+	for i := range _diskErrors {
+		diskErrors[_diskErrors[i].DiskUUID] = &_diskErrors[i]
+	}
+
+	// Prepare test context
+	writer := httptest.NewRecorder()
+	_ctx, _ := gin.CreateTestContext(writer)
+
+	for {
+		var blocks []dbo.Block
+
+		err = db.DB.DatabaseHandle.Where("volume_uuid = ? AND uuid > ?", volume.UUID, scrub.LastBlockUUID).Order("uuid").Limit(constants.SCRUB_BATCH_SIZE).Find(&blocks).Error
+		if err != nil {
+			logger.Logger.Error("scrub", "Cannot retrieve blocks of the volume: ", volume.UUID.String(), ", got an error: ", err.Error())
+			return
+		}
+
+		// All blocks were verified
+		if len(blocks) == 0 {
+			break
+		}
+
+		if !volume.IsReady(_ctx, true) {
+			logger.Logger.Warning("scrub", "Volume: ", volume.UUID.String(), " is not ready, the scrub: ", scrub.UUID.String(), " is interrupted.")
+			return
+		}
+
+		for _, block := range blocks {
+			result := ScrubBlock(volume, block)
+
+			// Skip blocks removed during the scrub
+			if result.Code != constants.SUCCESS && errors.Is(db.DB.DatabaseHandle.Where("uuid = ?", block.UUID).First(&dbo.Block{}).Error, gorm.ErrRecordNotFound) {
+				continue
+			}
+
+			scrub.CheckedBlocks++
+			if result.Code != constants.SUCCESS {
+				scrub.UnrecoverableBlocks++
+			} else if result.Repaired {
+				scrub.RepairedBlocks++
+			}
+
+			// Keep the hashes of the plain contents to rebuild the merkle roots of the files
+			if result.ContentHash != "" {
+				contentHashes[block.UUID] = result.ContentHash
+			} else if result.Code == constants.SUCCESS {
+				scrub.UnverifiedBlocks++
+			}
+
+			for _, diskUUID := range result.FaultyDisks {
+				if diskErrors[diskUUID] == nil {
+					diskErrors[diskUUID] = dbo.NewScrubDiskError(scrub.UUID, diskUUID)
+				}
+
+				diskErrors[diskUUID].Errors++
+			}
+		}
+
+		// Save progress of the scrub
+		scrub.LastBlockUUID = blocks[len(blocks)-1].UUID
+		err = saveScrub(scrub, diskErrors)
+		if err != nil {
+			logger.Logger.Error("scrub", "Cannot save progress of the scrub: ", scrub.UUID.String(), ", got an error: ", err.Error())
+			return
+		}
+	}
+
+	// Verify that the blocks of the files were not tampered with
+	scrub.CorruptedFiles, scrub.UnverifiedFiles, err = verifyMerkleRoots(volume, contentHashes)
+	if err != nil {
+		logger.Logger.Error("scrub", "Cannot verify merkle roots of the files of the volume: ", volume.UUID.String(), ", got an error: ", err.Error())
+		return
+	}
+
+	finishedAt := time.Now()
+	scrub.Status = constants.SCRUB_STATUS_COMPLETED
+	scrub.FinishedAt = &finishedAt
+
+	err = saveScrub(scrub, diskErrors)
+	if err != nil {
+		logger.Logger.Error("scrub", "Cannot save the scrub: ", scrub.UUID.String(), ", got an error: ", err.Error())
+		return
+	}
+
+	logger.Logger.Debug("scrub", "Finished the scrub: ", scrub.UUID.String(), " of the volume: ", volume.UUID.String(), ", verified ", strconv.Itoa(scrub.CheckedBlocks), " blocks.")
+}
+
+// saveScrub - save progress of the scrub along with the errors found on the disks
+//
+// params:
+//   - scrub *dbo.Scrub: progress of the scrub
+//   - diskErrors map[uuid.UUID]*dbo.ScrubDiskError: errors found on the disks
+//
+// return type:
+//   - error: database operation error
+func saveScrub(scrub *dbo.Scrub, diskErrors map[uuid.UUID]*dbo.ScrubDiskError) error {
+	return db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Save(scrub).Error
+		if err != nil {
+			return err
+		}
+
+		for _, diskError := range diskErrors {
+			err = tx.Save(diskError).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
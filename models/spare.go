@@ -0,0 +1,189 @@
+package models
+
+import (
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// SpareGracePeriod - time after which the unavailable disk is replaced with a hot spare
+var SpareGracePeriod time.Duration = constants.SPARE_GRACE_PERIOD
+
+var unavailableSince map[uuid.UUID]time.Time = make(map[uuid.UUID]time.Time)
+var unavailableSinceMutex sync.Mutex
+
+// CheckVirtualDiskMembers - replace disks of virtual disks which are unavailable
+// for longer than the grace period with hot spare disks
+//
+// Only volumes having at least one hot spare disk are checked.
+func CheckVirtualDiskMembers() {
+	var spareDisks []dbo.Disk
+	var volumeUUIDs = make(map[uuid.UUID]bool)
+
+	err := db.DB.DatabaseHandle.Where("is_spare = ? AND virtual_disk_uuid = ?", true, uuid.Nil).Find(&spareDisks).Error
+	if err != nil {
+		logger.Logger.Error("spare", "Cannot retrieve hot spare disks, got an error: ", err.Error())
+		return
+	}
+
+	for _, spareDisk := range spareDisks {
+		volumeUUIDs[spareDisk.VolumeUUID] = true
+	}
+
+	for volumeUUID := range volumeUUIDs {
+		volume := Transport.GetVolume(volumeUUID)
+		if volume == nil || volume.VolumeSettings.Backup == constants.BACKUP_TYPE_NO_BACKUP {
+			continue
+		}
+
+		CheckVolumeDisks(volume)
+	}
+}
+
+// CheckVolumeDisks - replace disks of the volume which are unavailable for longer
+// than the grace period with hot spare disks
+//
+// params:
+//   - volume *Volume: volume to be checked
+func CheckVolumeDisks(volume *Volume) {
+	// Prepare test context
+	writer := httptest.NewRecorder()
+	_ctx, _ := gin.CreateTestContext(writer)
+
+	// Disks of the volume may be added or removed by the API handlers in the meantime
+	for _, disk := range volume.GetRealDisks() {
+		if disk.GetVirtualDiskUUID() == uuid.Nil {
+			continue
+		}
+
+		if disk.GetReadiness().IsReadyForce(_ctx) {
+			unavailableSinceMutex.Lock()
+			delete(unavailableSince, disk.GetUUID())
+			unavailableSinceMutex.Unlock()
+			continue
+		}
+
+		// Measure how long the disk has been unavailable
+		unavailableSinceMutex.Lock()
+		since, ok := unavailableSince[disk.GetUUID()]
+		if !ok {
+			since = time.Now()
+			unavailableSince[disk.GetUUID()] = since
+		}
+		unavailableSinceMutex.Unlock()
+
+		if time.Since(since) < SpareGracePeriod {
+			continue
+		}
+
+		// Disks used by the pending transfers cannot be replaced
+		if Transport.FindEnqueuedDisk(disk.GetUUID()) != nil {
+			continue
+		}
+
+		PromoteSpareDisk(volume, disk)
+	}
+}
+
+// PromoteSpareDisk - replace the failed disk with a hot spare disk of the volume
+//
+// The replaced disk becomes a hot spare, so that it can be reused once it is
+// available again. Result of the promotion is recorded as an event.
+//
+// params:
+//   - volume *Volume: volume of the failed disk
+//   - disk Disk: failed disk assigned to the virtual disk
+//
+// return type:
+//   - string: constants.SUCCESS if the disk was replaced, error code otherwise
+func PromoteSpareDisk(volume *Volume, disk Disk) string {
+	var spareDisk Disk = nil
+
+	// Prepare test context
+	writer := httptest.NewRecorder()
+	_ctx, _ := gin.CreateTestContext(writer)
+
+	// Find available hot spare disk
+	for _, _spareDisk := range volume.GetSpareDisks() {
+		if _spareDisk.GetReadiness().IsReadyForce(_ctx) {
+			spareDisk = _spareDisk
+			break
+		}
+	}
+
+	if spareDisk == nil {
+		logger.Logger.Warning("spare", "No hot spare disk of the volume: ", volume.UUID.String(), " is available to replace the disk: ", disk.GetUUID().String(), ".")
+		return constants.TRANSPORT_DISK_NOT_FOUND
+	}
+
+	logger.Logger.Warning("spare", "Replacing the unavailable disk: ", disk.GetUUID().String(), " with the hot spare disk: ", spareDisk.GetUUID().String(), ".")
+
+	// Rebuild the virtual disk on the hot spare disk
+	errCode, err := volume.ReplaceVirtualDiskMember(disk, spareDisk)
+	if errCode != constants.SUCCESS {
+		logger.Logger.Error("spare", "Could not replace the disk: ", disk.GetUUID().String(), " with the hot spare disk: ", spareDisk.GetUUID().String(), ", got an error: ", err.Error())
+		recordEvent(volume, disk, constants.EVENT_TYPE_SPARE_PROMOTION_FAILED, errCode,
+			"Disk "+disk.GetName()+" is unavailable, but it could not be replaced with hot spare disk "+spareDisk.GetName()+": "+err.Error())
+
+		// Retry after another grace period
+		unavailableSinceMutex.Lock()
+		unavailableSince[disk.GetUUID()] = time.Now()
+		unavailableSinceMutex.Unlock()
+		return errCode
+	}
+
+	// Replaced disk becomes a hot spare
+	err = db.DB.DatabaseHandle.Model(&dbo.Disk{}).Where("uuid = ?", disk.GetUUID()).Update("is_spare", true).Error
+	if err != nil {
+		logger.Logger.Warning("spare", "Could not mark the replaced disk: ", disk.GetUUID().String(), " as a hot spare, got an error: ", err.Error())
+	} else {
+		disk.SetIsSpareFlag(true)
+	}
+
+	unavailableSinceMutex.Lock()
+	delete(unavailableSince, disk.GetUUID())
+	unavailableSinceMutex.Unlock()
+
+	recordEvent(volume, disk, constants.EVENT_TYPE_SPARE_PROMOTED, constants.SUCCESS,
+		"Disk "+disk.GetName()+" was unavailable and has been replaced with hot spare disk "+spareDisk.GetName()+".")
+	return constants.SUCCESS
+}
+
+// StartSpareWorker - start background worker periodically replacing unavailable disks with hot spares
+func StartSpareWorker() {
+	go func() {
+		for {
+			time.Sleep(constants.SPARE_CHECK_INTERVAL)
+			CheckVirtualDiskMembers()
+		}
+	}()
+}
+
+// recordEvent - save event concerning the disk of the volume
+//
+// params:
+//   - volume *Volume: volume of the disk
+//   - disk Disk: disk which the event concerns
+//   - eventType int: type of the event
+//   - code string: completion code of the operation
+//   - message string: description of the event
+func recordEvent(volume *Volume, disk Disk, eventType int, code string, message string) {
+	var event *dbo.Event = dbo.NewEvent()
+	event.UserUUID = volume.UserUUID
+	event.VolumeUUID = volume.UUID
+	event.DiskUUID = disk.GetUUID()
+	event.Type = eventType
+	event.Code = code
+	event.Message = message
+
+	err := db.DB.DatabaseHandle.Create(event).Error
+	if err != nil {
+		logger.Logger.Error("spare", "Could not save the event concerning the disk: ", disk.GetUUID().String(), ", got an error: ", err.Error())
+	}
+}
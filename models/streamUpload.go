@@ -0,0 +1,311 @@
+package models
+
+import (
+	"crypto/sha256"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/checksum"
+	"dcfs/util/logger"
+	"dcfs/util/merkle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io"
+	"strconv"
+)
+
+// StreamFileUpload - upload the file streamed by the client
+//
+// The stream is read in chunks of the volume block size, so no more than
+// a single block of the file is kept in memory at once. Each chunk is
+// hashed, encrypted, checksummed and uploaded to the disk assigned by the
+// partitioner before the next one is read, the checksum of the whole file
+// is calculated along the way. Every transferred block is recorded in the
+// upload session of the file, so that the file is considered in progress and
+// blocks of an interrupted upload are removed once the session expires. If the
+// upload fails, blocks which were already transferred are removed from the disks.
+//
+// params:
+//   - ctx *gin.Context: context of the request
+//   - reader io.Reader: stream with the contents of the file
+//   - name string: name of the file
+//   - userUUID uuid.UUID: UUID of the user who is uploading the file
+//   - rootUUID uuid.UUID: UUID of the parent directory of the file
+//
+// return type:
+//   - *RegularFile: uploaded file, its blocks are transferred but not saved in the db yet, its upload session has to be removed once it is saved
+//   - *apicalls.ErrorWrapper: error wrapper if the upload failed, nil otherwise
+func (v *Volume) StreamFileUpload(ctx *gin.Context, reader io.Reader, name string, userUUID uuid.UUID, rootUUID uuid.UUID) (*RegularFile, *apicalls.ErrorWrapper) {
+	var file *RegularFile = NewFile(constants.FILE_TYPE_REGULAR).(*RegularFile)
+	file.SetName(name)
+	file.SetRoot(rootUUID)
+	file.SetVolume(v)
+	file.Blocks = make(map[uuid.UUID]*Block)
+	fileHash := sha256.New()
+
+	for order := 0; ; order++ {
+		// Read the next block of the file
+		contents := make([]uint8, v.BlockSize)
+		readSize, err := io.ReadFull(reader, contents)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil && err != io.ErrUnexpectedEOF {
+			abortStreamedUpload(ctx, file)
+			return nil, apicalls.CreateErrorWrapper(constants.FS_CANNOT_LOAD_BLOCK, "Could not read the block of the file:", err.Error())
+		}
+
+		contents = contents[:readSize]
+		file.Size += readSize
+		fileHash.Write(contents)
+
+		if file.Size > Transport.MaximumFileSize {
+			abortStreamedUpload(ctx, file)
+			return nil, apicalls.CreateErrorWrapper(constants.TRANSPORT_FILE_TOO_BIG, fmt.Sprintf("The uploaded file is too big. Please make sure that the files are no larger than: %dB.", Transport.MaximumFileSize))
+		}
+
+		// Save the upload session before the first block is transferred
+		if order == 0 {
+			dbErr := SaveUploadSession(file, userUUID)
+			if dbErr != nil {
+				logger.Logger.Error("transport", "Could not save the upload session of the file: ", file.UUID.String(), ", got an error: ", dbErr.Error())
+				return nil, apicalls.CreateErrorWrapper(constants.DATABASE_ERROR, "Could not save the upload session:", dbErr.Error())
+			}
+		}
+
+		errorWrapper := v.uploadStreamedBlock(ctx, file, contents, userUUID, order)
+		if errorWrapper != nil {
+			abortStreamedUpload(ctx, file)
+			return nil, errorWrapper
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	if file.Size == 0 {
+		return nil, apicalls.CreateErrorWrapper(constants.VAL_SIZE_INVALID, "The uploaded file is empty.")
+	}
+
+	file.Checksum = hex.EncodeToString(fileHash.Sum(nil))
+
+	logger.Logger.Debug("transport", "Streamed ", strconv.Itoa(len(file.Blocks)), " blocks of the file: ", file.UUID.String(), ".")
+	return file, nil
+}
+
+// SaveUploadedFile - save the file whose blocks were all transferred in the db
+//
+// params:
+//   - file *RegularFile: uploaded file
+//   - userUUID uuid.UUID: UUID of the user who uploaded the file
+//
+// return type:
+//   - *dbo.File: saved file DBO
+//   - error: database operation error
+func SaveUploadedFile(file *RegularFile, userUUID uuid.UUID) (*dbo.File, error) {
+	var fileDBO *dbo.File = &dbo.File{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{
+			UUID: file.GetUUID(),
+		},
+		VolumeUUID: file.GetVolume().UUID,
+		RootUUID:   file.GetRoot(),
+		UserUUID:   userUUID,
+		Type:       file.GetType(),
+		Name:       file.GetName(),
+		Size:       file.GetSize(),
+		Checksum:   file.Checksum,
+		MerkleRoot: file.ComputeMerkleRoot(),
+	}
+
+	err := db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(fileDBO).Error
+		if err != nil {
+			return err
+		}
+
+		for _, _block := range file.Blocks {
+			err = tx.Create(&dbo.Block{
+				AbstractDatabaseObject: dbo.AbstractDatabaseObject{
+					UUID: _block.UUID,
+				},
+				FileUUID:       file.GetUUID(),
+				UserUUID:       userUUID,
+				VolumeUUID:     file.GetVolume().UUID,
+				DiskUUID:       _block.Disk.GetUUID(),
+				Size:           _block.Size,
+				Order:          _block.Order,
+				Checksum:       _block.Checksum,
+				RemoteID:       _block.RemoteID,
+				Compression:    _block.Compression,
+				CompressedSize: _block.CompressedSize,
+				KeyUUID:        _block.KeyUUID,
+				FormatVersion:  _block.FormatVersion,
+				ContentHash:    _block.ContentHash,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fileDBO, nil
+}
+
+// uploadStreamedBlock - upload a single block of the streamed file
+//
+// params:
+//   - ctx *gin.Context: context of the request
+//   - file *RegularFile: streamed file
+//   - contents []uint8: contents of the block
+//   - userUUID uuid.UUID: UUID of the user who is uploading the file
+//   - order int: order of the block in the file
+//
+// return type:
+//   - *apicalls.ErrorWrapper: error wrapper if the upload failed, nil otherwise
+func (v *Volume) uploadStreamedBlock(ctx *gin.Context, file *RegularFile, contents []uint8, userUUID uuid.UUID, order int) *apicalls.ErrorWrapper {
+	var disk Disk = v.partitioner.AssignDisk(len(contents))
+	if disk == nil {
+		logger.Logger.Error("transport", "Partitioner did not assign a disk to the block: ", strconv.Itoa(order), " of the file: ", file.UUID.String(), ".")
+		return apicalls.CreateErrorWrapper(constants.OPERATION_FAILED, "Could not upload the file. No ready disks were found.")
+	}
+
+	var block *Block = NewBlock(uuid.New(), userUUID, file, disk, len(contents), "", constants.BLOCK_STATUS_IN_PROGRESS, order)
+	block.ContentHash = merkle.HashContent(contents)
+
+	// Compress the block
+	compression, err := v.Compress(&contents)
+	if err != nil {
+		logger.Logger.Error("transport", "Failed to compress the block of the file: ", file.UUID.String())
+		return apicalls.CreateErrorWrapper(constants.COMPRESSION_JOB_FAILED, "Failed to compress file: "+file.UUID.String())
+	}
+	block.Compression = compression
+	block.CompressedSize = len(contents)
+
+	// Prepare internal block metadata
+	var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
+	blockMetadata.Ctx = ctx
+	blockMetadata.FileUUID = file.UUID
+	blockMetadata.Content = &contents
+	blockMetadata.UUID = block.UUID
+	blockMetadata.Size = int64(block.GetStoredSize())
+	blockMetadata.Status = &block.Status
+	blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
+		*status = constants.BLOCK_STATUS_TRANSFERRED
+	}
+
+	// Encrypt the block
+	block.KeyUUID, err = v.Encrypt(blockMetadata.Content, block.GetIdentity(file.UUID))
+	if errors.Is(err, ErrVolumeLocked) {
+		logger.Logger.Error("transport", "Failed to encrypt the block of the file: ", file.UUID.String(), ", the volume is locked.")
+		return apicalls.CreateErrorWrapper(constants.TRANSPORT_VOLUME_LOCKED, "The volume is locked")
+	} else if err != nil {
+		logger.Logger.Error("transport", "Failed to encrypt the block of the file: ", file.UUID.String())
+		return apicalls.CreateErrorWrapper(constants.ENCRYPTION_JOB_FAILED, "Failed to encrypt file: "+file.UUID.String())
+	}
+	block.FormatVersion = constants.BLOCK_FORMAT_VERSION_BOUND
+
+	// Calculate block checksum
+	block.Checksum = checksum.CalculateChecksum(contents)
+
+	// Upload the block to the target disk
+	errorWrapper := disk.Upload(blockMetadata)
+	if errorWrapper != nil {
+		logger.Logger.Error("transport", "Failed to upload the block: ", block.UUID.String(), " of the file: ", file.UUID.String(), ".")
+		return errorWrapper
+	}
+
+	block.Status = constants.BLOCK_STATUS_TRANSFERRED
+	block.RemoteID = blockMetadata.RemoteID
+	file.Blocks[block.UUID] = block
+
+	// Update target disk usage
+	disk.UpdateUsedSpace(int64(block.GetStoredSize()))
+
+	// Record the block in the upload session, so that it is removed if the upload is interrupted
+	err = AddUploadSessionBlock(file.UUID, block)
+	if err != nil {
+		logger.Logger.Error("transport", "Could not save the block: ", block.UUID.String(), " of the upload session: ", file.UUID.String(), ", got an error: ", err.Error())
+		return apicalls.CreateErrorWrapper(constants.DATABASE_ERROR, "Could not save the block of the upload session:", err.Error())
+	}
+
+	return nil
+}
+
+// abortStreamedUpload - remove the blocks and the upload session of the file whose upload failed
+//
+// The upload session is kept if some of the blocks could not be removed,
+// they are going to be removed once the session expires.
+//
+// params:
+//   - ctx *gin.Context: context of the request
+//   - file *RegularFile: streamed file
+func abortStreamedUpload(ctx *gin.Context, file *RegularFile) {
+	if !removeStreamedBlocks(ctx, file) {
+		return
+	}
+
+	err := RemoveUploadSession(file.UUID)
+	if err != nil {
+		logger.Logger.Warning("transport", "Could not remove the upload session of the file: ", file.UUID.String(), ", got an error: ", err.Error())
+	}
+}
+
+// removeStreamedBlocks - remove already transferred blocks of the file whose upload failed
+//
+// params:
+//   - ctx *gin.Context: context of the request
+//   - file *RegularFile: streamed file
+//
+// return type:
+//   - bool: true if all transferred blocks were removed, false otherwise
+func removeStreamedBlocks(ctx *gin.Context, file *RegularFile) bool {
+	var removed []uuid.UUID
+
+	for _, block := range file.Blocks {
+		var status int
+		var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
+		blockMetadata.Ctx = ctx
+		blockMetadata.FileUUID = file.UUID
+		blockMetadata.UUID = block.UUID
+		blockMetadata.Size = int64(block.GetStoredSize())
+		blockMetadata.RemoteID = block.RemoteID
+		blockMetadata.Status = &status
+		blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
+		}
+
+		errorWrapper := block.Disk.Remove(blockMetadata)
+		if errorWrapper != nil {
+			logger.Logger.Warning("transport", "Cannot remove the block: ", block.UUID.String(), " of the failed upload of the file: ", file.UUID.String(), ".")
+			continue
+		}
+
+		block.Disk.UpdateUsedSpace(-int64(block.GetStoredSize()))
+		removed = append(removed, block.UUID)
+	}
+
+	if len(removed) == len(file.Blocks) {
+		return true
+	}
+
+	// Do not attempt to remove the blocks again once the upload session expires
+	if len(removed) > 0 {
+		err := db.DB.DatabaseHandle.Model(&dbo.UploadSessionBlock{}).Where("uuid IN ?", removed).Update("status", constants.BLOCK_STATUS_QUEUED).Error
+		if err != nil {
+			logger.Logger.Warning("transport", "Cannot update the removed blocks of the upload session: ", file.UUID.String(), ", got an error: ", err.Error())
+		}
+	}
+
+	return false
+}
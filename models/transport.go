@@ -400,13 +400,15 @@ func (transport *transport) DeleteDisk(disk Disk, volume *Volume, deletionType b
 
 			// Prepare apicall metadata
 			var status int
-			var contents []uint8 = make([]uint8, block.Size)
+			var storedSize int = NewBlockFromDBO(&block).GetStoredSize()
+			var contents []uint8 = make([]uint8, storedSize)
 			var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
 			blockMetadata.Ctx = _ctx
 			blockMetadata.FileUUID = block.FileUUID
 			blockMetadata.Content = &contents
 			blockMetadata.UUID = block.UUID
-			blockMetadata.Size = int64(block.Size)
+			blockMetadata.Size = int64(storedSize)
+			blockMetadata.RemoteID = block.RemoteID
 			blockMetadata.Status = &status
 			blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
 			}
@@ -421,16 +423,18 @@ func (transport *transport) DeleteDisk(disk Disk, volume *Volume, deletionType b
 					return
 				}
 
-				// Upload block to another disk
-				result = newDisk.Upload(blockMetadata)
+				// Upload block to another disk, the remote ID of the block on the current disk is still needed for removal
+				var uploadMetadata apicalls.BlockMetadata = *blockMetadata
+				uploadMetadata.RemoteID = ""
+				result = newDisk.Upload(&uploadMetadata)
 				if result != nil {
 					logger.Logger.Error("disk", "Relocation failed: cannot download block ", blockMetadata.UUID.String(), " to new disk ", disk.GetUUID().String(), ".")
 					taskCompleted = false
 					return
 				}
 
-				// Update block's disk uuid
-				dBErr := db.DB.DatabaseHandle.Model(&dbo.Block{}).Where("uuid = ?", blockMetadata.UUID).Update("disk_uuid", newDisk.GetUUID()).Error
+				// Update block's disk uuid and remote ID
+				dBErr := db.DB.DatabaseHandle.Model(&dbo.Block{}).Where("uuid = ?", blockMetadata.UUID).Updates(map[string]interface{}{"disk_uuid": newDisk.GetUUID(), "remote_id": uploadMetadata.RemoteID}).Error
 				if dBErr != nil {
 					logger.Logger.Error("disk", "Relocation failed: cannot update block's disk UUID in database ", blockMetadata.UUID.String(), ".")
 					taskCompleted = false
@@ -520,7 +524,8 @@ func (transport *transport) DeleteFile(file File, volume *Volume) (string, error
 			blockMetadata.FileUUID = uuid.Nil
 			blockMetadata.Content = nil
 			blockMetadata.UUID = block.UUID
-			blockMetadata.Size = int64(block.Size)
+			blockMetadata.Size = int64(block.GetStoredSize())
+			blockMetadata.RemoteID = block.RemoteID
 			blockMetadata.Status = &status
 			blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
 			}
@@ -0,0 +1,379 @@
+package models
+
+import (
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UploadSessionExpiration - time after which the abandoned upload session is removed along with its blocks
+var UploadSessionExpiration time.Duration = constants.UPLOAD_SESSION_EXPIRATION
+
+var uploadSessionMutex sync.Mutex
+
+// SaveUploadSession - save the file enqueued for upload, so the upload can be resumed later
+//
+// params:
+//   - file *RegularFile: file enqueued for upload
+//   - userUUID uuid.UUID: UUID of the user who is uploading the file
+//
+// return type:
+//   - error: database operation error
+func SaveUploadSession(file *RegularFile, userUUID uuid.UUID) error {
+	var session *dbo.UploadSession = dbo.NewUploadSession(file.GetUUID())
+	session.UserUUID = userUUID
+	session.VolumeUUID = file.GetVolume().UUID
+	session.RootUUID = file.GetRoot()
+	session.Name = file.GetName()
+	session.Size = file.GetSize()
+	session.Checksum = file.Checksum
+
+	return db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(session).Error
+		if err != nil {
+			return err
+		}
+
+		for _, block := range file.Blocks {
+			err = tx.Create(newUploadSessionBlock(file.GetUUID(), block)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// UpdateUploadSessionBlock - save the block of the file enqueued for upload after its transfer
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the uploaded file
+//   - block *Block: transferred block
+//
+// return type:
+//   - error: database operation error
+func UpdateUploadSessionBlock(fileUUID uuid.UUID, block *Block) error {
+	return db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Save(newUploadSessionBlock(fileUUID, block)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&dbo.UploadSession{}).Where("uuid = ?", fileUUID).Update("updated_at", time.Now()).Error
+	})
+}
+
+// AddUploadSessionBlock - save the block of the streamed file after its transfer
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the uploaded file
+//   - block *Block: transferred block
+//
+// return type:
+//   - error: database operation error
+func AddUploadSessionBlock(fileUUID uuid.UUID, block *Block) error {
+	return db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(newUploadSessionBlock(fileUUID, block)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&dbo.UploadSession{}).Where("uuid = ?", fileUUID).Update("updated_at", time.Now()).Error
+	})
+}
+
+// RemoveUploadSession - remove the upload session after the file is saved
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the uploaded file
+//
+// return type:
+//   - error: database operation error
+func RemoveUploadSession(fileUUID uuid.UUID) error {
+	return db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("session_uuid = ?", fileUUID).Delete(&dbo.UploadSessionBlock{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("uuid = ?", fileUUID).Delete(&dbo.UploadSession{}).Error
+	})
+}
+
+// IsUploadInProgress - check whether the file is still being uploaded
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the file
+//
+// return type:
+//   - bool: true if the upload session of the file exists, false otherwise
+func IsUploadInProgress(fileUUID uuid.UUID) bool {
+	if Transport.FileUploadQueue.GetEnqueuedInstance(fileUUID) != nil {
+		return true
+	}
+
+	var count int64
+	err := db.DB.DatabaseHandle.Model(&dbo.UploadSession{}).Where("uuid = ?", fileUUID).Count(&count).Error
+	return err != nil || count > 0
+}
+
+// GetUploadFile - get the file enqueued for upload
+//
+// Files which are no longer kept in the FileUploadQueue (e.g. after the restart
+// of the server) are restored from their upload sessions and enqueued again.
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the file
+//
+// return type:
+//   - *RegularFile: file enqueued for upload, nil if the upload session does not exist
+func (transport *transport) GetUploadFile(fileUUID uuid.UUID) *RegularFile {
+	uploadSessionMutex.Lock()
+	defer uploadSessionMutex.Unlock()
+
+	instance := transport.FileUploadQueue.GetEnqueuedInstance(fileUUID)
+	if instance != nil {
+		return instance.(*RegularFile)
+	}
+
+	file := NewFileFromUploadSession(fileUUID)
+	if file == nil {
+		return nil
+	}
+
+	transport.FileUploadQueue.EnqueueInstance(fileUUID, file)
+	logger.Logger.Debug("transport", "Restored the upload of the file: ", fileUUID.String(), ".")
+	return file
+}
+
+// NewFileFromUploadSession - create file model based on the saved upload session
+//
+// Blocks assigned to disks which were removed from the volume in the meantime
+// are assigned to other disks and have to be uploaded again.
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the uploaded file
+//
+// return type:
+//   - *RegularFile: created file model, nil if the upload cannot be resumed
+func NewFileFromUploadSession(fileUUID uuid.UUID) *RegularFile {
+	var session dbo.UploadSession
+	var sessionBlocks []dbo.UploadSessionBlock
+
+	err := db.DB.DatabaseHandle.Where("uuid = ?", fileUUID).First(&session).Error
+	if err != nil {
+		logger.Logger.Warning("transport", "Could not find the upload session of the file: ", fileUUID.String(), ".")
+		return nil
+	}
+
+	err = db.DB.DatabaseHandle.Where("session_uuid = ?", fileUUID).Find(&sessionBlocks).Error
+	if err != nil {
+		logger.Logger.Error("transport", "Could not retrieve blocks of the upload session: ", fileUUID.String(), ", got an error: ", err.Error())
+		return nil
+	}
+
+	volume := Transport.GetVolume(session.VolumeUUID)
+	if volume == nil {
+		return nil
+	}
+
+	var file *RegularFile = &RegularFile{
+		AbstractFile: AbstractFile{
+			UUID:     session.UUID,
+			Name:     session.Name,
+			Type:     constants.FILE_TYPE_REGULAR,
+			Size:     session.Size,
+			RootUUID: session.RootUUID,
+			Volume:   volume,
+		},
+		Blocks:   make(map[uuid.UUID]*Block),
+		Checksum: session.Checksum,
+	}
+
+	for _, sessionBlock := range sessionBlocks {
+		status := sessionBlock.Status
+		disk := volume.GetDisk(sessionBlock.DiskUUID)
+		if disk == nil {
+			disk = volume.partitioner.AssignDisk(sessionBlock.Size)
+			if disk == nil {
+				logger.Logger.Error("transport", "Partitioner did not assign a disk to the block: ", sessionBlock.UUID.String(), ".")
+				return nil
+			}
+
+			status = constants.BLOCK_STATUS_QUEUED
+		}
+
+		block := NewBlock(sessionBlock.UUID, session.UserUUID, file, disk, sessionBlock.Size, sessionBlock.Checksum, status, sessionBlock.Order)
+		block.RemoteID = sessionBlock.RemoteID
+		block.Compression = sessionBlock.Compression
+		block.CompressedSize = sessionBlock.CompressedSize
+		block.KeyUUID = sessionBlock.KeyUUID
+		block.FormatVersion = sessionBlock.FormatVersion
+		block.ContentHash = sessionBlock.ContentHash
+		file.Blocks[block.UUID] = block
+	}
+
+	return file
+}
+
+// RemoveExpiredUploadSessions - remove upload sessions abandoned for longer than the expiration time
+//
+// Blocks of the abandoned files which were already transferred are removed from
+// the disks. Sessions whose blocks cannot be removed yet are kept for the next pass.
+func RemoveExpiredUploadSessions() {
+	var sessions []dbo.UploadSession
+	var removed int = 0
+
+	err := db.DB.DatabaseHandle.Where("updated_at < ?", time.Now().Add(-UploadSessionExpiration)).Find(&sessions).Error
+	if err != nil {
+		logger.Logger.Error("transport", "Cannot retrieve expired upload sessions, got an error: ", err.Error())
+		return
+	}
+
+	for _, session := range sessions {
+		// Skip files which are being uploaded right now
+		if Transport.FileUploadQueue.GetEnqueuedInstance(session.UUID) != nil {
+			continue
+		}
+
+		if !removeUploadSessionBlocks(session) {
+			continue
+		}
+
+		err = RemoveUploadSession(session.UUID)
+		if err != nil {
+			logger.Logger.Warning("transport", "Cannot remove the upload session: ", session.UUID.String(), ", got an error: ", err.Error())
+			continue
+		}
+
+		removed++
+	}
+
+	if removed > 0 {
+		logger.Logger.Debug("transport", "Removed ", strconv.Itoa(removed), " expired upload sessions.")
+	}
+}
+
+// StartUploadSessionWorker - start background worker periodically removing expired upload sessions
+func StartUploadSessionWorker() {
+	go func() {
+		for {
+			RemoveExpiredUploadSessions()
+			time.Sleep(constants.UPLOAD_SESSION_INTERVAL)
+		}
+	}()
+}
+
+// removeUploadSessionBlocks - remove transferred blocks of the abandoned file from the disks
+//
+// params:
+//   - session dbo.UploadSession: upload session of the abandoned file
+//
+// return type:
+//   - bool: true if all transferred blocks were removed, false otherwise
+func removeUploadSessionBlocks(session dbo.UploadSession) bool {
+	var sessionBlocks []dbo.UploadSessionBlock
+
+	err := db.DB.DatabaseHandle.Where("session_uuid = ? AND status = ?", session.UUID, constants.BLOCK_STATUS_TRANSFERRED).Find(&sessionBlocks).Error
+	if err != nil {
+		logger.Logger.Error("transport", "Cannot retrieve blocks of the upload session: ", session.UUID.String(), ", got an error: ", err.Error())
+		return false
+	}
+
+	if len(sessionBlocks) == 0 {
+		return true
+	}
+
+	// Blocks of the file saved before its session was removed are in use
+	var count int64
+	err = db.DB.DatabaseHandle.Model(&dbo.File{}).Where("uuid = ?", session.UUID).Count(&count).Error
+	if err != nil || count > 0 {
+		return err == nil
+	}
+
+	// Blocks of the removed volume are gone along with its disks
+	volume := Transport.GetVolume(session.VolumeUUID)
+	if volume == nil {
+		return true
+	}
+
+	// Prepare test context
+	writer := httptest.NewRecorder()
+	_ctx, _ := gin.CreateTestContext(writer)
+
+	var removedAll bool = true
+	for _, sessionBlock := range sessionBlocks {
+		disk := volume.GetDisk(sessionBlock.DiskUUID)
+		if disk == nil {
+			continue
+		}
+
+		var storedSize int = sessionBlock.Size
+		if sessionBlock.CompressedSize != 0 {
+			storedSize = sessionBlock.CompressedSize
+		}
+
+		var status int
+		var blockMetadata *apicalls.BlockMetadata = new(apicalls.BlockMetadata)
+		blockMetadata.Ctx = _ctx
+		blockMetadata.FileUUID = session.UUID
+		blockMetadata.UUID = sessionBlock.UUID
+		blockMetadata.Size = int64(storedSize)
+		blockMetadata.RemoteID = sessionBlock.RemoteID
+		blockMetadata.Status = &status
+		blockMetadata.CompleteCallback = func(UUID uuid.UUID, status *int) {
+		}
+
+		errWrapper := disk.Remove(blockMetadata)
+		if errWrapper != nil {
+			logger.Logger.Warning("transport", "Cannot remove the block: ", sessionBlock.UUID.String(), " of the abandoned file: ", session.UUID.String(), ", will retry later.")
+			removedAll = false
+			continue
+		}
+
+		disk.UpdateUsedSpace(-int64(storedSize))
+
+		// Do not attempt to remove the block again in the next pass
+		err = db.DB.DatabaseHandle.Model(&sessionBlock).Update("status", constants.BLOCK_STATUS_QUEUED).Error
+		if err != nil {
+			logger.Logger.Warning("transport", "Cannot update the block: ", sessionBlock.UUID.String(), " of the abandoned file: ", session.UUID.String(), ", got an error: ", err.Error())
+		}
+	}
+
+	return removedAll
+}
+
+// newUploadSessionBlock - create upload session block DBO based on the block model
+//
+// params:
+//   - fileUUID uuid.UUID: UUID of the uploaded file
+//   - block *Block: block of the file
+//
+// return type:
+//   - *dbo.UploadSessionBlock: created upload session block DBO
+func newUploadSessionBlock(fileUUID uuid.UUID, block *Block) *dbo.UploadSessionBlock {
+	var sessionBlock *dbo.UploadSessionBlock = dbo.NewUploadSessionBlock(fileUUID, block.UUID)
+	sessionBlock.DiskUUID = block.Disk.GetUUID()
+	sessionBlock.Size = block.Size
+	sessionBlock.Order = block.Order
+	sessionBlock.Status = block.Status
+	sessionBlock.Checksum = block.Checksum
+	sessionBlock.RemoteID = block.RemoteID
+	sessionBlock.Compression = block.Compression
+	sessionBlock.CompressedSize = block.CompressedSize
+	sessionBlock.KeyUUID = block.KeyUUID
+	sessionBlock.FormatVersion = block.FormatVersion
+	sessionBlock.ContentHash = block.ContentHash
+
+	return sessionBlock
+}
@@ -1,23 +1,22 @@
 package models
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"dcfs/constants"
 	"dcfs/db"
 	"dcfs/db/dbo"
 	"dcfs/requests"
 	"dcfs/util/logger"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 	"io"
 	"log"
 	"math"
-	"os"
 	"strconv"
+	"sync"
 )
 
 type Volume struct {
@@ -30,7 +29,13 @@ type Volume struct {
 
 	disks        map[uuid.UUID]Disk
 	virtualDisks map[uuid.UUID]Disk
+	disksMutex   sync.RWMutex // guards the disk maps, which are modified while the background workers iterate over them
 	partitioner  Partitioner
+
+	keyUUID        uuid.UUID
+	passphraseSalt string
+	passphraseKey  []byte               // key derived from the passphrase, nil if the volume is locked
+	unlockedKeys   map[uuid.UUID][]byte // data keys unwrapped with the passphrase key
 }
 
 // GetDisk - retrieve disk model from the volume
@@ -38,6 +43,9 @@ type Volume struct {
 // params:
 //   - diskUUID uuid.UUID: UUID of the disk to be retrieved
 func (v *Volume) GetDisk(diskUUID uuid.UUID) Disk {
+	v.disksMutex.RLock()
+	defer v.disksMutex.RUnlock()
+
 	if v.disks == nil {
 		logger.Logger.Warning("volume", "Could not find the disk: ", diskUUID.String(), " (volume's disk map is not initialized).")
 		return nil
@@ -66,14 +74,41 @@ func (v *Volume) GetDisk(diskUUID uuid.UUID) Disk {
 
 // GetDisks - retrieve map of disks of the volume (real or virtual)
 //
+// The returned map is a copy, so it can be iterated while the disks of the volume are modified.
+//
 // return type:
 //   - map[uuid.UUID]Disk: map of real disks if volume has no backup or map of virtual disks is backup is enabled
 func (v *Volume) GetDisks() map[uuid.UUID]Disk {
+	v.disksMutex.RLock()
+	defer v.disksMutex.RUnlock()
+
+	var source map[uuid.UUID]Disk = v.virtualDisks
 	if v.VolumeSettings.Backup == constants.BACKUP_TYPE_NO_BACKUP {
-		return v.disks
-	} else {
-		return v.virtualDisks
+		source = v.disks
 	}
+
+	var disks = make(map[uuid.UUID]Disk, len(source))
+	for diskUUID, disk := range source {
+		disks[diskUUID] = disk
+	}
+
+	return disks
+}
+
+// GetRealDisks - retrieve real disks of the volume, including the ones assigned to virtual disks
+//
+// return type:
+//   - []Disk: copy of the real disks of the volume
+func (v *Volume) GetRealDisks() []Disk {
+	v.disksMutex.RLock()
+	defer v.disksMutex.RUnlock()
+
+	var disks = make([]Disk, 0, len(v.disks))
+	for _, disk := range v.disks {
+		disks = append(disks, disk)
+	}
+
+	return disks
 }
 
 // AddDisk - add disk to the volume
@@ -82,6 +117,9 @@ func (v *Volume) GetDisks() map[uuid.UUID]Disk {
 //   - diskUUID uuid.UUID: UUID of the disk to be added to the volume
 //   - _disk Disk: data of the disk
 func (v *Volume) AddDisk(diskUUID uuid.UUID, _disk Disk) {
+	v.disksMutex.Lock()
+	defer v.disksMutex.Unlock()
+
 	if v.disks == nil {
 		v.disks = make(map[uuid.UUID]Disk)
 	}
@@ -96,6 +134,9 @@ func (v *Volume) AddDisk(diskUUID uuid.UUID, _disk Disk) {
 //   - diskUUID uuid.UUID: UUID of the virtual disk to be added to the volume
 //   - _disk Disk: data of the disk
 func (v *Volume) AddVirtualDisk(diskUUID uuid.UUID, _disk Disk) {
+	v.disksMutex.Lock()
+	defer v.disksMutex.Unlock()
+
 	if v.virtualDisks == nil {
 		v.virtualDisks = make(map[uuid.UUID]Disk)
 	}
@@ -111,56 +152,42 @@ func (v *Volume) AddVirtualDisk(diskUUID uuid.UUID, _disk Disk) {
 func (v *Volume) CreateVirtualDiskAddToVolume(_virtualDisk dbo.Disk) {
 	var virtualDisk Disk
 
-	// Initialize virtual disk map
-	if v.virtualDisks == nil {
-		v.virtualDisks = make(map[uuid.UUID]Disk)
-	}
-
 	// Create virtual disk based on the target backup type
 	switch v.VolumeSettings.Backup {
 	// No backup is used
 	case constants.BACKUP_TYPE_NO_BACKUP:
 		return
 
-	// RAID1+0 backup
-	case constants.BACKUP_TYPE_RAID_1:
-		// Initialize RAID1 virtual disks
-		var firstDisk Disk = nil
-		var secondDisk Disk = nil
+	// RAID1+0 and erasure coded backup
+	case constants.BACKUP_TYPE_RAID_1, constants.BACKUP_TYPE_ERASURE_CODING:
+		var assignedDisks []Disk
 
 		// Locate real disks assigned to the virtual disk
-		for _, disk := range v.disks {
+		for _, disk := range v.GetRealDisks() {
 			if disk.GetVirtualDiskUUID() == _virtualDisk.UUID {
-				if firstDisk == nil {
-					firstDisk = disk
-				} else if secondDisk == nil {
-					secondDisk = disk
-				} else {
-					logger.Logger.Error("volume", "RAID1+0 error. More than 2 disks were assigned to single RAID1 drive.")
-					return
-				}
+				assignedDisks = append(assignedDisks, disk)
 			}
 		}
 
-		// Create RAID1 virtual disk
-		if firstDisk != nil && secondDisk != nil {
-			virtualDisk = DiskTypesRegistry[constants.PROVIDER_TYPE_RAID1]()
-			virtualDisk.SetUUID(_virtualDisk.UUID)
-			virtualDisk.AssignDisk(firstDisk)
-			virtualDisk.AssignDisk(secondDisk)
-			virtualDisk.SetVolume(v)
-		} else {
-			logger.Logger.Error("volume", "RAID1+0 error. Cannot load disks assigned to virtual RAID1 drive.")
+		if len(assignedDisks) != v.GetDisksPerVirtualDisk() {
+			logger.Logger.Error("volume", "Backup error. Expected ", strconv.Itoa(v.GetDisksPerVirtualDisk()), " disks assigned to the virtual drive: ", _virtualDisk.UUID.String(), ", found ", strconv.Itoa(len(assignedDisks)), ".")
 			return
 		}
+
+		// Create virtual disk
+		virtualDisk = DiskTypesRegistry[v.getVirtualProviderType()]()
+		virtualDisk.SetUUID(_virtualDisk.UUID)
+		virtualDisk.SetVolume(v)
+		for _, disk := range assignedDisks {
+			virtualDisk.AssignDisk(disk)
+		}
 	default:
 		logger.Logger.Warning("volume", "Cannot initialize backup drives. Unknown backup type.")
 	}
 
 	// Add virtual disk to virtual disk map
 	if virtualDisk != nil {
-		v.virtualDisks[virtualDisk.GetUUID()] = virtualDisk
-		logger.Logger.Debug("volume", "Added the virtual disk: ", virtualDisk.GetUUID().String(), " to the volume: ", v.UUID.String(), ".")
+		v.AddVirtualDisk(virtualDisk.GetUUID(), virtualDisk)
 	}
 }
 
@@ -173,62 +200,150 @@ func (v *Volume) CreateVirtualDiskAddToVolume(_virtualDisk dbo.Disk) {
 //   - uuid.UUID: virtual disk if matching is possible, uuid.Nil otherwise
 //   - error: database operation error
 func (v *Volume) GenerateVirtualDisk(newDisk Disk) (uuid.UUID, error) {
-	var virtualDisk *dbo.Disk = dbo.NewVirtualDisk()
-	virtualDisk.UUID = uuid.Nil
+	var virtualDisk *dbo.Disk
+	var disks []dbo.Disk
+	var providerType int
+	var err error
+
+	// Hot spare disks are not grouped until they replace a failed disk
+	if newDisk.GetIsSpareFlag() {
+		return uuid.Nil, nil
+	}
 
 	switch v.VolumeSettings.Backup {
 	case constants.BACKUP_TYPE_NO_BACKUP:
 		return uuid.Nil, nil
 
-	case constants.BACKUP_TYPE_RAID_1:
-		var disk *dbo.Disk
-		var err error
-
-		// Find unassigned disk to pair with
-		disk, err = db.FindUnassignedDisk(v.UUID)
+	case constants.BACKUP_TYPE_RAID_1, constants.BACKUP_TYPE_ERASURE_CODING:
+		// Find unassigned disks to group with
+		disks, err = db.FindUnassignedDisks(v.UUID, v.GetDisksPerVirtualDisk()-1)
 		if err != nil {
-			logger.Logger.Debug("disk", "Could not find an unassigned disk to pair with.")
-			if err == gorm.ErrRecordNotFound {
-				return uuid.Nil, nil
-			} else {
-				return uuid.Nil, err
-			}
+			return uuid.Nil, err
 		}
 
-		// Retrieve RAID1 virtual provider from database
-		var provider dbo.Provider
-		result := db.DB.DatabaseHandle.Where("type = ?", constants.PROVIDER_TYPE_RAID1).First(&provider)
-		if result.Error != nil {
-			logger.Logger.Error("disk", "Could not find the provider with the type: ", string(constants.PROVIDER_TYPE_RAID1), " from the db.")
-			return uuid.Nil, result.Error
+		if len(disks) < v.GetDisksPerVirtualDisk()-1 {
+			logger.Logger.Debug("disk", "Not enough unassigned disks to create a virtual disk, found: ", strconv.Itoa(len(disks)), ".")
+			return uuid.Nil, nil
 		}
 
-		// Generate virtual disk
-		virtualDisk.UUID = uuid.New()
-		virtualDisk.UserUUID = disk.UserUUID
-		virtualDisk.VolumeUUID = v.UUID
-		virtualDisk.ProviderUUID = provider.UUID
+		providerType = v.getVirtualProviderType()
 
-		result = db.DB.DatabaseHandle.Create(&virtualDisk)
+	default:
+		return uuid.Nil, nil
+	}
+
+	// Retrieve virtual provider from database
+	var provider dbo.Provider
+	result := db.DB.DatabaseHandle.Where("type = ?", providerType).First(&provider)
+	if result.Error != nil {
+		logger.Logger.Error("disk", "Could not find the provider with the type: ", strconv.Itoa(providerType), " from the db.")
+		return uuid.Nil, result.Error
+	}
+
+	// Generate virtual disk
+	virtualDisk = dbo.NewVirtualDisk()
+	virtualDisk.UUID = uuid.New()
+	virtualDisk.UserUUID = disks[0].UserUUID
+	virtualDisk.VolumeUUID = v.UUID
+	virtualDisk.ProviderUUID = provider.UUID
+
+	result = db.DB.DatabaseHandle.Create(&virtualDisk)
+	if result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+
+	// Save virtual disk uuid to selected disks
+	for _, disk := range disks {
+		result = db.DB.DatabaseHandle.Model(&disk).Update("virtual_disk_uuid", virtualDisk.UUID)
 		if result.Error != nil {
 			return uuid.Nil, result.Error
 		}
+		v.GetDisk(disk.UUID).SetVirtualDiskUUID(virtualDisk.UUID)
+	}
+	newDisk.SetVirtualDiskUUID(virtualDisk.UUID)
 
-		// Save virtual disk uuid to selected disk
-		result = db.DB.DatabaseHandle.Model(disk).Update("virtual_disk_uuid", virtualDisk.UUID)
-		if result.Error != nil {
-			return uuid.Nil, result.Error
+	// Save virtual disk to the volume
+	v.CreateVirtualDiskAddToVolume(*virtualDisk)
+
+	return virtualDisk.UUID, nil
+}
+
+// GetSpareDisks - retrieve hot spare disks of the volume
+//
+// return type:
+//   - []Disk: disks which are not assigned to any virtual disk and are marked as hot spares
+func (v *Volume) GetSpareDisks() []Disk {
+	var spareDisks = make([]Disk, 0)
+
+	for _, d := range v.GetRealDisks() {
+		if d.GetIsSpareFlag() && d.GetVirtualDiskUUID() == uuid.Nil {
+			spareDisks = append(spareDisks, d)
 		}
-		v.disks[disk.UUID].SetVirtualDiskUUID(virtualDisk.UUID)
-		v.disks[newDisk.GetUUID()].SetVirtualDiskUUID(virtualDisk.UUID)
+	}
 
-		// Save virtual disk to the volume
-		v.CreateVirtualDiskAddToVolume(*virtualDisk)
+	return spareDisks
+}
 
-		return virtualDisk.UUID, nil
-	default:
-		return uuid.Nil, nil
+// ReplaceVirtualDiskMember - replace disk assigned to the virtual disk with another one
+//
+// Blocks of the virtual disk are rebuilt on the new disk. The replaced disk remains
+// in the volume, but is no longer assigned to any virtual disk.
+//
+// params:
+//   - disk Disk: disk to be replaced
+//   - newDisk Disk: unassigned disk of the volume
+//
+// return type:
+//   - string: constants.SUCCESS if the disk was replaced, error code otherwise
+//   - error: error which caused the failure
+func (v *Volume) ReplaceVirtualDiskMember(disk Disk, newDisk Disk) (string, error) {
+	var _blocks []dbo.Block
+
+	// Retrieve virtual disk
+	virtualDisk := v.GetDisk(disk.GetVirtualDiskUUID())
+	if virtualDisk == nil {
+		return constants.TRANSPORT_DISK_NOT_FOUND, errors.New("virtual disk not found")
+	}
+
+	_virtualDisk, ok := virtualDisk.(VirtualDisk)
+	if !ok {
+		return constants.OPERATION_NOT_SUPPORTED, errors.New("disk is not a virtual disk")
+	}
+
+	// Retrieve blocks associated with the virtual disk
+	err := db.DB.DatabaseHandle.Where("disk_uuid = ?", virtualDisk.GetUUID()).Find(&_blocks).Error
+	if err != nil {
+		logger.Logger.Error("volume", "Could not find blocks associated with the disk with the uuid: ", virtualDisk.GetUUID().String(), " in the db.")
+		return constants.DATABASE_ERROR, err
 	}
+
+	// Trigger the replacement process
+	errCode := _virtualDisk.ReplaceDisk(disk, newDisk, _blocks)
+	if errCode != constants.SUCCESS {
+		return errCode, errors.New("relocation of the blocks failed")
+	}
+
+	// Remove virtual disk uuid from the current disk
+	err = db.DB.DatabaseHandle.Model(&dbo.Disk{}).Where("uuid = ?", disk.GetUUID()).Update("virtual_disk_uuid", uuid.Nil).Error
+	if err != nil {
+		return constants.DATABASE_ERROR, err
+	}
+	disk.SetVirtualDiskUUID(uuid.Nil)
+
+	// Add virtual disk uuid to the new disk
+	err = db.DB.DatabaseHandle.Model(&dbo.Disk{}).Where("uuid = ?", newDisk.GetUUID()).Updates(map[string]interface{}{"virtual_disk_uuid": virtualDisk.GetUUID(), "is_spare": false}).Error
+	if err != nil {
+		return constants.DATABASE_ERROR, err
+	}
+	newDisk.SetVirtualDiskUUID(virtualDisk.GetUUID())
+	newDisk.SetIsSpareFlag(false)
+
+	logger.Logger.Debug("volume", "Replaced the disk: ", disk.GetUUID().String(), " with the disk: ", newDisk.GetUUID().String(), " in the virtual disk: ", virtualDisk.GetUUID().String(), ".")
+
+	// Refresh volume partitioner after disk change
+	RefreshPartitionerFunc(v)
+
+	return constants.SUCCESS, nil
 }
 
 // DeleteDisk - remove disk from the volume
@@ -236,6 +351,9 @@ func (v *Volume) GenerateVirtualDisk(newDisk Disk) (uuid.UUID, error) {
 // params:
 //   - diskUUID uuid.UUID: UUID of the disk to be deleted from the volume
 func (v *Volume) DeleteDisk(diskUUID uuid.UUID) {
+	v.disksMutex.Lock()
+	defer v.disksMutex.Unlock()
+
 	if v.disks == nil {
 		logger.Logger.Warning("volume", "There are no disks in the volume: ", v.UUID.String(), ".")
 		return
@@ -250,6 +368,9 @@ func (v *Volume) DeleteDisk(diskUUID uuid.UUID) {
 // params:
 //   - diskUUID uuid.UUID: UUID of the virtual disk to be deleted from the volume
 func (v *Volume) DeleteVirtualDisk(diskUUID uuid.UUID) {
+	v.disksMutex.Lock()
+	defer v.disksMutex.Unlock()
+
 	if v.virtualDisks == nil {
 		logger.Logger.Warning("volume", "There are no virtual disks in the volume: ", v.UUID.String(), ".")
 		return
@@ -365,6 +486,8 @@ func (v *Volume) GetVolumeDBO() dbo.Volume {
 		Name:                   v.Name,
 		UserUUID:               v.UserUUID,
 		VolumeSettings:         v.VolumeSettings,
+		KeyUUID:                v.GetKeyUUID(),
+		PassphraseSalt:         v.getPassphraseSalt(),
 	}
 }
 
@@ -385,14 +508,8 @@ func (v *Volume) GetPartitioner() Partitioner {
 func (v *Volume) RefreshPartitioner() {
 	var disks []Disk
 
-	if v.VolumeSettings.Backup == constants.BACKUP_TYPE_NO_BACKUP {
-		for _, disk := range v.disks {
-			disks = append(disks, disk)
-		}
-	} else {
-		for _, disk := range v.virtualDisks {
-			disks = append(disks, disk)
-		}
+	for _, disk := range v.GetDisks() {
+		disks = append(disks, disk)
 	}
 
 	v.partitioner.FetchDisks(disks)
@@ -412,82 +529,92 @@ func (v *Volume) InitializeBackup(virtualDisks []dbo.Disk) {
 	}
 }
 
-// Encrypt - encrypt a []byte using a predefined 256 byte key
+// Encrypt - encrypt a []byte using the current data key of the volume
+//
+// Volumes which have no data key yet use the legacy global key. The identity
+// of the block is bound to the ciphertext, so the block cannot be moved to
+// another file or position without failing the decryption.
+//
+// params: block - []byte to be encrypted, identity - identity of the block
 //
-// params: block - []byte to be encrypted
+// return: UUID of the data key used to encrypt the block, error
+func (v *Volume) Encrypt(block *[]uint8, identity BlockIdentity) (uuid.UUID, error) {
+	if v.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
+		return uuid.Nil, nil
+	}
+
+	keyUUID := v.GetKeyUUID()
+	return keyUUID, v.encryptWithKey(block, keyUUID, identity)
+}
+
+// Decrypt - decrypt a []byte using the data key it was encrypted with
+//
+// params: block - []byte to be decrypted, keyUUID - UUID of the data key used to encrypt the block, identity - identity of the block
 //
 // return: error
-func (v *Volume) Encrypt(block *[]uint8) error {
+func (v *Volume) Decrypt(block *[]uint8, keyUUID uuid.UUID, identity BlockIdentity) error {
 	if v.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
 		return nil
 	}
 
-	key, err := os.ReadFile("./encryption.key")
-	if err != nil {
-		logger.Logger.Error("volume", "Could not read the encryption key, files will not be encrypted: ", err.Error())
-		return err
-	}
+	return v.decryptWithKey(block, keyUUID, identity)
+}
 
-	cb, err := aes.NewCipher(key)
-	if err != nil {
-		logger.Logger.Error("volume", "Could not generate a block cipher object: ", err.Error())
-		return err
+// Compress - compress a []byte using the compression algorithm of the volume
+//
+// Gzip is the only supported algorithm. Blocks which would not shrink are left intact and stored raw.
+//
+// params: block - []byte to be compressed
+//
+// return: compression algorithm applied to the block, error
+func (v *Volume) Compress(block *[]uint8) (int, error) {
+	if v.VolumeSettings.Compression != constants.COMPRESSION_TYPE_GZIP {
+		return constants.COMPRESSION_TYPE_NO_COMPRESSION, nil
 	}
 
-	gcm, err := cipher.NewGCM(cb)
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+
+	_, err := writer.Write(*block)
+	if err == nil {
+		err = writer.Close()
+	}
 	if err != nil {
-		logger.Logger.Error("volume", "Could not generate a gcm object: ", err.Error())
-		return err
+		logger.Logger.Error("volume", "Could not compress the block: ", err.Error(), ".")
+		return constants.COMPRESSION_TYPE_NO_COMPRESSION, err
 	}
 
-	nonce := make([]byte, constants.VOLUME_NONCE_SIZE)
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		logger.Logger.Error("volume", "Could not populate the cipher nonce with a random seed: ", err.Error())
-		return err
+	if buffer.Len() >= len(*block) {
+		return constants.COMPRESSION_TYPE_NO_COMPRESSION, nil
 	}
 
-	*block = gcm.Seal(nonce, nonce, *block, nil)
-
-	return nil
+	*block = buffer.Bytes()
+	return constants.COMPRESSION_TYPE_GZIP, nil
 }
 
-// Decrypt - decrypt a []byte using a predefined 256 byte key
+// Decompress - decompress a []byte using the algorithm it was compressed with
 //
-// params: block - []byte to be decrypted
+// params: block - []byte to be decompressed, compression - compression algorithm applied to the block
 //
 // return: error
-func (v *Volume) Decrypt(block *[]uint8) error {
-	if v.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_NO_ENCRYPTION {
+func (v *Volume) Decompress(block *[]uint8, compression int) error {
+	if compression != constants.COMPRESSION_TYPE_GZIP {
 		return nil
 	}
 
-	key, err := os.ReadFile("./encryption.key")
-	if err != nil {
-		logger.Logger.Error("volume", "Could not read the encryption key, files will not be encrypted: ", err.Error(), ".")
-		return err
-	}
-
-	cb, err := aes.NewCipher(key)
-	if err != nil {
-		logger.Logger.Error("volume", "Could not generate a block cipher object: ", err.Error(), ".")
-		return err
-	}
-
-	gcm, err := cipher.NewGCM(cb)
+	reader, err := gzip.NewReader(bytes.NewReader(*block))
 	if err != nil {
-		logger.Logger.Error("volume", "Could not generate a gcm object: ", err.Error(), ".")
+		logger.Logger.Error("volume", "Could not decompress the block: ", err.Error(), ".")
 		return err
 	}
 
-	nonce := (*block)[:constants.VOLUME_NONCE_SIZE]
-	ciphertext := (*block)[constants.VOLUME_NONCE_SIZE:]
-	*block = make([]uint8, 0)
-	*block, err = gcm.Open(nil, nonce, ciphertext, nil)
+	contents, err := io.ReadAll(reader)
 	if err != nil {
-		logger.Logger.Error("volume", "Could not decode the file: ", err.Error(), ".")
+		logger.Logger.Error("volume", "Could not decompress the block: ", err.Error(), ".")
 		return err
 	}
 
+	*block = contents
 	return nil
 }
 
@@ -495,17 +622,23 @@ func (v *Volume) Decrypt(block *[]uint8) error {
 //
 // return type: bool
 func (v *Volume) IsReady(ctx *gin.Context, blocking bool) bool {
-	if len(v.disks) == 0 {
+	disks := v.GetRealDisks()
+	if len(disks) == 0 {
 		return false
 	}
 
 	if v.VolumeSettings.Backup != constants.BACKUP_TYPE_NO_BACKUP {
-		if 2*len(v.virtualDisks) != len(v.disks) {
+		if v.GetDisksPerVirtualDisk()*len(v.GetDisks()) != len(disks)-len(v.GetSpareDisks()) {
 			return false
 		}
 	}
 
-	for _, d := range v.disks {
+	for _, d := range disks {
+		// Hot spare disks are not used until they replace a failed disk
+		if d.GetIsSpareFlag() {
+			continue
+		}
+
 		if blocking {
 			if !d.GetReadiness().IsReadyForce(ctx) {
 				return false
@@ -520,6 +653,37 @@ func (v *Volume) IsReady(ctx *gin.Context, blocking bool) bool {
 	return true
 }
 
+// GetDisksPerVirtualDisk - retrieve number of real disks assigned to single virtual disk
+//
+// return type:
+//   - int: number of real disks grouped by each virtual disk of the volume
+func (v *Volume) GetDisksPerVirtualDisk() int {
+	switch v.VolumeSettings.Backup {
+	case constants.BACKUP_TYPE_RAID_1:
+		// Volumes created before the replica count was configurable use two disks
+		if v.VolumeSettings.Replicas == 0 {
+			return constants.RAID1_DEFAULT_REPLICAS
+		}
+		return v.VolumeSettings.Replicas
+	case constants.BACKUP_TYPE_ERASURE_CODING:
+		return v.VolumeSettings.DataShards + v.VolumeSettings.ParityShards
+	default:
+		return 1
+	}
+}
+
+// getVirtualProviderType - retrieve provider type of the virtual disks used by the backup type of the volume
+//
+// return type:
+//   - int: provider type of the virtual disks
+func (v *Volume) getVirtualProviderType() int {
+	if v.VolumeSettings.Backup == constants.BACKUP_TYPE_ERASURE_CODING {
+		return constants.PROVIDER_TYPE_ERASURE
+	}
+
+	return constants.PROVIDER_TYPE_RAID1
+}
+
 // NewVolume - create new volume model based on volume and disks DBO
 //
 // This function creates volume model used internally by backend based on
@@ -541,6 +705,8 @@ func NewVolume(_volume *dbo.Volume, _disks []dbo.Disk, _virtualDisks []dbo.Disk)
 	v.Name = _volume.Name
 	v.UserUUID = _volume.UserUUID
 	v.VolumeSettings = _volume.VolumeSettings
+	v.keyUUID = _volume.KeyUUID
+	v.passphraseSalt = _volume.PassphraseSalt
 
 	v.partitioner = CreatePartitioner(v.VolumeSettings.FilePartition, v)
 
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"dcfs/util/sealing"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io"
+	"os"
+	"sync"
+)
+
+// dataKeys - cache of the unwrapped data keys, indexed by the UUID of the key
+var dataKeys = make(map[uuid.UUID][]byte)
+var dataKeysMutex sync.Mutex
+
+// GetKeyUUID - get the current data key of the volume
+//
+// return type:
+//   - uuid.UUID: UUID of the data key, uuid.Nil if the volume uses the legacy global key
+func (v *Volume) GetKeyUUID() uuid.UUID {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+
+	return v.keyUUID
+}
+
+// GenerateDataKey - generate a new data key of the volume and make it the current one
+//
+// The data key is wrapped with the master key, or with the passphrase key if the volume
+// is encrypted with a passphrase, before it is saved in the db. Blocks uploaded afterwards
+// are encrypted with the new key, while blocks encrypted with the previous keys remain readable.
+//
+// return type:
+//   - uuid.UUID: UUID of the generated data key
+//   - error: error if the key cannot be generated or saved
+func (v *Volume) GenerateDataKey() (uuid.UUID, error) {
+	key := make([]byte, constants.VOLUME_DATA_KEY_SIZE)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		logger.Logger.Error("volume", "Could not generate the data key of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return uuid.Nil, err
+	}
+
+	wrappedKey, err := v.wrapDataKey(key)
+	if err != nil {
+		logger.Logger.Error("volume", "Could not wrap the data key of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return uuid.Nil, err
+	}
+
+	volumeKey := dbo.NewVolumeKey(v.UUID, wrappedKey)
+	err = db.DB.DatabaseHandle.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(volumeKey).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&dbo.Volume{}).Where("uuid = ?", v.UUID).Update("key_uuid", volumeKey.UUID).Error
+	})
+	if err != nil {
+		logger.Logger.Error("volume", "Could not save the data key of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return uuid.Nil, err
+	}
+
+	dataKeysMutex.Lock()
+	if cache := v.getKeyCache(); cache != nil {
+		cache[volumeKey.UUID] = key
+	}
+	v.keyUUID = volumeKey.UUID
+	dataKeysMutex.Unlock()
+
+	logger.Logger.Debug("volume", "Generated the data key: ", volumeKey.UUID.String(), " of the volume: ", v.UUID.String(), ".")
+	return volumeKey.UUID, nil
+}
+
+// getKeyCache - retrieve the cache of the unwrapped data keys of the volume
+//
+// Data keys of the volumes encrypted with a passphrase are cached only until the volume
+// is locked. The caller must hold dataKeysMutex.
+//
+// return type:
+//   - map[uuid.UUID][]byte: cache of the data keys, nil if the volume is locked
+func (v *Volume) getKeyCache() map[uuid.UUID][]byte {
+	if v.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_PASSPHRASE {
+		return v.unlockedKeys
+	}
+
+	return dataKeys
+}
+
+// getDataKey - retrieve the unwrapped data key of the volume
+//
+// params:
+//   - keyUUID uuid.UUID: UUID of the data key, uuid.Nil for the legacy global key
+//
+// return type:
+//   - []byte: data key
+//   - error: error if the key cannot be retrieved or unwrapped, ErrVolumeLocked if the volume is locked
+func (v *Volume) getDataKey(keyUUID uuid.UUID) ([]byte, error) {
+	if v.IsLocked() {
+		return nil, ErrVolumeLocked
+	}
+
+	if keyUUID == uuid.Nil {
+		return os.ReadFile(constants.LEGACY_ENCRYPTION_KEY_PATH)
+	}
+
+	dataKeysMutex.Lock()
+	key, ok := v.getKeyCache()[keyUUID]
+	dataKeysMutex.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var volumeKey dbo.VolumeKey
+	err := db.DB.DatabaseHandle.Where("uuid = ? AND volume_uuid = ?", keyUUID, v.UUID).First(&volumeKey).Error
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = v.unwrapDataKey(volumeKey.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKeysMutex.Lock()
+	if cache := v.getKeyCache(); cache != nil {
+		cache[keyUUID] = key
+	}
+	dataKeysMutex.Unlock()
+
+	return key, nil
+}
+
+// wrapDataKey - encrypt the data key before it is saved in the db
+//
+// params:
+//   - key []byte: data key
+//
+// return type:
+//   - string: wrapped data key
+//   - error: error if the key cannot be wrapped, ErrVolumeLocked if the volume is locked
+func (v *Volume) wrapDataKey(key []byte) (string, error) {
+	if v.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_PASSPHRASE {
+		return sealing.Seal(base64.StdEncoding.EncodeToString(key))
+	}
+
+	passphraseKey := v.getPassphraseKey()
+	if passphraseKey == nil {
+		return "", ErrVolumeLocked
+	}
+
+	gcm, err := newKeyCipher(passphraseKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, key, nil)), nil
+}
+
+// unwrapDataKey - decrypt the data key saved in the db
+//
+// params:
+//   - wrappedKey string: wrapped data key
+//
+// return type:
+//   - []byte: data key
+//   - error: error if the key cannot be unwrapped, ErrVolumeLocked if the volume is locked
+func (v *Volume) unwrapDataKey(wrappedKey string) ([]byte, error) {
+	if v.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_PASSPHRASE {
+		encodedKey, err := sealing.Unseal(wrappedKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return base64.StdEncoding.DecodeString(encodedKey)
+	}
+
+	passphraseKey := v.getPassphraseKey()
+	if passphraseKey == nil {
+		return nil, ErrVolumeLocked
+	}
+
+	return unwrapWithPassphraseKey(passphraseKey, wrappedKey)
+}
+
+// unwrapWithPassphraseKey - decrypt the data key wrapped with the key derived from the passphrase
+//
+// params:
+//   - passphraseKey []byte: key derived from the passphrase
+//   - wrappedKey string: wrapped data key
+//
+// return type:
+//   - []byte: data key
+//   - error: error if the key cannot be unwrapped
+func unwrapWithPassphraseKey(passphraseKey []byte, wrappedKey string) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newKeyCipher(passphraseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("the wrapped data key is too short")
+	}
+
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+}
+
+// newKeyCipher - create the AES-GCM cipher with the given key
+//
+// params:
+//   - key []byte: AES-256 key
+//
+// return type:
+//   - cipher.AEAD: AES-GCM cipher
+//   - error: error if the key is invalid
+func newKeyCipher(key []byte) (cipher.AEAD, error) {
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(cb)
+}
+
+// newBlockCipher - create the AES-GCM cipher with the data key of the volume
+//
+// params:
+//   - keyUUID uuid.UUID: UUID of the data key, uuid.Nil for the legacy global key
+//
+// return type:
+//   - cipher.AEAD: AES-GCM cipher
+//   - error: error if the key cannot be retrieved
+func (v *Volume) newBlockCipher(keyUUID uuid.UUID) (cipher.AEAD, error) {
+	key, err := v.getDataKey(keyUUID)
+	if err != nil {
+		logger.Logger.Error("volume", "Could not read the data key: ", keyUUID.String(), " of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return nil, err
+	}
+
+	gcm, err := newKeyCipher(key)
+	if err != nil {
+		logger.Logger.Error("volume", "Could not generate a gcm object: ", err.Error(), ".")
+		return nil, err
+	}
+
+	return gcm, nil
+}
+
+// encryptWithKey - encrypt a []byte using the given data key of the volume
+//
+// The encrypted block starts with the block format header, followed by the nonce
+// and the ciphertext authenticated together with the header and the block identity.
+//
+// params:
+//   - block *[]uint8: block to be encrypted
+//   - keyUUID uuid.UUID: UUID of the data key
+//   - identity BlockIdentity: identity of the block bound to the ciphertext
+//
+// return type:
+//   - error: encryption error
+func (v *Volume) encryptWithKey(block *[]uint8, keyUUID uuid.UUID, identity BlockIdentity) error {
+	gcm, err := v.newBlockCipher(keyUUID)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, constants.VOLUME_NONCE_SIZE)
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		logger.Logger.Error("volume", "Could not populate the cipher nonce with a random seed: ", err.Error())
+		return err
+	}
+
+	header := newBlockHeader()
+	encrypted := make([]byte, 0, len(header)+len(nonce)+len(*block)+gcm.Overhead())
+	encrypted = append(encrypted, header...)
+	encrypted = append(encrypted, nonce...)
+	*block = gcm.Seal(encrypted, nonce, *block, identity.associatedData(v.UUID, header))
+	return nil
+}
+
+// decryptWithKey - decrypt a []byte using the given data key of the volume
+//
+// The block is decrypted in the format recorded in its identity. Only blocks recorded
+// as encrypted before the block format header was introduced are decrypted without
+// the associated data, until the data key of the volume is rotated. The block is left
+// intact if it cannot be decrypted.
+//
+// params:
+//   - block *[]uint8: block to be decrypted
+//   - keyUUID uuid.UUID: UUID of the data key
+//   - identity BlockIdentity: identity of the block bound to the ciphertext
+//
+// return type:
+//   - error: decryption error
+func (v *Volume) decryptWithKey(block *[]uint8, keyUUID uuid.UUID, identity BlockIdentity) error {
+	var header []byte
+	var associatedData []byte
+
+	switch identity.FormatVersion {
+	case constants.BLOCK_FORMAT_VERSION_LEGACY:
+		// Blocks encrypted before the header was introduced are not bound to their identity
+	case constants.BLOCK_FORMAT_VERSION_BOUND:
+		header = newBlockHeader()
+		associatedData = identity.associatedData(v.UUID, header)
+	default:
+		return errors.New("unsupported format of the encrypted block")
+	}
+
+	contents := *block
+	if len(contents) < len(header)+constants.VOLUME_NONCE_SIZE {
+		return errors.New("the encrypted block is too short")
+	}
+
+	if !bytes.HasPrefix(contents, header) {
+		logger.Logger.Error("volume", "The header of the block: ", identity.UUID.String(), " does not match its recorded format.")
+		return errors.New("the header of the encrypted block does not match its format")
+	}
+
+	gcm, err := v.newBlockCipher(keyUUID)
+	if err != nil {
+		return err
+	}
+
+	nonce := contents[len(header) : len(header)+constants.VOLUME_NONCE_SIZE]
+	plaintext, err := gcm.Open(nil, nonce, contents[len(header)+constants.VOLUME_NONCE_SIZE:], associatedData)
+	if err != nil {
+		logger.Logger.Error("volume", "Could not decode the block: ", identity.UUID.String(), " with the data key: ", keyUUID.String(), ", got an error: ", err.Error(), ".")
+		return err
+	}
+
+	*block = plaintext
+	return nil
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"crypto/rand"
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/util/logger"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+	"io"
+)
+
+// ErrVolumeLocked - the volume is encrypted with a passphrase and was not unlocked
+var ErrVolumeLocked = errors.New("the volume is locked")
+
+// ErrInvalidPassphrase - the passphrase does not match the one the volume was encrypted with
+var ErrInvalidPassphrase = errors.New("invalid passphrase")
+
+// IsLocked - check whether the volume is encrypted with a passphrase which was not provided
+//
+// return type:
+//   - bool: true if the blocks of the volume cannot be encrypted or decrypted until it is unlocked
+func (v *Volume) IsLocked() bool {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+
+	return v.VolumeSettings.Encryption == constants.ENCRYPTION_TYPE_PASSPHRASE && v.passphraseKey == nil
+}
+
+// SetPassphrase - derive a new passphrase key of the volume with a fresh salt
+//
+// Only the salt is saved in the db, the volume remains unlocked with the derived key.
+// Data keys wrapped with the previous passphrase become unreadable, so the passphrase
+// may only be set for volumes without blocks.
+//
+// params:
+//   - passphrase string: passphrase provided by the user
+//
+// return type:
+//   - error: error if the salt cannot be generated or saved
+func (v *Volume) SetPassphrase(passphrase string) error {
+	salt := make([]byte, constants.PASSPHRASE_SALT_SIZE)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		logger.Logger.Error("volume", "Could not generate the passphrase salt of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return err
+	}
+
+	encodedSalt := base64.StdEncoding.EncodeToString(salt)
+	err := db.DB.DatabaseHandle.Model(&dbo.Volume{}).Where("uuid = ?", v.UUID).Update("passphrase_salt", encodedSalt).Error
+	if err != nil {
+		logger.Logger.Error("volume", "Could not save the passphrase salt of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return err
+	}
+
+	passphraseKey := derivePassphraseKey(passphrase, salt)
+
+	dataKeysMutex.Lock()
+	v.passphraseSalt = encodedSalt
+	v.passphraseKey = passphraseKey
+	v.unlockedKeys = make(map[uuid.UUID][]byte)
+	dataKeysMutex.Unlock()
+
+	return nil
+}
+
+// Unlock - unlock the volume encrypted with a passphrase
+//
+// The passphrase is verified by unwrapping the current data key of the volume. The derived
+// key is kept only in memory, so the volume is locked again once it is removed from transport.
+//
+// params:
+//   - passphrase string: passphrase provided by the user
+//
+// return type:
+//   - error: ErrInvalidPassphrase if the passphrase is invalid, other error if the volume cannot be unlocked
+func (v *Volume) Unlock(passphrase string) error {
+	if v.VolumeSettings.Encryption != constants.ENCRYPTION_TYPE_PASSPHRASE {
+		return errors.New("the volume is not encrypted with a passphrase")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(v.getPassphraseSalt())
+	if err != nil || len(salt) == 0 {
+		logger.Logger.Error("volume", "The passphrase salt of the volume: ", v.UUID.String(), " is invalid.")
+		return errors.New("the passphrase salt of the volume is invalid")
+	}
+
+	keyUUID := v.GetKeyUUID()
+	var volumeKey dbo.VolumeKey
+	err = db.DB.DatabaseHandle.Where("uuid = ? AND volume_uuid = ?", keyUUID, v.UUID).First(&volumeKey).Error
+	if err != nil {
+		logger.Logger.Error("volume", "Could not retrieve the data key of the volume: ", v.UUID.String(), ", got an error: ", err.Error())
+		return err
+	}
+
+	passphraseKey := derivePassphraseKey(passphrase, salt)
+	key, err := unwrapWithPassphraseKey(passphraseKey, volumeKey.WrappedKey)
+	if err != nil {
+		logger.Logger.Warning("volume", "Invalid passphrase was provided to unlock the volume: ", v.UUID.String(), ".")
+		return ErrInvalidPassphrase
+	}
+
+	dataKeysMutex.Lock()
+	v.passphraseKey = passphraseKey
+	v.unlockedKeys = map[uuid.UUID][]byte{keyUUID: key}
+	dataKeysMutex.Unlock()
+
+	logger.Logger.Debug("volume", "Unlocked the volume: ", v.UUID.String(), ".")
+	return nil
+}
+
+// Lock - forget the passphrase key and the data keys of the volume encrypted with a passphrase
+func (v *Volume) Lock() {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+
+	v.passphraseKey = nil
+	v.unlockedKeys = nil
+
+	logger.Logger.Debug("volume", "Locked the volume: ", v.UUID.String(), ".")
+}
+
+// getPassphraseKey - retrieve the key derived from the passphrase
+//
+// return type:
+//   - []byte: passphrase key, nil if the volume is locked
+func (v *Volume) getPassphraseKey() []byte {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+
+	return v.passphraseKey
+}
+
+// getPassphraseSalt - retrieve the salt of the key derived from the passphrase
+//
+// return type:
+//   - string: base64 encoded salt, empty if the volume is not encrypted with a passphrase
+func (v *Volume) getPassphraseSalt() string {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+
+	return v.passphraseSalt
+}
+
+// derivePassphraseKey - derive the AES-256 key from the passphrase using Argon2id
+//
+// params:
+//   - passphrase string: passphrase provided by the user
+//   - salt []byte: salt of the volume
+//
+// return type:
+//   - []byte: derived key
+func derivePassphraseKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, constants.PASSPHRASE_KDF_TIME, constants.PASSPHRASE_KDF_MEMORY, constants.PASSPHRASE_KDF_THREADS, uint32(constants.VOLUME_DATA_KEY_SIZE))
+}
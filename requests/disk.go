@@ -1,6 +1,7 @@
 package requests
 
 import (
+	"dcfs/constants"
 	"encoding/json"
 )
 
@@ -10,19 +11,72 @@ type FTPCredentials struct {
 	Host     string `json:"host"`
 	Port     string `json:"port"`
 	Path     string `json:"path"`
+
+	TLSMode        string `json:"tlsMode,omitempty"`
+	TLSCA          string `json:"tlsCA,omitempty"`          // PEM encoded CA bundle used instead of the system roots
+	TLSFingerprint string `json:"tlsFingerprint,omitempty"` // hex SHA-256 of the pinned server certificate
+
+	PrivateKey string `json:"privateKey,omitempty"` // PEM encoded SSH private key
+	Passphrase string `json:"passphrase,omitempty"`
+	HostKey    string `json:"hostKey,omitempty"` // SHA256 fingerprint of the trusted SSH host key
+}
+
+type S3Credentials struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+type WebDAVCredentials struct {
+	URL      string `json:"url"`
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Path     string `json:"path"`
+}
+
+type AzureCredentials struct {
+	ConnectionString string `json:"connectionString"`
+	ServiceURL       string `json:"serviceURL"`
+	SASToken         string `json:"sasToken"`
+	Container        string `json:"container"`
+	Prefix           string `json:"prefix"`
+}
+
+// DiskCredentials - credentials provided for a new disk, fields used depend on the provider type
+//
+// WebDAV disks are configured with the server URL, the login, password
+// and path are shared with the FTP credentials. Azure Blob disks are
+// configured with the container and either the connection string or
+// the service URL with the SAS token, the prefix is shared with
+// the S3 credentials.
+type DiskCredentials struct {
+	FTPCredentials
+	S3Credentials
+
+	URL string `json:"url"`
+
+	ConnectionString string `json:"connectionString"`
+	ServiceURL       string `json:"serviceURL"`
+	SASToken         string `json:"sasToken"`
+	Container        string `json:"container"`
 }
 
 type OAuthCredentials struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+	FolderID     string `json:"folderID,omitempty"`
 }
 
 type DiskCreateRequest struct {
-	Name         string         `json:"name" binding:"required,gte=1,lte=64"`
-	TotalSpace   uint64         `json:"totalSpace" binding:"required,min=1"`
-	ProviderUUID string         `json:"providerUUID" binding:"required"`
-	VolumeUUID   string         `json:"volumeUUID" binding:"required"`
-	Credentials  FTPCredentials `json:"credentials" binding:"required"`
+	Name         string          `json:"name" binding:"required,gte=1,lte=64"`
+	TotalSpace   uint64          `json:"totalSpace" binding:"required,min=1"`
+	ProviderUUID string          `json:"providerUUID" binding:"required"`
+	VolumeUUID   string          `json:"volumeUUID" binding:"required"`
+	Credentials  DiskCredentials `json:"credentials" binding:"required"`
+	IsSpare      bool            `json:"isSpare"`
 }
 
 type OAuthRequest struct {
@@ -30,9 +84,10 @@ type OAuthRequest struct {
 }
 
 type DiskUpdateRequest struct {
-	Name        string         `json:"name" binding:"required,gte=1,lte=64"`
-	TotalSpace  uint64         `json:"totalSpace" binding:"required,min=1"`
-	Credentials FTPCredentials `json:"credentials" binding:"required"`
+	Name        string          `json:"name" binding:"required,gte=1,lte=64"`
+	TotalSpace  uint64          `json:"totalSpace" binding:"required,min=1"`
+	Credentials DiskCredentials `json:"credentials" binding:"required"`
+	IsSpare     *bool           `json:"isSpare"`
 }
 
 // ToString - convert FTP credentials to JSON string
@@ -40,7 +95,15 @@ type DiskUpdateRequest struct {
 // return type:
 //   - string: credentials converted to JSON string
 func (cred *FTPCredentials) ToString() string {
-	if cred.Port == "" || cred.Login == "" || cred.Host == "" {
+	// Local disks are configured with the directory path only
+	isLocal := cred.Port == "" && cred.Login == "" && cred.Host == "" && cred.Password == "" && cred.Path != ""
+	if !isLocal && (cred.Port == "" || cred.Login == "" || cred.Host == "") {
+		return ""
+	}
+
+	switch cred.TLSMode {
+	case "", constants.FTP_TLS_MODE_NONE, constants.FTP_TLS_MODE_EXPLICIT, constants.FTP_TLS_MODE_IMPLICIT:
+	default:
 		return ""
 	}
 
@@ -62,6 +125,115 @@ func StringToFTPCredentials(cred string) *FTPCredentials {
 	return ret
 }
 
+// ToString - convert S3 credentials to JSON string
+//
+// return type:
+//   - string: credentials converted to JSON string
+func (cred *S3Credentials) ToString() string {
+	if cred.Endpoint == "" || cred.Bucket == "" || cred.AccessKey == "" || cred.SecretKey == "" {
+		return ""
+	}
+
+	ret, _ := json.Marshal(cred)
+	return string(ret)
+}
+
+// StringToS3Credentials - convert JSON string to S3 credentials
+//
+// params:
+//   - cred string: JSON representation of S3 credentials
+//
+// return type:
+//   - *S3Credentials: converted S3 credentials
+func StringToS3Credentials(cred string) *S3Credentials {
+	var ret *S3Credentials = &S3Credentials{}
+	_ = json.Unmarshal([]byte(cred), ret)
+
+	return ret
+}
+
+// ToString - convert WebDAV credentials to JSON string
+//
+// return type:
+//   - string: credentials converted to JSON string
+func (cred *WebDAVCredentials) ToString() string {
+	if cred.URL == "" {
+		return ""
+	}
+
+	ret, _ := json.Marshal(cred)
+	return string(ret)
+}
+
+// StringToWebDAVCredentials - convert JSON string to WebDAV credentials
+//
+// params:
+//   - cred string: JSON representation of WebDAV credentials
+//
+// return type:
+//   - *WebDAVCredentials: converted WebDAV credentials
+func StringToWebDAVCredentials(cred string) *WebDAVCredentials {
+	var ret *WebDAVCredentials = &WebDAVCredentials{}
+	_ = json.Unmarshal([]byte(cred), ret)
+
+	return ret
+}
+
+// ToString - convert Azure Blob credentials to JSON string
+//
+// return type:
+//   - string: credentials converted to JSON string
+func (cred *AzureCredentials) ToString() string {
+	if cred.Container == "" || (cred.ConnectionString == "" && cred.ServiceURL == "") {
+		return ""
+	}
+
+	ret, _ := json.Marshal(cred)
+	return string(ret)
+}
+
+// StringToAzureCredentials - convert JSON string to Azure Blob credentials
+//
+// params:
+//   - cred string: JSON representation of Azure Blob credentials
+//
+// return type:
+//   - *AzureCredentials: converted Azure Blob credentials
+func StringToAzureCredentials(cred string) *AzureCredentials {
+	var ret *AzureCredentials = &AzureCredentials{}
+	_ = json.Unmarshal([]byte(cred), ret)
+
+	return ret
+}
+
+// ToString - convert disk credentials to JSON string of the credentials used by the provider
+//
+// return type:
+//   - string: credentials converted to JSON string
+func (cred *DiskCredentials) ToString() string {
+	if cred.Bucket != "" {
+		return cred.S3Credentials.ToString()
+	}
+
+	if cred.Container != "" {
+		azureCredentials := AzureCredentials{
+			ConnectionString: cred.ConnectionString,
+			ServiceURL:       cred.ServiceURL,
+			SASToken:         cred.SASToken,
+			Container:        cred.Container,
+			Prefix:           cred.Prefix,
+		}
+		return azureCredentials.ToString()
+	}
+
+	if cred.URL != "" {
+		webDAVCredentials := WebDAVCredentials{URL: cred.URL, Login: cred.Login, Password: cred.Password, Path: cred.Path}
+		return webDAVCredentials.ToString()
+	}
+
+	return cred.FTPCredentials.ToString()
+}
+
 // StringToOAuthCredentials - convert JSON string to OAuth credentials
 //
 // params:
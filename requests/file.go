@@ -1,9 +1,10 @@
 package requests
 
 type FileDataRequest struct {
-	Name string `json:"name" binding:"required,gte=1,lte=64"`
-	Type int    `json:"type" binding:"required,min=2,max=2"` // 1 is not allowed (directory)
-	Size int    `json:"size" binding:"required,min=1"`
+	Name     string `json:"name" binding:"required,gte=1,lte=64"`
+	Type     int    `json:"type" binding:"required,min=2,max=2"` // 1 is not allowed (directory)
+	Size     int    `json:"size" binding:"required,min=1"`
+	Checksum string `json:"checksum" binding:"omitempty,len=64,hexadecimal"` // SHA-256 of the plain contents of the file
 }
 
 type DirectoryCreateRequest struct {
@@ -18,6 +19,10 @@ type InitFileUploadRequest struct {
 	File       FileDataRequest `json:"file" binding:"required"`
 }
 
+type InitFileDownloadRequest struct {
+	Files []string `json:"files"`
+}
+
 type UpdateFileRequest struct {
 	Name     string `json:"name" binding:"required,gte=1,lte=64"`
 	RootUUID string `json:"rootUUID"`
@@ -1,12 +1,21 @@
 package requests
 
 type VolumeSettingsRequest struct {
-	Backup        int `json:"backup" binding:"required,min=1,max=2"`
-	Encryption    int `json:"encryption" binding:"required,min=1,max=2"`
+	Backup        int `json:"backup" binding:"required,min=1,max=3"`
+	Encryption    int `json:"encryption" binding:"required,min=1,max=3"`
+	Compression   int `json:"compression" binding:"omitempty,min=1,max=2"` // gzip (1) or no compression (2)
 	FilePartition int `json:"filePartition" binding:"required,min=1,max=3"`
+	Replicas      int `json:"replicas" binding:"omitempty,min=2,max=8"`
+	DataShards    int `json:"dataShards" binding:"omitempty,min=1,max=128"`
+	ParityShards  int `json:"parityShards" binding:"omitempty,min=1,max=128"`
 }
 
 type VolumeCreateRequest struct {
-	Name     string                `json:"name" binding:"required,gte=1,lte=64"`
-	Settings VolumeSettingsRequest `json:"settings" binding:"required"`
+	Name       string                `json:"name" binding:"required,gte=1,lte=64"`
+	Settings   VolumeSettingsRequest `json:"settings" binding:"required"`
+	Passphrase string                `json:"passphrase" binding:"omitempty,gte=8,lte=256"`
+}
+
+type VolumeUnlockRequest struct {
+	Passphrase string `json:"passphrase" binding:"required"`
 }
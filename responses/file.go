@@ -1,6 +1,7 @@
 package responses
 
 import (
+	"dcfs/constants"
 	"dcfs/db/dbo"
 	"dcfs/models"
 	"github.com/google/uuid"
@@ -108,6 +109,43 @@ func NewInitFileUploadRequestResponse(userUUID uuid.UUID, file models.File) *Suc
 	return r
 }
 
+// NewFileUploadStatusResponse - create file upload status success response
+//
+// params:
+//   - userUUID uuid.UUID: UUID of owner of the file
+//   - file models.File: file and block data to return
+//
+// return type:
+//   - *SuccessResponse: response with file data and blocks which are not transferred yet
+func NewFileUploadStatusResponse(userUUID uuid.UUID, file models.File) *SuccessResponse {
+	var r *SuccessResponse = new(SuccessResponse)
+	var fr *FileRequestResponse = new(FileRequestResponse)
+
+	// Prepare file for response
+	fr.File = file.GetFileDBO(userUUID)
+
+	// Prepare missing blocks for response
+	var blocks []FileRequestBlockResponse = make([]FileRequestBlockResponse, 0)
+	for _, block := range file.GetBlocks() {
+		if block.Status == constants.BLOCK_STATUS_TRANSFERRED {
+			continue
+		}
+
+		blocks = append(blocks, FileRequestBlockResponse{
+			UUID:  block.UUID,
+			Order: block.Order,
+			Size:  block.Size,
+		})
+	}
+	fr.Blocks = blocks
+
+	// Prepare final response
+	r.Success = true
+	r.Data = fr
+
+	return r
+}
+
 // NewBlockTransferFailureResponse - create block transfer failure response
 //
 // params:
@@ -0,0 +1,38 @@
+package responses
+
+import (
+	"dcfs/models"
+	"github.com/google/uuid"
+)
+
+type BlockProofResponse struct {
+	FileUUID   uuid.UUID `json:"fileUUID"`
+	Checksum   string    `json:"checksum"`
+	MerkleRoot string    `json:"merkleRoot"`
+	Verified   bool      `json:"verified"` // stored contents of the block lead to the merkle root of the file
+	models.BlockProof
+}
+
+// NewBlockProofSuccessResponse - create block proof success response
+//
+// params:
+//   - file *models.RegularFile: file to which the block belongs
+//   - proof *models.BlockProof: proof of inclusion of the block in the merkle tree of the file
+//   - verified bool: true if the stored contents of the block were verified against the merkle root of the file
+//
+// return type:
+//   - *SuccessResponse: response with the proof of the block
+func NewBlockProofSuccessResponse(file *models.RegularFile, proof *models.BlockProof, verified bool) *SuccessResponse {
+	var r *SuccessResponse = new(SuccessResponse)
+
+	r.Success = true
+	r.Data = BlockProofResponse{
+		FileUUID:   file.GetUUID(),
+		Checksum:   file.Checksum,
+		MerkleRoot: file.MerkleRoot,
+		Verified:   verified,
+		BlockProof: *proof,
+	}
+
+	return r
+}
@@ -0,0 +1,31 @@
+package responses
+
+import "github.com/google/uuid"
+
+type KeyRotationResponse struct {
+	KeyUUID         uuid.UUID `json:"keyUUID"`
+	RemainingBlocks int64     `json:"remainingBlocks"`
+	InProgress      bool      `json:"inProgress"`
+}
+
+// NewKeyRotationSuccessResponse - create key rotation progress success response
+//
+// params:
+//   - keyUUID uuid.UUID: UUID of the current data key of the volume
+//   - remainingBlocks int64: number of blocks which are not yet encrypted with the current data key
+//   - inProgress bool: true if the key rotation is currently running
+//
+// return type:
+//   - *SuccessResponse: response with key rotation progress
+func NewKeyRotationSuccessResponse(keyUUID uuid.UUID, remainingBlocks int64, inProgress bool) *SuccessResponse {
+	var r *SuccessResponse = new(SuccessResponse)
+
+	r.Success = true
+	r.Data = KeyRotationResponse{
+		KeyUUID:         keyUUID,
+		RemainingBlocks: remainingBlocks,
+		InProgress:      inProgress,
+	}
+
+	return r
+}
@@ -0,0 +1,35 @@
+package responses
+
+import "dcfs/db/dbo"
+
+type ScrubResponse struct {
+	dbo.Scrub
+	InProgress bool                 `json:"inProgress"`
+	DiskErrors []dbo.ScrubDiskError `json:"diskErrors"`
+}
+
+// NewScrubSuccessResponse - create scrub report success response
+//
+// params:
+//   - scrub *dbo.Scrub: progress and report of the scrub
+//   - diskErrors []dbo.ScrubDiskError: number of errors found on each disk
+//   - inProgress bool: true if the scrub is currently running
+//
+// return type:
+//   - *SuccessResponse: response with scrub report
+func NewScrubSuccessResponse(scrub *dbo.Scrub, diskErrors []dbo.ScrubDiskError, inProgress bool) *SuccessResponse {
+	var r *SuccessResponse = new(SuccessResponse)
+
+	if diskErrors == nil {
+		diskErrors = make([]dbo.ScrubDiskError, 0)
+	}
+
+	r.Success = true
+	r.Data = ScrubResponse{
+		Scrub:      *scrub,
+		InProgress: inProgress,
+		DiskErrors: diskErrors,
+	}
+
+	return r
+}
@@ -4,7 +4,8 @@ import "dcfs/db/dbo"
 
 type VolumeResponse struct {
 	dbo.Volume
-	IsReady bool `json:"isReady"`
+	IsReady  bool `json:"isReady"`
+	IsLocked bool `json:"isLocked"`
 }
 
 // NewVolumeDataSuccessResponse - create volume data success response
@@ -0,0 +1,153 @@
+package mock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const AzureAccountName string = "devstoreaccount1"
+const AzureAccountKey string = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+const AzureContainer string = "dcfs"
+const AzureSASToken string = "sv=2020-10-02&ss=b&srt=co&sp=rwdlac&sig=mock"
+
+// MockAzureServer - in-memory stand-in of the Azurite blob service
+type MockAzureServer struct {
+	Server *httptest.Server
+	Blobs  map[string][]byte
+	Staged map[string]map[string][]byte
+
+	// Number of blobs committed from the staged blocks
+	CommittedBlockLists int
+
+	mtx sync.Mutex
+}
+
+func (s *MockAzureServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = fmt.Fprint(w, "<Error><Code>AuthenticationFailed</Code><Message>Server failed to authenticate the request.</Message></Error>")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/"+AzureAccountName+"/")
+	container, name, _ := strings.Cut(path, "/")
+	if container != AzureContainer {
+		w.Header().Set("x-ms-error-code", "ContainerNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodHead && query.Get("restype") == "container":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		if s.Staged[name] == nil {
+			s.Staged[name] = make(map[string][]byte)
+		}
+		s.Staged[name][query.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var blockList struct {
+			Latest []string `xml:"Latest"`
+		}
+		_ = xml.Unmarshal(body, &blockList)
+
+		var blob []byte
+		for _, id := range blockList.Latest {
+			blob = append(blob, s.Staged[name][id]...)
+		}
+		s.Blobs[name] = blob
+		s.CommittedBlockLists++
+		delete(s.Staged, name)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-blob-type") == "BlockBlob":
+		s.Blobs[name] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		blob, ok := s.Blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, "<Error><Code>BlobNotFound</Code><Message>The specified blob does not exist.</Message></Error>")
+			return
+		}
+		_, _ = w.Write(blob)
+	case r.Method == http.MethodDelete:
+		delete(s.Blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// authorized - verify the SAS token or the Shared Key signature of the request
+func (s *MockAzureServer) authorized(r *http.Request) bool {
+	if r.URL.Query().Get("sig") != "" {
+		return r.URL.Query().Get("sig") == "mock"
+	}
+
+	var msHeaders []string
+	for name := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-ms-") {
+			msHeaders = append(msHeaders, strings.ToLower(name)+":"+r.Header.Get(name)+"\n")
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var params []string
+	for name, values := range r.URL.Query() {
+		params = append(params, "\n"+strings.ToLower(name)+":"+strings.Join(values, ","))
+	}
+	sort.Strings(params)
+
+	contentLength := ""
+	if r.ContentLength > 0 {
+		contentLength = strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	stringToSign := r.Method + "\n\n\n" + contentLength + "\n\n" + r.Header.Get("Content-Type") + "\n\n\n\n\n\n\n" +
+		strings.Join(msHeaders, "") + "/" + AzureAccountName + r.URL.EscapedPath() + strings.Join(params, "")
+
+	key, _ := base64.StdEncoding.DecodeString(AzureAccountKey)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+
+	return r.Header.Get("Authorization") == "SharedKey "+AzureAccountName+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ConnectionStringCredentials - get credentials of the disk authorized with the account key
+func (s *MockAzureServer) ConnectionStringCredentials(container string) string {
+	return fmt.Sprintf(`{"connectionString":"DefaultEndpointsProtocol=http;AccountName=%s;AccountKey=%s;BlobEndpoint=%s/%s;","container":"%s","prefix":"volume"}`,
+		AzureAccountName, AzureAccountKey, s.Server.URL, AzureAccountName, container)
+}
+
+// SASCredentials - get credentials of the disk authorized with the SAS token
+func (s *MockAzureServer) SASCredentials(container string) string {
+	return fmt.Sprintf(`{"serviceURL":"%s/%s","sasToken":"%s","container":"%s","prefix":"volume"}`,
+		s.Server.URL, AzureAccountName, AzureSASToken, container)
+}
+
+func NewMockAzureServer() *MockAzureServer {
+	s := &MockAzureServer{
+		Blobs:  make(map[string][]byte),
+		Staged: make(map[string]map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
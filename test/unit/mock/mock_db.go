@@ -1,26 +1,36 @@
 package mock
 
 import (
+	"database/sql/driver"
 	"dcfs/db"
 	"dcfs/db/dbo"
+	"dcfs/util/sealing"
 	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"os"
+	"path/filepath"
 )
 
 var DBMock sqlmock.Sqlmock
 
 var DiskColumns []string = []string{"uuid", "user_uuid", "volume_uuid", "provider_uuid", "credentials", "name", "created_at", "used_space", "total_space", "is_virtual", "virtual_disk_uuid"}
 
-var VolumeColumns []string = []string{"uuid", "name", "user_uuid", "backup", "encryption", "file_partition", "created_at", "deleted_at"}
+var VolumeColumns []string = []string{"uuid", "name", "user_uuid", "backup", "encryption", "file_partition", "compression", "key_uuid", "passphrase_salt", "created_at", "deleted_at"}
 
-var BlockColumns []string = []string{"uuid", "user_uuid", "volume_uuid", "disk_uuid", "file_uuid", "size", "order", "checksum"}
+var BlockColumns []string = []string{"uuid", "user_uuid", "volume_uuid", "disk_uuid", "file_uuid", "size", "order", "checksum", "remote_id", "compression", "compressed_size", "key_uuid", "format_version", "content_hash"}
 
 var ProviderColumns []string = []string{"uuid", "type", "name", "logo"}
 
 var UserColumns []string = []string{"uuid", "first_name", "last_name", "email", "password"}
 
+var UploadSessionColumns []string = []string{"uuid", "user_uuid", "volume_uuid", "root_uuid", "name", "size", "checksum", "created_at", "updated_at"}
+
+var UploadSessionBlockColumns []string = []string{"uuid", "session_uuid", "disk_uuid", "size", "order", "status", "checksum", "remote_id", "compression", "compressed_size", "key_uuid", "format_version", "content_hash"}
+
+var DegradedBlockColumns []string = []string{"uuid", "block_uuid", "file_uuid", "volume_uuid", "virtual_disk_uuid", "disk_uuid", "attempts", "retry_at", "created_at"}
+
 func DiskRow(_dbos ...*dbo.Disk) *sqlmock.Rows {
 	ret := sqlmock.NewRows(DiskColumns)
 
@@ -61,6 +71,9 @@ func VolumeRow(_dbos ...*dbo.Volume) *sqlmock.Rows {
 			_dbo.VolumeSettings.Backup,
 			_dbo.VolumeSettings.Encryption,
 			_dbo.VolumeSettings.FilePartition,
+			_dbo.VolumeSettings.Compression,
+			_dbo.KeyUUID,
+			_dbo.PassphraseSalt,
 			_dbo.CreatedAt,
 			_dbo.DeletedAt)
 	}
@@ -84,7 +97,13 @@ func BlockRow(_dbos ...*dbo.Block) *sqlmock.Rows {
 			_dbo.FileUUID,
 			_dbo.Size,
 			_dbo.Order,
-			_dbo.Checksum)
+			_dbo.Checksum,
+			_dbo.RemoteID,
+			_dbo.Compression,
+			_dbo.CompressedSize,
+			_dbo.KeyUUID,
+			_dbo.FormatVersion,
+			_dbo.ContentHash)
 	}
 
 	return ret
@@ -127,6 +146,89 @@ func UserRow(_dbos ...*dbo.User) *sqlmock.Rows {
 	return ret
 }
 
+func UploadSessionRow(_dbos ...*dbo.UploadSession) *sqlmock.Rows {
+	ret := sqlmock.NewRows(UploadSessionColumns)
+
+	for _, _dbo := range _dbos {
+		if _dbo == nil {
+			continue
+		}
+
+		ret.AddRow(
+			_dbo.UUID,
+			_dbo.UserUUID,
+			_dbo.VolumeUUID,
+			_dbo.RootUUID,
+			_dbo.Name,
+			_dbo.Size,
+			_dbo.Checksum,
+			_dbo.CreatedAt,
+			_dbo.UpdatedAt)
+	}
+
+	return ret
+}
+
+func UploadSessionBlockRow(_dbos ...*dbo.UploadSessionBlock) *sqlmock.Rows {
+	ret := sqlmock.NewRows(UploadSessionBlockColumns)
+
+	for _, _dbo := range _dbos {
+		if _dbo == nil {
+			continue
+		}
+
+		ret.AddRow(
+			_dbo.UUID,
+			_dbo.SessionUUID,
+			_dbo.DiskUUID,
+			_dbo.Size,
+			_dbo.Order,
+			_dbo.Status,
+			_dbo.Checksum,
+			_dbo.RemoteID,
+			_dbo.Compression,
+			_dbo.CompressedSize,
+			_dbo.KeyUUID,
+			_dbo.FormatVersion,
+			_dbo.ContentHash)
+	}
+
+	return ret
+}
+
+func DegradedBlockRow(_dbos ...*dbo.DegradedBlock) *sqlmock.Rows {
+	ret := sqlmock.NewRows(DegradedBlockColumns)
+
+	for _, _dbo := range _dbos {
+		if _dbo == nil {
+			continue
+		}
+
+		ret.AddRow(
+			_dbo.UUID,
+			_dbo.BlockUUID,
+			_dbo.FileUUID,
+			_dbo.VolumeUUID,
+			_dbo.VirtualDiskUUID,
+			_dbo.DiskUUID,
+			_dbo.Attempts,
+			_dbo.RetryAt,
+			_dbo.CreatedAt)
+	}
+
+	return ret
+}
+
+// CapturedArgument - sqlmock argument matching any value and remembering the last matched one
+type CapturedArgument struct {
+	Value driver.Value
+}
+
+func (a *CapturedArgument) Match(v driver.Value) bool {
+	a.Value = v
+	return true
+}
+
 func init() {
 	_db, _mock, _ := sqlmock.New()
 	_mock.MatchExpectationsInOrder(false)
@@ -135,4 +237,7 @@ func init() {
 		SkipInitializeWithVersion: true,
 	}), &gorm.Config{})
 	DBMock = _mock
+
+	// Do not generate the key-encryption key in the repository
+	sealing.KeyPath = filepath.Join(os.TempDir(), "dcfs-mock-master.key")
 }
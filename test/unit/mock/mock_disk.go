@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -25,6 +26,14 @@ type MockDisk struct {
 
 	CreationTime  time.Time
 	DiskReadiness *MockDiskReadiness
+
+	// Blocks stores uploaded contents if initialized, IsFailing makes all operations on stored blocks fail
+	Blocks    map[uuid.UUID][]uint8
+	IsFailing bool
+	mutex     sync.Mutex
+
+	IsSpare         bool
+	VirtualDiskUUID uuid.UUID
 }
 
 /* Mandatory Disk interface implementations */
@@ -32,6 +41,19 @@ type MockDisk struct {
 func (d *MockDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
 	time.Sleep(time.Duration(d.SpeedFactor) * time.Millisecond)
 
+	if d.Blocks != nil {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		if d.IsFailing {
+			return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Mock disk is failing")
+		}
+
+		contents := make([]uint8, len(*blockMetadata.Content))
+		copy(contents, *blockMetadata.Content)
+		d.Blocks[blockMetadata.UUID] = contents
+	}
+
 	*blockMetadata.Status = constants.BLOCK_STATUS_TRANSFERRED
 	blockMetadata.CompleteCallback(blockMetadata.UUID, blockMetadata.Status)
 
@@ -41,6 +63,20 @@ func (d *MockDisk) Upload(blockMetadata *apicalls.BlockMetadata) *apicalls.Error
 func (d *MockDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
 	time.Sleep(time.Duration(d.SpeedFactor) * time.Millisecond)
 
+	if d.Blocks != nil {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		contents, ok := d.Blocks[blockMetadata.UUID]
+		if d.IsFailing || !ok {
+			return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Mock disk cannot download the block")
+		}
+
+		_contents := make([]uint8, len(contents))
+		copy(_contents, contents)
+		blockMetadata.Content = &_contents
+	}
+
 	*blockMetadata.Status = constants.BLOCK_STATUS_TRANSFERRED
 	blockMetadata.CompleteCallback(blockMetadata.UUID, blockMetadata.Status)
 
@@ -48,6 +84,17 @@ func (d *MockDisk) Download(blockMetadata *apicalls.BlockMetadata) *apicalls.Err
 }
 
 func (d *MockDisk) Remove(blockMetadata *apicalls.BlockMetadata) *apicalls.ErrorWrapper {
+	if d.Blocks != nil {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		if d.IsFailing {
+			return apicalls.CreateErrorWrapper(constants.REMOTE_FAILED_JOB, "Mock disk is failing")
+		}
+
+		delete(d.Blocks, blockMetadata.UUID)
+	}
+
 	*blockMetadata.Status = constants.BLOCK_STATUS_TRANSFERRED
 	blockMetadata.CompleteCallback(blockMetadata.UUID, blockMetadata.Status)
 
@@ -107,11 +154,19 @@ func (d *MockDisk) GetIsVirtualFlag() bool {
 }
 
 func (d *MockDisk) SetVirtualDiskUUID(uuid uuid.UUID) {
-	return
+	d.VirtualDiskUUID = uuid
 }
 
 func (d *MockDisk) GetVirtualDiskUUID() uuid.UUID {
-	return uuid.Nil
+	return d.VirtualDiskUUID
+}
+
+func (d *MockDisk) SetIsSpareFlag(isSpare bool) {
+	d.IsSpare = isSpare
+}
+
+func (d *MockDisk) GetIsSpareFlag() bool {
+	return d.IsSpare
 }
 
 func (d *MockDisk) SetUsedSpace(usage uint64) {
@@ -186,18 +241,28 @@ func (d *MockDisk) GetResponse(_disk *dbo.Disk, ctx *gin.Context) *models.DiskRe
 	return nil
 }
 
-type MockDiskReadiness struct{}
+type MockDiskReadiness struct {
+	IsUnavailable bool
+}
 
 func (mdr *MockDiskReadiness) IsReady(ctx context.Context) bool {
-	return true
+	return !mdr.IsUnavailable
 }
 
 func (mdr *MockDiskReadiness) IsReadyForce(ctx context.Context) bool {
-	return true
+	return !mdr.IsUnavailable
 }
 
 func (mdr *MockDiskReadiness) IsReadyForceNonBlocking(ctx context.Context) bool {
-	return true
+	return !mdr.IsUnavailable
+}
+
+func (mdr *MockDiskReadiness) GetFailureCode() string {
+	if mdr.IsUnavailable {
+		return constants.REMOTE_CLIENT_UNAVAILABLE
+	}
+
+	return constants.SUCCESS
 }
 
 func NewMockDisk() models.Disk {
@@ -230,6 +295,17 @@ func GetMockDisks(number int) []*MockDisk {
 	return ret
 }
 
+func GetStorageMockDisks(number int) []*MockDisk {
+	var ret []*MockDisk = GetMockDisks(number)
+
+	for _, d := range ret {
+		d.Blocks = make(map[uuid.UUID][]uint8)
+		d.DiskReadiness = new(MockDiskReadiness)
+	}
+
+	return ret
+}
+
 func GetSpecifiedDisksDBO(number int, provider int) []dbo.Disk {
 	var ret []dbo.Disk = make([]dbo.Disk, 0)
 
@@ -0,0 +1,133 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const DropboxAccessToken string = "dropbox-access-token"
+
+// MockDropboxServer - local stand-in of the Dropbox API v2 and its OAuth token endpoint
+type MockDropboxServer struct {
+	Server   *httptest.Server
+	Files    map[string][]byte
+	Sessions map[string][]byte
+
+	// Number of upload sessions finished
+	FinishedSessions int
+
+	mtx sync.Mutex
+}
+
+type mockDropboxCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    int    `json:"offset"`
+}
+
+type mockDropboxArg struct {
+	Path   string            `json:"path"`
+	Cursor mockDropboxCursor `json:"cursor"`
+	Commit struct {
+		Path string `json:"path"`
+	} `json:"commit"`
+}
+
+func (s *MockDropboxServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if r.URL.Path == "/oauth2/token" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"%s","token_type":"bearer","expires_in":14400}`, DropboxAccessToken)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+DropboxAccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"error_summary":"invalid_access_token/"}`)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	var arg mockDropboxArg
+	if header := r.Header.Get("Dropbox-API-Arg"); header != "" {
+		_ = json.Unmarshal([]byte(header), &arg)
+	} else if len(body) > 0 {
+		_ = json.Unmarshal(body, &arg)
+	}
+
+	switch r.URL.Path {
+	case "/2/files/upload":
+		s.Files[arg.Path] = body
+		_, _ = fmt.Fprintf(w, `{"path_display":"%s"}`, arg.Path)
+	case "/2/files/upload_session/start":
+		sessionID := fmt.Sprintf("session-%d", len(s.Sessions)+1)
+		s.Sessions[sessionID] = body
+		_, _ = fmt.Fprintf(w, `{"session_id":"%s"}`, sessionID)
+	case "/2/files/upload_session/append_v2", "/2/files/upload_session/finish":
+		session, ok := s.Sessions[arg.Cursor.SessionID]
+		if !ok || len(session) != arg.Cursor.Offset {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprint(w, `{"error_summary":"incorrect_offset/"}`)
+			return
+		}
+		s.Sessions[arg.Cursor.SessionID] = append(session, body...)
+
+		if r.URL.Path == "/2/files/upload_session/finish" {
+			s.Files[arg.Commit.Path] = s.Sessions[arg.Cursor.SessionID]
+			s.FinishedSessions++
+			delete(s.Sessions, arg.Cursor.SessionID)
+			_, _ = fmt.Fprintf(w, `{"path_display":"%s"}`, arg.Commit.Path)
+		} else {
+			_, _ = fmt.Fprint(w, `null`)
+		}
+	case "/2/files/download", "/2/files/delete_v2":
+		f, ok := s.Files[arg.Path]
+		if !ok {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprint(w, `{"error_summary":"path/not_found/"}`)
+			return
+		}
+
+		if r.URL.Path == "/2/files/download" {
+			_, _ = w.Write(f)
+		} else {
+			delete(s.Files, arg.Path)
+			_, _ = fmt.Fprint(w, `{}`)
+		}
+	case "/2/users/get_space_usage":
+		var used int
+		for _, f := range s.Files {
+			used += len(f)
+		}
+		_, _ = fmt.Fprintf(w, `{"used":%d,"allocation":{".tag":"individual","allocated":2147483648}}`, used)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WriteDropboxConfig - write the client secret file pointing to the mock server
+func (s *MockDropboxServer) WriteDropboxConfig(dir string) string {
+	path := filepath.Join(dir, "credentials.json")
+	config := fmt.Sprintf(`{"web":{"client_id":"dcfs","client_secret":"secret","auth_uri":"%[1]s/oauth2/authorize","token_uri":"%[1]s/oauth2/token","redirect_uris":["http://localhost"]}}`, s.Server.URL)
+	_ = os.WriteFile(path, []byte(config), 0600)
+
+	return path
+}
+
+func NewMockDropboxServer() *MockDropboxServer {
+	s := &MockDropboxServer{
+		Files:    make(map[string][]byte),
+		Sessions: make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
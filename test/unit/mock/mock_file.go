@@ -1,8 +1,11 @@
 package mock
 
 import (
+	"crypto/rand"
+	"dcfs/apicalls"
 	"dcfs/constants"
 	"dcfs/db/dbo"
+	"dcfs/util/checksum"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
@@ -51,3 +54,32 @@ func GetFileDBO(diskUUID uuid.UUID, fileType int, fileSize int) dbo.File {
 		User:       *UserDBO,
 	}
 }
+
+func GetBlockMetadata(size int) (*apicalls.BlockMetadata, []uint8) {
+	var status int
+	var contents []uint8 = make([]uint8, size)
+	_, _ = rand.Read(contents)
+
+	_contents := make([]uint8, size)
+	copy(_contents, contents)
+
+	return &apicalls.BlockMetadata{
+		UUID:             uuid.New(),
+		Size:             int64(size),
+		Status:           &status,
+		Checksum:         checksum.CalculateChecksum(contents),
+		Content:          &_contents,
+		CompleteCallback: func(uuid.UUID, *int) {},
+	}, contents
+}
+
+func CopyBlockMetadata(blockMetadata *apicalls.BlockMetadata) *apicalls.BlockMetadata {
+	var status int
+	var contents []uint8 = make([]uint8, 0)
+
+	_blockMetadata := *blockMetadata
+	_blockMetadata.Status = &status
+	_blockMetadata.Content = &contents
+
+	return &_blockMetadata
+}
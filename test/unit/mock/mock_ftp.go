@@ -0,0 +1,257 @@
+package mock
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const FTPLogin string = "dcfs"
+const FTPPassword string = "dcfs"
+
+// MockFTPServer - in-memory FTP server supporting explicit and implicit TLS
+type MockFTPServer struct {
+	Listener net.Listener
+	Files    map[string][]byte
+
+	// TLS mode of the server: "none", "explicit" or "implicit"
+	TLSMode string
+	// PEM encoded self-signed certificate of the server
+	CertificatePEM string
+	// Hex encoded SHA-256 of the server certificate
+	Fingerprint string
+
+	tlsConfig *tls.Config
+	mtx       sync.Mutex
+}
+
+func (s *MockFTPServer) serve() {
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *MockFTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if s.TLSMode == "implicit" {
+		conn = tls.Server(conn, s.tlsConfig)
+	}
+
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var dataConn chan net.Conn
+	var protected bool
+	reply("220 DCFS mock FTP server")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command, argument, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch strings.ToUpper(command) {
+		case "AUTH":
+			if s.TLSMode != "explicit" {
+				reply("502 TLS is not supported")
+				continue
+			}
+			reply("234 Proceed with negotiation")
+			conn = tls.Server(conn, s.tlsConfig)
+			reader = bufio.NewReader(conn)
+		case "USER":
+			reply("331 Password required")
+		case "PASS":
+			if argument != FTPPassword {
+				reply("530 Login incorrect")
+				continue
+			}
+			reply("230 Logged in")
+		case "FEAT":
+			reply("211-Features:\r\n UTF8\r\n EPSV\r\n211 End")
+		case "TYPE", "OPTS", "PBSZ":
+			reply("200 OK")
+		case "PROT":
+			protected = argument == "P"
+			reply("200 OK")
+		case "EPSV":
+			dataListener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 Cannot open data connection")
+				continue
+			}
+			dataConn = s.acceptData(dataListener, protected)
+			reply("229 Entering Extended Passive Mode (|||%d|)", dataListener.Addr().(*net.TCPAddr).Port)
+		case "STOR", "RETR":
+			var data net.Conn
+			if dataConn != nil {
+				data = <-dataConn
+				dataConn = nil
+			}
+			if data == nil {
+				reply("425 Cannot open data connection")
+				continue
+			}
+
+			if strings.ToUpper(command) == "STOR" {
+				reply("150 Ok to send data")
+				content, _ := io.ReadAll(data)
+				s.mtx.Lock()
+				s.Files[argument] = content
+				s.mtx.Unlock()
+			} else {
+				s.mtx.Lock()
+				content, exists := s.Files[argument]
+				s.mtx.Unlock()
+				if !exists {
+					_ = data.Close()
+					reply("550 File not found")
+					continue
+				}
+				reply("150 Opening data connection")
+				_, _ = data.Write(content)
+			}
+
+			_ = data.Close()
+			reply("226 Transfer complete")
+		case "DELE":
+			s.mtx.Lock()
+			_, exists := s.Files[argument]
+			delete(s.Files, argument)
+			s.mtx.Unlock()
+			if !exists {
+				reply("550 File not found")
+				continue
+			}
+			reply("250 Deleted")
+		case "QUIT":
+			reply("221 Goodbye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// acceptData - accept the passive data connection in the background, the TLS handshake is
+// performed right after the connection is accepted, before the transfer command is received
+func (s *MockFTPServer) acceptData(listener net.Listener, protected bool) chan net.Conn {
+	ret := make(chan net.Conn, 1)
+
+	go func() {
+		defer listener.Close()
+		_ = listener.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+
+		conn, err := listener.Accept()
+		if err != nil {
+			ret <- nil
+			return
+		}
+
+		if protected {
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				_ = conn.Close()
+				ret <- nil
+				return
+			}
+			conn = tlsConn
+		}
+
+		ret <- conn
+	}()
+
+	return ret
+}
+
+// Credentials - get FTP disk credentials of the server
+//
+// params:
+//   - tlsMode string: TLS mode used by the client
+//   - ca string: PEM encoded CA bundle trusted by the client
+//   - fingerprint string: pinned server certificate fingerprint
+//
+// return type:
+//   - string: JSON credential string
+func (s *MockFTPServer) Credentials(tlsMode string, ca string, fingerprint string) string {
+	host, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	ret, _ := json.Marshal(map[string]string{
+		"login":          FTPLogin,
+		"password":       FTPPassword,
+		"host":           host,
+		"port":           port,
+		"path":           "/",
+		"tlsMode":        tlsMode,
+		"tlsCA":          ca,
+		"tlsFingerprint": fingerprint,
+	})
+
+	return string(ret)
+}
+
+func (s *MockFTPServer) Close() {
+	_ = s.Listener.Close()
+}
+
+// NewMockFTPServer - start the FTP server with a freshly generated self-signed certificate
+//
+// params:
+//   - tlsMode string: "none", "explicit" or "implicit"
+//
+// return type:
+//   - *MockFTPServer: started server
+func NewMockFTPServer(tlsMode string) *MockFTPServer {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	fingerprint := sha256.Sum256(der)
+
+	s := &MockFTPServer{
+		Files:          make(map[string][]byte),
+		TLSMode:        tlsMode,
+		CertificatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		},
+	}
+
+	s.Listener, _ = net.Listen("tcp", "127.0.0.1:0")
+	go s.serve()
+
+	return s
+}
@@ -0,0 +1,118 @@
+package mock
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const S3Bucket string = "dcfs"
+const S3AccessKey string = "minioadmin"
+const S3SecretKey string = "minioadmin"
+
+// MockS3Server - in-memory S3 compatible server supporting the requests used by S3Disk
+type MockS3Server struct {
+	Server  *httptest.Server
+	Objects map[string][]byte
+	Uploads map[string]map[int][]byte
+
+	// Number of parts assembled by the completed multipart uploads
+	CompletedParts int
+
+	mtx sync.Mutex
+}
+
+func (s *MockS3Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+S3AccessKey+"/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != S3Bucket {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprint(w, "<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>")
+		return
+	}
+
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodHead && key == "":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(s.Uploads) + 1)
+		s.Uploads[uploadID] = make(map[int][]byte)
+		_, _ = fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		s.Uploads[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"%d\"", partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var request struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		_ = xml.Unmarshal(body, &request)
+
+		var numbers []int
+		for _, part := range request.Parts {
+			numbers = append(numbers, part.PartNumber)
+		}
+		sort.Ints(numbers)
+
+		var object []byte
+		for _, n := range numbers {
+			object = append(object, s.Uploads[query.Get("uploadId")][n]...)
+		}
+		s.Objects[key] = object
+		s.CompletedParts += len(numbers)
+		delete(s.Uploads, query.Get("uploadId"))
+		_, _ = fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.Uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		s.Objects[key] = body
+	case r.Method == http.MethodGet:
+		object, ok := s.Objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		_, _ = w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(s.Objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// S3Credentials - get credentials of the disk using the mock server
+func (s *MockS3Server) S3Credentials(prefix string) string {
+	return fmt.Sprintf("{\"endpoint\":\"%s\",\"bucket\":\"%s\",\"prefix\":\"%s\",\"accessKey\":\"%s\",\"secretKey\":\"%s\"}",
+		s.Server.URL, S3Bucket, prefix, S3AccessKey, S3SecretKey)
+}
+
+func NewMockS3Server() *MockS3Server {
+	s := &MockS3Server{
+		Objects: make(map[string][]byte),
+		Uploads: make(map[string]map[int][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
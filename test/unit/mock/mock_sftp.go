@@ -0,0 +1,155 @@
+package mock
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"sync/atomic"
+)
+
+const SFTPLogin string = "dcfs"
+const SFTPPassword string = "dcfs"
+const SFTPKeyPassphrase string = "dcfs-passphrase"
+
+// MockSFTPServer - SSH server exposing the SFTP subsystem backed by the local filesystem
+type MockSFTPServer struct {
+	Listener net.Listener
+
+	// SHA256 fingerprint of the host key
+	Fingerprint string
+	// PEM encoded private key accepted by the server
+	ClientKeyPEM string
+	// ClientKeyPEM encrypted with SFTPKeyPassphrase
+	EncryptedClientKeyPEM string
+	// Number of accepted SSH connections
+	Connections int32
+
+	config *ssh.ServerConfig
+}
+
+func (s *MockSFTPServer) serve() {
+	for {
+		conn, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		atomic.AddInt32(&s.Connections, 1)
+		go s.handle(conn)
+	}
+}
+
+func (s *MockSFTPServer) handle(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range channelRequests {
+				// The subsystem name is prefixed with its uint32 length
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(isSFTP, nil)
+				if !isSFTP {
+					continue
+				}
+
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					_ = channel.Close()
+					return
+				}
+				_ = server.Serve()
+				_ = channel.Close()
+			}
+		}()
+	}
+}
+
+// Credentials - get SFTP disk credentials of the server
+//
+// params:
+//   - directory string: remote directory of the disk
+//   - fields map[string]string: authentication fields (password, privateKey, passphrase, hostKey)
+//
+// return type:
+//   - string: JSON credential string
+func (s *MockSFTPServer) Credentials(directory string, fields map[string]string) string {
+	host, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	cred := map[string]string{
+		"login": SFTPLogin,
+		"host":  host,
+		"port":  port,
+		"path":  directory,
+	}
+	for key, value := range fields {
+		cred[key] = value
+	}
+
+	ret, _ := json.Marshal(cred)
+	return string(ret)
+}
+
+func (s *MockSFTPServer) Close() {
+	_ = s.Listener.Close()
+}
+
+// NewMockSFTPServer - start the SFTP server with freshly generated host and client keys
+//
+// return type:
+//   - *MockSFTPServer: started server
+func NewMockSFTPServer() *MockSFTPServer {
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, _ := ssh.NewSignerFromKey(hostKey)
+
+	clientKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	clientPublicKey, _ := ssh.NewPublicKey(&clientKey.PublicKey)
+	clientDER := x509.MarshalPKCS1PrivateKey(clientKey)
+	encryptedBlock, _ := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", clientDER, []byte(SFTPKeyPassphrase), x509.PEMCipherAES256)
+
+	s := &MockSFTPServer{
+		Fingerprint:           ssh.FingerprintSHA256(hostSigner.PublicKey()),
+		ClientKeyPEM:          string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: clientDER})),
+		EncryptedClientKeyPEM: string(pem.EncodeToMemory(encryptedBlock)),
+		config: &ssh.ServerConfig{
+			PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+				if conn.User() == SFTPLogin && string(password) == SFTPPassword {
+					return nil, nil
+				}
+				return nil, errors.New("invalid password")
+			},
+			PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if conn.User() == SFTPLogin && bytes.Equal(key.Marshal(), clientPublicKey.Marshal()) {
+					return nil, nil
+				}
+				return nil, errors.New("unknown public key")
+			},
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	s.Listener, _ = net.Listen("tcp", "127.0.0.1:0")
+	go s.serve()
+
+	return s
+}
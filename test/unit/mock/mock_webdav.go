@@ -0,0 +1,124 @@
+package mock
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const WebDAVLogin string = "dcfs"
+const WebDAVPassword string = "UszatekM*01"
+const WebDAVRealm string = "DCFS"
+const WebDAVNonce string = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+
+// MockWebDAVServer - in-memory WebDAV server with quota support
+type MockWebDAVServer struct {
+	Server     *httptest.Server
+	Files      map[string][]byte
+	UseDigest  bool
+	QuotaBytes int64
+
+	mtx sync.Mutex
+}
+
+func (s *MockWebDAVServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.authorized(r) {
+		if s.UseDigest {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="5ccc069c403ebaf9f0171e9517f40e41"`, WebDAVRealm, WebDAVNonce))
+		} else {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, WebDAVRealm))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	switch r.Method {
+	case "PROPFIND":
+		if r.URL.Path != "/dav/dcfs/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var used int64
+		for _, f := range s.Files {
+			used += int64(len(f))
+		}
+
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:"><d:response><d:href>/dav/dcfs/</d:href>`+
+			`<d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype><d:quota-available-bytes>%d</d:quota-available-bytes>`+
+			`<d:quota-used-bytes>%d</d:quota-used-bytes></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`,
+			s.QuotaBytes-used, used)
+	case http.MethodPut:
+		s.Files[r.URL.Path] = body
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		f, ok := s.Files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(f)
+	case http.MethodDelete:
+		delete(s.Files, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized - verify the basic or digest credentials of the request
+func (s *MockWebDAVServer) authorized(r *http.Request) bool {
+	if !s.UseDigest {
+		login, password, ok := r.BasicAuth()
+		return ok && login == WebDAVLogin && password == WebDAVPassword
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Digest ") {
+		return false
+	}
+
+	params := make(map[string]string)
+	for _, m := range regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`).FindAllStringSubmatch(header, -1) {
+		params[m[1]] = m[2] + m[3]
+	}
+
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	ha1 := h(WebDAVLogin + ":" + WebDAVRealm + ":" + WebDAVPassword)
+	ha2 := h(r.Method + ":" + params["uri"])
+	expected := h(strings.Join([]string{ha1, WebDAVNonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+
+	return params["username"] == WebDAVLogin && params["qop"] == "auth" && params["response"] == expected
+}
+
+// WebDAVCredentials - get credentials of the disk using the mock server
+func (s *MockWebDAVServer) WebDAVCredentials(password string) string {
+	return fmt.Sprintf("{\"url\":\"%s/dav\",\"login\":\"%s\",\"password\":\"%s\",\"path\":\"dcfs\"}", s.Server.URL, WebDAVLogin, password)
+}
+
+func NewMockWebDAVServer(useDigest bool, quotaBytes int64) *MockWebDAVServer {
+	s := &MockWebDAVServer{
+		Files:      make(map[string][]byte),
+		UseDigest:  useDigest,
+		QuotaBytes: quotaBytes,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"dcfs/constants"
+	"dcfs/models/disk/AzureBlobDisk"
+	"dcfs/test/unit/mock"
+	"dcfs/util/azblob"
+	_ "dcfs/util/logger"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestAzureConnectionString(t *testing.T) {
+	Convey("Development storage points to the Azurite emulator", t, func() {
+		cs, err := azblob.ParseConnectionString("UseDevelopmentStorage=true")
+		So(err, ShouldBeNil)
+		So(cs.ServiceURL, ShouldEqual, "http://127.0.0.1:10000/devstoreaccount1")
+		So(cs.AccountName, ShouldEqual, mock.AzureAccountName)
+		So(cs.AccountKey, ShouldEqual, mock.AzureAccountKey)
+	})
+
+	Convey("Blob endpoint is derived from the account name", t, func() {
+		cs, err := azblob.ParseConnectionString("DefaultEndpointsProtocol=https;AccountName=dcfs;AccountKey=a2V5;EndpointSuffix=core.windows.net")
+		So(err, ShouldBeNil)
+		So(cs.ServiceURL, ShouldEqual, "https://dcfs.blob.core.windows.net")
+	})
+
+	Convey("Connection string without credentials is rejected", t, func() {
+		_, err := azblob.ParseConnectionString("BlobEndpoint=https://dcfs.blob.core.windows.net")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestAzureBlobDisk(t *testing.T) {
+	server := mock.NewMockAzureServer()
+	defer server.Server.Close()
+
+	for _, cred := range []string{server.ConnectionStringCredentials(mock.AzureContainer), server.SASCredentials(mock.AzureContainer)} {
+		disk := AzureBlobDisk.NewAzureBlobDisk()
+		disk.SetUUID(uuid.New())
+		disk.CreateCredentials(cred)
+
+		Convey("Disk is ready when the container is accessible", t, func() {
+			So(disk.GetReadiness().IsReadyForce(context.Background()), ShouldBeTrue)
+		})
+
+		Convey("Small block is uploaded as a single block blob", t, func() {
+			blockMetadata, contents := mock.GetBlockMetadata(1024)
+			So(disk.Upload(blockMetadata), ShouldBeNil)
+			So(bytes.Equal(server.Blobs["volume/"+blockMetadata.UUID.String()], contents), ShouldBeTrue)
+
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		})
+
+		Convey("Large block is uploaded with staged blocks", t, func() {
+			committed := server.CommittedBlockLists
+			blockMetadata, contents := mock.GetBlockMetadata(constants.AZURE_SIZE_LIMIT + 1024)
+			So(disk.Upload(blockMetadata), ShouldBeNil)
+			So(server.CommittedBlockLists, ShouldEqual, committed+1)
+			So(server.Staged, ShouldBeEmpty)
+
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		})
+
+		Convey("Removed block cannot be downloaded", t, func() {
+			blockMetadata, _ := mock.GetBlockMetadata(128)
+			So(disk.Upload(blockMetadata), ShouldBeNil)
+			So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+			So(disk.Download(mock.CopyBlockMetadata(blockMetadata)).Code, ShouldEqual, constants.REMOTE_BAD_FILE)
+		})
+	}
+
+	Convey("Disk is not ready when the container does not exist", t, func() {
+		disk := AzureBlobDisk.NewAzureBlobDisk()
+		disk.SetUUID(uuid.New())
+		disk.CreateCredentials(server.ConnectionStringCredentials("missing"))
+		So(disk.GetReadiness().IsReadyForce(context.Background()), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,340 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/disk/BackupDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestBackupDisk_UploadDownload(t *testing.T) {
+	disk, disks := CreateBackupDisk(3)
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	Convey("Block is uploaded to all replicas", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		for _, d := range disks {
+			So(bytes.Equal(d.Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+		}
+	})
+
+	Convey("Block is downloaded while only one replica is available", t, func() {
+		disks[0].IsFailing = true
+		disks[1].IsFailing = true
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+	})
+
+	Convey("Download fails when no replica is available", t, func() {
+		disks[2].IsFailing = true
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldNotBeNil)
+	})
+
+	Convey("Assigning more disks than replicas is ignored", t, func() {
+		disk.AssignDisk(mock.GetStorageMockDisks(1)[0])
+		So(disk.GetReadiness().(*models.VirtualDiskReadiness).RealDiskReadinessObjects, ShouldHaveLength, 3)
+	})
+}
+
+func TestBackupDisk_MajorityVoting(t *testing.T) {
+	disk, disks := CreateBackupDisk(3)
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	Convey("Corrupted replica is repaired using the stored checksum", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		disks[0].Blocks[blockMetadata.UUID][0] ^= 0xFF
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		So(bytes.Equal(disks[0].Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+	})
+
+	Convey("Majority of replicas decides without the stored checksum", t, func() {
+		disks[1].Blocks[blockMetadata.UUID][0] ^= 0xFF
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		_blockMetadata.Checksum = ""
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		So(bytes.Equal(disks[1].Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+	})
+}
+
+func TestBackupDisk_ReplaceDisk(t *testing.T) {
+	disk, disks := CreateBackupDisk(3)
+	newDisk := mock.GetStorageMockDisks(1)[0]
+	blockMetadata, contents := mock.GetBlockMetadata(2048)
+
+	Convey("Blocks are copied to the new replica", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		disks[1].IsFailing = true
+
+		blocks := []dbo.Block{{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}}
+
+		So(disk.ReplaceDisk(disks[1], newDisk, blocks), ShouldEqual, constants.SUCCESS)
+		So(bytes.Equal(newDisk.Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+	})
+
+	Convey("Blocks copied before a failed replacement are removed from the new replica", t, func() {
+		disk, disks := CreateBackupDisk(3)
+		newDisk := mock.GetStorageMockDisks(1)[0]
+		missingMetadata, _ := mock.GetBlockMetadata(2048)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		disks[1].IsFailing = true
+
+		blocks := []dbo.Block{{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}, {
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: missingMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               missingMetadata.Checksum,
+		}}
+
+		So(disk.ReplaceDisk(disks[1], newDisk, blocks), ShouldEqual, constants.OPERATION_FAILED)
+		So(len(newDisk.Blocks), ShouldEqual, 0)
+		So(disk.ReplaceDisk(disks[1], newDisk, []dbo.Block{}), ShouldEqual, constants.SUCCESS)
+	})
+
+	Convey("Replacing disk which is not assigned fails", t, func() {
+		So(disk.ReplaceDisk(disks[1], newDisk, []dbo.Block{}), ShouldEqual, constants.FS_DISK_MISMATCH)
+	})
+}
+
+func TestBackupDisk_DegradedUpload(t *testing.T) {
+	disk, disks := CreateBackupDisk(3)
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	Convey("Block is uploaded when one of the replicas is not available", t, func() {
+		disks[1].IsFailing = true
+
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `degraded_blocks`")).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.DBMock.ExpectCommit()
+
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		So(disks[1].Blocks, ShouldNotContainKey, blockMetadata.UUID)
+	})
+
+	Convey("Missing replica is resynchronized once the disk is available", t, func() {
+		disks[1].IsFailing = false
+
+		block := dbo.Block{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}
+
+		So(disk.ResyncBlock(block, disks[1]), ShouldEqual, constants.SUCCESS)
+		So(bytes.Equal(disks[1].Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+	})
+
+	Convey("Upload fails if the missing replicas cannot be recorded", t, func() {
+		disks[2].IsFailing = true
+		defer func() { disks[2].IsFailing = false }()
+
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `degraded_blocks`")).WillReturnError(errors.New("connection lost"))
+		mock.DBMock.ExpectRollback()
+
+		_blockMetadata, _ := mock.GetBlockMetadata(1024)
+		errWrapper := disk.Upload(_blockMetadata)
+		So(errWrapper, ShouldNotBeNil)
+		So(errWrapper.Code, ShouldEqual, constants.DATABASE_ERROR)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		So(disks[0].Blocks, ShouldNotContainKey, _blockMetadata.UUID)
+		So(disks[1].Blocks, ShouldNotContainKey, _blockMetadata.UUID)
+	})
+
+	Convey("Resync to disk which is not assigned fails", t, func() {
+		So(disk.ResyncBlock(dbo.Block{}, mock.GetStorageMockDisks(1)[0]), ShouldEqual, constants.FS_DISK_MISMATCH)
+	})
+
+	Convey("Upload fails when no replica is available", t, func() {
+		for _, d := range disks {
+			d.IsFailing = true
+		}
+
+		_blockMetadata, _ := mock.GetBlockMetadata(1024)
+		So(disk.Upload(_blockMetadata), ShouldNotBeNil)
+	})
+}
+
+func TestBackupDisk_ScrubBlock(t *testing.T) {
+	disk, disks := CreateBackupDisk(3)
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	block := dbo.Block{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+		Size:                   len(contents),
+		Checksum:               blockMetadata.Checksum,
+	}
+
+	Convey("Healthy block is verified", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		result := disk.ScrubBlock(block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.FaultyDisks, ShouldBeEmpty)
+		So(result.Repaired, ShouldBeFalse)
+	})
+
+	Convey("Corrupted and missing replicas are repaired", t, func() {
+		disks[0].Blocks[blockMetadata.UUID][0] ^= 0xFF
+		delete(disks[2].Blocks, blockMetadata.UUID)
+
+		result := disk.ScrubBlock(block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.FaultyDisks, ShouldResemble, []uuid.UUID{disks[0].GetUUID(), disks[2].GetUUID()})
+		So(result.Repaired, ShouldBeTrue)
+
+		for _, d := range disks {
+			So(bytes.Equal(d.Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+		}
+	})
+
+	Convey("Block is reported when all replicas are corrupted", t, func() {
+		for _, d := range disks {
+			d.Blocks[blockMetadata.UUID][0] ^= 0xFF
+		}
+
+		result := disk.ScrubBlock(block)
+		So(result.Code, ShouldEqual, constants.REMOTE_CORRUPTED_BLOCKS)
+		So(result.FaultyDisks, ShouldHaveLength, 3)
+	})
+}
+
+func TestResyncDegradedBlocks(t *testing.T) {
+	volume, disks, _ := CreateVolumeWithSpare()
+	virtualDisk := volume.GetDisk(disks[0].GetVirtualDiskUUID())
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	models.Transport.ActiveVolumes.EnqueueInstance(volume.UUID, volume)
+	defer models.Transport.ActiveVolumes.RemoveEnqueuedInstance(volume.UUID)
+
+	block := dbo.Block{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+		VolumeUUID:             volume.UUID,
+		DiskUUID:               virtualDisk.GetUUID(),
+		Size:                   len(contents),
+		Checksum:               blockMetadata.Checksum,
+	}
+	degradedBlock := dbo.NewDegradedBlock()
+	degradedBlock.BlockUUID = blockMetadata.UUID
+	degradedBlock.FileUUID = uuid.New()
+	degradedBlock.VolumeUUID = volume.UUID
+	degradedBlock.VirtualDiskUUID = virtualDisk.GetUUID()
+	degradedBlock.DiskUUID = disks[1].GetUUID()
+
+	expectResync := func() {
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `degraded_blocks`")).WillReturnRows(mock.DegradedBlockRow(degradedBlock))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `upload_sessions`")).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `blocks` WHERE uuid = ?")).WillReturnRows(mock.BlockRow(&block))
+	}
+
+	Convey("Block whose replicas are all corrupted is postponed", t, func() {
+		disks[1].IsFailing = true
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `degraded_blocks`")).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.DBMock.ExpectCommit()
+		So(virtualDisk.Upload(blockMetadata), ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+		disks[1].IsFailing = false
+		disks[0].Blocks[blockMetadata.UUID][0] ^= 0xFF
+
+		expectResync()
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `degraded_blocks` SET `attempts`=?,`retry_at`=?")).WithArgs(1, sqlmock.AnyArg(), degradedBlock.UUID).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.DBMock.ExpectCommit()
+
+		models.ResyncDegradedBlocks()
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		So(disks[1].Blocks, ShouldNotContainKey, blockMetadata.UUID)
+	})
+
+	Convey("Postponed block is skipped until its retry time", t, func() {
+		degradedBlock.Attempts = 1
+		degradedBlock.RetryAt = time.Now().Add(time.Hour)
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `degraded_blocks`")).WillReturnRows(mock.DegradedBlockRow(degradedBlock))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `upload_sessions`")).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		models.ResyncDegradedBlocks()
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Block of the file which is still being streamed is kept", t, func() {
+		_degradedBlock := *degradedBlock
+		_degradedBlock.RetryAt = time.Time{}
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `degraded_blocks`")).WillReturnRows(mock.DegradedBlockRow(&_degradedBlock))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `upload_sessions` WHERE uuid = ?")).WithArgs(degradedBlock.FileUUID).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		models.ResyncDegradedBlocks()
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Block is given up and reported once the retry limit is reached", t, func() {
+		degradedBlock.Attempts = constants.RESYNC_MAX_ATTEMPTS - 1
+		degradedBlock.RetryAt = time.Now().Add(-time.Minute)
+
+		expectResync()
+		for _, query := range []string{"INSERT INTO `events`", "DELETE FROM `degraded_blocks`"} {
+			mock.DBMock.ExpectBegin()
+			mock.DBMock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.DBMock.ExpectCommit()
+		}
+
+		models.ResyncDegradedBlocks()
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+}
+
+func CreateBackupDisk(replicas int) (*BackupDisk.BackupDisk, []*mock.MockDisk) {
+	volume := &models.Volume{
+		UUID:      uuid.New(),
+		BlockSize: constants.DEFAULT_VOLUME_BLOCK_SIZE,
+		Name:      "Mock Backup Volume",
+		UserUUID:  mock.UserUUID,
+		VolumeSettings: dbo.VolumeSettings{
+			Backup:        constants.BACKUP_TYPE_RAID_1,
+			Encryption:    constants.ENCRYPTION_TYPE_NO_ENCRYPTION,
+			FilePartition: constants.PARTITION_TYPE_BALANCED,
+			Replicas:      replicas,
+		},
+	}
+
+	disk := BackupDisk.NewBackupDisk()
+	disk.SetVolume(volume)
+
+	disks := mock.GetStorageMockDisks(replicas)
+	for _, d := range disks {
+		d.SetVolume(volume)
+		disk.AssignDisk(d)
+	}
+
+	return disk, disks
+}
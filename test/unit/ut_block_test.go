@@ -0,0 +1,41 @@
+package unit
+
+import (
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"regexp"
+	"testing"
+)
+
+func TestBlockRemoteID(t *testing.T) {
+	block := dbo.Block{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: uuid.New()},
+		DiskUUID:               uuid.New(),
+		RemoteID:               "cached-id",
+	}
+
+	Convey("Cached remote ID is passed to the disk", t, func() {
+		So(models.NewBlockFromDBO(&block).RemoteID, ShouldEqual, "cached-id")
+		So(models.NewBlockMetadataFromDBO(block).RemoteID, ShouldEqual, "cached-id")
+	})
+
+	Convey("Remote ID found by the disk is saved for the block stored on it", t, func() {
+		blockMetadata := models.NewBlockMetadataFromDBO(block)
+
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `blocks` SET `remote_id`=? WHERE uuid = ? AND disk_uuid = ?")).
+			WithArgs("new-id", block.UUID, block.DiskUUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+
+		models.UpdateBlockRemoteID(blockMetadata, block.DiskUUID, "new-id")
+
+		So(blockMetadata.RemoteID, ShouldEqual, "new-id")
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+}
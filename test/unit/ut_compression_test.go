@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVolumeCompression(t *testing.T) {
+	volume, disks := CreateVolumeWithDisks(2)
+	volume.VolumeSettings.Compression = constants.COMPRESSION_TYPE_GZIP
+
+	writer := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(writer)
+
+	Convey("Compressed block is restored by decompression", t, func() {
+		contents := bytes.Repeat([]uint8("compressible "), 100)
+		block := append([]uint8(nil), contents...)
+
+		compression, err := volume.Compress(&block)
+		So(err, ShouldBeNil)
+		So(compression, ShouldEqual, constants.COMPRESSION_TYPE_GZIP)
+		So(len(block), ShouldBeLessThan, len(contents))
+
+		So(volume.Decompress(&block, compression), ShouldBeNil)
+		So(block, ShouldResemble, contents)
+	})
+
+	Convey("Blocks are not compressed if the volume disables compression", t, func() {
+		volume.VolumeSettings.Compression = constants.COMPRESSION_TYPE_NO_COMPRESSION
+		defer func() { volume.VolumeSettings.Compression = constants.COMPRESSION_TYPE_GZIP }()
+
+		contents := bytes.Repeat([]uint8("compressible "), 100)
+		block := append([]uint8(nil), contents...)
+
+		compression, err := volume.Compress(&block)
+		So(err, ShouldBeNil)
+		So(compression, ShouldEqual, constants.COMPRESSION_TYPE_NO_COMPRESSION)
+		So(block, ShouldResemble, contents)
+	})
+
+	Convey("Compressible file is stored compressed and downloaded intact", t, func() {
+		usedSpace := disks[0].GetUsedSpace() + disks[1].GetUsedSpace()
+		contents := bytes.Repeat([]uint8("compressible "), 3*volume.BlockSize/13)
+
+		ExpectStreamedUpload(volume, len(contents))
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(contents), "compressible", mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+
+		var storedSize int = 0
+		for _, block := range file.Blocks {
+			So(block.Compression, ShouldEqual, constants.COMPRESSION_TYPE_GZIP)
+			So(block.CompressedSize, ShouldBeLessThan, block.Size)
+			So(block.Disk.(*mock.MockDisk).Blocks[block.UUID], ShouldHaveLength, block.CompressedSize)
+			storedSize += block.CompressedSize
+		}
+		So(disks[0].GetUsedSpace()+disks[1].GetUsedSpace()-usedSpace, ShouldEqual, uint64(storedSize))
+
+		var buffer bytes.Buffer
+		brokenBlocks, err := models.WriteFileRange(&buffer, file, 0, int64(len(contents)-1), &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.GetUUID()})
+		So(err, ShouldBeNil)
+		So(brokenBlocks, ShouldBeEmpty)
+		So(buffer.Bytes(), ShouldResemble, contents)
+	})
+
+	Convey("Incompressible file is stored raw", t, func() {
+		contents := make([]uint8, volume.BlockSize+volume.BlockSize/2)
+		rand.Read(contents)
+
+		ExpectStreamedUpload(volume, len(contents))
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(contents), "incompressible", mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+
+		for _, block := range file.Blocks {
+			start := block.Order * volume.BlockSize
+			So(block.Compression, ShouldEqual, constants.COMPRESSION_TYPE_NO_COMPRESSION)
+			So(block.GetStoredSize(), ShouldEqual, block.Size)
+			So(block.Disk.(*mock.MockDisk).Blocks[block.UUID], ShouldResemble, contents[start:start+block.Size])
+		}
+
+		var buffer bytes.Buffer
+		_, err := models.WriteFileRange(&buffer, file, 0, int64(len(contents)-1), &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.GetUUID()})
+		So(err, ShouldBeNil)
+		So(buffer.Bytes(), ShouldResemble, contents)
+	})
+
+	Convey("Blocks stay readable after the compression of the volume is changed", t, func() {
+		download := func(file *models.RegularFile, size int) []uint8 {
+			var buffer bytes.Buffer
+			_, err := models.WriteFileRange(&buffer, file, 0, int64(size-1), &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.GetUUID()})
+			So(err, ShouldBeNil)
+			return buffer.Bytes()
+		}
+
+		compressedContents := bytes.Repeat([]uint8("compressible "), 2*volume.BlockSize/13+1)[:2*volume.BlockSize]
+		ExpectStreamedUpload(volume, len(compressedContents))
+		compressed, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(compressedContents), "compressed", mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+		for _, block := range compressed.Blocks {
+			So(block.Compression, ShouldEqual, constants.COMPRESSION_TYPE_GZIP)
+		}
+
+		volume.VolumeSettings.Compression = constants.COMPRESSION_TYPE_NO_COMPRESSION
+		defer func() { volume.VolumeSettings.Compression = constants.COMPRESSION_TYPE_GZIP }()
+
+		rawContents := bytes.Repeat([]uint8("compressible "), volume.BlockSize/13)
+		ExpectStreamedUpload(volume, len(rawContents))
+		raw, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(rawContents), "raw", mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+		for _, block := range raw.Blocks {
+			So(block.Compression, ShouldEqual, constants.COMPRESSION_TYPE_NO_COMPRESSION)
+		}
+
+		So(download(compressed, len(compressedContents)), ShouldResemble, compressedContents)
+		So(download(raw, len(rawContents)), ShouldResemble, rawContents)
+
+		// File whose blocks were uploaded before and after the change
+		mixed := &models.RegularFile{AbstractFile: models.AbstractFile{UUID: uuid.New(), Volume: volume, Size: len(compressedContents) + len(rawContents)}, Blocks: make(map[uuid.UUID]*models.Block)}
+		for _, block := range compressed.Blocks {
+			mixed.Blocks[block.UUID] = block
+		}
+		for _, block := range raw.Blocks {
+			_block := *block
+			_block.Order += len(compressed.Blocks)
+			mixed.Blocks[block.UUID] = &_block
+		}
+
+		contents := append(append([]uint8(nil), compressedContents...), rawContents...)
+		So(download(mixed, len(contents)), ShouldResemble, contents)
+
+		volume.VolumeSettings.Compression = constants.COMPRESSION_TYPE_GZIP
+		So(download(mixed, len(contents)), ShouldResemble, contents)
+	})
+}
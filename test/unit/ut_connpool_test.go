@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"context"
+	"dcfs/constants"
+	"dcfs/util/connpool"
+	. "github.com/smartystreets/goconvey/convey"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type MockConnection struct {
+	IsBroken atomic.Bool
+	IsClosed atomic.Bool
+}
+
+func (c *MockConnection) IsAlive() bool {
+	return !c.IsBroken.Load()
+}
+
+func (c *MockConnection) Close() error {
+	c.IsClosed.Store(true)
+	return nil
+}
+
+func TestConnectionPool(t *testing.T) {
+	connpool.MaxConnections = 2
+	defer func() { connpool.MaxConnections = constants.CONNECTION_POOL_MAX_CONNECTIONS }()
+
+	var opened []*MockConnection
+	var errCode string = constants.SUCCESS
+	pool := connpool.NewPool(func(ctx context.Context) (connpool.Connection, string) {
+		if errCode != constants.SUCCESS {
+			return nil, errCode
+		}
+
+		connection := &MockConnection{}
+		opened = append(opened, connection)
+		return connection, constants.SUCCESS
+	})
+
+	Convey("Returned connection is reused", t, func() {
+		connection, code := pool.Get(context.Background())
+		So(code, ShouldEqual, constants.SUCCESS)
+		pool.Put(connection)
+		So(pool.GetIdleCount(), ShouldEqual, 1)
+
+		_connection, _ := pool.Get(context.Background())
+		So(_connection, ShouldEqual, connection)
+		So(opened, ShouldHaveLength, 1)
+		pool.Put(_connection)
+	})
+
+	Convey("Broken idle connection is replaced", t, func() {
+		opened[0].IsBroken.Store(true)
+
+		connection, code := pool.Get(context.Background())
+		So(code, ShouldEqual, constants.SUCCESS)
+		So(connection, ShouldNotEqual, opened[0])
+		So(opened[0].IsClosed.Load(), ShouldBeTrue)
+		pool.Put(connection)
+	})
+
+	Convey("Pool waits for a connection when the limit is reached", t, func() {
+		first, _ := pool.Get(context.Background())
+		second, _ := pool.Get(context.Background())
+		So(pool.GetActiveCount(), ShouldEqual, 2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		connection, code := pool.Get(ctx)
+		So(connection, ShouldBeNil)
+		So(code, ShouldEqual, constants.REMOTE_CLIENT_UNAVAILABLE)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			pool.Put(first)
+		}()
+		connection, code = pool.Get(context.Background())
+		So(code, ShouldEqual, constants.SUCCESS)
+		So(connection, ShouldEqual, first)
+
+		pool.Put(connection)
+		pool.Discard(second)
+		So(second.(*MockConnection).IsClosed.Load(), ShouldBeTrue)
+		So(pool.GetActiveCount(), ShouldEqual, 0)
+	})
+
+	Convey("Failed connection releases its slot", t, func() {
+		pool.Close()
+		pool = connpool.NewPool(func(ctx context.Context) (connpool.Connection, string) { return nil, errCode })
+		errCode = constants.REMOTE_HOST_KEY_MISMATCH
+
+		for i := 0; i < 3; i++ {
+			connection, code := pool.Get(context.Background())
+			So(connection, ShouldBeNil)
+			So(code, ShouldEqual, constants.REMOTE_HOST_KEY_MISMATCH)
+		}
+		So(pool.GetActiveCount(), ShouldEqual, 0)
+	})
+}
+
+func TestConnectionPool_IdleTimeout(t *testing.T) {
+	connpool.IdleTimeout = 50 * time.Millisecond
+	defer func() { connpool.IdleTimeout = constants.CONNECTION_IDLE_TIMEOUT }()
+
+	pool := connpool.NewPool(func(ctx context.Context) (connpool.Connection, string) {
+		return &MockConnection{}, constants.SUCCESS
+	})
+
+	Convey("Idle connection is closed after the timeout", t, func() {
+		connection, _ := pool.Get(context.Background())
+		pool.Put(connection)
+		So(pool.GetIdleCount(), ShouldEqual, 1)
+
+		time.Sleep(150 * time.Millisecond)
+		So(pool.GetIdleCount(), ShouldEqual, 0)
+		So(connection.(*MockConnection).IsClosed.Load(), ShouldBeTrue)
+	})
+
+	Convey("Idle connections are closed with the pool", t, func() {
+		connection, _ := pool.Get(context.Background())
+		pool.Put(connection)
+		pool.Close()
+
+		So(pool.GetIdleCount(), ShouldEqual, 0)
+		So(connection.(*MockConnection).IsClosed.Load(), ShouldBeTrue)
+	})
+}
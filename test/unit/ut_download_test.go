@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"math/rand"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFileWrapperDownload(t *testing.T) {
+	volume, _ := CreateVolumeWithDisks(2)
+	volume.BlockSize = 1024
+
+	writer := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(writer)
+
+	uploadFile := func(name string, size int) (*models.RegularFile, []uint8) {
+		contents := make([]uint8, size)
+		rand.Read(contents)
+
+		ExpectStreamedUpload(volume, size)
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(contents), name, mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+		return file, contents
+	}
+
+	download := func(files ...models.File) *httptest.ResponseRecorder {
+		writer := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(writer)
+
+		wrapper := models.NewFileWrapper(constants.FILE_TYPE_WRAPPER, files)
+		errorWrapper := wrapper.Download(&apicalls.BlockMetadata{Ctx: ctx, FileUUID: wrapper.GetUUID(), UUID: wrapper.GetUUID()})
+		So(errorWrapper, ShouldBeNil)
+		return writer
+	}
+
+	readArchive := func(writer *httptest.ResponseRecorder) map[string][]uint8 {
+		body := writer.Body.Bytes()
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		So(err, ShouldBeNil)
+
+		entries := make(map[string][]uint8)
+		for _, entry := range archive.File {
+			reader, err := entry.Open()
+			So(err, ShouldBeNil)
+			contents, err := io.ReadAll(reader)
+			So(err, ShouldBeNil)
+			entries[entry.Name] = contents
+		}
+
+		return entries
+	}
+
+	Convey("Single file is streamed as is", t, func() {
+		file, contents := uploadFile("single", 3*volume.BlockSize+10)
+
+		writer := download(file)
+		So(writer.Header().Get("Content-Type"), ShouldEqual, "application/octet-stream")
+		So(writer.Header().Get("Content-Disposition"), ShouldContainSubstring, "single")
+		So(writer.Body.Bytes(), ShouldResemble, contents)
+		So(writer.Header().Get("File-Completeness"), ShouldEqual, "complete")
+	})
+
+	Convey("Directory tree is streamed as a ZIP archive", t, func() {
+		first, firstContents := uploadFile("first", 2*volume.BlockSize)
+		second, secondContents := uploadFile("second", volume.BlockSize/2)
+		duplicate, duplicateContents := uploadFile("first", 10)
+
+		subdirectory := &models.Directory{
+			AbstractFile: models.AbstractFile{UUID: uuid.New(), Name: "nested", Type: constants.FILE_TYPE_DIRECTORY, Volume: volume},
+			Files:        []models.File{second},
+		}
+		directory := &models.Directory{
+			AbstractFile: models.AbstractFile{UUID: uuid.New(), Name: "root", Type: constants.FILE_TYPE_DIRECTORY, Volume: volume},
+			Files:        []models.File{first, subdirectory},
+		}
+
+		writer := download(directory, duplicate)
+		So(writer.Header().Get("Content-Type"), ShouldEqual, "application/zip")
+		So(writer.Header().Get("File-Completeness"), ShouldEqual, "complete")
+
+		entries := readArchive(writer)
+		So(entries, ShouldContainKey, "root/")
+		So(entries, ShouldContainKey, "root/nested/")
+		So(entries["root/first"], ShouldResemble, firstContents)
+		So(entries["root/nested/second"], ShouldResemble, secondContents)
+		So(entries["first"], ShouldResemble, duplicateContents)
+		So(entries, ShouldNotContainKey, constants.DOWNLOAD_INTEGRITY_REPORT_NAME)
+	})
+
+	Convey("Blocks which fail verification are reported per entry", t, func() {
+		intact, intactContents := uploadFile("intact", volume.BlockSize)
+		corrupted, _ := uploadFile("corrupted", 2*volume.BlockSize)
+
+		var corruptedBlock *models.Block
+		for _, block := range corrupted.Blocks {
+			if block.Order == 1 {
+				corruptedBlock = block
+			}
+		}
+		corruptedBlock.Disk.(*mock.MockDisk).Blocks[corruptedBlock.UUID][0] ^= 0xFF
+
+		writer := download(intact, corrupted)
+		So(writer.Header().Get("File-Completeness"), ShouldEqual, "not complete")
+
+		entries := readArchive(writer)
+		So(entries["intact"], ShouldResemble, intactContents)
+		So(entries["corrupted"], ShouldHaveLength, 2*volume.BlockSize)
+		So(entries, ShouldContainKey, constants.DOWNLOAD_INTEGRITY_REPORT_NAME)
+
+		var report []models.DownloadIntegrityEntry
+		So(json.Unmarshal(entries[constants.DOWNLOAD_INTEGRITY_REPORT_NAME], &report), ShouldBeNil)
+		So(report, ShouldHaveLength, 1)
+		So(report[0].Path, ShouldEqual, "corrupted")
+		So(report[0].FileUUID, ShouldEqual, corrupted.GetUUID())
+		So(report[0].BrokenBlocks, ShouldResemble, []uuid.UUID{corruptedBlock.UUID})
+	})
+
+	Convey("Only the blocks covering the byte range are downloaded", t, func() {
+		file, contents := uploadFile("ranged", 4*volume.BlockSize+100)
+
+		// Blocks outside of the range cannot be downloaded
+		var skippedBlocks []*models.Block
+		for _, block := range file.Blocks {
+			if block.Order == 0 || block.Order == 4 {
+				skippedBlocks = append(skippedBlocks, block)
+				delete(block.Disk.(*mock.MockDisk).Blocks, block.UUID)
+			}
+		}
+		So(skippedBlocks, ShouldHaveLength, 2)
+
+		start, end := int64(volume.BlockSize+10), int64(3*volume.BlockSize+20)
+		var buffer bytes.Buffer
+		brokenBlocks, err := models.WriteFileRange(&buffer, file, start, end, &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.GetUUID()})
+		So(err, ShouldBeNil)
+		So(brokenBlocks, ShouldBeEmpty)
+		So(buffer.Bytes(), ShouldResemble, contents[start:end+1])
+
+		buffer.Reset()
+		brokenBlocks, err = models.WriteFileRange(&buffer, file, 0, 5, &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.GetUUID()})
+		So(err, ShouldBeNil)
+		So(brokenBlocks, ShouldHaveLength, 1)
+		So(buffer.Bytes(), ShouldResemble, make([]uint8, 6))
+	})
+}
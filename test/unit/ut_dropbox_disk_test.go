@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/models/disk/DropboxDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/oauth2"
+	"testing"
+)
+
+func TestDropboxDisk(t *testing.T) {
+	server := mock.NewMockDropboxServer()
+	defer server.Server.Close()
+
+	DropboxDisk.CredentialsPath = server.WriteDropboxConfig(t.TempDir())
+	DropboxDisk.DropboxAPIURL = server.Server.URL + "/2"
+	DropboxDisk.DropboxContentURL = server.Server.URL + "/2"
+
+	disk := DropboxDisk.NewDropboxDisk()
+	disk.SetUUID(uuid.New())
+	disk.SetVolume(&models.Volume{UUID: uuid.New()})
+	disk.CreateCredentials(`{"accessToken":"expired","refreshToken":"refresh"}`)
+
+	Convey("Authorization URL requests the offline access", t, func() {
+		So(disk.GetConfig().AuthCodeURL("state-token", oauth2.AccessTypeOffline), ShouldContainSubstring, "token_access_type=offline")
+	})
+
+	Convey("Disk is ready after the token is refreshed", t, func() {
+		So(disk.GetReadiness().IsReadyForce(context.Background()), ShouldBeTrue)
+	})
+
+	Convey("Small block is uploaded in a single request", t, func() {
+		blockMetadata, contents := mock.GetBlockMetadata(1024)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		So(bytes.Equal(server.Files["/"+disk.GetVolume().UUID.String()+"/"+blockMetadata.UUID.String()], contents), ShouldBeTrue)
+		So(server.FinishedSessions, ShouldEqual, 0)
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+	})
+
+	Convey("Large block is uploaded using the upload session", t, func() {
+		blockMetadata, contents := mock.GetBlockMetadata(2*constants.DROPBOX_UPLOAD_LIMIT + 1024)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		So(server.FinishedSessions, ShouldEqual, 1)
+		So(server.Sessions, ShouldBeEmpty)
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+	})
+
+	Convey("Provider space is reported by the space usage endpoint", t, func() {
+		usedSpace, totalSpace, errCode := disk.GetProviderSpace()
+		So(errCode, ShouldEqual, constants.SUCCESS)
+		So(usedSpace, ShouldEqual, 2*constants.DROPBOX_UPLOAD_LIMIT+2048)
+		So(totalSpace, ShouldEqual, 2147483648)
+	})
+
+	Convey("Removed block cannot be downloaded", t, func() {
+		blockMetadata, _ := mock.GetBlockMetadata(128)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+		So(disk.Download(mock.CopyBlockMetadata(blockMetadata)).Code, ShouldEqual, constants.REMOTE_BAD_FILE)
+	})
+
+	Convey("Block uploaded outside of the volume folder can still be accessed", t, func() {
+		blockMetadata, contents := mock.GetBlockMetadata(128)
+		server.Files["/"+blockMetadata.UUID.String()] = contents
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+
+		So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+		So(server.Files, ShouldNotContainKey, "/"+blockMetadata.UUID.String())
+	})
+}
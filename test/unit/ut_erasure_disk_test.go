@@ -0,0 +1,258 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/disk/ErasureDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestErasureDisk_UploadDownload(t *testing.T) {
+	disk, disks := CreateErasureDisk(2, 1)
+
+	Convey("Block is split into shards stored on all disks", t, func() {
+		blockMetadata, contents := mock.GetBlockMetadata(1001)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		for _, d := range disks {
+			So(len(d.Blocks[blockMetadata.UUID]), ShouldEqual, constants.ERASURE_SHARD_HEADER_SIZE+501)
+		}
+
+		Convey("Block is reassembled from the shards", func() {
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		})
+	})
+
+	Convey("Empty block is stored correctly", t, func() {
+		blockMetadata, _ := mock.GetBlockMetadata(0)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(len(*_blockMetadata.Content), ShouldEqual, 0)
+	})
+
+	Convey("Upload fails if one of the disks is not available", t, func() {
+		disks[0].IsFailing = true
+		blockMetadata, _ := mock.GetBlockMetadata(128)
+		So(disk.Upload(blockMetadata), ShouldNotBeNil)
+		disks[0].IsFailing = false
+	})
+}
+
+func TestErasureDisk_DegradedDownload(t *testing.T) {
+	disk, disks := CreateErasureDisk(3, 2)
+	blockMetadata, contents := mock.GetBlockMetadata(4096)
+
+	Convey("Block is uploaded", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+	})
+
+	Convey("Block is reconstructed when parity count of disks are unavailable", t, func() {
+		disks[0].IsFailing = true
+		disks[3].IsFailing = true
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+	})
+
+	Convey("Download fails when more disks are unavailable", t, func() {
+		disks[1].IsFailing = true
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldNotBeNil)
+	})
+}
+
+func TestErasureDisk_CorruptedShard(t *testing.T) {
+	disk, disks := CreateErasureDisk(2, 2)
+	blockMetadata, contents := mock.GetBlockMetadata(2048)
+
+	Convey("Corrupted shard is detected and repaired", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		validShard := make([]uint8, len(disks[1].Blocks[blockMetadata.UUID]))
+		copy(validShard, disks[1].Blocks[blockMetadata.UUID])
+		disks[1].Blocks[blockMetadata.UUID][constants.ERASURE_SHARD_HEADER_SIZE+10] ^= 0xFF
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		So(bytes.Equal(disks[1].Blocks[blockMetadata.UUID], validShard), ShouldBeTrue)
+	})
+
+	Convey("Block which cannot be recovered is reported as corrupted", t, func() {
+		disks[0].Blocks[blockMetadata.UUID][constants.ERASURE_SHARD_HEADER_SIZE] ^= 0xFF
+		disks[1].Blocks[blockMetadata.UUID][constants.ERASURE_SHARD_HEADER_SIZE] ^= 0xFF
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		errWrapper := disk.Download(_blockMetadata)
+		So(errWrapper, ShouldNotBeNil)
+		So(errWrapper.Code, ShouldEqual, constants.REMOTE_CORRUPTED_BLOCKS)
+	})
+}
+
+func TestErasureDisk_ReplaceDisk(t *testing.T) {
+	disk, disks := CreateErasureDisk(2, 1)
+	newDisk := mock.GetStorageMockDisks(1)[0]
+	blockMetadata, contents := mock.GetBlockMetadata(3000)
+
+	Convey("Missing shards are rebuilt on the new disk", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		lostShard := disks[2].Blocks[blockMetadata.UUID]
+		disks[2].IsFailing = true
+
+		blocks := []dbo.Block{{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}}
+
+		So(disk.ReplaceDisk(disks[2], newDisk, blocks), ShouldEqual, constants.SUCCESS)
+		So(bytes.Equal(newDisk.Blocks[blockMetadata.UUID], lostShard), ShouldBeTrue)
+
+		Convey("Block is available after another disk fails", func() {
+			disks[0].IsFailing = true
+
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		})
+	})
+
+	Convey("Shard of the replaced disk is rebuilt even if other shards are missing", t, func() {
+		disk, disks := CreateErasureDisk(2, 2)
+		newDisk := mock.GetStorageMockDisks(1)[0]
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		lostShard := disks[2].Blocks[blockMetadata.UUID]
+		delete(disks[0].Blocks, blockMetadata.UUID)
+		disks[2].IsFailing = true
+
+		blocks := []dbo.Block{{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}}
+
+		So(disk.ReplaceDisk(disks[2], newDisk, blocks), ShouldEqual, constants.SUCCESS)
+		So(bytes.Equal(newDisk.Blocks[blockMetadata.UUID], lostShard), ShouldBeTrue)
+	})
+
+	Convey("Shards rebuilt before a failed replacement are removed from the new disk", t, func() {
+		disk, disks := CreateErasureDisk(2, 1)
+		newDisk := mock.GetStorageMockDisks(1)[0]
+		corruptedMetadata, _ := mock.GetBlockMetadata(3000)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		So(disk.Upload(corruptedMetadata), ShouldBeNil)
+
+		disks[2].IsFailing = true
+		disks[0].Blocks[corruptedMetadata.UUID][constants.ERASURE_SHARD_HEADER_SIZE] ^= 0xFF
+
+		blocks := []dbo.Block{{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}, {
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: corruptedMetadata.UUID},
+			Size:                   len(contents),
+			Checksum:               corruptedMetadata.Checksum,
+		}}
+
+		So(disk.ReplaceDisk(disks[2], newDisk, blocks), ShouldEqual, constants.OPERATION_FAILED)
+		So(len(newDisk.Blocks), ShouldEqual, 0)
+		So(disks[2].Blocks, ShouldContainKey, blockMetadata.UUID)
+		So(disk.ReplaceDisk(disks[2], newDisk, []dbo.Block{}), ShouldEqual, constants.SUCCESS)
+	})
+
+	Convey("Replacing disk which is not assigned fails", t, func() {
+		So(disk.ReplaceDisk(disks[2], newDisk, []dbo.Block{}), ShouldEqual, constants.FS_DISK_MISMATCH)
+	})
+}
+
+func TestErasureDisk_ScrubBlock(t *testing.T) {
+	disk, disks := CreateErasureDisk(2, 2)
+	blockMetadata, contents := mock.GetBlockMetadata(2048)
+
+	block := dbo.Block{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+		Size:                   len(contents),
+		Checksum:               blockMetadata.Checksum,
+	}
+
+	Convey("Healthy block is verified", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		result := disk.ScrubBlock(block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.FaultyDisks, ShouldBeEmpty)
+	})
+
+	Convey("Corrupted and missing shards are repaired", t, func() {
+		var validShards [][]uint8
+		for _, d := range disks {
+			shard := make([]uint8, len(d.Blocks[blockMetadata.UUID]))
+			copy(shard, d.Blocks[blockMetadata.UUID])
+			validShards = append(validShards, shard)
+		}
+
+		disks[3].Blocks[blockMetadata.UUID][constants.ERASURE_SHARD_HEADER_SIZE] ^= 0xFF
+		delete(disks[1].Blocks, blockMetadata.UUID)
+
+		result := disk.ScrubBlock(block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.FaultyDisks, ShouldResemble, []uuid.UUID{disks[1].GetUUID(), disks[3].GetUUID()})
+		So(result.Repaired, ShouldBeTrue)
+
+		for i, d := range disks {
+			So(bytes.Equal(d.Blocks[blockMetadata.UUID], validShards[i]), ShouldBeTrue)
+		}
+	})
+
+	Convey("Block is reported when not enough shards are available", t, func() {
+		disks[0].IsFailing = true
+		disks[1].IsFailing = true
+		disks[2].IsFailing = true
+
+		result := disk.ScrubBlock(block)
+		So(result.Code, ShouldEqual, constants.REMOTE_FAILED_JOB)
+		So(result.FaultyDisks, ShouldHaveLength, 3)
+	})
+}
+
+func CreateErasureDisk(dataShards int, parityShards int) (*ErasureDisk.ErasureDisk, []*mock.MockDisk) {
+	volume := &models.Volume{
+		UUID:      uuid.New(),
+		BlockSize: constants.DEFAULT_VOLUME_BLOCK_SIZE,
+		Name:      "Mock Erasure Volume",
+		UserUUID:  mock.UserUUID,
+		VolumeSettings: dbo.VolumeSettings{
+			Backup:        constants.BACKUP_TYPE_ERASURE_CODING,
+			Encryption:    constants.ENCRYPTION_TYPE_NO_ENCRYPTION,
+			FilePartition: constants.PARTITION_TYPE_BALANCED,
+			DataShards:    dataShards,
+			ParityShards:  parityShards,
+		},
+	}
+
+	disk := ErasureDisk.NewErasureDisk()
+	disk.SetVolume(volume)
+
+	disks := mock.GetStorageMockDisks(dataShards + parityShards)
+	for _, d := range disks {
+		d.SetVolume(volume)
+		disk.AssignDisk(d)
+	}
+
+	return disk, disks
+}
@@ -0,0 +1,184 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"dcfs/util/merkle"
+	"encoding/hex"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestFileIntegrity(t *testing.T) {
+	volume, _ := CreateVolumeWithDisks(2)
+	volume.BlockSize = 1024
+
+	writer := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(writer)
+
+	uploadFile := func(size int) (*models.RegularFile, []uint8) {
+		contents := make([]uint8, size)
+		rand.Read(contents)
+
+		ExpectStreamedUpload(volume, size)
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(contents), "integrity", mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+		file.MerkleRoot = file.ComputeMerkleRoot()
+		return file, contents
+	}
+
+	download := func(file *models.RegularFile) (*bytes.Buffer, []uuid.UUID) {
+		var buffer bytes.Buffer
+		brokenBlocks, err := models.WriteFileRange(&buffer, file, 0, int64(file.GetSize())-1, &apicalls.BlockMetadata{Ctx: ctx, FileUUID: file.GetUUID()})
+		So(err, ShouldBeNil)
+		return &buffer, brokenBlocks
+	}
+
+	Convey("Streamed file is hashed along with its blocks", t, func() {
+		file, contents := uploadFile(3*volume.BlockSize + 10)
+
+		checksum := sha256.Sum256(contents)
+		So(file.Checksum, ShouldEqual, hex.EncodeToString(checksum[:]))
+		for _, block := range file.Blocks {
+			start := block.Order * volume.BlockSize
+			So(block.ContentHash, ShouldEqual, merkle.HashContent(contents[start:start+block.Size]))
+		}
+		So(file.MerkleRoot, ShouldNotBeEmpty)
+		So(file.VerifyMerkleRoot(), ShouldBeTrue)
+
+		Convey("and the hashes are saved in the database", func() {
+			mock.DBMock.ExpectBegin()
+			mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `files`")).WillReturnResult(sqlmock.NewResult(1, 1))
+			for range file.Blocks {
+				mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `blocks`")).WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			mock.DBMock.ExpectCommit()
+
+			fileDBO, err := models.SaveUploadedFile(file, mock.UserUUID)
+			So(err, ShouldBeNil)
+			So(fileDBO.Checksum, ShouldEqual, file.Checksum)
+			So(fileDBO.MerkleRoot, ShouldEqual, file.MerkleRoot)
+			So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("and every block can be proven to belong to the file", func() {
+			for _, block := range file.Blocks {
+				proof, err := file.GetBlockProof(block.UUID)
+				So(err, ShouldBeNil)
+				So(proof.Order, ShouldEqual, block.Order)
+				So(merkle.Verify(proof.Hash, proof.Proof, file.MerkleRoot), ShouldBeTrue)
+			}
+
+			proof, err := file.GetBlockProof(uuid.New())
+			So(err, ShouldBeNil)
+			So(proof, ShouldBeNil)
+		})
+
+		Convey("and the stored contents of every block are verified against the merkle root", func() {
+			for _, block := range file.Blocks {
+				proof, err := file.GetBlockProof(block.UUID)
+				So(err, ShouldBeNil)
+				So(file.VerifyBlockProof(ctx, proof), ShouldBeTrue)
+			}
+		})
+	})
+
+	Convey("Proof is not verified if the stored contents of the block do not match the merkle root", t, func() {
+		file, _ := uploadFile(2*volume.BlockSize + 10)
+
+		// Hashes in the db consistent with a forged merkle root do not make the block verified
+		for _, block := range file.Blocks {
+			block.ContentHash = merkle.HashContent([]uint8("forged " + block.UUID.String()))
+		}
+		file.MerkleRoot = file.ComputeMerkleRoot()
+		So(file.VerifyMerkleRoot(), ShouldBeTrue)
+
+		for _, block := range file.Blocks {
+			proof, err := file.GetBlockProof(block.UUID)
+			So(err, ShouldBeNil)
+			So(file.VerifyBlockProof(ctx, proof), ShouldBeFalse)
+		}
+	})
+
+	Convey("Intact file is downloaded without broken blocks", t, func() {
+		file, contents := uploadFile(2*volume.BlockSize + 10)
+
+		buffer, brokenBlocks := download(file)
+		So(brokenBlocks, ShouldBeEmpty)
+		So(buffer.Bytes(), ShouldResemble, contents)
+	})
+
+	Convey("All blocks are reported broken if the merkle root does not match", t, func() {
+		file, _ := uploadFile(2*volume.BlockSize + 10)
+		for _, block := range file.Blocks {
+			if block.Order == 0 {
+				block.ContentHash = merkle.HashContent([]uint8("tampered"))
+			}
+		}
+		So(file.VerifyMerkleRoot(), ShouldBeFalse)
+
+		_, brokenBlocks := download(file)
+		So(brokenBlocks, ShouldHaveLength, len(file.Blocks))
+	})
+
+	Convey("All blocks are reported broken if the checksum of the file does not match", t, func() {
+		file, _ := uploadFile(2*volume.BlockSize + 10)
+		file.Checksum = merkle.HashContent([]uint8("tampered"))
+
+		_, brokenBlocks := download(file)
+		So(brokenBlocks, ShouldHaveLength, len(file.Blocks))
+	})
+
+	Convey("Files uploaded before hashing are trusted", t, func() {
+		file, contents := uploadFile(volume.BlockSize + 10)
+		file.Checksum, file.MerkleRoot = "", ""
+		for _, block := range file.Blocks {
+			block.ContentHash = ""
+		}
+
+		So(file.ComputeMerkleRoot(), ShouldBeEmpty)
+		So(file.VerifyMerkleRoot(), ShouldBeTrue)
+
+		_, err := file.GetBlockProof(uuid.New())
+		So(err, ShouldEqual, models.ErrIntegrityUnknown)
+
+		buffer, brokenBlocks := download(file)
+		So(brokenBlocks, ShouldBeEmpty)
+		So(buffer.Bytes(), ShouldResemble, contents)
+	})
+
+	Convey("Streamed file is removed if its checksum does not match the provided one", t, func() {
+		file, contents := uploadFile(volume.BlockSize + 10)
+
+		checksum := sha256.Sum256(contents)
+		So(models.VerifyStreamedFile(ctx, file, hex.EncodeToString(checksum[:])), ShouldBeNil)
+
+		ExpectUploadSessionRemoval()
+		errorWrapper := models.VerifyStreamedFile(ctx, file, merkle.HashContent([]uint8("other")))
+		So(errorWrapper, ShouldNotBeNil)
+		So(errorWrapper.Code, ShouldEqual, constants.FS_CHECKSUM_MISMATCH)
+		for _, block := range file.Blocks {
+			So(block.Disk.(*mock.MockDisk).Blocks, ShouldNotContainKey, block.UUID)
+		}
+	})
+
+	Convey("Block whose contents do not match its hash is detected", t, func() {
+		block := &models.Block{ContentHash: merkle.HashContent([]uint8("contents"))}
+		So(block.VerifyBlockContent([]uint8("contents")), ShouldBeTrue)
+		So(block.VerifyBlockContent([]uint8("tampered")), ShouldBeFalse)
+
+		block.ContentHash = ""
+		So(block.VerifyBlockContent([]uint8("tampered")), ShouldBeTrue)
+	})
+}
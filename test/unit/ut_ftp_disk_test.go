@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/FTPDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestFTPDisk_TLS(t *testing.T) {
+	for _, tlsMode := range []string{constants.FTP_TLS_MODE_EXPLICIT, constants.FTP_TLS_MODE_IMPLICIT} {
+		server := mock.NewMockFTPServer(tlsMode)
+
+		Convey("Block is transferred over "+tlsMode+" TLS with the custom CA", t, func() {
+			disk := CreateFTPDisk(server.Credentials(tlsMode, server.CertificatePEM, ""))
+			So(ConnectFTPDisk(disk), ShouldEqual, constants.SUCCESS)
+
+			blockMetadata, contents := mock.GetBlockMetadata(2048)
+			So(disk.Upload(blockMetadata), ShouldBeNil)
+			So(bytes.Equal(server.Files["/"+blockMetadata.UUID.String()], contents), ShouldBeTrue)
+
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+
+			So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+			So(server.Files, ShouldNotContainKey, "/"+blockMetadata.UUID.String())
+		})
+
+		Convey("Pinned certificate is accepted over "+tlsMode+" TLS", t, func() {
+			disk := CreateFTPDisk(server.Credentials(tlsMode, "", strings.ToUpper(server.Fingerprint)))
+			So(ConnectFTPDisk(disk), ShouldEqual, constants.SUCCESS)
+		})
+
+		Convey("Untrusted certificate is reported as a TLS handshake failure over "+tlsMode+" TLS", t, func() {
+			disk := CreateFTPDisk(server.Credentials(tlsMode, "", ""))
+			So(ConnectFTPDisk(disk), ShouldEqual, constants.REMOTE_TLS_HANDSHAKE_FAILED)
+		})
+
+		Convey("Mismatched fingerprint is reported as a TLS handshake failure over "+tlsMode+" TLS", t, func() {
+			disk := CreateFTPDisk(server.Credentials(tlsMode, "", strings.Repeat("00", 32)))
+			So(ConnectFTPDisk(disk), ShouldEqual, constants.REMOTE_TLS_HANDSHAKE_FAILED)
+		})
+
+		server.Close()
+	}
+
+	Convey("Plain text server is reported as a TLS handshake failure", t, func() {
+		server := mock.NewMockFTPServer(constants.FTP_TLS_MODE_NONE)
+		defer server.Close()
+
+		for _, tlsMode := range []string{constants.FTP_TLS_MODE_EXPLICIT, constants.FTP_TLS_MODE_IMPLICIT} {
+			disk := CreateFTPDisk(server.Credentials(tlsMode, server.CertificatePEM, ""))
+			So(ConnectFTPDisk(disk), ShouldEqual, constants.REMOTE_TLS_HANDSHAKE_FAILED)
+		}
+
+		disk := CreateFTPDisk(server.Credentials(constants.FTP_TLS_MODE_NONE, "", ""))
+		So(ConnectFTPDisk(disk), ShouldEqual, constants.SUCCESS)
+	})
+
+	Convey("Invalid password is not reported as a TLS failure", t, func() {
+		server := mock.NewMockFTPServer(constants.FTP_TLS_MODE_EXPLICIT)
+		defer server.Close()
+
+		disk := CreateFTPDisk(strings.Replace(server.Credentials(constants.FTP_TLS_MODE_EXPLICIT, server.CertificatePEM, ""), `"password":"`+mock.FTPPassword, `"password":"wrong`, 1))
+		So(ConnectFTPDisk(disk), ShouldEqual, constants.REMOTE_CANNOT_AUTHENTICATE)
+	})
+}
+
+func TestRealDiskReadiness_FailureCode(t *testing.T) {
+	errCode := constants.REMOTE_TLS_HANDSHAKE_FAILED
+	readiness := models.NewRealDiskReadinessWithCode(func(ctx context.Context) string { return errCode }, func() bool { return false })
+
+	Convey("Failure code of the readiness check is reported", t, func() {
+		So(readiness.IsReadyForce(context.Background()), ShouldBeFalse)
+		So(readiness.GetFailureCode(), ShouldEqual, constants.REMOTE_TLS_HANDSHAKE_FAILED)
+		So(models.NewVirtualDiskReadiness(readiness).GetFailureCode(), ShouldEqual, constants.REMOTE_TLS_HANDSHAKE_FAILED)
+	})
+
+	Convey("Failure code is cleared once the disk is ready", t, func() {
+		errCode = constants.SUCCESS
+		So(readiness.IsReadyForce(context.Background()), ShouldBeTrue)
+		So(readiness.GetFailureCode(), ShouldEqual, constants.SUCCESS)
+	})
+
+	Convey("Boolean readiness checkers report authentication failures", t, func() {
+		readiness := models.NewRealDiskReadiness(func(ctx context.Context) bool { return false }, func() bool { return false })
+		So(readiness.IsReadyForce(context.Background()), ShouldBeFalse)
+		So(readiness.GetFailureCode(), ShouldEqual, constants.REMOTE_CANNOT_AUTHENTICATE)
+	})
+}
+
+// ConnectFTPDisk - connect to the FTP server of the disk and return the completion code
+func ConnectFTPDisk(disk *FTPDisk.FTPDisk) string {
+	conn, errCode := disk.GetCredentials().(*credentials.FTPCredentials).Connect(context.Background())
+	if conn != nil {
+		_ = conn.Quit()
+	}
+
+	return errCode
+}
+
+func CreateFTPDisk(cred string) *FTPDisk.FTPDisk {
+	disk := FTPDisk.NewFTPDisk()
+	disk.SetUUID(uuid.New())
+	disk.CreateCredentials(cred)
+
+	return disk
+}
@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"dcfs/util/httprange"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	Convey("Byte ranges are parsed and clamped to the size of the file", t, func() {
+		r, err := httprange.Parse("bytes=10-19", 100)
+		So(err, ShouldBeNil)
+		So(r, ShouldResemble, httprange.Range{Start: 10, End: 19})
+		So(r.Length(), ShouldEqual, 10)
+		So(r.ContentRange(100), ShouldEqual, "bytes 10-19/100")
+
+		r, err = httprange.Parse("bytes=90-", 100)
+		So(err, ShouldBeNil)
+		So(r, ShouldResemble, httprange.Range{Start: 90, End: 99})
+
+		r, err = httprange.Parse("bytes=90-200", 100)
+		So(err, ShouldBeNil)
+		So(r, ShouldResemble, httprange.Range{Start: 90, End: 99})
+
+		r, err = httprange.Parse("bytes=-30", 100)
+		So(err, ShouldBeNil)
+		So(r, ShouldResemble, httprange.Range{Start: 70, End: 99})
+
+		r, err = httprange.Parse("bytes=-300", 100)
+		So(err, ShouldBeNil)
+		So(r, ShouldResemble, httprange.Range{Start: 0, End: 99})
+	})
+
+	Convey("Ranges outside of the file are not satisfiable", t, func() {
+		_, err := httprange.Parse("bytes=100-", 100)
+		So(err, ShouldEqual, httprange.ErrUnsatisfiable)
+
+		_, err = httprange.Parse("bytes=-0", 100)
+		So(err, ShouldEqual, httprange.ErrUnsatisfiable)
+	})
+
+	Convey("Malformed and multiple ranges are ignored", t, func() {
+		for _, header := range []string{"items=0-10", "bytes=10", "bytes=20-10", "bytes=a-b", "bytes=0-10,20-30"} {
+			_, err := httprange.Parse(header, 100)
+			So(err, ShouldEqual, httprange.ErrInvalid)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/LocalDisk"
+	"dcfs/requests"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalDisk(t *testing.T) {
+	root := t.TempDir()
+	credentials.LocalDiskRoot = root
+	defer func() { credentials.LocalDiskRoot = "" }()
+
+	volume := &models.Volume{UUID: uuid.New(), UserUUID: mock.UserUUID}
+	directory := filepath.Join(root, mock.UserUUID.String(), "disk")
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		t.Fatal(err)
+	}
+	cred := requests.FTPCredentials{Path: "disk"}
+
+	disk := LocalDisk.NewLocalDisk()
+	disk.SetUUID(uuid.New())
+	disk.SetVolume(volume)
+	disk.CreateCredentials(cred.ToString())
+
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	Convey("Local credentials contain only the path", t, func() {
+		So(cred.ToString(), ShouldNotBeEmpty)
+		So(disk.GetCredentials().GetPath(), ShouldEqual, "disk")
+	})
+
+	Convey("Block is stored in the local directory of the owner", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		stored, err := os.ReadFile(filepath.Join(directory, blockMetadata.UUID.String()))
+		So(err, ShouldBeNil)
+		So(bytes.Equal(stored, contents), ShouldBeTrue)
+	})
+
+	Convey("Block is downloaded from the local directory", t, func() {
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+	})
+
+	Convey("Uploading the block again replaces its contents", t, func() {
+		replaced := []uint8("replaced contents")
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		_blockMetadata.Content = &replaced
+		So(disk.Upload(_blockMetadata), ShouldBeNil)
+
+		stored, err := os.ReadFile(filepath.Join(directory, blockMetadata.UUID.String()))
+		So(err, ShouldBeNil)
+		So(bytes.Equal(stored, replaced), ShouldBeTrue)
+
+		entries, err := os.ReadDir(directory)
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 1)
+	})
+
+	Convey("Provider space is reported by the filesystem", t, func() {
+		usedSpace, totalSpace, errCode := disk.GetProviderSpace()
+		So(errCode, ShouldEqual, constants.SUCCESS)
+		So(totalSpace, ShouldBeGreaterThan, 0)
+		So(usedSpace, ShouldBeLessThanOrEqualTo, totalSpace)
+	})
+
+	Convey("Block is removed from the local directory", t, func() {
+		So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+
+		_, err := os.Stat(filepath.Join(directory, blockMetadata.UUID.String()))
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata).Code, ShouldEqual, constants.REMOTE_BAD_FILE)
+	})
+
+	Convey("Directories outside of the directory of the owner are rejected", t, func() {
+		outside := t.TempDir()
+		So(os.Symlink(outside, filepath.Join(directory, "link")), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join(root, uuid.New().String()), 0700), ShouldBeNil)
+
+		for _, path := range []string{"..", "../..", "disk/../../other", outside, root, "disk/link"} {
+			_disk := LocalDisk.NewLocalDisk()
+			_disk.SetVolume(volume)
+			_cred := requests.FTPCredentials{Path: path}
+			_disk.CreateCredentials(_cred.ToString())
+
+			So(_disk.GetCredentials().Authenticate(nil), ShouldBeNil)
+			So(_disk.Upload(mock.CopyBlockMetadata(blockMetadata)).Code, ShouldEqual, constants.REMOTE_CANNOT_AUTHENTICATE)
+		}
+
+		_, err := os.Stat(filepath.Join(outside, blockMetadata.UUID.String()))
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("Absolute path within the directory of the owner is accepted", t, func() {
+		_disk := LocalDisk.NewLocalDisk()
+		_disk.SetVolume(volume)
+		_cred := requests.FTPCredentials{Path: directory}
+		_disk.CreateCredentials(_cred.ToString())
+
+		So(_disk.GetCredentials().Authenticate(nil), ShouldNotBeNil)
+	})
+
+	Convey("Local disks are disabled when the root is not configured", t, func() {
+		credentials.LocalDiskRoot = ""
+		defer func() { credentials.LocalDiskRoot = root }()
+
+		So(disk.GetCredentials().Authenticate(nil), ShouldBeNil)
+	})
+
+	Convey("Disk is not available when the directory does not exist", t, func() {
+		missingCred := requests.FTPCredentials{Path: "missing"}
+		disk.CreateCredentials(missingCred.ToString())
+
+		So(disk.GetReadiness().IsReadyForce(context.Background()), ShouldBeFalse)
+		So(disk.Upload(mock.CopyBlockMetadata(blockMetadata)).Code, ShouldEqual, constants.REMOTE_CANNOT_AUTHENTICATE)
+	})
+}
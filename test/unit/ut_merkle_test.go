@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"dcfs/constants"
+	"dcfs/util/merkle"
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestMerkleTree(t *testing.T) {
+	getLeaves := func(number int) []string {
+		var leaves []string
+		for i := 0; i < number; i++ {
+			leaves = append(leaves, merkle.HashContent([]uint8(fmt.Sprintf("block %d", i))))
+		}
+
+		return leaves
+	}
+
+	Convey("Root depends on the contents and the order of the leaves", t, func() {
+		leaves := getLeaves(5)
+		root, err := merkle.Root(leaves)
+		So(err, ShouldBeNil)
+		So(root, ShouldHaveLength, 64)
+
+		_root, err := merkle.Root(getLeaves(5))
+		So(err, ShouldBeNil)
+		So(_root, ShouldEqual, root)
+
+		swapped := getLeaves(5)
+		swapped[1], swapped[2] = swapped[2], swapped[1]
+		_root, err = merkle.Root(swapped)
+		So(err, ShouldBeNil)
+		So(_root, ShouldNotEqual, root)
+
+		changed := getLeaves(5)
+		changed[4] = merkle.HashContent([]uint8("changed"))
+		_root, err = merkle.Root(changed)
+		So(err, ShouldBeNil)
+		So(_root, ShouldNotEqual, root)
+	})
+
+	Convey("Proof of every leaf leads to the root", t, func() {
+		for _, number := range []int{1, 2, 3, 5, 8} {
+			leaves := getLeaves(number)
+			root, err := merkle.Root(leaves)
+			So(err, ShouldBeNil)
+
+			for index, leaf := range leaves {
+				proof, err := merkle.Proof(leaves, index)
+				So(err, ShouldBeNil)
+				So(merkle.Verify(leaf, proof, root), ShouldBeTrue)
+			}
+		}
+	})
+
+	Convey("Tampered proof is rejected", t, func() {
+		leaves := getLeaves(5)
+		root, _ := merkle.Root(leaves)
+		proof, err := merkle.Proof(leaves, 2)
+		So(err, ShouldBeNil)
+		So(proof, ShouldNotBeEmpty)
+
+		So(merkle.Verify(leaves[2], proof, root), ShouldBeTrue)
+		So(merkle.Verify(leaves[3], proof, root), ShouldBeFalse)
+
+		_proof := append([]merkle.ProofNode{}, proof...)
+		_proof[0].Hash = leaves[0]
+		So(merkle.Verify(leaves[2], _proof, root), ShouldBeFalse)
+
+		_proof = append([]merkle.ProofNode{}, proof...)
+		if _proof[0].Position == constants.MERKLE_SIBLING_LEFT {
+			_proof[0].Position = constants.MERKLE_SIBLING_RIGHT
+		} else {
+			_proof[0].Position = constants.MERKLE_SIBLING_LEFT
+		}
+		So(merkle.Verify(leaves[2], _proof, root), ShouldBeFalse)
+	})
+
+	Convey("Tree cannot be built from invalid leaves", t, func() {
+		_, err := merkle.Root(nil)
+		So(err, ShouldEqual, merkle.ErrNoLeaves)
+
+		_, err = merkle.Root([]string{"not a hash"})
+		So(err, ShouldEqual, merkle.ErrInvalidLeaf)
+
+		_, err = merkle.Proof(getLeaves(3), 3)
+		So(err, ShouldEqual, merkle.ErrInvalidLeaf)
+	})
+}
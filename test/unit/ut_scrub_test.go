@@ -0,0 +1,101 @@
+package unit
+
+import (
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"dcfs/util/merkle"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestScrubBlock(t *testing.T) {
+	volume := &models.Volume{
+		UUID:      uuid.New(),
+		BlockSize: constants.DEFAULT_VOLUME_BLOCK_SIZE,
+		Name:      "Mock Volume",
+		UserUUID:  mock.UserUUID,
+		VolumeSettings: dbo.VolumeSettings{
+			Backup:        constants.BACKUP_TYPE_NO_BACKUP,
+			Encryption:    constants.ENCRYPTION_TYPE_NO_ENCRYPTION,
+			FilePartition: constants.PARTITION_TYPE_BALANCED,
+		},
+	}
+
+	disk := mock.GetStorageMockDisks(1)[0]
+	disk.SetVolume(volume)
+	volume.AddDisk(disk.GetUUID(), disk)
+
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+	block := dbo.Block{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+		DiskUUID:               disk.GetUUID(),
+		Size:                   len(contents),
+		Checksum:               blockMetadata.Checksum,
+	}
+
+	Convey("Healthy block is verified along with its contents", t, func() {
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		_block := block
+		_block.ContentHash = merkle.HashContent(contents)
+		result := models.ScrubBlock(volume, _block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.FaultyDisks, ShouldBeEmpty)
+		So(result.ContentHash, ShouldEqual, _block.ContentHash)
+	})
+
+	Convey("Contents of the block uploaded before it was hashed are left unverified", t, func() {
+		result := models.ScrubBlock(volume, block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.ContentHash, ShouldBeEmpty)
+	})
+
+	Convey("Contents of the block stored on the locked volume are left unverified", t, func() {
+		volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_PASSPHRASE
+		defer func() { volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_NO_ENCRYPTION }()
+		So(volume.IsLocked(), ShouldBeTrue)
+
+		_block := block
+		_block.ContentHash = merkle.HashContent(contents)
+		result := models.ScrubBlock(volume, _block)
+		So(result.Code, ShouldEqual, constants.SUCCESS)
+		So(result.ContentHash, ShouldBeEmpty)
+	})
+
+	Convey("Block whose contents do not match its hash is reported", t, func() {
+		_block := block
+		_block.ContentHash = merkle.HashContent([]uint8("tampered"))
+		result := models.ScrubBlock(volume, _block)
+		So(result.Code, ShouldEqual, constants.FS_CHECKSUM_MISMATCH)
+		So(result.FaultyDisks, ShouldBeEmpty)
+		So(result.ContentHash, ShouldBeEmpty)
+	})
+
+	Convey("Corrupted block is reported", t, func() {
+		disk.Blocks[blockMetadata.UUID][0] ^= 0xFF
+
+		result := models.ScrubBlock(volume, block)
+		So(result.Code, ShouldEqual, constants.REMOTE_CORRUPTED_BLOCKS)
+		So(result.FaultyDisks, ShouldResemble, []uuid.UUID{disk.GetUUID()})
+		So(result.Repaired, ShouldBeFalse)
+	})
+
+	Convey("Unreadable block is reported", t, func() {
+		disk.IsFailing = true
+
+		result := models.ScrubBlock(volume, block)
+		So(result.Code, ShouldEqual, constants.REMOTE_FAILED_JOB)
+		So(result.FaultyDisks, ShouldResemble, []uuid.UUID{disk.GetUUID()})
+	})
+
+	Convey("Block stored on unknown disk is reported", t, func() {
+		_block := block
+		_block.DiskUUID = uuid.New()
+
+		So(models.ScrubBlock(volume, _block).Code, ShouldEqual, constants.TRANSPORT_DISK_NOT_FOUND)
+	})
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"dcfs/constants"
+	"dcfs/db"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	_ "dcfs/models/disk/LocalDisk"
+	"dcfs/requests"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"dcfs/util/sealing"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestCredentialsSealing(t *testing.T) {
+	keyPath := sealing.KeyPath
+	sealing.KeyPath = filepath.Join(t.TempDir(), "master.key")
+	defer func() { sealing.KeyPath = keyPath }()
+
+	cred := requests.FTPCredentials{Login: "login", Password: "secret", Host: "localhost", Port: "21", Path: t.TempDir()}
+
+	Convey("Sealed credentials can be unsealed", t, func() {
+		sealed, err := sealing.Seal(cred.ToString())
+		So(err, ShouldBeNil)
+		So(sealing.IsSealed(sealed), ShouldBeTrue)
+		So(sealed, ShouldNotContainSubstring, "secret")
+
+		unsealed, err := sealing.Unseal(sealed)
+		So(err, ShouldBeNil)
+		So(unsealed, ShouldEqual, cred.ToString())
+
+		Convey("and the generated key is kept private", func() {
+			info, err := os.Stat(sealing.KeyPath)
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+			So(info.Size(), ShouldEqual, 32)
+		})
+	})
+
+	Convey("Credentials saved before sealing are returned as is", t, func() {
+		unsealed, err := sealing.Unseal(cred.ToString())
+		So(err, ShouldBeNil)
+		So(unsealed, ShouldEqual, cred.ToString())
+	})
+
+	Convey("Credentials sealed with another key cannot be unsealed", t, func() {
+		sealed, err := sealing.Seal(cred.ToString())
+		So(err, ShouldBeNil)
+
+		currentKeyPath := sealing.KeyPath
+		sealing.KeyPath = filepath.Join(t.TempDir(), "other.key")
+		defer func() { sealing.KeyPath = currentKeyPath }()
+
+		_, err = sealing.Unseal(sealed)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Disk is created from the sealed credentials", t, func() {
+		sealed, err := sealing.Seal(cred.ToString())
+		So(err, ShouldBeNil)
+
+		diskDBO := &dbo.Disk{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: uuid.New()},
+			Credentials:            sealed,
+			Provider:               dbo.Provider{Type: constants.PROVIDER_TYPE_LOCAL},
+		}
+		disk := models.CreateDisk(models.CreateDiskMetadata{Disk: diskDBO, Volume: mock.Volume})
+		So(disk, ShouldNotBeNil)
+		So(disk.GetCredentials().GetPath(), ShouldEqual, cred.Path)
+
+		Convey("but the disk response does not contain them", func() {
+			response, err := json.Marshal(models.DiskResponse{Disk: *diskDBO})
+			So(err, ShouldBeNil)
+			So(string(response), ShouldNotContainSubstring, "credentials")
+			So(string(response), ShouldNotContainSubstring, sealed)
+		})
+
+		Convey("unless the credentials cannot be unsealed", func() {
+			diskDBO.Credentials = constants.SEALED_PREFIX + "invalid"
+			So(models.CreateDisk(models.CreateDiskMetadata{Disk: diskDBO, Volume: mock.Volume}), ShouldBeNil)
+		})
+	})
+
+	Convey("Plain text credentials are sealed by the migration", t, func() {
+		sealed, err := sealing.Seal(cred.ToString())
+		So(err, ShouldBeNil)
+
+		disks := mock.GetSpecifiedDisksDBO(2, constants.PROVIDER_TYPE_SFTP)
+		disks[1].Credentials = sealed
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `disks` WHERE credentials <> ''")).WillReturnRows(mock.DiskRow(&disks[0], &disks[1]))
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `disks` SET `credentials`=? WHERE uuid = ?")).
+			WithArgs(sqlmock.AnyArg(), disks[0].UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+
+		So(db.DB.SealDiskCredentials(), ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+}
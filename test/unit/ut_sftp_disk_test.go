@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/apicalls"
+	"dcfs/constants"
+	"dcfs/models/credentials"
+	"dcfs/models/disk/SFTPDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSFTPDisk_KeyAuthentication(t *testing.T) {
+	server := mock.NewMockSFTPServer()
+	defer server.Close()
+	directory := t.TempDir()
+
+	Convey("Block is transferred using the private key of the disk", t, func() {
+		disk := CreateSFTPDisk(server.Credentials(directory, map[string]string{"privateKey": server.ClientKeyPEM, "hostKey": server.Fingerprint}))
+		So(ConnectSFTPDisk(disk, uuid.Nil), ShouldEqual, constants.SUCCESS)
+
+		blockMetadata, contents := mock.GetBlockMetadata(1024)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+
+		stored, err := os.ReadFile(filepath.Join(directory, blockMetadata.UUID.String()))
+		So(err, ShouldBeNil)
+		So(bytes.Equal(stored, contents), ShouldBeTrue)
+
+		_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+		So(disk.Download(_blockMetadata), ShouldBeNil)
+		So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+	})
+
+	Convey("Passphrase protected private key is decrypted", t, func() {
+		disk := CreateSFTPDisk(server.Credentials(directory, map[string]string{
+			"privateKey": server.EncryptedClientKeyPEM,
+			"passphrase": mock.SFTPKeyPassphrase,
+			"hostKey":    server.Fingerprint,
+		}))
+		So(ConnectSFTPDisk(disk, uuid.Nil), ShouldEqual, constants.SUCCESS)
+	})
+
+	Convey("Private key with a wrong passphrase is rejected", t, func() {
+		disk := CreateSFTPDisk(server.Credentials(directory, map[string]string{
+			"privateKey": server.EncryptedClientKeyPEM,
+			"passphrase": "wrong",
+		}))
+		So(ConnectSFTPDisk(disk, uuid.Nil), ShouldEqual, constants.REMOTE_CANNOT_AUTHENTICATE)
+	})
+
+	Convey("Private key is kept in the stored credentials", t, func() {
+		cred := server.Credentials(directory, map[string]string{"privateKey": server.ClientKeyPEM, "passphrase": "secret"})
+		_credentials := credentials.NewSFTPCredentials(cred)
+		restored := credentials.NewSFTPCredentials(_credentials.ToString())
+		So(restored.PrivateKey, ShouldEqual, server.ClientKeyPEM)
+		So(restored.Passphrase, ShouldEqual, "secret")
+		So(restored.Host, ShouldEqual, _credentials.Host)
+	})
+}
+
+func TestSFTPDisk_HostKeyVerification(t *testing.T) {
+	server := mock.NewMockSFTPServer()
+	defer server.Close()
+	directory := t.TempDir()
+
+	Convey("Host key is trusted and saved on the first connection", t, func() {
+		diskUUID := uuid.New()
+		disk := CreateSFTPDisk(server.Credentials(directory, map[string]string{"password": mock.SFTPPassword}))
+
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `disks` SET `credentials`")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+
+		So(ConnectSFTPDisk(disk, diskUUID), ShouldEqual, constants.SUCCESS)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		So(disk.GetCredentials().(*credentials.SFTPCredentials).HostKey, ShouldEqual, server.Fingerprint)
+
+		// Next connections verify the key without updating the credentials
+		So(ConnectSFTPDisk(disk, diskUUID), ShouldEqual, constants.SUCCESS)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Disk is not ready when the host key changes", t, func() {
+		other := mock.NewMockSFTPServer()
+		defer other.Close()
+
+		disk := CreateSFTPDisk(server.Credentials(directory, map[string]string{"password": mock.SFTPPassword, "hostKey": other.Fingerprint}))
+		So(ConnectSFTPDisk(disk, uuid.Nil), ShouldEqual, constants.REMOTE_HOST_KEY_MISMATCH)
+		So(disk.Upload(func() *apicalls.BlockMetadata { b, _ := mock.GetBlockMetadata(16); return b }()).Code, ShouldEqual, constants.REMOTE_CANNOT_AUTHENTICATE)
+	})
+}
+
+func TestSFTPDisk_ConnectionPool(t *testing.T) {
+	server := mock.NewMockSFTPServer()
+	defer server.Close()
+	directory := t.TempDir()
+
+	disk := CreateSFTPDisk(server.Credentials(directory, map[string]string{"password": mock.SFTPPassword, "hostKey": server.Fingerprint}))
+
+	Convey("Block operations share the pooled connection", t, func() {
+		for i := 0; i < 5; i++ {
+			blockMetadata, contents := mock.GetBlockMetadata(512)
+			So(disk.Upload(blockMetadata), ShouldBeNil)
+
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+			So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+		}
+
+		So(atomic.LoadInt32(&server.Connections), ShouldEqual, 1)
+	})
+
+	Convey("Connections are reopened after the credentials change", t, func() {
+		disk.CreateCredentials(server.Credentials(directory, map[string]string{"password": mock.SFTPPassword, "hostKey": server.Fingerprint}))
+
+		blockMetadata, _ := mock.GetBlockMetadata(512)
+		So(disk.Upload(blockMetadata), ShouldBeNil)
+		So(atomic.LoadInt32(&server.Connections), ShouldEqual, 2)
+	})
+}
+
+// ConnectSFTPDisk - connect to the SFTP server of the disk and return the completion code
+func ConnectSFTPDisk(disk *SFTPDisk.SFTPDisk, diskUUID uuid.UUID) string {
+	_credentials := disk.GetCredentials().(*credentials.SFTPCredentials)
+	client, errCode := _credentials.Connect(&apicalls.CredentialsAuthenticateMetadata{DiskUUID: diskUUID})
+	if client != nil {
+		_ = client.Close()
+	}
+
+	return errCode
+}
+
+func CreateSFTPDisk(cred string) *SFTPDisk.SFTPDisk {
+	disk := SFTPDisk.NewSFTPDisk()
+	disk.SetUUID(uuid.New())
+	disk.CreateCredentials(cred)
+
+	return disk
+}
@@ -0,0 +1,131 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/models/disk/BackupDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCheckVolumeDisks(t *testing.T) {
+	volume, disks, spareDisk := CreateVolumeWithSpare()
+	virtualDiskUUID := disks[0].GetVirtualDiskUUID()
+	blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+	Convey("Disk is not replaced before the grace period ends", t, func() {
+		So(volume.GetDisk(virtualDiskUUID).Upload(blockMetadata), ShouldBeNil)
+		disks[1].DiskReadiness.IsUnavailable = true
+
+		models.SpareGracePeriod = time.Hour
+		models.CheckVolumeDisks(volume)
+
+		So(disks[1].GetVirtualDiskUUID(), ShouldEqual, virtualDiskUUID)
+		So(spareDisk.GetIsSpareFlag(), ShouldBeTrue)
+	})
+
+	Convey("Unavailable disk is replaced with the hot spare", t, func() {
+		block := dbo.Block{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: blockMetadata.UUID},
+			DiskUUID:               virtualDiskUUID,
+			Size:                   len(contents),
+			Checksum:               blockMetadata.Checksum,
+		}
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `blocks` WHERE disk_uuid = ?")).WithArgs(virtualDiskUUID).WillReturnRows(mock.BlockRow(&block))
+		for _, query := range []string{"DELETE FROM `degraded_blocks`", "UPDATE `disks`", "UPDATE `disks`", "UPDATE `disks`", "INSERT INTO `events`"} {
+			mock.DBMock.ExpectBegin()
+			mock.DBMock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.DBMock.ExpectCommit()
+		}
+
+		models.SpareGracePeriod = 0
+		models.CheckVolumeDisks(volume)
+
+		So(spareDisk.GetVirtualDiskUUID(), ShouldEqual, virtualDiskUUID)
+		So(spareDisk.GetIsSpareFlag(), ShouldBeFalse)
+		So(bytes.Equal(spareDisk.Blocks[blockMetadata.UUID], contents), ShouldBeTrue)
+
+		So(disks[1].GetVirtualDiskUUID(), ShouldEqual, uuid.Nil)
+		So(disks[1].GetIsSpareFlag(), ShouldBeTrue)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Unavailable disk is not replaced without available hot spare", t, func() {
+		disks[0].DiskReadiness.IsUnavailable = true
+
+		So(models.PromoteSpareDisk(volume, disks[0]), ShouldEqual, constants.TRANSPORT_DISK_NOT_FOUND)
+		So(disks[0].GetVirtualDiskUUID(), ShouldEqual, virtualDiskUUID)
+	})
+}
+
+func TestCheckVolumeDisks_ConcurrentChanges(t *testing.T) {
+	volume, _, _ := CreateVolumeWithSpare()
+	models.SpareGracePeriod = time.Hour
+
+	Convey("Disks can be added and removed while the volume is checked", t, func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, d := range mock.GetStorageMockDisks(500) {
+				volume.AddDisk(d.GetUUID(), d)
+				volume.DeleteDisk(d.GetUUID())
+			}
+		}()
+
+		for checking := true; checking; {
+			select {
+			case <-done:
+				checking = false
+			default:
+				models.CheckVolumeDisks(volume)
+				So(volume.GetSpareDisks(), ShouldHaveLength, 1)
+			}
+		}
+
+		So(volume.GetRealDisks(), ShouldHaveLength, 3)
+	})
+}
+
+func CreateVolumeWithSpare() (*models.Volume, []*mock.MockDisk, *mock.MockDisk) {
+	models.RefreshPartitionerFunc = func(v *models.Volume) { v.RefreshPartitioner() }
+
+	volume := models.NewVolume(&dbo.Volume{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: uuid.New()},
+		Name:                   "Mock Spare Volume",
+		UserUUID:               mock.UserUUID,
+		VolumeSettings: dbo.VolumeSettings{
+			Backup:        constants.BACKUP_TYPE_RAID_1,
+			Encryption:    constants.ENCRYPTION_TYPE_NO_ENCRYPTION,
+			FilePartition: constants.PARTITION_TYPE_BALANCED,
+			Replicas:      2,
+		},
+	}, nil, nil)
+
+	virtualDisk := BackupDisk.NewBackupDisk()
+	virtualDisk.SetUUID(uuid.New())
+	virtualDisk.SetVolume(volume)
+	volume.AddVirtualDisk(virtualDisk.GetUUID(), virtualDisk)
+
+	disks := mock.GetStorageMockDisks(3)
+	for _, d := range disks {
+		d.SetVolume(volume)
+		volume.AddDisk(d.GetUUID(), d)
+	}
+
+	for _, d := range disks[:2] {
+		d.SetVirtualDiskUUID(virtualDisk.GetUUID())
+		virtualDisk.AssignDisk(d)
+	}
+
+	disks[2].SetIsSpareFlag(true)
+	return volume, disks[:2], disks[2]
+}
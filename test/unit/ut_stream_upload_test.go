@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/constants"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"math/rand"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamFileUpload(t *testing.T) {
+	volume, disks := CreateVolumeWithDisks(2)
+
+	writer := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(writer)
+
+	contents := make([]uint8, 2*volume.BlockSize+volume.BlockSize/2)
+	rand.Read(contents)
+
+	Convey("Streamed file is partitioned into blocks", t, func() {
+		ExpectStreamedUpload(volume, len(contents))
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(contents), "streamed", mock.UserUUID, uuid.Nil)
+		So(errorWrapper, ShouldBeNil)
+		So(file.GetSize(), ShouldEqual, len(contents))
+		So(file.Blocks, ShouldHaveLength, 3)
+
+		for _, block := range file.Blocks {
+			start := block.Order * volume.BlockSize
+			So(block.Status, ShouldEqual, constants.BLOCK_STATUS_TRANSFERRED)
+			So(block.Size, ShouldEqual, len(contents[start:start+block.Size]))
+			So(block.Disk.(*mock.MockDisk).Blocks[block.UUID], ShouldResemble, contents[start:start+block.Size])
+			So(block.Checksum, ShouldNotBeEmpty)
+		}
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+		Convey("and saved in the database along with its blocks", func() {
+			mock.DBMock.ExpectBegin()
+			mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `files`")).WillReturnResult(sqlmock.NewResult(1, 1))
+			for range file.Blocks {
+				mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `blocks`")).WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			mock.DBMock.ExpectCommit()
+
+			fileDBO, err := models.SaveUploadedFile(file, mock.UserUUID)
+			So(err, ShouldBeNil)
+			So(fileDBO.UUID, ShouldEqual, file.GetUUID())
+			So(fileDBO.VolumeUUID, ShouldEqual, volume.UUID)
+			So(fileDBO.Size, ShouldEqual, len(contents))
+			So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		})
+	})
+
+	Convey("Transferred blocks and the upload session are removed if the upload fails", t, func() {
+		for _, d := range disks {
+			d.Blocks = make(map[uuid.UUID][]uint8)
+		}
+
+		ExpectStreamedUpload(volume, 2*volume.BlockSize)
+		ExpectUploadSessionRemoval()
+
+		reader := io.MultiReader(bytes.NewReader(contents[:2*volume.BlockSize]), iotest.ErrReader(errors.New("connection reset")))
+		file, errorWrapper := volume.StreamFileUpload(ctx, reader, "streamed", mock.UserUUID, uuid.Nil)
+		So(file, ShouldBeNil)
+		So(errorWrapper.Code, ShouldEqual, constants.FS_CANNOT_LOAD_BLOCK)
+		for _, d := range disks {
+			So(d.Blocks, ShouldBeEmpty)
+		}
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Upload session is kept if the transferred blocks cannot be removed", t, func() {
+		for _, d := range disks {
+			d.Blocks = make(map[uuid.UUID][]uint8)
+		}
+		defer func() {
+			for _, d := range disks {
+				d.IsFailing = false
+			}
+		}()
+
+		ExpectStreamedUpload(volume, 2*volume.BlockSize)
+
+		reader := &interruptedReader{reader: bytes.NewReader(contents[:2*volume.BlockSize]), interrupt: func() {
+			for _, d := range disks {
+				d.IsFailing = true
+			}
+		}}
+		file, errorWrapper := volume.StreamFileUpload(ctx, reader, "streamed", mock.UserUUID, uuid.Nil)
+		So(file, ShouldBeNil)
+		So(errorWrapper.Code, ShouldEqual, constants.FS_CANNOT_LOAD_BLOCK)
+		So(len(disks[0].Blocks)+len(disks[1].Blocks), ShouldEqual, 2)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Streamed file is in progress until its upload session is removed", t, func() {
+		fileUUID := uuid.New()
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `upload_sessions` WHERE uuid = ?")).WithArgs(fileUUID).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		So(models.IsUploadInProgress(fileUUID), ShouldBeTrue)
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `upload_sessions` WHERE uuid = ?")).WithArgs(fileUUID).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		So(models.IsUploadInProgress(fileUUID), ShouldBeFalse)
+	})
+
+	Convey("Streamed file cannot exceed the maximum file size", t, func() {
+		maximumFileSize := models.Transport.MaximumFileSize
+		models.Transport.MaximumFileSize = volume.BlockSize
+		defer func() { models.Transport.MaximumFileSize = maximumFileSize }()
+		for _, d := range disks {
+			d.Blocks = make(map[uuid.UUID][]uint8)
+		}
+
+		ExpectStreamedUpload(volume, volume.BlockSize)
+		ExpectUploadSessionRemoval()
+
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(contents), "streamed", mock.UserUUID, uuid.Nil)
+		So(file, ShouldBeNil)
+		So(errorWrapper.Code, ShouldEqual, constants.TRANSPORT_FILE_TOO_BIG)
+		for _, d := range disks {
+			So(d.Blocks, ShouldBeEmpty)
+		}
+	})
+
+	Convey("Empty stream is rejected", t, func() {
+		file, errorWrapper := volume.StreamFileUpload(ctx, bytes.NewReader(nil), "streamed", mock.UserUUID, uuid.Nil)
+		So(file, ShouldBeNil)
+		So(errorWrapper.Code, ShouldEqual, constants.VAL_SIZE_INVALID)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+}
+
+// ExpectStreamedUpload - expect the upload session of the streamed file to be saved along with its blocks
+func ExpectStreamedUpload(volume *models.Volume, size int) {
+	mock.DBMock.ExpectBegin()
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `upload_sessions`")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.DBMock.ExpectCommit()
+
+	for i := 0; i < (size+volume.BlockSize-1)/volume.BlockSize; i++ {
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `upload_session_blocks`")).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `upload_sessions` SET `updated_at`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+	}
+}
+
+// ExpectUploadSessionRemoval - expect the upload session to be removed along with its blocks
+func ExpectUploadSessionRemoval() {
+	mock.DBMock.ExpectBegin()
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `upload_session_blocks` WHERE session_uuid = ?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `upload_sessions` WHERE uuid = ?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.DBMock.ExpectCommit()
+}
+
+// interruptedReader - reader which calls the interrupt function and fails once the wrapped reader is exhausted
+type interruptedReader struct {
+	reader    io.Reader
+	interrupt func()
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		r.interrupt()
+		return n, errors.New("connection reset")
+	}
+
+	return n, err
+}
@@ -139,7 +139,7 @@ func TestVolumeKeepAlive(t *testing.T) {
 
 	Convey("The volume from db should appear in the transport queue", t, func() {
 		v := models.Transport.ActiveVolumes.GetEnqueuedInstance(volume.UUID).(*models.Volume)
-		So(*v, ShouldResemble, *volume)
+		So(v, ShouldResemble, volume)
 	})
 	Convey("The database call should be correct", t, func() {
 		So(mock.DBMock.ExpectationsWereMet(), ShouldEqual, nil)
@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"bytes"
+	"dcfs/constants"
+	"dcfs/controllers"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUploadBlock(t *testing.T) {
+	volume, disks := CreateVolumeWithDisks(1)
+
+	waitTime := models.Transport.WaitTime
+	models.Transport.WaitTime = 10 * time.Millisecond
+	defer func() { models.Transport.WaitTime = waitTime }()
+
+	uploadBlock := func(file *models.RegularFile, block *models.Block, contents []uint8) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		_ = form.WriteField("fileUUID", file.GetUUID().String())
+		part, _ := form.CreateFormFile("block", block.UUID.String())
+		_, _ = part.Write(contents)
+		_ = form.Close()
+
+		writer := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(writer)
+		ctx.Request = httptest.NewRequest("POST", "/files/upload/"+block.UUID.String(), &body)
+		ctx.Request.Header.Set("Content-Type", form.FormDataContentType())
+		ctx.Params = gin.Params{{Key: "BlockUUID", Value: block.UUID.String()}}
+
+		controllers.UploadBlock(ctx)
+		return writer
+	}
+
+	Convey("File is unlocked if the block cannot be encrypted with the locked volume", t, func() {
+		volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_PASSPHRASE
+		defer func() { volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_NO_ENCRYPTION }()
+		So(volume.IsLocked(), ShouldBeTrue)
+
+		file := models.NewFile(constants.FILE_TYPE_REGULAR).(*models.RegularFile)
+		file.SetVolume(volume)
+		file.Blocks = make(map[uuid.UUID]*models.Block)
+		block := models.NewBlock(uuid.New(), mock.UserUUID, file, disks[0], 1024, "", constants.BLOCK_STATUS_QUEUED, 0)
+		file.Blocks[block.UUID] = block
+
+		models.Transport.FileUploadQueue.EnqueueInstance(file.GetUUID(), file)
+		defer models.Transport.FileUploadQueue.RemoveEnqueuedInstance(file.GetUUID())
+
+		writer := uploadBlock(file, block, make([]uint8, 1024))
+		So(writer.Code, ShouldEqual, 423)
+		So(disks[0].Blocks, ShouldNotContainKey, block.UUID)
+
+		time.Sleep(10 * models.Transport.WaitTime)
+		So(models.Transport.FileUploadQueue.GetEnqueuedInstance(file.GetUUID()), ShouldBeNil)
+	})
+}
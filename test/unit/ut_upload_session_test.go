@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/requests"
+	"dcfs/responses"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestUploadSession(t *testing.T) {
+	volume, disks := CreateVolumeWithDisks(2)
+	models.Transport.ActiveVolumes.EnqueueInstance(volume.UUID, volume)
+	defer models.Transport.ActiveVolumes.RemoveEnqueuedInstance(volume.UUID)
+
+	file := volume.FileUploadRequest(&requests.InitFileUploadRequest{
+		VolumeUUID: volume.UUID.String(),
+		File: requests.FileDataRequest{
+			Name: "resumed",
+			Type: constants.FILE_TYPE_REGULAR,
+			Size: 2*volume.BlockSize + volume.BlockSize/2,
+		},
+	}, mock.UserUUID, uuid.Nil)
+
+	var blocks []*models.Block
+	for _, block := range file.Blocks {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Order < blocks[j].Order })
+
+	session := &dbo.UploadSession{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: file.GetUUID()},
+		UserUUID:               mock.UserUUID,
+		VolumeUUID:             volume.UUID,
+		Name:                   file.GetName(),
+		Size:                   file.GetSize(),
+		UpdatedAt:              time.Now().Add(-2 * constants.UPLOAD_SESSION_EXPIRATION),
+	}
+
+	// The first block is transferred, the disk of the last one was removed from the volume
+	sessionBlocks := make([]*dbo.UploadSessionBlock, len(blocks))
+	for i, block := range blocks {
+		sessionBlocks[i] = dbo.NewUploadSessionBlock(file.GetUUID(), block.UUID)
+		sessionBlocks[i].DiskUUID = block.Disk.GetUUID()
+		sessionBlocks[i].Size = block.Size
+		sessionBlocks[i].Order = block.Order
+	}
+	sessionBlocks[0].Status = constants.BLOCK_STATUS_TRANSFERRED
+	sessionBlocks[0].Checksum = "checksum"
+	sessionBlocks[0].RemoteID = "remote-id"
+	sessionBlocks[2].DiskUUID = uuid.New()
+
+	Convey("Upload session is saved along with its blocks", t, func() {
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `upload_sessions`")).WillReturnResult(sqlmock.NewResult(1, 1))
+		for range blocks {
+			mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `upload_session_blocks`")).WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		mock.DBMock.ExpectCommit()
+
+		So(models.SaveUploadSession(file, mock.UserUUID), ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Upload is resumed from the saved session", t, func() {
+		models.Transport.FileUploadQueue.RemoveEnqueuedInstance(file.GetUUID())
+		defer models.Transport.FileUploadQueue.RemoveEnqueuedInstance(file.GetUUID())
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `upload_sessions` WHERE uuid = ?")).WithArgs(file.GetUUID()).WillReturnRows(mock.UploadSessionRow(session))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `upload_session_blocks` WHERE session_uuid = ?")).WithArgs(file.GetUUID()).WillReturnRows(mock.UploadSessionBlockRow(sessionBlocks...))
+
+		resumedFile := models.Transport.GetUploadFile(file.GetUUID())
+		So(resumedFile, ShouldNotBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		So(models.Transport.GetUploadFile(file.GetUUID()), ShouldEqual, resumedFile)
+
+		So(resumedFile.GetName(), ShouldEqual, file.GetName())
+		So(resumedFile.GetSize(), ShouldEqual, file.GetSize())
+		So(resumedFile.Blocks, ShouldHaveLength, len(blocks))
+
+		transferredBlock := resumedFile.Blocks[blocks[0].UUID]
+		So(transferredBlock.Status, ShouldEqual, constants.BLOCK_STATUS_TRANSFERRED)
+		So(transferredBlock.Checksum, ShouldEqual, "checksum")
+		So(transferredBlock.RemoteID, ShouldEqual, "remote-id")
+		So(transferredBlock.Disk, ShouldEqual, blocks[0].Disk)
+
+		reassignedBlock := resumedFile.Blocks[blocks[2].UUID]
+		So(reassignedBlock.Status, ShouldEqual, constants.BLOCK_STATUS_QUEUED)
+		So(volume.GetDisk(reassignedBlock.Disk.GetUUID()), ShouldNotBeNil)
+
+		fr := responses.NewFileUploadStatusResponse(mock.UserUUID, resumedFile).Data.(*responses.FileRequestResponse)
+		So(fr.File.UUID, ShouldEqual, file.GetUUID())
+		So(fr.Blocks, ShouldHaveLength, len(blocks)-1)
+		for _, block := range fr.Blocks {
+			So(block.UUID, ShouldNotEqual, blocks[0].UUID)
+		}
+	})
+
+	Convey("Unknown upload cannot be resumed", t, func() {
+		fileUUID := uuid.New()
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `upload_sessions` WHERE uuid = ?")).WithArgs(fileUUID).WillReturnRows(mock.UploadSessionRow())
+
+		So(models.Transport.GetUploadFile(fileUUID), ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Abandoned upload is removed along with its transferred blocks", t, func() {
+		disk := disks[0]
+		if disk.GetUUID() != sessionBlocks[0].DiskUUID {
+			disk = disks[1]
+		}
+		disk.Blocks[sessionBlocks[0].UUID] = []uint8{1, 2, 3}
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `upload_sessions` WHERE updated_at < ?")).WillReturnRows(mock.UploadSessionRow(session))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `upload_session_blocks` WHERE session_uuid = ? AND status = ?")).WithArgs(file.GetUUID(), constants.BLOCK_STATUS_TRANSFERRED).WillReturnRows(mock.UploadSessionBlockRow(sessionBlocks[0]))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `files` WHERE uuid = ?")).WithArgs(file.GetUUID()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `upload_session_blocks` SET `status`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `upload_session_blocks` WHERE session_uuid = ?")).WithArgs(file.GetUUID()).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `upload_sessions` WHERE uuid = ?")).WithArgs(file.GetUUID()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+
+		models.RemoveExpiredUploadSessions()
+
+		So(disk.Blocks, ShouldNotContainKey, sessionBlocks[0].UUID)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+}
+
+func CreateVolumeWithDisks(number int) (*models.Volume, []*mock.MockDisk) {
+	models.RefreshPartitionerFunc = func(v *models.Volume) { v.RefreshPartitioner() }
+
+	volume := models.NewVolume(&dbo.Volume{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: uuid.New()},
+		Name:                   "Mock Upload Volume",
+		UserUUID:               mock.UserUUID,
+		VolumeSettings: dbo.VolumeSettings{
+			Backup:        constants.BACKUP_TYPE_NO_BACKUP,
+			Encryption:    constants.ENCRYPTION_TYPE_NO_ENCRYPTION,
+			FilePartition: constants.PARTITION_TYPE_BALANCED,
+		},
+	}, nil, nil)
+
+	disks := mock.GetStorageMockDisks(number)
+	for _, d := range disks {
+		d.SetTotalSpace(16 * uint64(volume.BlockSize))
+		d.SetVolume(volume)
+		volume.AddDisk(d.GetUUID(), d)
+	}
+	volume.RefreshPartitioner()
+
+	return volume, disks
+}
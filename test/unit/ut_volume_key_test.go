@@ -0,0 +1,398 @@
+package unit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	"dcfs/util/checksum"
+	_ "dcfs/util/logger"
+	"dcfs/util/sealing"
+	"encoding/base64"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestVolumeDataKeys(t *testing.T) {
+	volume, disks := CreateVolumeWithDisks(1)
+	volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
+
+	var firstKeyUUID uuid.UUID
+	var secondKeyUUID uuid.UUID
+
+	Convey("Generated data key becomes the current key of the volume", t, func() {
+		ExpectDataKeyGeneration(volume.UUID)
+
+		keyUUID, err := volume.GenerateDataKey()
+		So(err, ShouldBeNil)
+		So(keyUUID, ShouldNotEqual, uuid.Nil)
+		So(volume.GetKeyUUID(), ShouldEqual, keyUUID)
+		So(volume.GetVolumeDBO().KeyUUID, ShouldEqual, keyUUID)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+		firstKeyUUID = keyUUID
+	})
+
+	contents := []uint8("block contents encrypted with the data key of the volume")
+	block := append([]uint8(nil), contents...)
+	identity := models.BlockIdentity{UUID: uuid.New(), FileUUID: uuid.New(), Order: 1, FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND}
+
+	Convey("Blocks are encrypted with the current data key", t, func() {
+		keyUUID, err := volume.Encrypt(&block, identity)
+		So(err, ShouldBeNil)
+		So(keyUUID, ShouldEqual, firstKeyUUID)
+		So(block, ShouldNotResemble, contents)
+
+		decrypted := append([]uint8(nil), block...)
+		So(volume.Decrypt(&decrypted, keyUUID, identity), ShouldBeNil)
+		So(decrypted, ShouldResemble, contents)
+	})
+
+	Convey("Blocks encrypted with the previous key remain readable after a new key is generated", t, func() {
+		ExpectDataKeyGeneration(volume.UUID)
+
+		keyUUID, err := volume.GenerateDataKey()
+		So(err, ShouldBeNil)
+		So(keyUUID, ShouldNotEqual, firstKeyUUID)
+		secondKeyUUID = keyUUID
+
+		decrypted := append([]uint8(nil), block...)
+		So(volume.Decrypt(&decrypted, firstKeyUUID, identity), ShouldBeNil)
+		So(decrypted, ShouldResemble, contents)
+	})
+
+	Convey("Data key which is not cached is unwrapped from the db", t, func() {
+		key := make([]uint8, constants.VOLUME_DATA_KEY_SIZE)
+		rand.Read(key)
+		wrappedKey, err := sealing.Seal(base64.StdEncoding.EncodeToString(key))
+		So(err, ShouldBeNil)
+		So(wrappedKey, ShouldNotContainSubstring, base64.StdEncoding.EncodeToString(key))
+
+		volumeKey := dbo.NewVolumeKey(volume.UUID, wrappedKey)
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `volume_keys` WHERE uuid = ? AND volume_uuid = ?")).
+			WithArgs(volumeKey.UUID, volume.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid", "volume_uuid", "wrapped_key"}).AddRow(volumeKey.UUID, volume.UUID, wrappedKey))
+
+		legacyIdentity := identity
+		legacyIdentity.FormatVersion = constants.BLOCK_FORMAT_VERSION_LEGACY
+
+		encrypted := EncryptWithKey(key, contents)
+		So(volume.Decrypt(&encrypted, volumeKey.UUID, legacyIdentity), ShouldBeNil)
+		So(encrypted, ShouldResemble, contents)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Block is re-encrypted with the current data key", t, func() {
+		blockDBO := dbo.Block{
+			AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: identity.UUID},
+			VolumeUUID:             volume.UUID,
+			FileUUID:               identity.FileUUID,
+			Order:                  identity.Order,
+			DiskUUID:               disks[0].GetUUID(),
+			Size:                   len(contents),
+			Checksum:               checksum.CalculateChecksum(block),
+			KeyUUID:                firstKeyUUID,
+			FormatVersion:          constants.BLOCK_FORMAT_VERSION_BOUND,
+		}
+		disks[0].Blocks[blockDBO.UUID] = append([]uint8(nil), block...)
+
+		ExpectPendingReencryption(blockDBO.UUID, firstKeyUUID, secondKeyUUID, 1)
+		ExpectCompletedReencryption(blockDBO.UUID, firstKeyUUID, secondKeyUUID, 1)
+
+		So(models.ReencryptBlock(volume, blockDBO, secondKeyUUID), ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+		reencrypted := append([]uint8(nil), disks[0].Blocks[blockDBO.UUID]...)
+		So(reencrypted, ShouldNotResemble, block)
+		So(volume.Decrypt(&reencrypted, secondKeyUUID, identity), ShouldBeNil)
+		So(reencrypted, ShouldResemble, contents)
+
+		Convey("unless the block was removed in the meantime", func() {
+			disks[0].Blocks[blockDBO.UUID] = append([]uint8(nil), block...)
+
+			ExpectPendingReencryption(blockDBO.UUID, firstKeyUUID, secondKeyUUID, 0)
+
+			So(models.ReencryptBlock(volume, blockDBO, secondKeyUUID), ShouldNotBeNil)
+			So(disks[0].Blocks[blockDBO.UUID], ShouldResemble, block)
+			So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+			ExpectPendingReencryption(blockDBO.UUID, firstKeyUUID, secondKeyUUID, 1)
+			ExpectCompletedReencryption(blockDBO.UUID, firstKeyUUID, secondKeyUUID, 0)
+
+			So(models.ReencryptBlock(volume, blockDBO, secondKeyUUID), ShouldNotBeNil)
+			So(disks[0].Blocks, ShouldNotContainKey, blockDBO.UUID)
+			So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("and the block remains readable if the rotation is interrupted after the upload", func() {
+			disks[0].Blocks[blockDBO.UUID] = append([]uint8(nil), block...)
+
+			ExpectPendingReencryption(blockDBO.UUID, firstKeyUUID, secondKeyUUID, 1)
+			mock.DBMock.ExpectBegin()
+			mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `blocks` SET `checksum`=?,`format_version`=?,`key_uuid`=?,`pending_checksum`=?,`pending_key_uuid`=?,`remote_id`=? WHERE uuid = ? AND key_uuid = ?")).
+				WillReturnError(errors.New("connection lost"))
+			mock.DBMock.ExpectRollback()
+
+			So(models.ReencryptBlock(volume, blockDBO, secondKeyUUID), ShouldNotBeNil)
+			So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+			// The disk stores the re-encrypted block while the db still refers to the previous key
+			reencrypted := append([]uint8(nil), disks[0].Blocks[blockDBO.UUID]...)
+			So(reencrypted, ShouldNotResemble, block)
+
+			pendingDBO := blockDBO
+			pendingDBO.PendingKeyUUID = secondKeyUUID
+			pendingDBO.PendingChecksum = checksum.CalculateChecksum(reencrypted)
+
+			stored, valid := models.NewBlockFromDBO(&pendingDBO).GetStoredVersion(reencrypted)
+			So(valid, ShouldBeTrue)
+			So(stored.KeyUUID, ShouldEqual, secondKeyUUID)
+			So(volume.Decrypt(&reencrypted, stored.KeyUUID, stored.GetIdentity(pendingDBO.FileUUID)), ShouldBeNil)
+			So(reencrypted, ShouldResemble, contents)
+
+			So(models.ScrubBlock(volume, pendingDBO).Code, ShouldEqual, constants.SUCCESS)
+
+			_, valid = models.NewBlockFromDBO(&blockDBO).GetStoredVersion(disks[0].Blocks[blockDBO.UUID])
+			So(valid, ShouldBeFalse)
+
+			Convey("and the resumed rotation switches the block to the new key without uploading it again", func() {
+				uploaded := append([]uint8(nil), disks[0].Blocks[blockDBO.UUID]...)
+
+				mock.DBMock.ExpectBegin()
+				mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `blocks` SET `checksum`=?,`format_version`=?,`key_uuid`=?,`pending_checksum`=?,`pending_key_uuid`=?,`remote_id`=? WHERE uuid = ? AND key_uuid = ?")).
+					WithArgs(pendingDBO.PendingChecksum, constants.BLOCK_FORMAT_VERSION_BOUND, secondKeyUUID, "", uuid.Nil, "", blockDBO.UUID, firstKeyUUID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.DBMock.ExpectCommit()
+
+				So(models.ReencryptBlock(volume, pendingDBO, secondKeyUUID), ShouldBeNil)
+				So(disks[0].Blocks[blockDBO.UUID], ShouldResemble, uploaded)
+				So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+			})
+		})
+
+		Convey("and the block encrypted in the legacy format is recorded in the current one", func() {
+			legacyDBO := blockDBO
+			legacyDBO.KeyUUID = uuid.Nil
+			legacyDBO.FormatVersion = constants.BLOCK_FORMAT_VERSION_LEGACY
+
+			key, err := os.ReadFile(constants.LEGACY_ENCRYPTION_KEY_PATH)
+			So(err, ShouldBeNil)
+			disks[0].Blocks[legacyDBO.UUID] = EncryptWithKey(key, contents)
+			legacyDBO.Checksum = checksum.CalculateChecksum(disks[0].Blocks[legacyDBO.UUID])
+
+			ExpectPendingReencryption(legacyDBO.UUID, uuid.Nil, secondKeyUUID, 1)
+			ExpectCompletedReencryption(legacyDBO.UUID, uuid.Nil, secondKeyUUID, 1)
+
+			So(models.ReencryptBlock(volume, legacyDBO, secondKeyUUID), ShouldBeNil)
+			So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+			reencrypted := append([]uint8(nil), disks[0].Blocks[legacyDBO.UUID]...)
+			So(volume.Decrypt(&reencrypted, secondKeyUUID, identity), ShouldBeNil)
+			So(reencrypted, ShouldResemble, contents)
+		})
+
+		Convey("unless the block is corrupted", func() {
+			disks[0].Blocks[blockDBO.UUID] = []uint8("corrupted")
+
+			So(models.ReencryptBlock(volume, blockDBO, secondKeyUUID), ShouldNotBeNil)
+			So(disks[0].Blocks[blockDBO.UUID], ShouldResemble, []uint8("corrupted"))
+		})
+	})
+
+	Convey("Rotation removes only the unused data keys of the volume from the cache", t, func() {
+		otherVolume, _ := CreateVolumeWithDisks(1)
+		otherVolume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
+		ExpectDataKeyGeneration(otherVolume.UUID)
+		_, err := otherVolume.GenerateDataKey()
+		So(err, ShouldBeNil)
+
+		otherBlock := append([]uint8(nil), contents...)
+		otherKeyUUID, err := otherVolume.Encrypt(&otherBlock, identity)
+		So(err, ShouldBeNil)
+
+		usedBlock := append([]uint8(nil), contents...)
+		usedKeyUUID, err := volume.Encrypt(&usedBlock, identity)
+		So(err, ShouldBeNil)
+		So(usedKeyUUID, ShouldEqual, secondKeyUUID)
+
+		ExpectDataKeyGeneration(volume.UUID)
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `blocks` WHERE volume_uuid = ? AND key_uuid <> ?")).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT `uuid` FROM `volume_keys` WHERE (volume_uuid = ? AND uuid <> ?)")).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(firstKeyUUID).AddRow(secondKeyUUID))
+		for keyUUID, rows := range map[uuid.UUID]int64{firstKeyUUID: 1, secondKeyUUID: 0} {
+			mock.DBMock.ExpectBegin()
+			mock.DBMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `volume_keys` WHERE (volume_uuid = ? AND uuid <> ?)")).
+				WithArgs(volume.UUID, sqlmock.AnyArg(), volume.UUID, keyUUID).
+				WillReturnResult(sqlmock.NewResult(0, rows))
+			mock.DBMock.ExpectCommit()
+		}
+
+		_, errCode := models.StartKeyRotation(volume)
+		So(errCode, ShouldEqual, constants.SUCCESS)
+		for i := 0; i < 100 && models.IsKeyRotationInProgress(volume.UUID); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(models.IsKeyRotationInProgress(volume.UUID), ShouldBeFalse)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+		// Keys which are still cached are used without querying the db
+		So(otherVolume.Decrypt(&otherBlock, otherKeyUUID, identity), ShouldBeNil)
+		So(otherBlock, ShouldResemble, contents)
+		So(volume.Decrypt(&usedBlock, usedKeyUUID, identity), ShouldBeNil)
+		So(usedBlock, ShouldResemble, contents)
+
+		mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `volume_keys` WHERE uuid = ? AND volume_uuid = ?")).
+			WithArgs(firstKeyUUID, volume.UUID).
+			WillReturnRows(sqlmock.NewRows([]string{"uuid"}))
+		removedBlock := append([]uint8(nil), block...)
+		So(volume.Decrypt(&removedBlock, firstKeyUUID, identity), ShouldNotBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+	})
+
+	Convey("Key of the volume without encryption cannot be rotated", t, func() {
+		volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_NO_ENCRYPTION
+		defer func() { volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256 }()
+
+		_, errCode := models.StartKeyRotation(volume)
+		So(errCode, ShouldEqual, constants.OPERATION_NOT_SUPPORTED)
+		So(models.IsKeyRotationInProgress(volume.UUID), ShouldBeFalse)
+	})
+}
+
+// ExpectDataKeyGeneration - expect the data key of the volume to be saved in the db
+func ExpectDataKeyGeneration(volumeUUID uuid.UUID) {
+	mock.DBMock.ExpectBegin()
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `volume_keys`")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `volumes` SET `key_uuid`=? WHERE uuid = ?")).
+		WithArgs(sqlmock.AnyArg(), volumeUUID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.DBMock.ExpectCommit()
+}
+
+// ExpectPendingReencryption - expect the re-encrypted version of the block to be recorded in the db
+func ExpectPendingReencryption(blockUUID uuid.UUID, previousKeyUUID uuid.UUID, keyUUID uuid.UUID, rowsAffected int64) {
+	mock.DBMock.ExpectBegin()
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `blocks` SET `pending_checksum`=?,`pending_key_uuid`=? WHERE uuid = ? AND key_uuid = ?")).
+		WithArgs(sqlmock.AnyArg(), keyUUID, blockUUID, previousKeyUUID).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+	mock.DBMock.ExpectCommit()
+}
+
+// ExpectCompletedReencryption - expect the block to be switched to the new data key in the db
+func ExpectCompletedReencryption(blockUUID uuid.UUID, previousKeyUUID uuid.UUID, keyUUID uuid.UUID, rowsAffected int64) {
+	mock.DBMock.ExpectBegin()
+	mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `blocks` SET `checksum`=?,`format_version`=?,`key_uuid`=?,`pending_checksum`=?,`pending_key_uuid`=?,`remote_id`=? WHERE uuid = ? AND key_uuid = ?")).
+		WithArgs(sqlmock.AnyArg(), constants.BLOCK_FORMAT_VERSION_BOUND, keyUUID, "", uuid.Nil, "", blockUUID, previousKeyUUID).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+	mock.DBMock.ExpectCommit()
+}
+
+// EncryptWithKey - encrypt the contents with AES-GCM the way the volume does
+func EncryptWithKey(key []uint8, contents []uint8) []uint8 {
+	cb, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(cb)
+
+	nonce := make([]uint8, constants.VOLUME_NONCE_SIZE)
+	rand.Read(nonce)
+
+	return gcm.Seal(nonce, nonce, contents, nil)
+}
+
+func TestBlockIdentityBinding(t *testing.T) {
+	volume := MockNewVolume(*mock.VolumeDBO, nil, true)
+	volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
+
+	contents := []uint8("block contents bound to the identity of the block")
+	identity := models.BlockIdentity{UUID: uuid.New(), FileUUID: uuid.New(), Order: 3, FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND}
+
+	block := append([]uint8(nil), contents...)
+	_, err := volume.Encrypt(&block, identity)
+
+	Convey("Encrypted block starts with the block format header", t, func() {
+		So(err, ShouldBeNil)
+		So(len(block), ShouldEqual, len(contents)+constants.VOLUME_CIPHER_OVERHEAD)
+		So(string(block[:len(constants.BLOCK_HEADER_MAGIC)]), ShouldEqual, constants.BLOCK_HEADER_MAGIC)
+		So(block[len(constants.BLOCK_HEADER_MAGIC)], ShouldEqual, constants.BLOCK_FORMAT_VERSION_BOUND)
+	})
+
+	Convey("Block is decrypted with its own identity", t, func() {
+		decrypted := append([]uint8(nil), block...)
+		So(volume.Decrypt(&decrypted, uuid.Nil, identity), ShouldBeNil)
+		So(decrypted, ShouldResemble, contents)
+	})
+
+	Convey("Block cannot be decrypted with the identity of another block", t, func() {
+		identities := []models.BlockIdentity{
+			{UUID: uuid.New(), FileUUID: identity.FileUUID, Order: identity.Order, FormatVersion: identity.FormatVersion},
+			{UUID: identity.UUID, FileUUID: uuid.New(), Order: identity.Order, FormatVersion: identity.FormatVersion},
+			{UUID: identity.UUID, FileUUID: identity.FileUUID, Order: identity.Order + 1, FormatVersion: identity.FormatVersion},
+		}
+
+		for _, _identity := range identities {
+			decrypted := append([]uint8(nil), block...)
+			So(volume.Decrypt(&decrypted, uuid.Nil, _identity), ShouldNotBeNil)
+			So(decrypted, ShouldResemble, block)
+		}
+	})
+
+	Convey("Block cannot be moved to another volume", t, func() {
+		volumeDBO := *mock.VolumeDBO
+		volumeDBO.UUID = uuid.New()
+		other := MockNewVolume(volumeDBO, nil, true)
+		other.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
+
+		decrypted := append([]uint8(nil), block...)
+		So(other.Decrypt(&decrypted, uuid.Nil, identity), ShouldNotBeNil)
+	})
+
+	Convey("Block with a tampered header cannot be decrypted", t, func() {
+		tampered := append([]uint8(nil), block...)
+		tampered[len(constants.BLOCK_HEADER_MAGIC)]++
+
+		So(volume.Decrypt(&tampered, uuid.Nil, identity), ShouldNotBeNil)
+	})
+
+	Convey("Block encrypted in the legacy format remains readable", t, func() {
+		key, err := os.ReadFile(constants.LEGACY_ENCRYPTION_KEY_PATH)
+		So(err, ShouldBeNil)
+
+		legacyIdentity := identity
+		legacyIdentity.FormatVersion = constants.BLOCK_FORMAT_VERSION_LEGACY
+
+		legacy := EncryptWithKey(key, contents)
+		So(volume.Decrypt(&legacy, uuid.Nil, legacyIdentity), ShouldBeNil)
+		So(legacy, ShouldResemble, contents)
+	})
+
+	Convey("Block recorded in the current format cannot be replaced with a legacy one", t, func() {
+		key, err := os.ReadFile(constants.LEGACY_ENCRYPTION_KEY_PATH)
+		So(err, ShouldBeNil)
+
+		legacy := EncryptWithKey(key, contents)
+		So(volume.Decrypt(&legacy, uuid.Nil, identity), ShouldNotBeNil)
+
+		// Header of the block does not make the legacy ciphertext readable
+		headered := append(append([]uint8(nil), block[:constants.VOLUME_BLOCK_HEADER_SIZE]...), EncryptWithKey(key, contents)...)
+		So(volume.Decrypt(&headered, uuid.Nil, identity), ShouldNotBeNil)
+	})
+
+	Convey("Block recorded in the legacy format is not decrypted in the current format", t, func() {
+		legacyIdentity := identity
+		legacyIdentity.FormatVersion = constants.BLOCK_FORMAT_VERSION_LEGACY
+
+		decrypted := append([]uint8(nil), block...)
+		So(volume.Decrypt(&decrypted, uuid.Nil, legacyIdentity), ShouldNotBeNil)
+		So(decrypted, ShouldResemble, block)
+	})
+}
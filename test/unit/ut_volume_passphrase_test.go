@@ -0,0 +1,124 @@
+package unit
+
+import (
+	"dcfs/constants"
+	"dcfs/db/dbo"
+	"dcfs/models"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"regexp"
+	"testing"
+)
+
+func TestVolumePassphrase(t *testing.T) {
+	const passphrase = "correct horse battery staple"
+
+	volumeDBO := &dbo.Volume{
+		AbstractDatabaseObject: dbo.AbstractDatabaseObject{UUID: uuid.New()},
+		Name:                   "Mock Passphrase Volume",
+		UserUUID:               mock.UserUUID,
+		VolumeSettings: dbo.VolumeSettings{
+			Backup:        constants.BACKUP_TYPE_NO_BACKUP,
+			Encryption:    constants.ENCRYPTION_TYPE_PASSPHRASE,
+			FilePartition: constants.PARTITION_TYPE_BALANCED,
+		},
+	}
+	volume := models.NewVolume(volumeDBO, nil, nil)
+
+	contents := []uint8("block contents encrypted with the key derived from the passphrase")
+	block := append([]uint8(nil), contents...)
+	identity := models.BlockIdentity{UUID: uuid.New(), FileUUID: uuid.New(), FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND}
+	var salt mock.CapturedArgument
+	var wrappedKey mock.CapturedArgument
+
+	Convey("Volume encrypted with a passphrase is locked until the passphrase is set", t, func() {
+		So(volume.IsLocked(), ShouldBeTrue)
+
+		_, err := volume.Encrypt(&block, identity)
+		So(err, ShouldEqual, models.ErrVolumeLocked)
+		So(block, ShouldResemble, contents)
+	})
+
+	Convey("Only the salt of the passphrase is saved in the db", t, func() {
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `volumes` SET `passphrase_salt`=? WHERE uuid = ?")).
+			WithArgs(&salt, volume.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+
+		So(volume.SetPassphrase(passphrase), ShouldBeNil)
+		So(volume.IsLocked(), ShouldBeFalse)
+		So(salt.Value, ShouldNotBeEmpty)
+		So(salt.Value, ShouldNotContainSubstring, passphrase)
+		So(volume.GetVolumeDBO().PassphraseSalt, ShouldEqual, salt.Value)
+
+		mock.DBMock.ExpectBegin()
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("INSERT INTO `volume_keys`")).
+			WithArgs(sqlmock.AnyArg(), volume.UUID, &wrappedKey, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.DBMock.ExpectExec(regexp.QuoteMeta("UPDATE `volumes` SET `key_uuid`=? WHERE uuid = ?")).
+			WithArgs(sqlmock.AnyArg(), volume.UUID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.DBMock.ExpectCommit()
+
+		_, err := volume.GenerateDataKey()
+		So(err, ShouldBeNil)
+		So(mock.DBMock.ExpectationsWereMet(), ShouldBeNil)
+
+		_, err = volume.Encrypt(&block, identity)
+		So(err, ShouldBeNil)
+		So(block, ShouldNotResemble, contents)
+	})
+
+	Convey("Volume loaded from the db is locked", t, func() {
+		volumeDBO.KeyUUID = volume.GetKeyUUID()
+		volumeDBO.PassphraseSalt = salt.Value.(string)
+		reloaded := models.NewVolume(volumeDBO, nil, nil)
+		So(reloaded.IsLocked(), ShouldBeTrue)
+
+		decrypted := append([]uint8(nil), block...)
+		So(reloaded.Decrypt(&decrypted, volumeDBO.KeyUUID, identity), ShouldEqual, models.ErrVolumeLocked)
+
+		Convey("and cannot be unlocked with an invalid passphrase", func() {
+			ExpectVolumeKeyQuery(volumeDBO, wrappedKey.Value.(string))
+
+			So(reloaded.Unlock("invalid passphrase"), ShouldEqual, models.ErrInvalidPassphrase)
+			So(reloaded.IsLocked(), ShouldBeTrue)
+		})
+
+		Convey("but can be unlocked with the passphrase", func() {
+			ExpectVolumeKeyQuery(volumeDBO, wrappedKey.Value.(string))
+
+			So(reloaded.Unlock(passphrase), ShouldBeNil)
+			So(reloaded.IsLocked(), ShouldBeFalse)
+			So(reloaded.Decrypt(&decrypted, volumeDBO.KeyUUID, identity), ShouldBeNil)
+			So(decrypted, ShouldResemble, contents)
+
+			Convey("and locked again", func() {
+				reloaded.Lock()
+				So(reloaded.IsLocked(), ShouldBeTrue)
+
+				encrypted := append([]uint8(nil), contents...)
+				_, err := reloaded.Encrypt(&encrypted, identity)
+				So(err, ShouldEqual, models.ErrVolumeLocked)
+			})
+		})
+	})
+
+	Convey("Key of the locked volume cannot be rotated", t, func() {
+		volume.Lock()
+
+		_, errCode := models.StartKeyRotation(volume)
+		So(errCode, ShouldEqual, constants.TRANSPORT_VOLUME_LOCKED)
+	})
+}
+
+// ExpectVolumeKeyQuery - expect the data key of the volume to be retrieved from the db
+func ExpectVolumeKeyQuery(volume *dbo.Volume, wrappedKey string) {
+	mock.DBMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `volume_keys` WHERE uuid = ? AND volume_uuid = ?")).
+		WithArgs(volume.KeyUUID, volume.UUID).
+		WillReturnRows(sqlmock.NewRows([]string{"uuid", "volume_uuid", "wrapped_key"}).AddRow(volume.KeyUUID, volume.UUID, wrappedKey))
+}
@@ -192,7 +192,7 @@ func TestEncrypt(t *testing.T) {
 	}
 
 	Convey("The block should not be encrypted when the encryption option is off", t, func() {
-		err := volume.Encrypt(&block)
+		_, err := volume.Encrypt(&block, models.BlockIdentity{FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND})
 		Convey("The error should be nil", func() {
 			So(err, ShouldEqual, nil)
 		})
@@ -211,7 +211,7 @@ func TestEncrypt(t *testing.T) {
 
 	volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
 	Convey("The block should be encrypted when the encryption option is on", t, func() {
-		err := volume.Encrypt(&block)
+		_, err := volume.Encrypt(&block, models.BlockIdentity{FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND})
 		So(err, ShouldEqual, nil)
 
 		identical := true
@@ -224,7 +224,7 @@ func TestEncrypt(t *testing.T) {
 
 		So(identical, ShouldEqual, false)
 
-		_ = volume.Decrypt(&block)
+		_ = volume.Decrypt(&block, uuid.Nil, models.BlockIdentity{FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND})
 		identical = true
 
 		for i := 0; i < 1024; i++ {
@@ -254,12 +254,12 @@ func TestDecrypt(t *testing.T) {
 	volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
 
 	// encrypt the block
-	_ = volume.Encrypt(&block)
+	_, _ = volume.Encrypt(&block, models.BlockIdentity{FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND})
 
 	volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_NO_ENCRYPTION
 
 	Convey("The block should not be decrypted if the encryption setting is of", t, func() {
-		err := volume.Decrypt(&block)
+		err := volume.Decrypt(&block, uuid.Nil, models.BlockIdentity{FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND})
 		Convey("The returned error should be nil", func() {
 			So(err, ShouldEqual, nil)
 		})
@@ -279,7 +279,7 @@ func TestDecrypt(t *testing.T) {
 	volume.VolumeSettings.Encryption = constants.ENCRYPTION_TYPE_AES_256
 
 	Convey("The block should be successfully decrypted if the encryption setting is on", t, func() {
-		err := volume.Decrypt(&block)
+		err := volume.Decrypt(&block, uuid.Nil, models.BlockIdentity{FormatVersion: constants.BLOCK_FORMAT_VERSION_BOUND})
 		So(err, ShouldEqual, nil)
 
 		identical := true
@@ -0,0 +1,58 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"dcfs/constants"
+	"dcfs/models/disk/WebDAVDisk"
+	"dcfs/test/unit/mock"
+	_ "dcfs/util/logger"
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestWebDAVDisk(t *testing.T) {
+	for _, useDigest := range []bool{false, true} {
+		server := mock.NewMockWebDAVServer(useDigest, 1024*1024)
+		defer server.Server.Close()
+
+		disk := WebDAVDisk.NewWebDAVDisk()
+		disk.SetUUID(uuid.New())
+		disk.CreateCredentials(server.WebDAVCredentials(mock.WebDAVPassword))
+
+		blockMetadata, contents := mock.GetBlockMetadata(1024)
+
+		Convey("Disk is ready when the base path is accessible", t, func() {
+			So(disk.GetReadiness().IsReadyForce(context.Background()), ShouldBeTrue)
+		})
+
+		Convey("Block is uploaded under the base path", t, func() {
+			So(disk.Upload(blockMetadata), ShouldBeNil)
+			So(bytes.Equal(server.Files["/dav/dcfs/"+blockMetadata.UUID.String()], contents), ShouldBeTrue)
+
+			_blockMetadata := mock.CopyBlockMetadata(blockMetadata)
+			So(disk.Download(_blockMetadata), ShouldBeNil)
+			So(bytes.Equal(*_blockMetadata.Content, contents), ShouldBeTrue)
+		})
+
+		Convey("Provider space is read from the quota properties", t, func() {
+			usedSpace, totalSpace, errCode := disk.GetProviderSpace()
+			So(errCode, ShouldEqual, constants.SUCCESS)
+			So(usedSpace, ShouldEqual, 1024)
+			So(totalSpace, ShouldEqual, 1024*1024)
+		})
+
+		Convey("Removed block cannot be downloaded", t, func() {
+			So(disk.Remove(mock.CopyBlockMetadata(blockMetadata)), ShouldBeNil)
+			So(disk.Download(mock.CopyBlockMetadata(blockMetadata)).Code, ShouldEqual, constants.REMOTE_BAD_FILE)
+		})
+
+		Convey("Disk is not ready with invalid credentials", t, func() {
+			_disk := WebDAVDisk.NewWebDAVDisk()
+			_disk.SetUUID(uuid.New())
+			_disk.CreateCredentials(server.WebDAVCredentials("invalid"))
+			So(_disk.GetReadiness().IsReadyForce(context.Background()), ShouldBeFalse)
+		})
+	}
+}
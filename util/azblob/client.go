@@ -0,0 +1,266 @@
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const apiVersion string = "2020-10-02"
+
+// Client - minimal client of the Azure Blob Storage container
+//
+// Requests are authorized either with the Shared Key of the storage
+// account or with the SAS token appended to each request.
+type Client struct {
+	ServiceURL  *url.URL
+	Container   string
+	AccountName string
+	AccountKey  string
+	SASToken    string
+	HTTPClient  *http.Client
+}
+
+// ResponseError - error returned by the Blob service
+type ResponseError struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+func (e *ResponseError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("Blob request failed with status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("Blob request failed with status %d: %s: %s", e.StatusCode, e.Code, strings.TrimSpace(e.Message))
+}
+
+// IsNotFound - check whether the error indicates a missing container or blob
+//
+// params:
+//   - err error: error to check
+//
+// return type:
+//   - bool: true if the container or blob does not exist
+func IsNotFound(err error) bool {
+	var responseError *ResponseError
+	return errors.As(err, &responseError) && responseError.StatusCode == http.StatusNotFound
+}
+
+// GetContainerProperties - check whether the container exists and is accessible
+//
+// params:
+//   - ctx context.Context: request context
+//
+// return type:
+//   - error: nil if the container is accessible
+func (c *Client) GetContainerProperties(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodHead, "", url.Values{"restype": {"container"}}, nil, nil)
+	return err
+}
+
+// PutBlob - upload the block blob in a single request
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: blob name
+//   - content []byte: blob content
+//
+// return type:
+//   - error: nil if the blob was uploaded
+func (c *Client) PutBlob(ctx context.Context, name string, content []byte) error {
+	_, err := c.do(ctx, http.MethodPut, name, nil, map[string]string{
+		"x-ms-blob-type": "BlockBlob",
+		"Content-Type":   "application/octet-stream",
+	}, content)
+	return err
+}
+
+// PutBlock - stage the block of the block blob
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: blob name
+//   - blockID string: base64 encoded block ID, all IDs of the blob must have the same length
+//   - content []byte: block content
+//
+// return type:
+//   - error: nil if the block was staged
+func (c *Client) PutBlock(ctx context.Context, name string, blockID string, content []byte) error {
+	_, err := c.do(ctx, http.MethodPut, name, url.Values{"comp": {"block"}, "blockid": {blockID}}, map[string]string{
+		"Content-Type": "application/octet-stream",
+	}, content)
+	return err
+}
+
+// PutBlockList - commit the staged blocks as the block blob
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: blob name
+//   - blockIDs []string: IDs of the staged blocks in order
+//
+// return type:
+//   - error: nil if the blob was committed
+func (c *Client) PutBlockList(ctx context.Context, name string, blockIDs []string) error {
+	content, err := xml.Marshal(blockList{Latest: blockIDs})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(ctx, http.MethodPut, name, url.Values{"comp": {"blocklist"}}, map[string]string{
+		"Content-Type": "application/xml",
+	}, append([]byte(xml.Header), content...))
+	return err
+}
+
+// GetBlob - download the blob
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: blob name
+//
+// return type:
+//   - []byte: blob content
+//   - error: nil if the blob was downloaded
+func (c *Client) GetBlob(ctx context.Context, name string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, name, nil, nil, nil)
+}
+
+// DeleteBlob - delete the blob
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: blob name
+//
+// return type:
+//   - error: nil if the blob was deleted
+func (c *Client) DeleteBlob(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, name, nil, nil, nil)
+	return err
+}
+
+// BlockID - get block ID of the block with the given index
+//
+// params:
+//   - index int: index of the block in the blob
+//
+// return type:
+//   - string: base64 encoded block ID of a fixed length
+func BlockID(index int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", index)))
+}
+
+// do - send the authorized request and read the response body
+func (c *Client) do(ctx context.Context, method string, name string, query url.Values, headers map[string]string, content []byte) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	u := *c.ServiceURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.Container
+	if name != "" {
+		u.Path += "/" + name
+	}
+
+	rawQuery := query.Encode()
+	if c.SASToken != "" {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += c.SASToken
+	}
+	u.RawQuery = rawQuery
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(content))
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", apiVersion)
+
+	// Requests with the SAS token are already authorized
+	if c.SASToken == "" {
+		if err = SignRequest(req, c.AccountName, c.AccountKey); err != nil {
+			return nil, err
+		}
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		responseError := &ResponseError{StatusCode: res.StatusCode, Code: res.Header.Get("x-ms-error-code")}
+		_ = xml.Unmarshal(body, responseError)
+		return nil, responseError
+	}
+
+	return body, nil
+}
+
+// NewClient - create new client of the container
+//
+// params:
+//   - cs *ConnectionString: endpoint and credentials of the storage account
+//   - container string: name of the container
+//
+// return type:
+//   - *Client: created client
+//   - error: error if the blob endpoint is not a valid URL
+func NewClient(cs *ConnectionString, container string) (*Client, error) {
+	u, err := url.Parse(cs.ServiceURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("blob endpoint must be an absolute HTTP(S) URL")
+	}
+
+	// The query of the service SAS URL is the token
+	sasToken := cs.SASToken
+	if sasToken == "" && u.RawQuery != "" {
+		sasToken = u.RawQuery
+	}
+	u.RawQuery = ""
+
+	return &Client{
+		ServiceURL:  u,
+		Container:   container,
+		AccountName: cs.AccountName,
+		AccountKey:  cs.AccountKey,
+		SASToken:    sasToken,
+		HTTPClient:  &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
@@ -0,0 +1,73 @@
+package azblob
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	developmentAccountName string = "devstoreaccount1"
+	developmentAccountKey  string = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	developmentBlobURL     string = "http://127.0.0.1:10000/devstoreaccount1"
+)
+
+// ConnectionString - parsed Azure Storage connection string
+type ConnectionString struct {
+	ServiceURL  string
+	AccountName string
+	AccountKey  string
+	SASToken    string
+}
+
+// ParseConnectionString - parse the Azure Storage connection string
+//
+// The development storage shortcut (UseDevelopmentStorage=true) points
+// to the default Azurite endpoint and account.
+//
+// params:
+//   - s string: connection string
+//
+// return type:
+//   - *ConnectionString: parsed connection string
+//   - error: error if the blob endpoint or the credentials cannot be determined
+func ParseConnectionString(s string) (*ConnectionString, error) {
+	values := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			values[strings.ToLower(key)] = value
+		}
+	}
+
+	if strings.EqualFold(values["usedevelopmentstorage"], "true") {
+		return &ConnectionString{ServiceURL: developmentBlobURL, AccountName: developmentAccountName, AccountKey: developmentAccountKey}, nil
+	}
+
+	cs := &ConnectionString{
+		ServiceURL:  values["blobendpoint"],
+		AccountName: values["accountname"],
+		AccountKey:  values["accountkey"],
+		SASToken:    strings.TrimPrefix(values["sharedaccesssignature"], "?"),
+	}
+
+	if cs.ServiceURL == "" && cs.AccountName != "" {
+		protocol, suffix := values["defaultendpointsprotocol"], values["endpointsuffix"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		if suffix == "" {
+			suffix = "core.windows.net"
+		}
+		cs.ServiceURL = fmt.Sprintf("%s://%s.blob.%s", protocol, cs.AccountName, suffix)
+	}
+
+	if cs.ServiceURL == "" {
+		return nil, errors.New("connection string does not specify the blob endpoint")
+	}
+	if cs.SASToken == "" && (cs.AccountName == "" || cs.AccountKey == "") {
+		return nil, errors.New("connection string does not contain the account key or the shared access signature")
+	}
+
+	return cs, nil
+}
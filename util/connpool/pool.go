@@ -0,0 +1,176 @@
+package connpool
+
+import (
+	"context"
+	"dcfs/constants"
+	"sync"
+	"time"
+)
+
+// MaxConnections - maximum number of connections opened by a single pool
+var MaxConnections int = constants.CONNECTION_POOL_MAX_CONNECTIONS
+
+// IdleTimeout - time after which an unused connection is closed
+var IdleTimeout time.Duration = constants.CONNECTION_IDLE_TIMEOUT
+
+// Connection - authenticated client connection which can be reused
+type Connection interface {
+	// IsAlive - check if the connection can still be used, called before an idle connection is reused
+	IsAlive() bool
+	Close() error
+}
+
+type idleConnection struct {
+	connection Connection
+	timer      *time.Timer
+}
+
+// Pool - pool of authenticated connections to a single remote server
+type Pool struct {
+	connect func(ctx context.Context) (Connection, string)
+
+	// Every connection handed out by the pool holds a slot until it is returned or discarded
+	slots chan struct{}
+
+	idle        []*idleConnection
+	idleTimeout time.Duration
+	closed      bool
+	mtx         sync.Mutex
+}
+
+// Get - get an idle connection or open a new one, waits if the pool is exhausted
+//
+// params:
+//   - ctx context.Context: context used while waiting and connecting
+//
+// return type:
+//   - Connection: connection, nil if it could not be established
+//   - string: constants.SUCCESS, constants.REMOTE_CLIENT_UNAVAILABLE if the context was cancelled
+//     while waiting, otherwise the completion code returned by the connect function
+func (p *Pool) Get(ctx context.Context) (Connection, string) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, constants.REMOTE_CLIENT_UNAVAILABLE
+	}
+
+	// Reuse the most recently returned connection which is still alive
+	for connection := p.popIdle(); connection != nil; connection = p.popIdle() {
+		if connection.IsAlive() {
+			return connection, constants.SUCCESS
+		}
+		_ = connection.Close()
+	}
+
+	connection, errCode := p.connect(ctx)
+	if connection == nil {
+		<-p.slots
+		return nil, errCode
+	}
+
+	return connection, constants.SUCCESS
+}
+
+// Put - return a working connection to the pool
+//
+// params:
+//   - connection Connection: connection received from Get
+func (p *Pool) Put(connection Connection) {
+	p.mtx.Lock()
+	if p.closed {
+		_ = connection.Close()
+	} else {
+		entry := &idleConnection{connection: connection}
+		entry.timer = time.AfterFunc(p.idleTimeout, func() { p.expire(entry) })
+		p.idle = append(p.idle, entry)
+	}
+	p.mtx.Unlock()
+
+	<-p.slots
+}
+
+// Discard - close a broken connection received from Get
+//
+// params:
+//   - connection Connection: connection received from Get
+func (p *Pool) Discard(connection Connection) {
+	_ = connection.Close()
+	<-p.slots
+}
+
+// Close - close all idle connections, connections in use are closed when they are returned
+func (p *Pool) Close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.closed = true
+	for _, entry := range p.idle {
+		entry.timer.Stop()
+		_ = entry.connection.Close()
+	}
+	p.idle = nil
+}
+
+// GetIdleCount - get number of idle connections
+func (p *Pool) GetIdleCount() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.idle)
+}
+
+// GetActiveCount - get number of connections in use
+func (p *Pool) GetActiveCount() int {
+	return len(p.slots)
+}
+
+func (p *Pool) popIdle() Connection {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(p.idle) == 0 {
+		return nil
+	}
+
+	entry := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	entry.timer.Stop()
+
+	return entry.connection
+}
+
+// expire - close the connection which stayed idle for too long
+func (p *Pool) expire(entry *idleConnection) {
+	p.mtx.Lock()
+	for i, _entry := range p.idle {
+		if _entry == entry {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			p.mtx.Unlock()
+
+			_ = entry.connection.Close()
+			return
+		}
+	}
+	p.mtx.Unlock()
+}
+
+// NewPool - create new connection pool using the global limits
+//
+// params:
+//   - connect func(ctx context.Context) (Connection, string): opens and authenticates a new connection,
+//     returns nil and the completion code on failure
+//
+// return type:
+//   - *Pool: created pool
+func NewPool(connect func(ctx context.Context) (Connection, string)) *Pool {
+	maxConnections := MaxConnections
+	if maxConnections < 1 {
+		maxConnections = 1
+	}
+
+	return &Pool{
+		connect:     connect,
+		slots:       make(chan struct{}, maxConnections),
+		idleTimeout: IdleTimeout,
+	}
+}
@@ -0,0 +1,102 @@
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalid - the Range header is malformed or requests multiple ranges, it should be ignored
+	ErrInvalid = errors.New("invalid range")
+	// ErrUnsatisfiable - the requested range does not overlap the resource
+	ErrUnsatisfiable = errors.New("range not satisfiable")
+)
+
+// Range - byte range of the resource, both offsets are inclusive
+type Range struct {
+	Start int64
+	End   int64
+}
+
+// Length - get the number of bytes in the range
+//
+// return type:
+//   - int64: length of the range
+func (r Range) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange - generate the value of the Content-Range header for the range
+//
+// params:
+//   - size int64: size of the whole resource
+//
+// return type:
+//   - string: value of the Content-Range header
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// Parse - parse the single byte range from the value of the Range header
+//
+// Supported forms are "bytes=start-end", "bytes=start-" and "bytes=-suffix".
+// The end of the range is clamped to the size of the resource.
+//
+// params:
+//   - header string: value of the Range header
+//   - size int64: size of the resource
+//
+// return type:
+//   - Range: requested range
+//   - error: ErrInvalid if the header should be ignored, ErrUnsatisfiable if the range is outside the resource
+func Parse(header string, size int64) (Range, error) {
+	header = strings.TrimSpace(header)
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return Range{}, ErrInvalid
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(strings.TrimPrefix(header, "bytes=")), "-")
+	if !found {
+		return Range{}, ErrInvalid
+	}
+
+	// Suffix range: the last bytes of the resource
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix < 0 {
+			return Range{}, ErrInvalid
+		}
+		if suffix == 0 || size == 0 {
+			return Range{}, ErrUnsatisfiable
+		}
+		if suffix > size {
+			suffix = size
+		}
+
+		return Range{Start: size - suffix, End: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return Range{}, ErrInvalid
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return Range{}, ErrInvalid
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return Range{}, ErrUnsatisfiable
+	}
+
+	return Range{Start: start, End: end}, nil
+}
@@ -0,0 +1,198 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"dcfs/constants"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrNoLeaves - the tree cannot be built without any leaves
+var ErrNoLeaves = errors.New("the merkle tree has no leaves")
+
+// ErrInvalidLeaf - the leaf is not a hex encoded SHA-256 hash or its index is out of range
+var ErrInvalidLeaf = errors.New("invalid leaf of the merkle tree")
+
+// ProofNode - sibling hash on the path from the leaf to the root of the tree
+type ProofNode struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // side of the sibling: constants.MERKLE_SIBLING_LEFT or constants.MERKLE_SIBLING_RIGHT
+}
+
+// HashContent - calculate SHA-256 hash of the plain contents
+//
+// params:
+//   - data []uint8: contents of the block or the file
+//
+// return type:
+//   - string: hex encoded hash
+func HashContent(data []uint8) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Root - calculate the root of the merkle tree over the hashes of the blocks
+//
+// Leaves and inner nodes are hashed with distinct prefixes, so a leaf cannot
+// be passed off as an inner node. The last node of an odd level is promoted
+// to the next level as is.
+//
+// params:
+//   - leaves []string: hex encoded hashes of the blocks, in order of the blocks
+//
+// return type:
+//   - string: hex encoded root of the tree
+//   - error: error if there are no leaves or a leaf is invalid
+func Root(leaves []string) (string, error) {
+	levels, err := buildTree(leaves)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(levels[len(levels)-1][0]), nil
+}
+
+// Proof - build the proof of inclusion of the block in the merkle tree
+//
+// params:
+//   - leaves []string: hex encoded hashes of the blocks, in order of the blocks
+//   - index int: index of the block whose inclusion is proven
+//
+// return type:
+//   - []ProofNode: sibling hashes from the leaf up to the root
+//   - error: error if the index is out of range or a leaf is invalid
+func Proof(leaves []string, index int) ([]ProofNode, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, ErrInvalidLeaf
+	}
+
+	levels, err := buildTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	var proof []ProofNode = make([]ProofNode, 0)
+	for _, level := range levels[:len(levels)-1] {
+		if index%2 == 1 {
+			proof = append(proof, ProofNode{Hash: hex.EncodeToString(level[index-1]), Position: constants.MERKLE_SIBLING_LEFT})
+		} else if index+1 < len(level) {
+			proof = append(proof, ProofNode{Hash: hex.EncodeToString(level[index+1]), Position: constants.MERKLE_SIBLING_RIGHT})
+		}
+
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// Verify - check that the hash of the block is included in the merkle tree with the given root
+//
+// params:
+//   - leaf string: hex encoded hash of the block
+//   - proof []ProofNode: proof of inclusion of the block
+//   - root string: hex encoded root of the tree
+//
+// return type:
+//   - bool: true if the proof leads from the leaf to the root
+func Verify(leaf string, proof []ProofNode, root string) bool {
+	node, err := hashLeaf(leaf)
+	if err != nil {
+		return false
+	}
+
+	for _, sibling := range proof {
+		hash, err := hex.DecodeString(sibling.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			return false
+		}
+
+		switch sibling.Position {
+		case constants.MERKLE_SIBLING_LEFT:
+			node = hashNode(hash, node)
+		case constants.MERKLE_SIBLING_RIGHT:
+			node = hashNode(node, hash)
+		default:
+			return false
+		}
+	}
+
+	return hex.EncodeToString(node) == root
+}
+
+// buildTree - calculate all levels of the merkle tree
+//
+// params:
+//   - leaves []string: hex encoded hashes of the blocks
+//
+// return type:
+//   - [][][]byte: levels of the tree, from the leaves up to the root
+//   - error: error if there are no leaves or a leaf is invalid
+func buildTree(leaves []string) ([][][]byte, error) {
+	if len(leaves) == 0 {
+		return nil, ErrNoLeaves
+	}
+
+	var level [][]byte = make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		node, err := hashLeaf(leaf)
+		if err != nil {
+			return nil, err
+		}
+
+		level[i] = node
+	}
+
+	var levels [][][]byte = [][][]byte{level}
+	for len(level) > 1 {
+		var next [][]byte = make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+
+			next = append(next, hashNode(level[i], level[i+1]))
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels, nil
+}
+
+// hashLeaf - calculate the leaf node of the tree from the hash of the block
+//
+// params:
+//   - leaf string: hex encoded hash of the block
+//
+// return type:
+//   - []byte: leaf node
+//   - error: error if the leaf is not a hex encoded SHA-256 hash
+func hashLeaf(leaf string) ([]byte, error) {
+	hash, err := hex.DecodeString(leaf)
+	if err != nil || len(hash) != sha256.Size {
+		return nil, ErrInvalidLeaf
+	}
+
+	node := sha256.Sum256(append([]byte{constants.MERKLE_LEAF_PREFIX}, hash...))
+	return node[:], nil
+}
+
+// hashNode - calculate the inner node of the tree from its children
+//
+// params:
+//   - left []byte: left child
+//   - right []byte: right child
+//
+// return type:
+//   - []byte: inner node
+func hashNode(left []byte, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, constants.MERKLE_NODE_PREFIX)
+	data = append(data, left...)
+	data = append(data, right...)
+
+	node := sha256.Sum256(data)
+	return node[:]
+}
@@ -0,0 +1,310 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client - minimal client of the S3 compatible object storage
+//
+// Buckets are addressed in the path style (endpoint/bucket/key),
+// which is supported by AWS S3, MinIO, Wasabi and Ceph RGW.
+type Client struct {
+	Endpoint   *url.URL
+	Region     string
+	Bucket     string
+	AccessKey  string
+	SecretKey  string
+	HTTPClient *http.Client
+}
+
+// ResponseError - error returned by the object storage
+type ResponseError struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+// CompletedPart - uploaded part of the multipart upload
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+func (e *ResponseError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 request failed with status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("S3 request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound - check whether the error indicates a missing bucket or object
+//
+// params:
+//   - err error: error to check
+//
+// return type:
+//   - bool: true if the bucket or object does not exist
+func IsNotFound(err error) bool {
+	var responseError *ResponseError
+	return errors.As(err, &responseError) && responseError.StatusCode == http.StatusNotFound
+}
+
+// HeadBucket - check whether the bucket exists and is accessible
+//
+// params:
+//   - ctx context.Context: request context
+//
+// return type:
+//   - error: nil if the bucket is accessible
+func (c *Client) HeadBucket(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodHead, "", nil, nil)
+	return err
+}
+
+// PutObject - upload the object in a single request
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//   - content []byte: object content
+//
+// return type:
+//   - error: nil if the object was uploaded
+func (c *Client) PutObject(ctx context.Context, key string, content []byte) error {
+	_, err := c.do(ctx, http.MethodPut, key, nil, content)
+	return err
+}
+
+// GetObject - download the object
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//
+// return type:
+//   - []byte: object content
+//   - error: nil if the object was downloaded
+func (c *Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, key, nil, nil)
+}
+
+// DeleteObject - delete the object
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//
+// return type:
+//   - error: nil if the object was deleted
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, key, nil, nil)
+	return err
+}
+
+// CreateMultipartUpload - initiate the multipart upload of the object
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//
+// return type:
+//   - string: upload ID
+//   - error: nil if the upload was initiated
+func (c *Client) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	body, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result initiateMultipartUploadResult
+	if err = xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return "", errors.New("invalid response to the multipart upload initiation")
+	}
+
+	return result.UploadID, nil
+}
+
+// UploadPart - upload a part of the multipart upload
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//   - uploadID string: upload ID
+//   - partNumber int: number of the part, starting from 1
+//   - content []byte: part content
+//
+// return type:
+//   - CompletedPart: uploaded part
+//   - error: nil if the part was uploaded
+func (c *Client) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, content []byte) (CompletedPart, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+
+	req, err := c.newRequest(ctx, http.MethodPut, key, query, content)
+	if err != nil {
+		return CompletedPart{}, err
+	}
+
+	res, _, err := c.send(req)
+	if err != nil {
+		return CompletedPart{}, err
+	}
+
+	return CompletedPart{PartNumber: partNumber, ETag: res.Header.Get("ETag")}, nil
+}
+
+// CompleteMultipartUpload - assemble the object from the uploaded parts
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//   - uploadID string: upload ID
+//   - parts []CompletedPart: uploaded parts in order
+//
+// return type:
+//   - error: nil if the object was assembled
+func (c *Client) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []CompletedPart) error {
+	content, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	body, err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, content)
+	if err != nil {
+		return err
+	}
+
+	// The request may fail after the response status was sent
+	if bytes.Contains(body, []byte("<Error>")) {
+		responseError := &ResponseError{StatusCode: http.StatusOK}
+		_ = xml.Unmarshal(body, responseError)
+		return responseError
+	}
+
+	return nil
+}
+
+// AbortMultipartUpload - abort the multipart upload and remove the uploaded parts
+//
+// params:
+//   - ctx context.Context: request context
+//   - key string: object key
+//   - uploadID string: upload ID
+//
+// return type:
+//   - error: nil if the upload was aborted
+func (c *Client) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	_, err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	return err
+}
+
+// do - send the signed request and read the response body
+func (c *Client) do(ctx context.Context, method string, key string, query url.Values, content []byte) ([]byte, error) {
+	req, err := c.newRequest(ctx, method, key, query, content)
+	if err != nil {
+		return nil, err
+	}
+
+	_, body, err := c.send(req)
+	return body, err
+}
+
+// newRequest - prepare the signed request to the object in the bucket
+func (c *Client) newRequest(ctx context.Context, method string, key string, query url.Values, content []byte) (*http.Request, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	u := *c.Endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(content))
+
+	SignRequest(req, hashHex(content), c.AccessKey, c.SecretKey, c.Region, time.Now())
+	return req, nil
+}
+
+// send - send the request and convert error responses
+func (c *Client) send(req *http.Request) (*http.Response, []byte, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		responseError := &ResponseError{StatusCode: res.StatusCode}
+		_ = xml.Unmarshal(body, responseError)
+		return nil, nil, responseError
+	}
+
+	return res, body, nil
+}
+
+// NewClient - create new S3 client
+//
+// params:
+//   - endpoint string: URL of the object storage, e.g. https://s3.eu-central-1.amazonaws.com
+//   - region string: region of the bucket
+//   - bucket string: name of the bucket
+//   - accessKey string: access key ID
+//   - secretKey string: secret access key
+//
+// return type:
+//   - *Client: created client
+//   - error: error if the endpoint is not a valid URL
+func NewClient(endpoint string, region string, bucket string, accessKey string, secretKey string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("endpoint must be an absolute HTTP(S) URL")
+	}
+
+	return &Client{
+		Endpoint:   u,
+		Region:     region,
+		Bucket:     bucket,
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
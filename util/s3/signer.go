@@ -0,0 +1,169 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signatureAlgorithm = "AWS4-HMAC-SHA256"
+	signatureService   = "s3"
+	signatureTimeFmt   = "20060102T150405Z"
+	signatureDateFmt   = "20060102"
+)
+
+// SignRequest - sign the request using AWS Signature Version 4
+//
+// All headers set in the request and the host are signed. The request
+// must not be modified afterwards.
+//
+// params:
+//   - req *http.Request: request to sign
+//   - payloadHash string: hex encoded SHA-256 hash of the request body
+//   - accessKey string: access key ID
+//   - secretKey string: secret access key
+//   - region string: region of the bucket
+//   - t time.Time: signing time
+func SignRequest(req *http.Request, payloadHash string, accessKey string, secretKey string, region string, t time.Time) {
+	amzTime := t.UTC().Format(signatureTimeFmt)
+	amzDate := t.UTC().Format(signatureDateFmt)
+
+	req.Header.Set("X-Amz-Date", amzTime)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Prepare canonical headers
+	headers := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		headers["host"] = req.URL.Host
+	}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		encodeQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	// Prepare string to sign
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", amzDate, region, signatureService)
+	stringToSign := strings.Join([]string{signatureAlgorithm, amzTime, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	// Derive the signing key and compute the signature
+	key := hmacSHA256([]byte("AWS4"+secretKey), amzDate)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, signatureService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signatureAlgorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// hashHex - compute hex encoded SHA-256 hash of the data
+//
+// params:
+//   - data []byte: data to hash
+//
+// return type:
+//   - string: hex encoded hash
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 - compute HMAC-SHA256 of the data
+//
+// params:
+//   - key []byte: HMAC key
+//   - data string: data to authenticate
+//
+// return type:
+//   - []byte: computed HMAC
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// encodePath - URI encode the path, keeping the slashes
+//
+// params:
+//   - path string: path to encode
+//
+// return type:
+//   - string: encoded path
+func encodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = encodeURIComponent(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// encodeQuery - encode the query parameters in the canonical form
+//
+// params:
+//   - query map[string][]string: query parameters
+//
+// return type:
+//   - string: canonical query string
+func encodeQuery(query map[string][]string) string {
+	var params []string
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, encodeURIComponent(key)+"="+encodeURIComponent(value))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// encodeURIComponent - URI encode all characters except the unreserved ones
+//
+// params:
+//   - s string: string to encode
+//
+// return type:
+//   - string: encoded string
+func encodeURIComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package sealing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"dcfs/constants"
+	"dcfs/util/logger"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyPath - path of the key-encryption key used to seal the secrets saved in the db
+var KeyPath string = constants.MASTER_KEY_PATH
+
+// ErrInvalidKey - the key-encryption key does not have the length of an AES-256 key
+var ErrInvalidKey = errors.New("the key-encryption key must be 32 bytes long")
+
+// ErrMalformed - the sealed value is not a valid output of Seal
+var ErrMalformed = errors.New("malformed sealed value")
+
+// IsSealed - check whether the value was sealed with the key-encryption key
+//
+// params:
+//   - value string: value saved in the db
+//
+// return type:
+//   - bool: true if the value is sealed, false if it is stored as plain text
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, constants.SEALED_PREFIX)
+}
+
+// Seal - encrypt the secret with the key-encryption key
+//
+// Empty and already sealed values are returned as is.
+//
+// params:
+//   - plaintext string: secret to be sealed
+//
+// return type:
+//   - string: sealed secret, safe to be saved in the db
+//   - error: error if the key cannot be loaded or the encryption failed
+func Seal(plaintext string) (string, error) {
+	if plaintext == "" || IsSealed(plaintext) {
+		return plaintext, nil
+	}
+
+	gcm, err := newCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		logger.Logger.Error("credentials", "Could not populate the cipher nonce with a random seed: ", err.Error(), ".")
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return constants.SEALED_PREFIX + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Unseal - decrypt the secret sealed with the key-encryption key
+//
+// Values which were saved before sealing was introduced are returned as is.
+//
+// params:
+//   - value string: value saved in the db
+//
+// return type:
+//   - string: plain text secret
+//   - error: error if the key cannot be loaded or the value cannot be decrypted
+func Unseal(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, constants.SEALED_PREFIX))
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	gcm, err := newCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", ErrMalformed
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		logger.Logger.Error("credentials", "Could not unseal the secret: ", err.Error(), ".")
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// newCipher - create the AEAD cipher with the key-encryption key
+//
+// The key is generated and saved in KeyPath if it does not exist yet.
+//
+// return type:
+//   - cipher.AEAD: AES-GCM cipher
+//   - error: error if the key cannot be loaded or generated
+func newCipher() (cipher.AEAD, error) {
+	key, err := os.ReadFile(KeyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = generateKey()
+	}
+	if err != nil {
+		logger.Logger.Error("credentials", "Could not read the key-encryption key: ", err.Error(), ".")
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		logger.Logger.Error("credentials", "The key-encryption key: ", KeyPath, " is not a 256 bit key.")
+		return nil, ErrInvalidKey
+	}
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		logger.Logger.Error("credentials", "Could not generate a block cipher object: ", err.Error(), ".")
+		return nil, err
+	}
+
+	return cipher.NewGCM(cb)
+}
+
+// generateKey - generate a new key-encryption key and save it in KeyPath
+//
+// return type:
+//   - []byte: generated key
+//   - error: error if the key cannot be saved
+func generateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	// Do not overwrite the key created in the meantime by another request
+	file, err := os.OpenFile(KeyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return os.ReadFile(KeyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(key); err != nil {
+		return nil, err
+	}
+
+	logger.Logger.Warning("credentials", "Generated a new key-encryption key: ", KeyPath, ". Back it up, the disk credentials and encrypted volumes cannot be recovered without it.")
+	return key, nil
+}
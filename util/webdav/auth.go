@@ -0,0 +1,151 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// challenge - authentication challenge sent by the server in the WWW-Authenticate header
+type challenge struct {
+	scheme string
+	params map[string]string
+	count  int
+}
+
+// parseChallenges - parse the authentication challenges sent by the server
+//
+// params:
+//   - headers []string: values of the WWW-Authenticate headers
+//
+// return type:
+//   - *challenge: digest challenge if offered, otherwise basic challenge, nil if none is supported
+func parseChallenges(headers []string) *challenge {
+	var basic *challenge
+
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+
+		switch strings.ToLower(scheme) {
+		case "digest":
+			return &challenge{scheme: "digest", params: parseParams(rest)}
+		case "basic":
+			basic = &challenge{scheme: "basic"}
+		}
+	}
+
+	return basic
+}
+
+// parseParams - parse the comma separated key=value parameters of the challenge
+//
+// params:
+//   - s string: parameters of the challenge
+//
+// return type:
+//   - map[string]string: parsed parameters with lowercase keys
+func parseParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for len(s) > 0 {
+		var key, value string
+
+		s = strings.TrimLeft(s, " ,")
+		key, s, _ = strings.Cut(s, "=")
+		s = strings.TrimSpace(s)
+
+		if strings.HasPrefix(s, "\"") {
+			end := strings.Index(s[1:], "\"")
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			value, s, _ = strings.Cut(s, ",")
+		}
+
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	return params
+}
+
+// authorize - set the Authorization header answering the challenge
+//
+// params:
+//   - req *http.Request: request to authorize
+//   - login string: user name
+//   - password string: user password
+func (c *challenge) authorize(req *http.Request, login string, password string) {
+	if c.scheme == "basic" {
+		req.SetBasicAuth(login, password)
+		return
+	}
+
+	algorithm := c.params["algorithm"]
+	var newHash func() hash.Hash = md5.New
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	c.count++
+	nc := fmt.Sprintf("%08x", c.count)
+	cnonce := newCnonce()
+	realm, nonce, uri := c.params["realm"], c.params["nonce"], req.URL.RequestURI()
+
+	ha1 := h(login + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+
+	// Only the "auth" quality of protection is supported
+	qop := ""
+	for _, q := range strings.Split(c.params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, login, realm, nonce, uri, response)
+	if algorithm != "" {
+		header += ", algorithm=" + algorithm
+	}
+	if opaque, ok := c.params["opaque"]; ok {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	if qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+
+	req.Header.Set("Authorization", header)
+}
+
+// newCnonce - generate random client nonce
+//
+// return type:
+//   - string: hex encoded client nonce
+func newCnonce() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,246 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client - minimal WebDAV client supporting basic and digest authentication
+//
+// The authentication scheme is chosen based on the challenge sent by
+// the server, digest authentication is preferred when offered.
+type Client struct {
+	BaseURL    *url.URL
+	Login      string
+	Password   string
+	HTTPClient *http.Client
+
+	challenge *challenge
+}
+
+// ResponseError - unexpected status returned by the WebDAV server
+type ResponseError struct {
+	StatusCode int
+}
+
+type propfindResponse struct {
+	Responses []struct {
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				QuotaAvailableBytes string `xml:"DAV: quota-available-bytes"`
+				QuotaUsedBytes      string `xml:"DAV: quota-used-bytes"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const propfindQuotaBody string = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:quota-available-bytes/><d:quota-used-bytes/></d:prop></d:propfind>`
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("WebDAV request failed with status %d", e.StatusCode)
+}
+
+// IsNotFound - check whether the error indicates a missing resource
+//
+// params:
+//   - err error: error to check
+//
+// return type:
+//   - bool: true if the resource does not exist
+func IsNotFound(err error) bool {
+	var responseError *ResponseError
+	return errors.As(err, &responseError) && responseError.StatusCode == http.StatusNotFound
+}
+
+// Put - upload the file to the base path
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: name of the file
+//   - content []byte: file content
+//
+// return type:
+//   - error: nil if the file was uploaded
+func (c *Client) Put(ctx context.Context, name string, content []byte) error {
+	_, err := c.do(ctx, http.MethodPut, name, nil, content)
+	return err
+}
+
+// Get - download the file from the base path
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: name of the file
+//
+// return type:
+//   - []byte: file content
+//   - error: nil if the file was downloaded
+func (c *Client) Get(ctx context.Context, name string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, name, nil, nil)
+}
+
+// Delete - remove the file from the base path
+//
+// params:
+//   - ctx context.Context: request context
+//   - name string: name of the file
+//
+// return type:
+//   - error: nil if the file was removed
+func (c *Client) Delete(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, name, nil, nil)
+	return err
+}
+
+// Quota - retrieve the RFC 4331 quota properties of the base path
+//
+// params:
+//   - ctx context.Context: request context
+//
+// return type:
+//   - int64: used bytes, -1 if not reported by the server
+//   - int64: available bytes, -1 if not reported or unlimited
+//   - error: nil if the base path is an accessible collection
+func (c *Client) Quota(ctx context.Context) (int64, int64, error) {
+	body, err := c.do(ctx, "PROPFIND", "", map[string]string{
+		"Depth":        "0",
+		"Content-Type": "application/xml; charset=utf-8",
+	}, []byte(propfindQuotaBody))
+	if err != nil {
+		return -1, -1, err
+	}
+
+	var multistatus propfindResponse
+	if err = xml.Unmarshal(body, &multistatus); err != nil {
+		return -1, -1, err
+	}
+
+	var used, available int64 = -1, -1
+	var isCollection bool
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+
+			isCollection = isCollection || propstat.Prop.ResourceType.Collection != nil
+			if v, err := strconv.ParseInt(strings.TrimSpace(propstat.Prop.QuotaUsedBytes), 10, 64); err == nil {
+				used = v
+			}
+			if v, err := strconv.ParseInt(strings.TrimSpace(propstat.Prop.QuotaAvailableBytes), 10, 64); err == nil && v >= 0 {
+				available = v
+			}
+		}
+	}
+
+	if !isCollection {
+		return -1, -1, errors.New("base path is not a collection")
+	}
+
+	return used, available, nil
+}
+
+// do - send the request, answering the authentication challenge if needed
+func (c *Client) do(ctx context.Context, method string, name string, headers map[string]string, content []byte) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	u := *c.BaseURL
+	u.Path = path.Join("/", u.Path, name)
+	if name == "" && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(content))
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		if c.challenge != nil {
+			c.challenge.authorize(req, c.Login, c.Password)
+		}
+
+		return c.HTTPClient.Do(req)
+	}
+
+	res, err := send()
+	if err != nil {
+		return nil, err
+	}
+
+	// Retry once with the credentials if the server requested authentication
+	if res.StatusCode == http.StatusUnauthorized {
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		c.challenge = parseChallenges(res.Header.Values("WWW-Authenticate"))
+		if c.challenge == nil {
+			return nil, &ResponseError{StatusCode: http.StatusUnauthorized}
+		}
+
+		res, err = send()
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &ResponseError{StatusCode: res.StatusCode}
+	}
+
+	return body, nil
+}
+
+// NewClient - create new WebDAV client
+//
+// params:
+//   - baseURL string: URL of the base collection, e.g. https://cloud.example.com/remote.php/dav/files/user/dcfs
+//   - login string: user name
+//   - password string: user password
+//
+// return type:
+//   - *Client: created client
+//   - error: error if the URL is not a valid HTTP(S) URL
+func NewClient(baseURL string, login string, password string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("URL must be an absolute HTTP(S) URL")
+	}
+
+	return &Client{
+		BaseURL:    u,
+		Login:      login,
+		Password:   password,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}